@@ -0,0 +1,62 @@
+package recordrequestlog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// statusClientClosedRequest 客户端在收到响应前断开连接时记录的状态码，与 nginx 和 Traefik 的访问日志一致
+const statusClientClosedRequest = 499
+
+// watchAbort 在请求的 context 被取消（通常是客户端断开连接）时记录已经处理的时间
+func (x *Exchange) watchAbort() {
+
+	start := x.start
+	x.stopAbort = context.AfterFunc(x.req.Context(), func() {
+		x.abortedAfter.Store(int64(time.Since(start)))
+	})
+}
+
+// aborted 停止等待取消并返回请求是否在处理完成前被取消，以及取消时已经处理的时间
+func (x *Exchange) aborted() (time.Duration, bool) {
+
+	if x.stopAbort == nil {
+		return 0, false
+	}
+	x.stopAbort()
+
+	if x.req.Context().Err() == nil {
+		return 0, false
+	}
+
+	// 取消回调可能还没有执行
+	if elapsed := x.abortedAfter.Load(); elapsed > 0 {
+		return time.Duration(elapsed), true
+	}
+
+	return time.Since(x.start), true
+}
+
+// abortAttrs 返回请求被取消时的属性
+func (e *RecordRequestLog) abortAttrs(ctx context.Context, elapsed time.Duration) []slog.Attr {
+
+	reason := "canceled"
+	if ctx.Err() == context.DeadlineExceeded {
+		reason = "deadline_exceeded"
+	}
+
+	if e.logFormat == LogFormatSemConv {
+		return []slog.Attr{
+			slog.Bool("http.request.aborted", true),
+			slog.Float64("http.request.aborted_after", elapsed.Seconds()),
+			slog.String("http.request.abort_reason", reason),
+		}
+	}
+
+	return []slog.Attr{
+		slog.Bool("aborted", true),
+		slog.Float64("aborted-after-ms", float64(elapsed)/float64(time.Millisecond)),
+		slog.String("abort-reason", reason),
+	}
+}
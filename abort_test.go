@@ -0,0 +1,73 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+	"time"
+)
+
+// contextSink 在导出时检查 context 是否已被取消
+type contextSink struct {
+	*recordrequestlogtest.Sink
+	errs chan error
+}
+
+func (s contextSink) Emit(ctx context.Context, record recordrequestlog.Record) error {
+	s.errs <- ctx.Err()
+	return s.Sink.Emit(ctx, record)
+}
+
+func TestAbortedRequest(t *testing.T) {
+
+	sink := contextSink{Sink: recordrequestlogtest.NewSink(), errs: make(chan error, 1)}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithTestExporters(recordrequestlog.TestExporters{Sink: sink}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/slow", nil).WithContext(ctx))
+
+	if err := <-sink.errs; err != nil {
+		t.Fatalf("expected record exported with a live context, got %v", err)
+	}
+
+	record := sink.Records()[0]
+	for key, want := range map[string]string{"aborted": "true", "abort-reason": "canceled", "status": "499"} {
+		if v, ok := recordrequestlogtest.Attr(record, key); !ok || v.String() != want {
+			t.Errorf("expected %s %s, got %v", key, want, v)
+		}
+	}
+
+	if v, _ := recordrequestlogtest.Attr(record, "aborted-after-ms"); v.Float64() < 20 {
+		t.Fatalf("expected aborted-after-ms at least 20, got %v", v)
+	}
+}
+
+func TestCompletedRequestNotAborted(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	if _, ok := recordrequestlogtest.Attr(rec.RequireRecords(t, 1)[0], "aborted"); ok {
+		t.Fatal("unexpected aborted attribute")
+	}
+}
@@ -0,0 +1,44 @@
+// Package recordrequestlogecho 为 Echo 提供请求日志中间件，使用路由模板（c.Path()）记录 http.route
+package recordrequestlogecho
+
+import (
+	"net/http"
+	"recordrequestlog"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware 创建 Echo 中间件，所有使用该中间件的路由共用同一组导出器
+func Middleware(config *recordrequestlog.Config) (echo.MiddlewareFunc, error) {
+
+	e, err := recordrequestlog.NewRecorder(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			x, rw, req := e.Begin(c.Response().Writer, c.Request())
+			c.SetRequest(req)
+			c.Response().Writer = rw
+			x.SetRoute(c.Path())
+
+			defer func() {
+				if v := recover(); v != nil {
+					x.End(http.StatusInternalServerError)
+					panic(v)
+				}
+			}()
+
+			// 先交给错误处理器写入响应，才能记录实际的状态码
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			x.End(c.Response().Status)
+			return err
+		}
+	}, nil
+}
@@ -0,0 +1,62 @@
+package recordrequestlogecho_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+
+	recordrequestlogecho "recordrequestlog/adapters/echo"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	middleware, err := recordrequestlogecho.Middleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.Use(middleware)
+	e.GET("/users/:id", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "no such user")
+	})
+
+	rw := httptest.NewRecorder()
+	e.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rw.Code)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("expected a record")
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record["route"] != "/users/:id" || record["status"] != float64(http.StatusNotFound) {
+		t.Errorf("unexpected record %v", record)
+	}
+}
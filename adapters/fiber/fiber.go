@@ -0,0 +1,80 @@
+// Package recordrequestlogfiber 为 Fiber 提供请求日志中间件，使用路由模板（c.Route().Path）记录 http.route
+package recordrequestlogfiber
+
+import (
+	"net/http"
+	"recordrequestlog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// Middleware 创建 Fiber 中间件，所有使用该中间件的路由共用同一组导出器
+func Middleware(config *recordrequestlog.Config) (fiber.Handler, error) {
+
+	e, err := recordrequestlog.NewRecorder(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *fiber.Ctx) error {
+
+		req := new(http.Request)
+		if err := fasthttpadaptor.ConvertRequest(c.Context(), req, true); err != nil {
+			return c.Next()
+		}
+
+		rw := &headerWriter{header: make(http.Header)}
+		x, out, req := e.Begin(rw, req)
+
+		// 将注入的 trace 上下文和请求 ID 写回 Fiber 的请求和响应
+		for key, values := range req.Header {
+			if len(values) > 0 && c.Get(key) != values[0] {
+				c.Request().Header.Set(key, values[0])
+			}
+		}
+		for key, values := range rw.header {
+			c.Set(key, values[0])
+		}
+		c.SetUserContext(req.Context())
+
+		defer func() {
+			if v := recover(); v != nil {
+				x.End(fiber.StatusInternalServerError)
+				panic(v)
+			}
+		}()
+
+		// 先交给错误处理器写入响应，才能记录实际的状态码
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		// 响应在处理完成后由 fasthttp 写出，将响应头和响应体交给 Begin 返回的 ResponseWriter，
+		// 记录响应头、响应大小和首字节耗时；流式响应体不读取
+		clear(rw.header)
+		c.Response().Header.VisitAll(func(key, value []byte) {
+			rw.header.Add(string(key), string(value))
+		})
+		out.WriteHeader(c.Response().StatusCode())
+		if !c.Response().IsBodyStream() {
+			_, _ = out.Write(c.Response().Body())
+		}
+
+		// 中间件中 c.Route() 在处理完成后才指向匹配的路由
+		x.SetRoute(c.Route().Path)
+		x.End(c.Response().StatusCode())
+		return nil
+	}, nil
+}
+
+// headerWriter 收集 Begin 写入的响应头，Fiber 的响应由 fasthttp 写出，写入的内容直接丢弃
+type headerWriter struct {
+	header http.Header
+}
+
+func (w *headerWriter) Header() http.Header         { return w.header }
+func (w *headerWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *headerWriter) WriteHeader(int)             {}
@@ -0,0 +1,120 @@
+package recordrequestlogfiber_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+
+	recordrequestlogfiber "recordrequestlog/adapters/fiber"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readRecord 读取 file 后端写入的第一条记录
+func readRecord(t *testing.T, path string) map[string]any {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("expected a record")
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func TestMiddleware(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	middleware, err := recordrequestlogfiber.Middleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var traceparent string
+	app := fiber.New()
+	app.Use(middleware)
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		traceparent = c.Get("traceparent")
+		return fiber.NewError(fiber.StatusTeapot, "short and stout")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusTeapot {
+		t.Errorf("expected status 418, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("expected request ID response header")
+	}
+	if traceparent == "" {
+		t.Error("expected traceparent to be forwarded")
+	}
+
+	record := readRecord(t, path)
+	if record["route"] != "/users/:id" || record["status"] != float64(fiber.StatusTeapot) {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestResponse(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.CaptureResponseHeaders = []string{"X-Cache"}
+
+	middleware, err := recordrequestlogfiber.Middleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(middleware)
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		c.Set("X-Cache", "hit")
+		return c.SendString("hello")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// 响应大小、响应头和首字节耗时来自 Fiber 写出的响应
+	record := readRecord(t, path)
+	if record["response-size"] != float64(len("hello")) {
+		t.Errorf("expected response size 5, got %v", record["response-size"])
+	}
+	if headers, _ := record["response-headers"].(map[string]any); headers["x-cache"] != "hit" {
+		t.Errorf("expected captured response header, got %v", record["response-headers"])
+	}
+	if _, ok := record["time-to-first-byte-ms"]; !ok {
+		t.Errorf("expected time to first byte in %v", record)
+	}
+}
@@ -0,0 +1,71 @@
+// Package recordrequestloggin 为 Gin 提供请求日志中间件，使用路由模板（c.FullPath()）记录 http.route
+package recordrequestloggin
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"recordrequestlog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware 创建 Gin 中间件，所有使用该中间件的路由共用同一组导出器
+func Middleware(config *recordrequestlog.Config) (gin.HandlerFunc, error) {
+
+	e, err := recordrequestlog.NewRecorder(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+
+		x, rw, req := e.Begin(c.Writer, c.Request)
+		c.Request = req
+		c.Writer = &responseWriter{ResponseWriter: c.Writer, rw: rw}
+		x.SetRoute(c.FullPath())
+
+		// panic 交给外层的 Recovery 处理，这里只按 500 记录
+		defer func() {
+			if v := recover(); v != nil {
+				x.End(http.StatusInternalServerError)
+				panic(v)
+			}
+		}()
+
+		c.Next()
+		x.End(c.Writer.Status())
+	}, nil
+}
+
+// responseWriter 将 Gin 中的写入转发给 Begin 返回的 ResponseWriter，记录响应大小和首字节耗时；
+// Status、Size 等查询仍由 Gin 的 ResponseWriter 处理
+type responseWriter struct {
+	gin.ResponseWriter
+	rw http.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.rw.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	return w.rw.Write(b)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.rw.Write([]byte(s))
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.rw.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return w.ResponseWriter.Hijack()
+}
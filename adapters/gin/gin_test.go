@@ -0,0 +1,114 @@
+package recordrequestloggin_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+
+	recordrequestloggin "recordrequestlog/adapters/gin"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readRecord 读取 file 后端写入的第一条记录
+func readRecord(t *testing.T, path string) map[string]any {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("expected a record")
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func TestMiddleware(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	middleware, err := recordrequestloggin.Middleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(middleware)
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	if rw.Header().Get("X-Request-ID") == "" {
+		t.Error("expected request ID response header")
+	}
+
+	record := readRecord(t, path)
+	if record["route"] != "/users/:id" || record["status"] != float64(http.StatusNoContent) {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestResponse(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.CaptureResponseHeaders = []string{"X-Cache"}
+
+	middleware, err := recordrequestloggin.Middleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(middleware)
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Header("X-Cache", "hit")
+		c.String(http.StatusOK, "hello")
+	})
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	if rw.Body.String() != "hello" {
+		t.Errorf("unexpected response body %q", rw.Body.String())
+	}
+
+	// 响应经过 Begin 返回的 ResponseWriter 写出
+	record := readRecord(t, path)
+	if record["response-size"] != float64(len("hello")) {
+		t.Errorf("expected response size 5, got %v", record["response-size"])
+	}
+	if headers, _ := record["response-headers"].(map[string]any); headers["x-cache"] != "hit" {
+		t.Errorf("expected captured response header, got %v", record["response-headers"])
+	}
+	if _, ok := record["time-to-first-byte-ms"]; !ok {
+		t.Errorf("expected time to first byte in %v", record)
+	}
+}
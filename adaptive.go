@@ -0,0 +1,103 @@
+package recordrequestlog
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 自适应采样统计请求速率的窗口，以及新窗口的速率在平滑速率中的权重
+const (
+	adaptiveWindow = time.Second
+	adaptiveWeight = 0.5
+)
+
+// adaptiveSampler 按路由的请求速率调整采样概率，使记录数不超过每秒 target 条：
+// 速率低于 target 时概率为 1，高于时为 target/速率；概率不超过路由的 sample_rate
+type adaptiveSampler struct {
+	// 指标中的路由名称，顶层配置为 default，路由为 routes[i]
+	name   string
+	target float64
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       float64
+	// 平滑后的每秒请求数
+	rate float64
+
+	// 当前的采样概率，float64 的二进制表示
+	probability atomic.Uint64
+}
+
+func newAdaptiveSampler(name string, target float64) *adaptiveSampler {
+
+	a := &adaptiveSampler{name: name, target: target, windowStart: time.Now()}
+	a.probability.Store(math.Float64bits(1))
+
+	return a
+}
+
+// observe 计入一个请求并返回当前的采样概率，窗口结束时按窗口内的请求速率更新概率
+func (a *adaptiveSampler) observe(now time.Time) float64 {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.count++
+
+	elapsed := now.Sub(a.windowStart)
+	if elapsed < adaptiveWindow {
+		return a.current()
+	}
+
+	// 空闲较久后的第一个窗口速率很低，概率随之恢复
+	observed := a.count / elapsed.Seconds()
+	if a.rate == 0 {
+		a.rate = observed
+	} else {
+		a.rate = adaptiveWeight*observed + (1-adaptiveWeight)*a.rate
+	}
+	a.windowStart, a.count = now, 0
+
+	probability := 1.0
+	if a.rate > a.target {
+		probability = a.target / a.rate
+	}
+	a.probability.Store(math.Float64bits(probability))
+
+	return probability
+}
+
+// current 返回当前的采样概率
+func (a *adaptiveSampler) current() float64 {
+	return math.Float64frombits(a.probability.Load())
+}
+
+// effectiveRate 返回路由实际生效的采样率
+func (s *routeSettings) effectiveRate() float64 {
+
+	if s.adaptive == nil {
+		return s.sampleRate
+	}
+
+	return min(s.sampleRate, s.adaptive.current())
+}
+
+// adaptiveSamplers 返回所有开启了自适应采样的路由
+func (rules *rules) adaptiveSamplers() []*routeSettings {
+
+	var settings []*routeSettings
+
+	if rules.defaults.adaptive != nil {
+		settings = append(settings, rules.defaults)
+	}
+
+	for _, r := range rules.routes {
+		if r.settings.adaptive != nil {
+			settings = append(settings, r.settings)
+		}
+	}
+
+	return settings
+}
@@ -0,0 +1,63 @@
+package recordrequestlog
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveSampler(t *testing.T) {
+
+	start := time.Now()
+	a := newAdaptiveSampler("default", 10)
+	a.windowStart = start
+
+	// 第一个窗口内每秒 1000 个请求
+	for i := 1; i <= 1000; i++ {
+		a.observe(start.Add(time.Duration(i) * time.Millisecond))
+	}
+	if p := a.current(); math.Abs(p-0.01) > 0.001 {
+		t.Fatalf("expected probability near 0.01 under load, got %v", p)
+	}
+
+	// 流量回落到每秒 1 个请求后逐渐恢复
+	now := start.Add(time.Second)
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		a.observe(now)
+	}
+	if p := a.current(); p != 1 {
+		t.Fatalf("expected probability to recover, got %v", p)
+	}
+}
+
+func TestAdaptiveRouteSettings(t *testing.T) {
+
+	target := 0.0
+
+	cfg := CreateConfig()
+	cfg.SampleRate = 0.5
+	cfg.TargetRecordsPerSecond = 100
+	cfg.Routes = []RouteConfig{
+		{PathPrefix: "/api"},
+		{PathPrefix: "/health", TargetRecordsPerSecond: &target},
+	}
+
+	r, err := newRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samplers := r.adaptiveSamplers()
+	if len(samplers) != 2 || samplers[0].adaptive.name != "default" || samplers[1].adaptive.name != "routes[0]" {
+		t.Fatalf("unexpected adaptive samplers %v", samplers)
+	}
+	// 每个路由单独统计
+	if samplers[0].adaptive == samplers[1].adaptive {
+		t.Fatal("expected routes to have their own sampler")
+	}
+	// 生效的采样率不超过 sample_rate
+	if rate := samplers[0].effectiveRate(); rate != 0.5 {
+		t.Fatalf("expected effective rate capped by sample_rate, got %v", rate)
+	}
+}
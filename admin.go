@@ -0,0 +1,211 @@
+package recordrequestlog
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Stats 中间件运行时的统计
+type Stats struct {
+	// 是否记录请求日志，管理接口可以临时关闭
+	Logging bool `json:"logging"`
+	// 顶层配置的日志采样率
+	SampleRate float64 `json:"sample_rate"`
+	// 异步队列中尚未导出的记录数，没有开启 async 时为 0
+	Queued int64 `json:"queued"`
+	// 已交给日志后端的记录数
+	Exported int64 `json:"exported"`
+	// 丢弃的记录数，与 Dropped 一致
+	Dropped int64 `json:"dropped"`
+}
+
+// Stats 返回当前的运行时统计
+func (e *RecordRequestLog) Stats() Stats {
+
+	stats := Stats{
+		Logging:    e.logging.Load(),
+		SampleRate: e.rules.Load().defaults.sampleRate,
+		Exported:   e.state.exported.Load(),
+		Dropped:    e.Dropped(),
+	}
+
+	if q, ok := e.sink.(sinkQueue); ok {
+		stats.Queued = q.queued()
+	}
+
+	return stats
+}
+
+// SetLogging 开启或关闭请求日志，关闭期间 trace 和指标不受影响
+func (e *RecordRequestLog) SetLogging(enabled bool) {
+	e.logging.Store(enabled)
+}
+
+// SetSampleRate 修改顶层配置的日志采样率，单独设置了 sample_rate 的路由不受影响
+func (e *RecordRequestLog) SetSampleRate(rate float64) error {
+
+	config := *e.rules.Load().config
+	config.SampleRate = rate
+
+	return e.applyConfig(&config)
+}
+
+// sampled 按采样率和日志开关决定是否记录请求
+func (e *RecordRequestLog) sampled(settings *routeSettings) bool {
+	return e.logging.Load() && settings.sampled()
+}
+
+// AdminHandler 返回管理接口，路径相对于 admin_path_prefix，请求需要携带 Authorization: Bearer <admin_token>：
+//
+//	GET  /stats                    返回 Stats
+//	POST /logging?enabled=false    开启或关闭请求日志
+//	POST /sample-rate?value=0.5    修改日志采样率
+//	POST /flush                    立即导出缓冲的记录
+//	GET  /metrics                  metrics_backend 为 prometheus 时返回 Prometheus 格式的指标
+//	GET  /schema                   返回当前日志格式下请求记录的 JSON Schema
+//
+// 没有配置 admin_token 时返回 nil。可以将其挂载到单独的端口，例如 http.ListenAndServe(":9090", e.AdminHandler())
+func (e *RecordRequestLog) AdminHandler() http.Handler {
+
+	if e.adminToken == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", e.adminStats)
+	mux.HandleFunc("POST /logging", e.adminLogging)
+	mux.HandleFunc("POST /sample-rate", e.adminSampleRate)
+	mux.HandleFunc("POST /flush", e.adminFlush)
+	mux.HandleFunc("GET /metrics", e.adminMetrics)
+	mux.HandleFunc("GET /schema", e.adminSchema)
+
+	return e.adminAuth(mux)
+}
+
+// serveAdmin 处理 admin_path_prefix 下的请求，返回 false 时请求不属于管理接口
+func (e *RecordRequestLog) serveAdmin(rw http.ResponseWriter, req *http.Request) bool {
+
+	if e.admin == nil {
+		return false
+	}
+
+	path, ok := strings.CutPrefix(req.URL.Path, e.adminPathPrefix)
+	if !ok || (path != "" && path[0] != '/') {
+		return false
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Path = path
+	req.URL.RawPath = ""
+
+	e.admin.ServeHTTP(rw, req)
+	return true
+}
+
+func (e *RecordRequestLog) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		token, _ := bearerToken(req)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(e.adminToken)) != 1 {
+			writeAdminReply(rw, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func (e *RecordRequestLog) adminStats(rw http.ResponseWriter, req *http.Request) {
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(e.Stats())
+}
+
+func (e *RecordRequestLog) adminLogging(rw http.ResponseWriter, req *http.Request) {
+
+	enabled, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+	if err != nil {
+		writeAdminReply(rw, http.StatusBadRequest, fmt.Sprintf("invalid enabled %q", req.URL.Query().Get("enabled")))
+		return
+	}
+
+	e.SetLogging(enabled)
+	writeAdminReply(rw, http.StatusOK, "ok")
+}
+
+func (e *RecordRequestLog) adminSampleRate(rw http.ResponseWriter, req *http.Request) {
+
+	rate, err := strconv.ParseFloat(req.URL.Query().Get("value"), 64)
+	if err != nil {
+		writeAdminReply(rw, http.StatusBadRequest, fmt.Sprintf("invalid value %q", req.URL.Query().Get("value")))
+		return
+	}
+
+	if err := e.SetSampleRate(rate); err != nil {
+		writeAdminReply(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeAdminReply(rw, http.StatusOK, "ok")
+}
+
+func (e *RecordRequestLog) adminFlush(rw http.ResponseWriter, req *http.Request) {
+
+	// 客户端断开后仍然完成导出
+	if err := e.Flush(context.WithoutCancel(req.Context())); err != nil {
+		writeAdminReply(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeAdminReply(rw, http.StatusOK, "ok")
+}
+
+func writeAdminReply(rw http.ResponseWriter, status int, msg string) {
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(NewReply("", msg, int64(status)))
+}
+
+// countingSink 统计交给日志后端的记录数
+type countingSink struct {
+	LogSink
+	exported *atomic.Int64
+	emitted  metric.Int64Counter
+}
+
+func (s countingSink) Emit(ctx context.Context, record Record) error {
+
+	err := s.LogSink.Emit(ctx, record)
+	if err == nil {
+		s.exported.Add(1)
+		s.emitted.Add(ctx, 1)
+	}
+
+	return err
+}
+
+func (s countingSink) queued() int64 {
+
+	if q, ok := s.LogSink.(sinkQueue); ok {
+		return q.queued()
+	}
+
+	return 0
+}
+
+func (s countingSink) ForceFlush(ctx context.Context) error {
+
+	if f, ok := s.LogSink.(sinkFlusher); ok {
+		return f.ForceFlush(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.AdminPathPrefix = "/_recordrequestlog"
+	cfg.AdminToken = "secret"
+	// 测试中没有 collector，只导出日志
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+
+	var forwarded int
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { forwarded++ })
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://localhost/_recordrequestlog"+target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if got := admin(http.MethodGet, "/stats", "wrong").Code; got != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong token, got %d", got)
+	}
+
+	if got := admin(http.MethodPost, "/logging?enabled=false", "secret").Code; got != http.StatusOK {
+		t.Fatalf("unexpected status %d disabling logging", got)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	if got := admin(http.MethodPost, "/sample-rate?value=2", "secret").Code; got != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid sample rate, got %d", got)
+	}
+
+	if got := admin(http.MethodPost, "/sample-rate?value=0.5", "secret").Code; got != http.StatusOK {
+		t.Fatalf("unexpected status %d setting sample rate", got)
+	}
+
+	if got := admin(http.MethodPost, "/logging?enabled=true", "secret").Code; got != http.StatusOK {
+		t.Fatalf("unexpected status %d enabling logging", got)
+	}
+
+	if got := admin(http.MethodPost, "/flush", "secret").Code; got != http.StatusOK {
+		t.Fatalf("unexpected status %d flushing", got)
+	}
+
+	recorder := admin(http.MethodGet, "/stats", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d for stats", recorder.Code)
+	}
+
+	var stats recordrequestlog.Stats
+	if err := json.Unmarshal(recorder.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if !stats.Logging || stats.SampleRate != 0.5 || stats.Exported != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// 管理接口的请求和关闭日志期间的请求都不记录，只有普通请求转发给下一个处理器
+	if records := readRecords(t, path); len(records) != 0 {
+		t.Fatalf("expected no records, got %d", len(records))
+	}
+
+	if forwarded != 1 {
+		t.Fatalf("expected 1 forwarded request, got %d", forwarded)
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+
+	// 先占用一个空闲端口得到地址，关闭后交给中间件监听
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = filepath.Join(t.TempDir(), "requests.log")
+	cfg.AdminPathPrefix = "/_recordrequestlog"
+	cfg.AdminToken = "secret"
+	cfg.EnableTraces = false
+	cfg.MetricsBackend = recordrequestlog.MetricsBackendPrometheus
+	cfg.PrometheusAddress = address
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	// 同一地址的两个实例共用监听
+	var handlers []http.Handler
+	for i := 0; i < 2; i++ {
+		handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		handlers = append(handlers, handler)
+	}
+
+	handlers[0].ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/orders", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/_recordrequestlog/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	handlers[0].ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d for admin metrics", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "http_server_request_duration_seconds_count") {
+		t.Errorf("expected request duration histogram in admin metrics, got:\n%s", recorder.Body.String())
+	}
+
+	resp, err := http.Get("http://" + address + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !strings.Contains(string(body), "http_server_request_duration_seconds_count") {
+		t.Errorf("expected request duration histogram on %s, got:\n%s", address, body)
+	}
+
+	// 关闭一个实例后另一个实例仍然可以抓取，全部关闭后停止监听
+	for i, handler := range handlers {
+		if err := handler.(*recordrequestlog.RecordRequestLog).Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.Get("http://" + address + "/metrics")
+		if i == 0 {
+			if err != nil {
+				t.Fatalf("expected metrics to be served after the first shutdown: %v", err)
+			}
+			resp.Body.Close()
+		} else if err == nil {
+			resp.Body.Close()
+			t.Fatal("expected listener to be closed after the last shutdown")
+		}
+	}
+}
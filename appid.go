@@ -0,0 +1,83 @@
+package recordrequestlog
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 默认标识调用方的请求头
+const defaultAppIDHeader = "AppId"
+
+// 默认作为指标属性的调用方个数上限，超出的调用方计入 otherAppID
+const defaultAppIDMetricsLimit = 100
+
+// otherAppID 超出 app_id_metrics_limit 的调用方在指标中的名称
+const otherAppID = "_other"
+
+// appIDLimiter 限制指标中 app.id 的基数，先出现的调用方单独统计，其余合并为 otherAppID
+type appIDLimiter struct {
+	limit int
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newAppIDLimiter(limit int) *appIDLimiter {
+
+	if limit <= 0 {
+		limit = defaultAppIDMetricsLimit
+	}
+
+	return &appIDLimiter{limit: limit, seen: make(map[string]bool)}
+}
+
+// label 返回调用方在指标中的名称
+func (l *appIDLimiter) label(appID string) string {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seen[appID] {
+		return appID
+	}
+
+	if len(l.seen) >= l.limit {
+		return otherAppID
+	}
+
+	l.seen[appID] = true
+	return appID
+}
+
+// sampledApp 按调用方的采样率决定是否记录请求，没有为该调用方配置 app_id_sample_rates 时使用路由的采样率
+func (e *RecordRequestLog) sampledApp(r *rules, settings *routeSettings, appID string) bool {
+
+	rate, ok := r.appIDRates[appID]
+	if !ok || appID == "" {
+		return e.sampled(settings)
+	}
+
+	return e.logging.Load() && (rate >= 1 || rand.Float64() < rate)
+}
+
+// recordAppUsage 按调用方统计请求数和收发的字节数，不受日志采样影响；没有调用方标识的请求不统计
+func (e *RecordRequestLog) recordAppUsage(ctx context.Context, appID string, requestSize, responseSize int64) {
+
+	if appID == "" {
+		return
+	}
+
+	attrs := metric.WithAttributes(attribute.String("app.id", e.appIDs.label(appID)))
+
+	e.appRequests.Add(ctx, 1, attrs)
+	if requestSize > 0 {
+		e.appRequestBytes.Add(ctx, requestSize, attrs)
+	}
+	if responseSize > 0 {
+		e.appResponseBytes.Add(ctx, responseSize, attrs)
+	}
+}
@@ -0,0 +1,74 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestAppIDAccounting(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.AppIDHeader = "X-App-Id"
+	cfg.AppIDMetricsLimit = 2
+	// noisy 的请求不记录日志，但仍然计入指标
+	cfg.AppIDSampleRates = map[string]float64{"noisy": 0}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+
+	for _, app := range []string{"partner", "partner", "noisy", "third", ""} {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader("hello"))
+		if app != "" {
+			req.Header.Set("X-App-Id", app)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	records := rec.RequireRecords(t, 4)
+	for _, record := range records {
+		if v, _ := recordrequestlogtest.Attr(record, "appid"); v.String() == "noisy" {
+			t.Fatal("expected noisy app to be sampled out of the logs")
+		}
+	}
+	if v, _ := recordrequestlogtest.Attr(records[0], "appid"); v.String() != "partner" {
+		t.Fatalf("expected appid from X-App-Id, got %q", v.String())
+	}
+
+	counts := map[string]int64{}
+	for _, point := range rec.RequireMetric(t, "recordrequestlog.app.requests").Data.(metricdata.Sum[int64]).DataPoints {
+		app, _ := point.Attributes.Value("app.id")
+		counts[app.AsString()] = point.Value
+	}
+	// 超过上限的调用方合并为 _other，没有调用方标识的请求不统计
+	if counts["partner"] != 2 || counts["noisy"] != 1 || counts["_other"] != 1 || len(counts) != 3 {
+		t.Fatalf("unexpected per-app request counts %v", counts)
+	}
+
+	bytes := map[string]int64{}
+	for _, point := range rec.RequireMetric(t, "recordrequestlog.app.response.size").Data.(metricdata.Sum[int64]).DataPoints {
+		app, _ := point.Attributes.Value("app.id")
+		bytes[app.AsString()] = point.Value
+	}
+	if bytes["partner"] != 4 {
+		t.Fatalf("unexpected per-app response bytes %v", bytes)
+	}
+
+	request := rec.RequireMetric(t, "recordrequestlog.app.request.size").Data.(metricdata.Sum[int64]).DataPoints
+	if len(request) == 0 {
+		t.Fatal("expected per-app request bytes")
+	}
+}
@@ -0,0 +1,167 @@
+package recordrequestlog
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 审计模式追加到记录中的属性，两种日志格式使用相同的名称，便于 VerifyChain 校验
+const (
+	// AuditChainKey 链标识，每个中间件实例启动时随机生成
+	AuditChainKey = "audit.chain"
+	// AuditSeqKey 记录在链中的序号，从 1 开始连续递增
+	AuditSeqKey = "audit.seq"
+	// AuditPrevKey 上一条记录的签名，第一条记录为空
+	AuditPrevKey = "audit.prev"
+	// AuditSignatureKey 记录的 HMAC-SHA256 签名
+	AuditSignatureKey = "audit.signature"
+)
+
+// auditChain 为记录计算哈希链签名，中间件的副本共享同一条链
+type auditChain struct {
+	key []byte
+	id  string
+
+	mu   sync.Mutex
+	seq  int64
+	prev string
+}
+
+func newAuditChain(key []byte) (*auditChain, error) {
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generate audit chain id: %w", err)
+	}
+
+	return &auditChain{key: key, id: hex.EncodeToString(id)}, nil
+}
+
+// sign 追加链标识、序号和上一条记录的签名，再对记录计算签名。
+// 签名之后丢弃的记录（例如队列已满）会使链出现缺口，由 VerifyChain 发现
+func (c *auditChain) sign(record *Record) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 不修改可能与其他记录共享的底层数组
+	attrs := append(slices.Clip(record.Attrs),
+		slog.String(AuditChainKey, c.id),
+		slog.Int64(AuditSeqKey, c.seq+1),
+		slog.String(AuditPrevKey, c.prev),
+	)
+
+	signature, err := auditSignature(c.key, Record{Time: record.Time, Level: record.Level, Message: record.Message, Attrs: attrs})
+	if err != nil {
+		return err
+	}
+
+	record.Attrs = append(attrs, slog.String(AuditSignatureKey, signature))
+	c.seq++
+	c.prev = signature
+	return nil
+}
+
+// auditSignature 对记录的规范 JSON 计算 HMAC-SHA256：字段与 JSON 类后端序列化的字段相同，
+// 另加 RFC 3339 格式的 UTC 时间 time，对象的键按字典序排列，不包含签名属性
+func auditSignature(key []byte, record Record) (string, error) {
+
+	fields := record.fields()
+	delete(fields, AuditSignatureKey)
+	fields["time"] = record.Time.UTC().Format(time.RFC3339Nano)
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("encode audit record: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyChain 校验审计模式导出的记录没有被篡改、删除或插入。records 需要来自同一条链，
+// 可以是任意顺序，按序号排序后校验。只校验序号连续的一段时无法发现这一段之前的记录被删除
+func VerifyChain(key []byte, records []Record) error {
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	type entry struct {
+		record    Record
+		chain     string
+		seq       int64
+		prev      string
+		signature string
+	}
+
+	entries := make([]entry, 0, len(records))
+	for i, record := range records {
+		en := entry{record: record}
+		for _, attr := range record.Attrs {
+			switch attr.Key {
+			case AuditChainKey:
+				en.chain = attr.Value.String()
+			case AuditSeqKey:
+				if attr.Value.Kind() == slog.KindInt64 {
+					en.seq = attr.Value.Int64()
+				}
+			case AuditPrevKey:
+				en.prev = attr.Value.String()
+			case AuditSignatureKey:
+				en.signature = attr.Value.String()
+			}
+		}
+
+		if en.seq <= 0 || en.signature == "" {
+			return fmt.Errorf("record %d: missing audit attributes", i)
+		}
+		if len(entries) > 0 && en.chain != entries[0].chain {
+			return fmt.Errorf("record %d: chain %q differs from %q", i, en.chain, entries[0].chain)
+		}
+
+		entries = append(entries, en)
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	if entries[0].seq == 1 && entries[0].prev != "" {
+		return errors.New("seq 1: unexpected previous signature")
+	}
+
+	for i, en := range entries {
+		if i > 0 {
+			switch prev := entries[i-1]; {
+			case en.seq == prev.seq:
+				return fmt.Errorf("seq %d: duplicate record", en.seq)
+			case en.seq != prev.seq+1:
+				return fmt.Errorf("seq %d: missing records after seq %d", en.seq, prev.seq)
+			case en.prev != prev.signature:
+				return fmt.Errorf("seq %d: previous signature mismatch", en.seq)
+			}
+		}
+
+		signature, err := auditSignature(key, en.record)
+		if err != nil {
+			return fmt.Errorf("seq %d: %w", en.seq, err)
+		}
+		if !hmac.Equal([]byte(signature), []byte(en.signature)) {
+			return fmt.Errorf("seq %d: signature mismatch", en.seq)
+		}
+	}
+
+	return nil
+}
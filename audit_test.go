@@ -0,0 +1,96 @@
+package recordrequestlog_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"slices"
+	"testing"
+)
+
+func auditRecords(t *testing.T, n int) []recordrequestlog.Record {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.AuditMode = true
+	cfg.AuditKey = "secret"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	for range n {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/orders", nil))
+	}
+
+	return rec.RequireRecords(t, n)
+}
+
+func TestAuditChain(t *testing.T) {
+
+	records := auditRecords(t, 3)
+
+	if v, _ := recordrequestlogtest.Attr(records[0], recordrequestlog.AuditSeqKey); v.Int64() != 1 {
+		t.Fatalf("unexpected first seq %v", v)
+	}
+	if v, _ := recordrequestlogtest.Attr(records[0], recordrequestlog.AuditPrevKey); v.String() != "" {
+		t.Fatalf("unexpected first prev %q", v)
+	}
+
+	prev, _ := recordrequestlogtest.Attr(records[0], recordrequestlog.AuditSignatureKey)
+	if v, _ := recordrequestlogtest.Attr(records[1], recordrequestlog.AuditPrevKey); v.String() != prev.String() {
+		t.Fatalf("record 2 is not chained to record 1")
+	}
+
+	if err := recordrequestlog.VerifyChain([]byte("secret"), records); err != nil {
+		t.Fatal(err)
+	}
+
+	// 顺序无关
+	reversed := slices.Clone(records)
+	slices.Reverse(reversed)
+	if err := recordrequestlog.VerifyChain([]byte("secret"), reversed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyChainDetectsTampering(t *testing.T) {
+
+	tests := map[string]func(records []recordrequestlog.Record) []recordrequestlog.Record{
+		"modified": func(records []recordrequestlog.Record) []recordrequestlog.Record {
+			records[1].Attrs = append(slices.Clip(records[1].Attrs), slog.String("method", "DELETE"))
+			return records
+		},
+		"message": func(records []recordrequestlog.Record) []recordrequestlog.Record {
+			records[2].Message = "forged"
+			return records
+		},
+		"deleted": func(records []recordrequestlog.Record) []recordrequestlog.Record {
+			return slices.Delete(records, 1, 2)
+		},
+		"duplicated": func(records []recordrequestlog.Record) []recordrequestlog.Record {
+			return append(records, records[1])
+		},
+		"other chain": func(records []recordrequestlog.Record) []recordrequestlog.Record {
+			return append(records, auditRecords(t, 1)...)
+		},
+	}
+
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			records := tamper(auditRecords(t, 3))
+			if err := recordrequestlog.VerifyChain([]byte("secret"), records); err == nil {
+				t.Fatal("expected verification error")
+			}
+		})
+	}
+
+	if err := recordrequestlog.VerifyChain([]byte("other"), auditRecords(t, 1)); err == nil {
+		t.Fatal("expected error for wrong key")
+	}
+}
@@ -0,0 +1,79 @@
+package recordrequestlog
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// baggageAllowAll 允许记录所有 baggage 条目
+const baggageAllowAll = "*"
+
+// baggageFilter 按允许列表选取请求携带的 W3C baggage 条目，记录为日志和 span 属性
+type baggageFilter struct {
+	keys map[string]bool
+	all  bool
+}
+
+// newBaggageFilter 允许列表为空时返回 nil，不记录 baggage
+func newBaggageFilter(keys []string) *baggageFilter {
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	f := &baggageFilter{keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		if key == baggageAllowAll {
+			f.all = true
+		}
+		f.keys[key] = true
+	}
+
+	return f
+}
+
+// members 返回 ctx 中允许记录的 baggage 条目，按 key 排序
+func (f *baggageFilter) members(ctx context.Context) []baggage.Member {
+
+	var members []baggage.Member
+	for _, member := range baggage.FromContext(ctx).Members() {
+		if f.all || f.keys[member.Key()] {
+			members = append(members, member)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Key() < members[j].Key() })
+	return members
+}
+
+// spanAttrs 返回 baggage.<key> 形式的 span 属性
+func (f *baggageFilter) spanAttrs(ctx context.Context) []attribute.KeyValue {
+
+	members := f.members(ctx)
+	attrs := make([]attribute.KeyValue, 0, len(members))
+	for _, member := range members {
+		attrs = append(attrs, attribute.String("baggage."+member.Key(), member.Value()))
+	}
+
+	return attrs
+}
+
+// attr 返回 baggage 分组的日志属性，没有允许记录的条目时返回 false
+func (f *baggageFilter) attr(ctx context.Context) (slog.Attr, bool) {
+
+	members := f.members(ctx)
+	if len(members) == 0 {
+		return slog.Attr{}, false
+	}
+
+	attrs := make([]any, 0, len(members))
+	for _, member := range members {
+		attrs = append(attrs, slog.String(member.Key(), member.Value()))
+	}
+
+	return slog.Group("baggage", attrs...), true
+}
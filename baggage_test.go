@@ -0,0 +1,32 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"testing"
+)
+
+func TestBaggageAttrs(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.BaggageKeys = []string{"experiment", "tenant"}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/api", nil)
+	req.Header.Set("baggage", "experiment=B,tenant=acme,session=secret")
+
+	record := captureRecord(t, cfg, req)
+
+	baggage, ok := record["baggage"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected baggage group, got %v", record["baggage"])
+	}
+
+	if baggage["experiment"] != "B" || baggage["tenant"] != "acme" {
+		t.Errorf("unexpected baggage %v", baggage)
+	}
+
+	if _, ok := baggage["session"]; ok {
+		t.Errorf("expected session to be filtered out, got %v", baggage)
+	}
+}
@@ -0,0 +1,86 @@
+package recordrequestlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newBenchmarkHandler(b *testing.B, mutate func(cfg *Config)) *RecordRequestLog {
+
+	cfg := CreateConfig()
+	cfg.Backend = BackendStdout
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+	if mutate != nil {
+		mutate(cfg)
+	}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"ok":true}`))
+	})
+
+	handler, err := New(context.Background(), next, cfg, "benchmark")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// 替换前关闭 stdout 后端，Shutdown 只关闭替换后的 noopSink
+	e := handler.(*RecordRequestLog)
+	if err := e.sink.Shutdown(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	e.sink = noopSink{}
+	b.Cleanup(func() { e.Shutdown(context.Background()) })
+
+	return e
+}
+
+func benchmarkServeHTTP(b *testing.B, e *RecordRequestLog, method, body string) {
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(method, "http://localhost/api/users?page=1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+
+	body := `{"name":"alice","email":"alice@example.com","tags":["a","b","c"]}`
+
+	// 不经过中间件的开销，包括构造测试请求的分配
+	b.Run("baseline", func(b *testing.B) {
+		e := newBenchmarkHandler(b, nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/api/users?page=1", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			e.next.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	})
+
+	b.Run("get", func(b *testing.B) {
+		benchmarkServeHTTP(b, newBenchmarkHandler(b, nil), http.MethodGet, "")
+	})
+
+	b.Run("post", func(b *testing.B) {
+		benchmarkServeHTTP(b, newBenchmarkHandler(b, nil), http.MethodPost, body)
+	})
+
+	b.Run("post large body", func(b *testing.B) {
+		benchmarkServeHTTP(b, newBenchmarkHandler(b, nil), http.MethodPost, strings.Repeat(body, 100))
+	})
+
+	b.Run("sampled out", func(b *testing.B) {
+		e := newBenchmarkHandler(b, func(cfg *Config) { cfg.SampleRate = 0 })
+		benchmarkServeHTTP(b, e, http.MethodPost, body)
+	})
+}
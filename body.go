@@ -0,0 +1,349 @@
+package recordrequestlog
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 默认记录请求体的请求方法
+var defaultCaptureMethods = []string{
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// 默认以明文记录的内容类型，支持 path.Match 通配符
+var defaultCaptureContentTypes = []string{
+	"application/json",
+	"application/*+json",
+	"application/xml",
+	"application/*+xml",
+	"application/x-www-form-urlencoded",
+	"text/*",
+}
+
+// 默认允许 base64 编码记录的二进制请求体大小上限
+const defaultMaxBinaryBodySize = 1024
+
+// 默认记录到日志中的请求体大小上限，压缩的请求体按解压后的大小计算
+const defaultMaxBodySize = 1 << 20
+
+// capturedBody 记录到日志中的请求体信息
+type capturedBody struct {
+	content     string
+	contentType string
+	// 请求体大小，未知时为 -1
+	size     int64
+	encoding string
+	// 请求体的 Content-Encoding，例如 gzip
+	contentEncoding string
+	// 记录的内容是否因超过大小上限被截断
+	truncated bool
+	// body_mode 为 hash 时计算的摘要，此时不记录内容
+	digest *bodyDigest
+	// 是否为 URL 编码的表单，记录时按字段解析并脱敏
+	form bool
+	// multipart 请求的字段和文件信息，此时不记录内容
+	multipart *multipartForm
+	// GraphQL 请求的操作信息
+	graphql *graphQLOperation
+	// SOAP 请求的操作信息
+	soap *soapInfo
+	// 开启 flatten_xml 时 XML 请求体的叶子元素，此时不记录内容
+	xmlFields []slog.Attr
+	// 按 status_streams 或因为不支持解压只记录请求体的元数据，不记录内容
+	metadataOnly bool
+}
+
+// release 结束 multipart 请求体的解析，请求处理完成后调用
+func (b *capturedBody) release() {
+
+	if b != nil && b.multipart != nil {
+		b.multipart.wait()
+	}
+}
+
+// shouldCaptureBody 判断是否需要读取并记录请求体
+func (s *routeSettings) shouldCaptureBody(req *http.Request) bool {
+
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return false
+	}
+
+	_, ok := s.captureMethods[req.Method]
+	return ok || s.captureAll
+}
+
+// captureBody 读取请求体，并将其重新放回请求中以便下一个处理器读取。
+// 非文本内容默认只记录内容类型和大小，开启 base64 后对较小的二进制内容编码记录
+func (e *RecordRequestLog) captureBody(req *http.Request, s *routeSettings) (*capturedBody, error) {
+
+	body := &capturedBody{
+		contentType:     req.Header.Get("Content-Type"),
+		contentEncoding: strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding"))),
+		size:            req.ContentLength,
+	}
+
+	if body.contentEncoding == "identity" {
+		body.contentEncoding = ""
+	}
+
+	if e.hashBody {
+		body.digest = newBodyDigest(req, e.bodyHashKey)
+		return body, nil
+	}
+
+	// multipart 请求只记录字段和文件元数据，压缩的请求体按二进制内容处理
+	if isMultipartForm(body.contentType) && body.contentEncoding == "" {
+		if body.multipart = newMultipartForm(req, body.contentType, s.maxBodySize); body.multipart != nil {
+			return body, nil
+		}
+	}
+
+	if ok, err := e.captureProto(req, s, body); ok || err != nil {
+		return body, err
+	}
+
+	if s.isPlaintext(body.contentType) {
+		body.form = isForm(body.contentType)
+
+		if err := e.readPlaintext(req, s, body); err != nil {
+			return nil, err
+		}
+
+		if e.encryptor != nil && isJSON(body.contentType) && body.content != "" {
+			e.encryptBody(req.Context(), body)
+		}
+
+		switch {
+		case e.isGraphQL(req):
+			body.graphql = e.parseGraphQL(req.Context(), body)
+		case isXML(body.contentType) && body.content != "":
+			e.captureXML(req.Context(), req, body)
+		}
+		return body, nil
+	}
+
+	if !s.base64Binary || req.ContentLength > int64(s.maxBinaryBodySize) {
+		return body, nil
+	}
+
+	b, err := readBody(req, int64(s.maxBinaryBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(b)) <= int64(s.maxBinaryBodySize) {
+		body.content = encodeBase64(b)
+		body.size = int64(len(b))
+		body.encoding = "base64"
+	}
+
+	return body, nil
+}
+
+// readPlaintext 读取以明文记录的请求体，压缩的请求体解压后记录
+func (e *RecordRequestLog) readPlaintext(req *http.Request, s *routeSettings, body *capturedBody) error {
+
+	// 不支持解压的编码（例如 br、zstd）是正常流量，只记录元数据，不输出错误
+	if body.contentEncoding != "" && !decodableEncoding(body.contentEncoding) {
+		body.metadataOnly = true
+		return nil
+	}
+
+	// 压缩的请求体多读取一部分原始内容，使解压后的副本可以达到 max_body_size
+	limit := s.maxBodySize
+	if body.contentEncoding != "" {
+		limit = compressedReadLimit(limit)
+	}
+
+	b, err := readBody(req, int64(limit))
+	if err != nil {
+		return err
+	}
+
+	rawTruncated := len(b) > limit
+
+	if body.contentEncoding != "" {
+		// 只解压用于记录的副本，转发给下一个处理器的仍是原始压缩内容
+		decoded, truncated, err := decodeBody(body.contentEncoding, b, s.maxBodySize, rawTruncated)
+		if err != nil {
+			e.logError("decode request body", err)
+			return nil
+		}
+
+		body.content = string(decoded)
+		body.truncated = truncated
+		if !rawTruncated {
+			body.size = int64(len(b))
+		}
+		return nil
+	}
+
+	if rawTruncated {
+		body.content = b[:s.maxBodySize]
+		body.truncated = true
+		return nil
+	}
+
+	body.content = b
+	body.size = int64(len(b))
+	return nil
+}
+
+// compressedReadLimit 返回压缩的请求体最多读取的原始字节数。解压后的内容通常大于原始内容，
+// 只有压缩率很低的内容在解压到 limit 之前读取结束
+func compressedReadLimit(limit int) int {
+	return 2*limit + 1024
+}
+
+// readBody 读取最多 limit+1 个字节（limit 小于 0 时读取全部），
+// 并将读取的内容与剩余的原始请求体拼接后放回请求中。读取使用池中的缓冲区，
+// 返回的字符串同时作为放回请求中的内容，记录截断的内容时不再复制
+func readBody(req *http.Request, limit int64) (string, error) {
+
+	var r io.Reader = req.Body
+	if limit >= 0 {
+		r = io.LimitReader(req.Body, limit+1)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	_, err := buf.ReadFrom(r)
+	b := buf.String()
+	req.Body = readCloser{io.MultiReader(strings.NewReader(b), req.Body), req.Body}
+
+	if err != nil {
+		return "", err
+	}
+
+	return b, nil
+}
+
+// decodableEncoding 判断 decodeBody 是否支持解压该编码
+func decodableEncoding(encoding string) bool {
+
+	switch encoding {
+	case "gzip", "x-gzip", "deflate":
+		return true
+	}
+
+	return false
+}
+
+// decodeBody 按 Content-Encoding 解压请求体，最多解压 limit 个字节。
+// partial 表示 raw 只是原始请求体的前一部分，此时解压到结尾提前结束不视为错误
+func decodeBody(encoding string, raw string, limit int, partial bool) ([]byte, bool, error) {
+
+	var r io.Reader
+
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(strings.NewReader(raw))
+		if err != nil {
+			return nil, false, err
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		// HTTP 中的 deflate 应为 zlib 格式，但部分客户端发送的是原始 deflate 数据
+		zr, err := zlib.NewReader(strings.NewReader(raw))
+		if err != nil {
+			fr := flate.NewReader(strings.NewReader(raw))
+			defer fr.Close()
+			r = fr
+		} else {
+			defer zr.Close()
+			r = zr
+		}
+	default:
+		return nil, false, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil && !(partial && errors.Is(err, io.ErrUnexpectedEOF)) {
+		return nil, false, err
+	}
+
+	truncated := partial && err != nil
+	if len(decoded) > limit {
+		decoded = decoded[:limit]
+		truncated = true
+	}
+
+	return decoded, truncated, nil
+}
+
+// readCloser 组合读取器与原始请求体的 Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isPlaintext 判断内容类型是否以明文记录，未声明内容类型时按文本处理
+func (s *routeSettings) isPlaintext(contentType string) bool {
+
+	if contentType == "" || s.captureAll {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range s.captureContentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodSet 将请求方法列表转换为集合，方法名统一为大写
+func methodSet(methods []string) map[string]struct{} {
+
+	set := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		set[strings.ToUpper(method)] = struct{}{}
+	}
+
+	return set
+}
+
+// lowerAll 将内容类型等不区分大小写的配置统一为小写
+func lowerAll(values []string) []string {
+
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		lowered = append(lowered, strings.ToLower(value))
+	}
+
+	return lowered
+}
+
+// encodeBase64 将内容编码为 base64，不复制原始内容
+func encodeBase64(s string) string {
+
+	var b strings.Builder
+	b.Grow(base64.StdEncoding.EncodedLen(len(s)))
+
+	w := base64.NewEncoder(base64.StdEncoding, &b)
+	io.WriteString(w, s)
+	w.Close()
+
+	return b.String()
+}
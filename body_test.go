@@ -0,0 +1,255 @@
+package recordrequestlog_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+func TestBodyPassthrough(t *testing.T) {
+
+	const payload = `{"data":{"type":"articles","id":"1"}}`
+
+	tests := []struct {
+		name           string
+		captureMethods []string
+		method         string
+		captured       bool
+	}{
+		{"patch configured", []string{"patch"}, http.MethodPatch, true},
+		{"post not configured", []string{"patch"}, http.MethodPost, false},
+		{"default post", nil, http.MethodPost, true},
+		{"default put", nil, http.MethodPut, true},
+		{"default patch", nil, http.MethodPatch, true},
+		{"default delete", nil, http.MethodDelete, true},
+		{"default get", nil, http.MethodGet, false},
+	}
+
+	for _, tt := range tests {
+		rec := recordrequestlogtest.New()
+
+		cfg := recordrequestlog.CreateConfig()
+		cfg.CaptureMethods = tt.captureMethods
+
+		middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var received string
+		handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received = string(b)
+		}))
+
+		req := httptest.NewRequest(tt.method, "http://localhost/api/articles/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/vnd.api+json")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if received != payload {
+			t.Fatalf("%s: unexpected body forwarded: %q", tt.name, received)
+		}
+
+		record := rec.RequireRecords(t, 1)[0]
+		if captured := record.Message == payload; captured != tt.captured {
+			t.Errorf("%s: expected body captured = %v, got message %q", tt.name, tt.captured, record.Message)
+		}
+		if _, ok := recordrequestlogtest.Attr(record, "body-size"); ok != tt.captured {
+			t.Errorf("%s: expected body-size present = %v", tt.name, tt.captured)
+		}
+	}
+}
+
+func TestBinaryBodyPassthrough(t *testing.T) {
+
+	payload := strings.Repeat("\x89PNG\r\n\x1a\n", 64)
+
+	for _, encode := range []bool{false, true} {
+		cfg := recordrequestlog.CreateConfig()
+		cfg.Base64BinaryBody = encode
+		cfg.MaxBinaryBodySize = 100
+
+		var received string
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received = string(b)
+		})
+
+		handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 使用未知长度的请求体，确保超过上限时剩余内容仍能转发
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/upload", io.MultiReader(strings.NewReader(payload)))
+		req.Header.Set("Content-Type", "image/png")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if received != payload {
+			t.Fatalf("base64=%v: forwarded body was modified", encode)
+		}
+	}
+}
+
+func TestCompressedBodyPassthrough(t *testing.T) {
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte(strings.Repeat(`{"key":"value"}`, 1024))); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limit := range []int{0, 16} {
+		cfg := recordrequestlog.CreateConfig()
+		cfg.MaxBodySize = limit
+
+		var received []byte
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received = b
+		})
+
+		handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/api", bytes.NewReader(compressed.Bytes()))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !bytes.Equal(received, compressed.Bytes()) {
+			t.Fatalf("max_body_size=%d: compressed body was modified", limit)
+		}
+	}
+}
+
+func TestCompressedBodyRecord(t *testing.T) {
+
+	compress := func(t *testing.T, newWriter func(io.Writer) io.WriteCloser, payload string) []byte {
+		t.Helper()
+
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		if _, err := w.Write([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	writers := map[string]struct {
+		encoding  string
+		newWriter func(io.Writer) io.WriteCloser
+	}{
+		"gzip":         {"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		"zlib deflate": {"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+		"raw deflate": {"deflate", func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}},
+	}
+
+	payload := strings.Repeat(`{"key":"value"}`, 64)
+
+	for name, tt := range writers {
+		for _, limit := range []int{0, 16} {
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.MaxBodySize = limit
+			cfg.CaptureMethods = []string{http.MethodPost}
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/api", bytes.NewReader(compress(t, tt.newWriter, payload)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", tt.encoding)
+			middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+			record := rec.RequireRecords(t, 1)[0]
+
+			// 记录的是解压后的内容，超过 max_body_size 时截断
+			want, truncated := payload, false
+			if limit > 0 {
+				want, truncated = payload[:limit], true
+			}
+			if record.Message != want {
+				t.Errorf("%s max_body_size=%d: expected decompressed message %q, got %q", name, limit, want, record.Message)
+			}
+			if v, ok := recordrequestlogtest.Attr(record, "body-truncated"); ok != truncated || (ok && !v.Bool()) {
+				t.Errorf("%s max_body_size=%d: expected body-truncated=%v, got %v", name, limit, truncated, v)
+			}
+			if v, _ := recordrequestlogtest.Attr(record, "content-encoding"); v.String() != tt.encoding {
+				t.Errorf("%s: expected content-encoding %s, got %v", name, tt.encoding, v)
+			}
+		}
+	}
+}
+
+func TestUnsupportedContentEncoding(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received string
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		received = string(b)
+	}))
+
+	const payload = "\x1b\x0b\x00\xf8brotli"
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "br")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != payload {
+		t.Fatalf("expected the compressed body to be forwarded, got %q", received)
+	}
+
+	record := rec.RequireRecords(t, 1)[0]
+	if record.Message != "" {
+		t.Errorf("expected no body content for an unsupported encoding, got %q", record.Message)
+	}
+	if v, _ := recordrequestlogtest.Attr(record, "content-encoding"); v.String() != "br" {
+		t.Errorf("expected content-encoding br, got %v", v)
+	}
+	if v, _ := recordrequestlogtest.Attr(record, "body-size"); v.Int64() != int64(len(payload)) {
+		t.Errorf("expected body-size %d, got %v", len(payload), v)
+	}
+}
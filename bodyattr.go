@@ -0,0 +1,106 @@
+package recordrequestlog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 默认 body 属性中展开的 JSON 层级
+const defaultBodyMaxDepth = 5
+
+// isJSON 判断内容类型是否为 JSON
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// bodyAttr 开启 body_attribute 时将请求体记录为属性：JSON 请求体解析为嵌套对象并按 redact_query_params 脱敏，
+// 超过 body_max_depth 的层级记录为 JSON 字符串；其余请求体以及被截断或格式有误的 JSON 记录为字符串
+func (e *RecordRequestLog) bodyAttr(ctx context.Context, redactor *queryRedactor, body *capturedBody) (slog.Attr, bool) {
+
+	if body.digest != nil || body.multipart != nil || body.metadataOnly {
+		return slog.Attr{}, false
+	}
+
+	content := formContent(redactor, body)
+	if content == "" {
+		return slog.Attr{}, false
+	}
+
+	key := e.attrKey("body", "http.request.body.content")
+
+	if !isJSON(body.contentType) || body.truncated || body.encoding != "" {
+		return slog.String(key, content), true
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return slog.String(key, content), true
+	}
+
+	if n := redactor.redactJSON(v); n > 0 {
+		e.redactions.Add(ctx, int64(n), metric.WithAttributes(attribute.String("source", "json_body")))
+	}
+
+	return slog.Attr{Key: key, Value: jsonValue(limitJSONDepth(v, e.bodyMaxDepth))}, true
+}
+
+// limitJSONDepth 将超过 depth 层的对象和数组替换为其 JSON 字符串
+func limitJSONDepth(v any, depth int) any {
+
+	switch value := v.(type) {
+	case map[string]any:
+		if depth <= 0 {
+			return marshalJSONString(value)
+		}
+		for k, child := range value {
+			value[k] = limitJSONDepth(child, depth-1)
+		}
+	case []any:
+		if depth <= 0 {
+			return marshalJSONString(value)
+		}
+		for i, child := range value {
+			value[i] = limitJSONDepth(child, depth-1)
+		}
+	}
+
+	return v
+}
+
+func marshalJSONString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// summaryMessage 开启 body_attribute 时的日志内容，例如 "POST /api/orders 201 34ms"；status 为 0 时不包含状态码
+func summaryMessage(req *http.Request, status int, duration time.Duration) string {
+
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.Path)
+
+	if status > 0 {
+		b.WriteByte(' ')
+		b.WriteString(strconv.Itoa(status))
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(duration.Milliseconds(), 10))
+	b.WriteString("ms")
+
+	return b.String()
+}
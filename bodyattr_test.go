@@ -0,0 +1,61 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBodyAttribute(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.BodyAttribute = true
+	cfg.BodyMaxDepth = 2
+	cfg.CaptureContentTypes = append(cfg.CaptureContentTypes, "text/plain")
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(
+		`{"order":{"id":7,"items":[{"sku":"A-1"}]},"password":"hunter2","tags":["new"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	record := rec.RequireRecords(t, 1)[0]
+	if !regexp.MustCompile(`^POST /api/orders 201 \d+ms$`).MatchString(record.Message) {
+		t.Fatalf("unexpected summary message %q", record.Message)
+	}
+
+	for key, want := range map[string]string{
+		"body.order.id":    "7",
+		"body.order.items": `[{"sku":"A-1"}]`,
+		"body.password":    "REDACTED",
+		"body.tags":        "[new]",
+	} {
+		if v, ok := recordrequestlogtest.Attr(record, key); !ok || v.String() != want {
+			t.Errorf("expected %s %q, got %q", key, want, v)
+		}
+	}
+
+	rec.Reset()
+	req = httptest.NewRequest(http.MethodPost, "http://localhost/api/notes", strings.NewReader("plain text"))
+	req.Header.Set("Content-Type", "text/plain")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	record = rec.RequireRecord(t, recordrequestlogtest.HasAttr("body", "plain text"))
+	if !strings.HasPrefix(record.Message, "POST /api/notes 201 ") {
+		t.Fatalf("unexpected summary message %q", record.Message)
+	}
+}
@@ -0,0 +1,89 @@
+package recordrequestlog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// 请求体的记录方式
+const (
+	// BodyModeContent 记录请求体内容
+	BodyModeContent = "content"
+	// BodyModeHash 只记录请求体的摘要、大小和内容类型，不记录内容
+	BodyModeHash = "hash"
+)
+
+// bodyDigest 在下一个处理器读取请求体时计算摘要，不缓存请求体内容
+type bodyDigest struct {
+	body io.ReadCloser
+	hmac bool
+
+	// 出站请求的请求体可能在 RoundTrip 返回后仍在读取
+	mu   sync.Mutex
+	hash hash.Hash
+	size int64
+	eof  bool
+}
+
+// newBodyDigest 替换请求体为计算摘要的读取器，key 不为空时使用 HMAC-SHA256
+func newBodyDigest(req *http.Request, key []byte) *bodyDigest {
+
+	d := &bodyDigest{body: req.Body, hash: sha256.New()}
+	if len(key) > 0 {
+		d.hash = hmac.New(sha256.New, key)
+		d.hmac = true
+	}
+
+	req.Body = d
+	return d
+}
+
+func (d *bodyDigest) Read(p []byte) (int, error) {
+
+	n, err := d.body.Read(p)
+
+	d.mu.Lock()
+	d.hash.Write(p[:n])
+	d.size += int64(n)
+	if err == io.EOF {
+		d.eof = true
+	}
+	d.mu.Unlock()
+
+	return n, err
+}
+
+func (d *bodyDigest) Close() error {
+	return d.body.Close()
+}
+
+// attrs 返回摘要和已读取的大小；处理器没有读完请求体时摘要只覆盖已读取的部分，并标记为 partial
+func (d *bodyDigest) attrs(e *RecordRequestLog) []slog.Attr {
+
+	d.mu.Lock()
+	sum := hex.EncodeToString(d.hash.Sum(nil))
+	size, eof := d.size, d.eof
+	d.mu.Unlock()
+
+	key := e.attrKey("body-sha256", "http.request.body.sha256")
+	if d.hmac {
+		key = e.attrKey("body-hmac-sha256", "http.request.body.hmac_sha256")
+	}
+
+	attrs := []slog.Attr{
+		slog.String(key, sum),
+		slog.Int64(e.attrKey("body-size", "http.request.body.size"), size),
+	}
+
+	if !eof {
+		attrs = append(attrs, slog.Bool(e.attrKey("body-hash-partial", "http.request.body.hash_partial"), true))
+	}
+
+	return attrs
+}
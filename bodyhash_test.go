@@ -0,0 +1,78 @@
+package recordrequestlog_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+func TestBodyHash(t *testing.T) {
+
+	const payload = `{"card":"4111111111111111"}`
+
+	sha := sha256.Sum256([]byte(payload))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(payload))
+
+	tests := map[string]struct {
+		key  string
+		attr string
+		want string
+	}{
+		"sha256": {attr: "body-sha256", want: hex.EncodeToString(sha[:])},
+		"hmac":   {key: "secret", attr: "body-hmac-sha256", want: hex.EncodeToString(mac.Sum(nil))},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.BodyMode = recordrequestlog.BodyModeHash
+			cfg.BodyHashKey = tt.key
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var received string
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				b, _ := io.ReadAll(req.Body)
+				received = string(b)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/pay", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if received != payload {
+				t.Fatalf("next handler received %q", received)
+			}
+
+			record := rec.RequireRecords(t, 1)[0]
+			if strings.Contains(record.Message, "4111") {
+				t.Fatalf("body content logged: %q", record.Message)
+			}
+
+			if v, _ := recordrequestlogtest.Attr(record, tt.attr); v.String() != tt.want {
+				t.Fatalf("unexpected %s %q, want %q", tt.attr, v, tt.want)
+			}
+
+			if v, _ := recordrequestlogtest.Attr(record, "body-size"); v.Int64() != int64(len(payload)) {
+				t.Fatalf("unexpected body-size %v", v)
+			}
+
+			if _, ok := recordrequestlogtest.Attr(record, "body-hash-partial"); ok {
+				t.Fatal("unexpected body-hash-partial for a fully read body")
+			}
+		})
+	}
+}
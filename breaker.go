@@ -0,0 +1,179 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 默认的熔断阈值和冷却时间
+const (
+	defaultCircuitBreakerThreshold = 5
+	defaultCircuitBreakerCooloff   = 30 * time.Second
+)
+
+// errCircuitOpen 熔断期间跳过导出时返回
+var errCircuitOpen = errors.New("exporter circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker 连续失败达到阈值后打开，冷却时间过后放行一次探测，
+// 探测成功则关闭，失败则重新打开
+type circuitBreaker struct {
+	threshold int
+	cooloff   time.Duration
+	onOpen    func()
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooloff time.Duration, onOpen func()) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooloff:   cooloff,
+		onOpen:    onOpen,
+	}
+}
+
+// allow 判断是否允许本次导出，没有配置熔断（b 为 nil）时总是允许
+func (b *circuitBreaker) allow() bool {
+
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooloff {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// 探测进行中，其余导出继续跳过
+		return false
+	default:
+		return true
+	}
+}
+
+// done 记录导出结果
+func (b *circuitBreaker) done(err error) {
+
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+
+	if err == nil {
+		b.state = breakerClosed
+		b.failures = 0
+		b.mu.Unlock()
+		return
+	}
+
+	b.failures++
+	opened := b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold)
+	if opened {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+
+	b.mu.Unlock()
+
+	if opened && b.onOpen != nil {
+		b.onOpen()
+	}
+}
+
+// onBreakerOpen 熔断打开时记录指标并输出到本地
+func (e *RecordRequestLog) onBreakerOpen() {
+	e.breakerTrips.Add(context.Background(), 1)
+	e.logError("export", errCircuitOpen)
+}
+
+// breakerLogExporter 熔断期间跳过日志导出。启用本地缓冲时返回 errCircuitOpen，
+// 由外层写入缓冲；否则丢弃记录并计入丢弃指标。每次导出的结果交给 onExport
+type breakerLogExporter struct {
+	log.Exporter
+	breaker    *circuitBreaker
+	reportOpen bool
+	dropped    metric.Int64Counter
+	onExport   func(ctx context.Context, err error)
+}
+
+func (x *breakerLogExporter) Export(ctx context.Context, records []log.Record) error {
+
+	if !x.breaker.allow() {
+		if x.reportOpen {
+			return errCircuitOpen
+		}
+
+		x.dropped.Add(ctx, int64(len(records)), metric.WithAttributes(attribute.String("reason", "circuit_open")))
+		return nil
+	}
+
+	err := x.Exporter.Export(ctx, records)
+	x.breaker.done(err)
+	x.onExport(ctx, err)
+	return err
+}
+
+// breakerSpanExporter 熔断期间丢弃 span
+type breakerSpanExporter struct {
+	trace.SpanExporter
+	breaker  *circuitBreaker
+	onExport func(ctx context.Context, err error)
+}
+
+func (x *breakerSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+
+	if !x.breaker.allow() {
+		return nil
+	}
+
+	err := x.SpanExporter.ExportSpans(ctx, spans)
+	x.breaker.done(err)
+	x.onExport(ctx, err)
+	return err
+}
+
+// breakerMetricExporter 熔断期间丢弃本次采集的指标
+type breakerMetricExporter struct {
+	sdkmetric.Exporter
+	breaker  *circuitBreaker
+	onExport func(ctx context.Context, err error)
+}
+
+func (x *breakerMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+
+	if !x.breaker.allow() {
+		return nil
+	}
+
+	err := x.Exporter.Export(ctx, rm)
+	x.breaker.done(err)
+	x.onExport(ctx, err)
+	return err
+}
@@ -0,0 +1,48 @@
+package recordrequestlog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+
+	opened := 0
+	b := newCircuitBreaker(2, 20*time.Millisecond, func() { opened++ })
+	failure := errors.New("unavailable")
+
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatalf("attempt %d: expected breaker to be closed", i)
+		}
+		b.done(failure)
+	}
+
+	if opened != 1 || b.allow() {
+		t.Fatal("expected breaker to open after reaching the threshold")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	// 冷却后只放行一次探测
+	if !b.allow() || b.allow() {
+		t.Fatal("expected a single probe after cooloff")
+	}
+
+	b.done(failure)
+	if opened != 2 || b.allow() {
+		t.Fatal("expected failed probe to reopen the breaker")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("expected a probe after cooloff")
+	}
+	b.done(nil)
+
+	if !b.allow() || !b.allow() {
+		t.Fatal("expected successful probe to close the breaker")
+	}
+}
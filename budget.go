@@ -0,0 +1,169 @@
+package recordrequestlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// truncationMarker 截断的值末尾追加的标记，计入大小上限
+const truncationMarker = "...[truncated]"
+
+// droppedAttrsKey 超过 max_record_size 时丢弃的属性个数
+const droppedAttrsKey = "dropped-attributes"
+
+// sizeBudget 单条记录的字节数上限，0 表示不限制
+type sizeBudget struct {
+	attribute int
+	message   int
+	record    int
+}
+
+func (b sizeBudget) enabled() bool {
+	return b.attribute > 0 || b.message > 0 || b.record > 0
+}
+
+// truncate 将 s 截断到最多 limit 个字节（包括截断标记），截断处不完整的 UTF-8 字符一并去掉
+func truncate(s string, limit int) (string, bool) {
+
+	if len(s) <= limit {
+		return s, false
+	}
+
+	if limit <= len(truncationMarker) {
+		return strings.ToValidUTF8(s[:max(limit, 0)], ""), true
+	}
+
+	return strings.ToValidUTF8(s[:limit-len(truncationMarker)], "") + truncationMarker, true
+}
+
+// attrSize 估算属性的字节数：键和值的字节数之和，数值按 8 个字节计算
+func attrSize(attr slog.Attr) int {
+
+	size := len(attr.Key)
+
+	switch v := attr.Value.Resolve(); v.Kind() {
+	case slog.KindString:
+		size += len(v.String())
+	case slog.KindBool:
+		size++
+	case slog.KindGroup:
+		for _, a := range v.Group() {
+			size += attrSize(a)
+		}
+	case slog.KindAny:
+		size += len(fmt.Sprint(v.Any()))
+	default:
+		size += 8
+	}
+
+	return size
+}
+
+// recordSize 估算记录的字节数
+func recordSize(record *Record) int {
+
+	size := len(record.Message)
+	for _, attr := range record.Attrs {
+		size += attrSize(attr)
+	}
+
+	return size
+}
+
+// truncateAttr 截断超过 max_attribute_size 的字符串值，返回截断的个数
+func (b sizeBudget) truncateAttr(attr *slog.Attr) int {
+
+	v := attr.Value.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		s, truncated := truncate(v.String(), b.attribute)
+		if !truncated {
+			return 0
+		}
+		attr.Value = slog.StringValue(s)
+		return 1
+	case slog.KindGroup:
+		group := append([]slog.Attr(nil), v.Group()...)
+		n := 0
+		for i := range group {
+			n += b.truncateAttr(&group[i])
+		}
+		if n > 0 {
+			attr.Value = slog.GroupValue(group...)
+		}
+		return n
+	}
+
+	return 0
+}
+
+// applyBudget 按 max_attribute_size、max_message_size 和 max_record_size 截断记录。超过 max_record_size 时
+// 先截断消息，仍然超过时从最后一个属性开始丢弃，并记录丢弃的个数；相同的记录总是得到相同的结果
+func (e *RecordRequestLog) applyBudget(ctx context.Context, record *Record) {
+
+	b := e.budget
+	if !b.enabled() {
+		return
+	}
+
+	count := func(field string, n int) {
+		if n > 0 {
+			e.truncations.Add(ctx, int64(n), metric.WithAttributes(attribute.String("field", field)))
+		}
+	}
+
+	if b.message > 0 {
+		var truncated bool
+		if record.Message, truncated = truncate(record.Message, b.message); truncated {
+			count("message", 1)
+		}
+	}
+
+	if b.attribute > 0 {
+		n := 0
+		for i := range record.Attrs {
+			n += b.truncateAttr(&record.Attrs[i])
+		}
+		count("attribute", n)
+	}
+
+	if b.record <= 0 {
+		return
+	}
+
+	size := recordSize(record)
+	if size <= b.record {
+		return
+	}
+
+	if excess := size - b.record; len(record.Message) > 0 {
+		message, _ := truncate(record.Message, max(len(record.Message)-excess, 0))
+		size += len(message) - len(record.Message)
+		record.Message = message
+		count("message", 1)
+	}
+
+	if size <= b.record {
+		return
+	}
+
+	// 为丢弃个数的属性预留空间
+	dropped := 0
+	marker := attrSize(slog.Int(droppedAttrsKey, 0))
+	for len(record.Attrs) > 0 && size+marker > b.record {
+		size -= attrSize(record.Attrs[len(record.Attrs)-1])
+		record.Attrs = record.Attrs[:len(record.Attrs)-1]
+		dropped++
+	}
+
+	if size+marker <= b.record {
+		record.Attrs = append(record.Attrs, slog.Int(droppedAttrsKey, dropped))
+	}
+	count("record", dropped)
+}
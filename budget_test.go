@@ -0,0 +1,81 @@
+package recordrequestlog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestApplyBudget(t *testing.T) {
+
+	newRecord := func() Record {
+		return Record{
+			Message: strings.Repeat("m", 100),
+			Attrs: []slog.Attr{
+				slog.String("level", "info"),
+				slog.String("user-agent", strings.Repeat("u", 100)),
+				slog.Group("form", slog.String("comment", strings.Repeat("c", 100))),
+				slog.Int("status", 200),
+				slog.String("body", strings.Repeat("b", 200)),
+			},
+		}
+	}
+
+	t.Run("attribute and message", func(t *testing.T) {
+
+		e := &RecordRequestLog{budget: sizeBudget{attribute: 40, message: 30}, truncations: noop.Int64Counter{}}
+
+		record := newRecord()
+		e.applyBudget(context.Background(), &record)
+
+		if len(record.Message) != 30 || !strings.HasSuffix(record.Message, truncationMarker) {
+			t.Fatalf("unexpected message %q", record.Message)
+		}
+		if v := recordAttr(record, "user-agent"); len(v) != 40 || !strings.HasSuffix(v, truncationMarker) {
+			t.Fatalf("unexpected user-agent %q", v)
+		}
+		form, _ := recordValue(record, "form")
+		if v := form.Group()[0].Value.String(); len(v) != 40 {
+			t.Fatalf("expected nested values to be truncated, got %q", v)
+		}
+	})
+
+	t.Run("record", func(t *testing.T) {
+
+		e := &RecordRequestLog{budget: sizeBudget{record: 300}, truncations: noop.Int64Counter{}}
+
+		var first Record
+		for i := 0; i < 2; i++ {
+			record := newRecord()
+			e.applyBudget(context.Background(), &record)
+
+			if size := recordSize(&record); size > 300 {
+				t.Fatalf("expected record within budget, got %d bytes", size)
+			}
+			if record.Message != "" {
+				t.Fatalf("expected the message to be truncated first, got %q", record.Message)
+			}
+			if v, ok := recordValue(record, droppedAttrsKey); !ok || v.Int64() != 1 {
+				t.Fatalf("expected the last attribute to be dropped, got %v", v)
+			}
+
+			// 相同的记录截断结果相同
+			if i == 0 {
+				first = record
+			} else if recordSize(&first) != recordSize(&record) || len(first.Attrs) != len(record.Attrs) {
+				t.Fatal("expected deterministic truncation")
+			}
+		}
+	})
+}
+
+func TestTruncateUTF8(t *testing.T) {
+
+	s, truncated := truncate("你好世界你好世界", 17)
+	if !truncated || s != "你"+truncationMarker {
+		t.Fatalf("unexpected truncation %q", s)
+	}
+}
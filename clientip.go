@@ -0,0 +1,154 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPResolver 解析请求的客户端地址。只有当直接连接的对端属于可信代理时，
+// 才会使用 Forwarded、X-Forwarded-For 和 X-Real-IP 请求头中的地址
+type clientIPResolver struct {
+	trusted   []netip.Prefix
+	anonymize bool
+}
+
+func newClientIPResolver(trustedProxies []string, anonymize bool) (*clientIPResolver, error) {
+
+	r := &clientIPResolver{anonymize: anonymize}
+
+	for _, proxy := range trustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(proxy)
+			if aerr != nil {
+				return nil, fmt.Errorf("invalid trusted_proxies entry %q: %w", proxy, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+
+	return r, nil
+}
+
+// resolve 返回客户端地址，无法解析时返回空字符串
+func (r *clientIPResolver) resolve(req *http.Request) string {
+
+	remote, ok := parseHostAddr(req.RemoteAddr)
+	if !ok {
+		return ""
+	}
+
+	client := remote
+	if r.isTrusted(remote) {
+		// 从离当前节点最近的一跳开始向前查找，第一个不可信的地址即为客户端地址
+		hops := forwardedHops(req)
+		for i := len(hops) - 1; i >= 0; i-- {
+			client = hops[i]
+			if !r.isTrusted(client) {
+				break
+			}
+		}
+	}
+
+	if r.anonymize {
+		client = anonymizeAddr(client)
+	}
+
+	return client.String()
+}
+
+func (r *clientIPResolver) isTrusted(addr netip.Addr) bool {
+
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedHops 按从客户端到最近代理的顺序返回请求头中记录的地址，
+// 优先使用 RFC 7239 Forwarded，其次 X-Forwarded-For，最后 X-Real-IP
+func forwardedHops(req *http.Request) []netip.Addr {
+
+	if values := req.Header.Values("Forwarded"); len(values) > 0 {
+		var hops []netip.Addr
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if !ok || !strings.EqualFold(key, "for") {
+						continue
+					}
+					if addr, ok := parseHostAddr(strings.Trim(val, `"`)); ok {
+						hops = append(hops, addr)
+					}
+				}
+			}
+		}
+		if len(hops) > 0 {
+			return hops
+		}
+	}
+
+	if values := req.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		var hops []netip.Addr
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				if addr, ok := parseHostAddr(strings.TrimSpace(item)); ok {
+					hops = append(hops, addr)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			return hops
+		}
+	}
+
+	if addr, ok := parseHostAddr(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ok {
+		return []netip.Addr{addr}
+	}
+
+	return nil
+}
+
+// parseHostAddr 解析可能带端口或方括号的地址，例如 "192.0.2.1:80"、"[2001:db8::1]:443"
+func parseHostAddr(value string) (netip.Addr, bool) {
+
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+// anonymizeAddr 隐去地址末尾部分：IPv4 保留前 24 位，IPv6 保留前 48 位
+func anonymizeAddr(addr netip.Addr) netip.Addr {
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr
+	}
+
+	return prefix.Addr()
+}
@@ -0,0 +1,72 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+
+	tests := map[string]struct {
+		remoteAddr string
+		headers    map[string]string
+		anonymize  bool
+		want       string
+	}{
+		"remote address": {
+			remoteAddr: "198.51.100.10:5000",
+			want:       "198.51.100.10",
+		},
+		"untrusted peer ignores headers": {
+			remoteAddr: "198.51.100.10:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			want:       "198.51.100.10",
+		},
+		"x-forwarded-for skips trusted hops": {
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1, 203.0.113.7, 10.0.0.2"},
+			want:       "203.0.113.7",
+		},
+		"forwarded takes precedence": {
+			remoteAddr: "10.0.0.1:5000",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:4711";proto=https, for=10.0.0.3`,
+				"X-Forwarded-For": "203.0.113.7",
+			},
+			want: "2001:db8::1",
+		},
+		"x-real-ip": {
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.9"},
+			want:       "203.0.113.9",
+		},
+		"anonymized": {
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			anonymize:  true,
+			want:       "203.0.113.0",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := recordrequestlog.CreateConfig()
+			cfg.LogFormat = recordrequestlog.LogFormatSemConv
+			cfg.TrustedProxies = []string{"10.0.0.0/8"}
+			cfg.AnonymizeClientIP = tt.anonymize
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			record := captureRecord(t, cfg, req)
+			if record["client.address"] != tt.want {
+				t.Fatalf("client.address: got %v, want %s", record["client.address"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// rrl-replay 将文件后端写入的 file_path（包括压缩的轮转文件）或本地缓冲（spool_dir）中的请求记录
+// 导出为 curl 脚本或 HAR 文件，用于在测试环境重现生产流量。中间件需要开启 replay_capture 才会记录请求头：
+//
+//	go run ./cmd/rrl-replay -format curl -target https://staging.example.com /var/log/traefik/requests.log > replay.sh
+//	go run ./cmd/rrl-replay -format har -method POST -path /api/ -o replay.har /var/lib/recordrequestlog/spool
+//
+// 参数为文件或目录，目录中的文件按名称顺序读取；脱敏的字段（REDACTED）原样导出，被截断的请求体标记为 incomplete
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"recordrequestlog"
+)
+
+func main() {
+
+	format := flag.String("format", "curl", "output format: curl or har")
+	target := flag.String("target", "", "replace the scheme and host of every request, e.g. https://staging.example.com")
+	method := flag.String("method", "", "only export requests with this method")
+	pathPrefix := flag.String("path", "", "only export requests whose path has this prefix")
+	limit := flag.Int("limit", 0, "maximum number of requests to export, 0 for no limit")
+	skipIncomplete := flag.Bool("skip-incomplete", false, "skip requests whose body or headers were not fully recorded")
+	output := flag.String("o", "", "output file, defaults to stdout")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: rrl-replay [flags] file-or-dir...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	var write func(io.Writer, []recordrequestlog.ReplayRequest) error
+	switch *format {
+	case "curl":
+		write = recordrequestlog.WriteCurl
+	case "har":
+		write = recordrequestlog.WriteHAR
+	default:
+		log.Fatalf("invalid -format %q", *format)
+	}
+
+	var base *url.URL
+	if *target != "" {
+		u, err := url.Parse(*target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			log.Fatalf("invalid -target %q", *target)
+		}
+		base = u
+	}
+
+	files, err := inputFiles(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var requests []recordrequestlog.ReplayRequest
+	for _, name := range files {
+		records, err := readFile(name)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+
+		for _, record := range records {
+			req, ok := recordrequestlog.NewReplayRequest(record)
+			if !ok || (*skipIncomplete && req.Incomplete) {
+				continue
+			}
+			if *method != "" && !strings.EqualFold(req.Method, *method) {
+				continue
+			}
+
+			u, err := url.Parse(req.URL)
+			if err != nil || !strings.HasPrefix(u.Path, *pathPrefix) {
+				continue
+			}
+			if base != nil {
+				u.Scheme, u.Host = base.Scheme, base.Host
+				req.URL = u.String()
+			}
+
+			requests = append(requests, req)
+		}
+	}
+
+	if *limit > 0 && len(requests) > *limit {
+		requests = requests[:*limit]
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := write(w, requests); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d requests\n", len(requests))
+}
+
+// inputFiles 展开目录，跳过子目录和本地缓冲写入中的临时文件
+func inputFiles(args []string) ([]string, error) {
+
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasSuffix(entry.Name(), ".tmp") {
+				names = append(names, filepath.Join(arg, entry.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+
+	return files, nil
+}
+
+// readFile 读取文件中的记录，file_compress 轮转出的 .gz 文件解压后读取
+func readFile(name string) ([]recordrequestlog.RequestRecord, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return recordrequestlog.ReadRequestRecords(r)
+}
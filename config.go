@@ -0,0 +1,557 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Config 中间件配置，字符串字段支持 ${VAR} 和 ${VAR:-default} 形式的环境变量引用，
+// 例如 authorization: "${OPENOBSERVE_AUTH}"
+type Config struct {
+	// 导出端地址，OTLP 后端可以用逗号分隔多个端点，第一个为主端点，其余按顺序作为备用端点；
+	// 其他后端只使用第一个端点
+	Endpoint      string `yaml:"endpoint,omitempty"`
+	Authorization string `yaml:"authorization,omitempty"`
+	Organization  string `yaml:"organization,omitempty"`
+	StreamName    string `yaml:"stream_name,omitempty"`
+	ServerName    string `yaml:"server_name,omitempty"`
+	// 导出请求额外携带的请求头，例如网关要求的租户 ID 或代理认证；
+	// 与 Authorization、organization、stream-name 重名时以后者为准
+	ExporterHeaders map[string]string `yaml:"exporter_headers,omitempty"`
+	// 遥测初始化或请求体读取失败时，是否仍然将请求转发给下一个处理器
+	FailOpen bool `yaml:"fail_open,omitempty"`
+	// 是否使用 lite 包的轻量实现：只依赖标准库，按 OTLP/HTTP JSON 导出日志和 span，只支持 lite.Config 中的配置项。
+	// Traefik 插件清单直接导入 lite 包，编译使用时可以通过该配置切换，只对 New 生效；修改了 lite 模式不支持的配置项时输出警告
+	LiteMode bool `yaml:"lite_mode,omitempty"`
+
+	// trace、metric 和日志的资源属性，service_name 为空时使用 server_name；
+	// detect_resource 开启时自动探测主机、操作系统、进程和容器信息
+	ServiceName           string            `yaml:"service_name,omitempty"`
+	ServiceVersion        string            `yaml:"service_version,omitempty"`
+	DeploymentEnvironment string            `yaml:"deployment_environment,omitempty"`
+	ResourceAttributes    map[string]string `yaml:"resource_attributes,omitempty"`
+	DetectResource        bool              `yaml:"detect_resource,omitempty"`
+
+	// 开启时从 downward API 注入的环境变量、主机名和服务账号读取 k8s.pod.name、k8s.namespace.name 等资源属性；
+	// kubernetes_api 开启时缺少的节点、Deployment 和 Pod UID 向集群内 API 查询，结果缓存 kubernetes_cache_ttl
+	KubernetesMetadata bool   `yaml:"kubernetes_metadata,omitempty"`
+	KubernetesAPI      bool   `yaml:"kubernetes_api,omitempty"`
+	KubernetesCacheTTL string `yaml:"kubernetes_cache_ttl,omitempty"`
+
+	// 是否导出请求日志、trace 和指标，关闭的信号不创建对应的 provider 和导出器；
+	// 关闭 trace 时仍然向下游传播上游的 trace 上下文
+	EnableLogs    bool `yaml:"enable_logs,omitempty"`
+	EnableTraces  bool `yaml:"enable_traces,omitempty"`
+	EnableMetrics bool `yaml:"enable_metrics,omitempty"`
+
+	// 将中间件的 TracerProvider、MeterProvider 和传播器设置为 otel 的全局实例。默认只在中间件实例内使用，
+	// 避免覆盖 Traefik 进程和其他插件的全局状态；应用可以通过 TracerProvider 和 MeterProvider 方法显式获取
+	SetGlobalProviders bool `yaml:"set_global_providers,omitempty"`
+
+	// 是否导出 Go 运行时指标（GC、goroutine、堆内存）和主机指标（CPU、内存、网络）
+	RuntimeMetrics bool `yaml:"runtime_metrics,omitempty"`
+	HostMetrics    bool `yaml:"host_metrics,omitempty"`
+
+	// 指标的导出方式：otlp（默认）推送到 endpoint，prometheus 提供 /metrics 供抓取。
+	// prometheus_address 不为空时单独监听该地址，例如 ":9464"，否则挂载在管理接口下
+	MetricsBackend    string `yaml:"metrics_backend,omitempty"`
+	PrometheusAddress string `yaml:"prometheus_address,omitempty"`
+	// 请求耗时直方图是否附带 trace ID exemplar，只记录被采样的 span，
+	// 可用 OTEL_METRICS_EXEMPLAR_FILTER 修改。OTLP 总是导出，Prometheus 在 OpenMetrics 格式下返回
+	Exemplars bool `yaml:"exemplars,omitempty"`
+	// http.server.request.body.size 和 http.server.response.body.size 直方图的分桶上界（字节），需要递增；
+	// 为空时使用 0 到 64MiB 按 4 倍递增的默认分桶
+	SizeBuckets []float64 `yaml:"size_buckets,omitempty"`
+	// 按指标名称重命名指标、丢弃属性以控制基数、修改直方图分桶或不导出指标
+	MetricViews []MetricViewConfig `yaml:"metric_views,omitempty"`
+
+	// 导出批处理参数，时间使用 Go duration 格式，例如 "1s"、"500ms"
+	TraceBatchTimeout string `yaml:"trace_batch_timeout,omitempty"`
+	TraceMaxBatchSize int    `yaml:"trace_max_batch_size,omitempty"`
+	// trace 采样器：parentbased_always_on（默认）、parentbased_always_off、parentbased_traceidratio、
+	// always_on、always_off、traceidratio；ratio 类采样器使用 trace_sample_ratio（0 到 1）。
+	// 只影响 span 的导出，日志和指标不受影响
+	TraceSampler     string  `yaml:"trace_sampler,omitempty"`
+	TraceSampleRatio float64 `yaml:"trace_sample_ratio,omitempty"`
+	MetricInterval   string  `yaml:"metric_interval,omitempty"`
+	LogQueueSize     int     `yaml:"log_queue_size,omitempty"`
+	LogExportTimeout string  `yaml:"log_export_timeout,omitempty"`
+	LogBatchInterval string  `yaml:"log_batch_interval,omitempty"`
+	LogMaxBatchSize  int     `yaml:"log_max_batch_size,omitempty"`
+	// 请求处理中同步导出记录的最长等待时间，超时后请求继续处理，记录在后台导出；为空时不限制
+	ExportTimeout string `yaml:"export_timeout,omitempty"`
+	// 开启 export_timeout 时同时在导出的记录数上限（默认 256），导出端无响应、后台导出的记录达到上限后新记录直接丢弃
+	ExportMaxPending int `yaml:"export_max_pending,omitempty"`
+	// Shutdown 和 Flush 在调用方没有设置截止时间时等待导出完成的最长时间
+	ShutdownTimeout string `yaml:"shutdown_timeout,omitempty"`
+
+	// 日志格式：legacy（默认）或 semconv
+	LogFormat string `yaml:"log_format,omitempty"`
+	// 记录中请求开始和结束时间（UTC，RFC3339）的精度：s、ms、us 或 ns（默认）
+	TimestampPrecision string `yaml:"timestamp_precision,omitempty"`
+
+	// 需要记录请求体的请求方法，默认 POST、PUT、PATCH、DELETE
+	CaptureMethods []string `yaml:"capture_methods,omitempty"`
+	// 按 GraphQL 请求解析的路径，支持通配符，默认 /graphql，设置为空列表时不解析。
+	// 记录操作名称、类型和规范化文档的哈希，变量按 redact_query_params 脱敏；操作名称和类型同时作为指标和 span 属性
+	GraphQLPaths []string `yaml:"graphql_paths,omitempty"`
+	// 以明文记录的请求体内容类型，支持通配符，例如 "text/*"、"application/*+json"。
+	// URL 编码的表单同时按字段记录，字段值按 redact_query_params 脱敏；
+	// multipart/form-data 总是只记录字段以及文件的文件名、内容类型和大小，不记录文件内容
+	CaptureContentTypes []string `yaml:"capture_content_types,omitempty"`
+	// 是否对不在明文列表中的较小请求体进行 base64 编码记录，否则只记录内容类型和大小
+	Base64BinaryBody  bool `yaml:"base64_binary_body,omitempty"`
+	MaxBinaryBodySize int  `yaml:"max_binary_body_size,omitempty"`
+	// 记录到日志中的请求体大小上限（字节），压缩的请求体按解压后的大小计算
+	MaxBodySize int `yaml:"max_body_size,omitempty"`
+	// 是否将请求体记录为 body 属性（semconv 格式为 http.request.body.content）而不是日志内容，日志内容改为
+	// "POST /api/orders 201 34ms" 形式的摘要。JSON 请求体解析为嵌套对象并按 redact_query_params 脱敏，
+	// 超过 body_max_depth 层（默认 5）的部分记录为 JSON 字符串；其余请求体记录为字符串
+	BodyAttribute bool `yaml:"body_attribute,omitempty"`
+	BodyMaxDepth  int  `yaml:"body_max_depth,omitempty"`
+
+	// 是否将请求体和响应体的前 span_body_max_size 个字节作为 server span 的事件记录，只记录被 trace 采样的请求。
+	// 请求体与日志使用相同的脱敏规则；响应体只记录 capture_content_types 中未压缩的内容，表单和 XML 按相同规则脱敏
+	SpanBodyEvents  bool `yaml:"span_body_events,omitempty"`
+	SpanBodyMaxSize int  `yaml:"span_body_max_size,omitempty"`
+
+	// 请求体记录方式：content（默认）记录内容；hash 只记录请求体的 SHA-256 摘要、大小和内容类型，不记录内容，
+	// 配置 body_hash_key 时摘要为 HMAC-SHA256。摘要按传输的原始字节（压缩的请求体不解压）计算，
+	// 在下一个处理器读取请求体时完成，处理器没有读完时只覆盖已读取的部分
+	BodyMode    string `yaml:"body_mode,omitempty"`
+	BodyHashKey string `yaml:"body_hash_key,omitempty"`
+
+	// 加密而不是隐去 JSON 请求体中的敏感字段：encrypt_json_fields 匹配的字段值（对象和数组整体）序列化后使用
+	// encrypt_recipient 加密为 age 格式，记录为 "age:" 加密文的 base64 编码，持有私钥的一方可以用 age -d 解密。
+	// 路径格式与 redact_xml_paths 相同，例如 "//card_number"、"/customer/ssn"；encrypt_recipient 为 age X25519 公钥（age1...）、
+	// ssh-rsa 公钥（RSA-OAEP），或包含这些公钥或 PEM 格式 RSA 公钥的文件。被截断或格式有误的 JSON 请求体不记录内容。
+	// 解码为 JSON 记录的 protobuf、gRPC-Web 请求体和 gRPC 消息同样加密
+	EncryptJSONFields []string `yaml:"encrypt_json_fields,omitempty"`
+	EncryptRecipient  string   `yaml:"encrypt_recipient,omitempty"`
+
+	// 审计模式：每条记录追加序号、上一条记录的签名和以 audit_key 计算的 HMAC-SHA256 签名，
+	// 形成哈希链，可以用 VerifyChain 校验记录没有被篡改、删除或插入
+	AuditMode bool   `yaml:"audit_mode,omitempty"`
+	AuditKey  string `yaml:"audit_key,omitempty"`
+
+	// 日志采样率，取值 0 到 1，默认 1 即记录所有请求
+	SampleRate float64 `yaml:"sample_rate,omitempty"`
+
+	// 自适应采样的目标，每个路由（包括未匹配路由的请求）每秒最多记录的条数，0 表示不开启。按每秒的请求速率
+	// 调整采样概率，负载升高时降低、流量回落时恢复，概率不超过 sample_rate；生效的采样率导出为
+	// recordrequestlog.sampling.rate 指标
+	TargetRecordsPerSecond float64 `yaml:"target_records_per_second,omitempty"`
+
+	// 标识调用方的请求头，默认 AppId，记录为 appid 属性。按调用方统计请求数和收发的字节数，
+	// 指标属性 app.id 最多区分 app_id_metrics_limit 个调用方（默认 100），其余计入 "_other"；
+	// app_id_sample_rates 按调用方覆盖日志采样率，优先于路由和顶层的 sample_rate
+	AppIDHeader       string             `yaml:"app_id_header,omitempty"`
+	AppIDMetricsLimit int                `yaml:"app_id_metrics_limit,omitempty"`
+	AppIDSampleRates  map[string]float64 `yaml:"app_id_sample_rates,omitempty"`
+
+	// 调试请求头，例如 "X-Debug-Capture"。携带该请求头的请求总是记录日志和 trace，记录所有请求方法和内容类型的请求体，
+	// 不受采样率、记录模式和 min_level 限制，记录带有 debug 属性。配置 debug_key 时请求头需要是 SignDebugToken
+	// 生成的令牌，debug_max_age（默认 1h）后失效；否则请求头不为空即可
+	DebugHeader string `yaml:"debug_header,omitempty"`
+	DebugKey    string `yaml:"debug_key,omitempty"`
+	DebugMaxAge string `yaml:"debug_max_age,omitempty"`
+
+	// 开启后收到请求时立即写入一条只包含请求属性的记录（event 为 request-received），响应完成时再写入完整的记录
+	// （event 为 response-completed），两者通过 request.id 关联，便于发现长时间未响应的请求。
+	// request_record_delay 大于 0 时在该时间后才写入请求记录，在此之前完成的请求只写入一条完整的记录；
+	// 请求记录只受采样率影响，log_mode 只作用于响应记录
+	RequestRecord      bool   `yaml:"request_record,omitempty"`
+	RequestRecordDelay string `yaml:"request_record_delay,omitempty"`
+
+	// 请求超过 hung_threshold 仍未完成时写入一条 warn 级别的记录（event 为 request-hung），附带处理该请求的
+	// goroutine 的栈（最多 hung_stack_size 个字节，默认 64KiB），不受采样和记录模式影响；为空时不检测
+	HungThreshold string `yaml:"hung_threshold,omitempty"`
+	HungStackSize int    `yaml:"hung_stack_size,omitempty"`
+
+	// 单条记录的字节数上限，0 表示不限制。超过 max_attribute_size 的字符串属性和超过 max_message_size 的消息
+	// 截断并追加 "...[truncated]"；估算大小超过 max_record_size 时先截断消息，再从最后一个属性开始丢弃，
+	// 丢弃的个数记录为 dropped-attributes
+	MaxAttributeSize int `yaml:"max_attribute_size,omitempty"`
+	MaxMessageSize   int `yaml:"max_message_size,omitempty"`
+	MaxRecordSize    int `yaml:"max_record_size,omitempty"`
+
+	// 合并 dedup_window 内相同的错误记录（状态码 4xx/5xx），相同指请求方法、路由（没有路由时为路径）、
+	// 状态码和请求体都相同。窗口内的第一条记录立即写入，其余在窗口结束时合并为一条记录，附带 repeat-count
+	// （semconv 格式为 repeat_count）属性；为空时不去重
+	DedupWindow string `yaml:"dedup_window,omitempty"`
+
+	// 日志记录模式：all（默认）、errors（只记录 4xx/5xx）、slow（只记录耗时超过 slow_threshold 的请求），
+	// 可以用逗号组合，例如 "errors,slow"；指标仍然统计所有请求
+	LogMode       string `yaml:"log_mode,omitempty"`
+	SlowThreshold string `yaml:"slow_threshold,omitempty"`
+	// OPTIONS、HEAD 以及带有 Access-Control-Request-Method 的 CORS 预检请求的记录方式：log（默认，与其他请求相同）、
+	// metrics（不写入日志，span 和指标照常记录）、drop（不写入日志也不创建 span，只计入请求指标）；调试请求总是记录
+	PreflightMode string `yaml:"preflight_mode,omitempty"`
+
+	// 延迟 SLO：耗时不超过 slo_latency_threshold 且不是 5xx 的请求达标，slo_objective 为目标达标比例（默认 0.999），
+	// 1 - slo_objective 为错误预算。按 Apdex 分区（不超过阈值 T 为 satisfied，不超过 4T 为 tolerating，其余为 frustrated）
+	// 计入 recordrequestlog.slo.requests，记录附带 slo-violation 和 apdex 属性；为空时不计算
+	SLOLatencyThreshold string  `yaml:"slo_latency_threshold,omitempty"`
+	SLOObjective        float64 `yaml:"slo_objective,omitempty"`
+
+	// 按响应状态码设置的日志级别，键为状态码类别（例如 "5xx"）或具体状态码，具体状态码优先；
+	// 默认 4xx 为 warn、5xx 为 error，其余为 info。min_level 以下的记录不导出，默认 debug 即不过滤
+	StatusLevels map[string]string `yaml:"status_levels,omitempty"`
+	MinLevel     string            `yaml:"min_level,omitempty"`
+
+	// 查询字符串中需要脱敏的参数名关键字，参数名包含任一关键字（不区分大小写）时值替换为 REDACTED；
+	// drop_raw_query 为 true 时记录的 URL 不包含查询字符串，只保留解析后的参数
+	RedactQueryParams []string `yaml:"redact_query_params,omitempty"`
+	DropRawQuery      bool     `yaml:"drop_raw_query,omitempty"`
+
+	// XML（包括 SOAP）请求体去掉注释和元素之间的空白后记录，redact_xml_paths 和 redact_query_params 匹配的元素内容
+	// 替换为 REDACTED。路径由元素的本地名称组成，* 匹配任意一个元素，// 匹配任意层级，例如 "//Password"；
+	// flatten_xml 为 true 时以叶子元素的路径和值代替请求体内容记录
+	RedactXMLPaths []string `yaml:"redact_xml_paths,omitempty"`
+	FlattenXML     bool     `yaml:"flatten_xml,omitempty"`
+
+	// 用于解码 protobuf 请求体（application/proto、application/grpc-web、application/connect+proto 等）的
+	// 描述符集合文件，由 protoc --include_imports --descriptor_set_out 生成。消息类型由内容类型的 proto 参数或
+	// /package.Service/Method 形式的路径确定，解码后按 JSON 记录并按 redact_query_params 脱敏；
+	// 找不到消息类型时按二进制内容处理。程序中生成代码注册的描述符总是可用
+	ProtoDescriptorSets []string `yaml:"proto_descriptor_sets,omitempty"`
+	// 通过 WithProtoFiles 注册的文件描述符
+	ProtoFiles []protoreflect.FileDescriptor `yaml:"-"`
+
+	// 是否从 Authorization 请求头的 Bearer token 中提取声明作为日志属性，不记录原始 token；
+	// 默认不校验签名，配置 jwt_jwks_url 后只记录签名校验通过且未过期的 token 的声明
+	ParseJWT               bool     `yaml:"parse_jwt,omitempty"`
+	JWTClaims              []string `yaml:"jwt_claims,omitempty"`
+	JWTJWKSURL             string   `yaml:"jwt_jwks_url,omitempty"`
+	JWTJWKSRefreshInterval string   `yaml:"jwt_jwks_refresh_interval,omitempty"`
+
+	// 按顺序组合的 trace 上下文传播格式：tracecontext、baggage、b3（单个请求头）、b3multi、jaeger、xray，
+	// 默认 tracecontext 和 baggage；注入时写入全部格式的请求头
+	Propagators []string `yaml:"propagators,omitempty"`
+
+	// 记录为日志和 span 属性的 W3C baggage 条目的 key，"*" 表示全部；为空时不记录。
+	// 日志中为 baggage 分组，span 属性为 baggage.<key>
+	BaggageKeys []string `yaml:"baggage_keys,omitempty"`
+
+	// 多租户时按租户写入不同的 stream：stream_name 中的 {tenant} 替换为 tenant_header 请求头的值，
+	// 请求头为空时使用 Bearer token 中 tenant_claim 声明的值，都没有时使用 default_tenant；
+	// 租户请求头应由可信的上游设置，否则客户端可以任意创建 stream。tenant_claim 需要配置 jwt_jwks_url 校验签名
+	TenantHeader  string `yaml:"tenant_header,omitempty"`
+	TenantClaim   string `yaml:"tenant_claim,omitempty"`
+	DefaultTenant string `yaml:"default_tenant,omitempty"`
+	// 允许的租户，其他租户使用 default_tenant；为空时允许前 max_tenants 个出现的租户（默认 100），之后的新租户使用 default_tenant
+	Tenants    []string `yaml:"tenants,omitempty"`
+	MaxTenants int      `yaml:"max_tenants,omitempty"`
+
+	// 可信代理的 IP 或 CIDR，只有直接连接的对端在列表中时才使用 Forwarded、X-Forwarded-For 和 X-Real-IP 中的客户端地址
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+	// 是否隐去客户端地址的末尾部分（IPv4 最后一段，IPv6 后 80 位）
+	AnonymizeClientIP bool `yaml:"anonymize_client_ip,omitempty"`
+	// 是否按内置规则解析 User-Agent，记录浏览器及版本、操作系统、设备类型（desktop、mobile、tablet、bot），
+	// 识别出爬虫和命令行客户端时记录 ua-bot（semconv 格式为 user_agent.bot）
+	ParseUserAgent bool `yaml:"parse_user_agent,omitempty"`
+	// MaxMind 格式（mmdb）的 GeoIP 数据库文件，配置时按客户端地址记录国家、地区和城市。
+	// 结果缓存 geoip_cache_size 条（默认 10000），未命中缓存的查询超过 geoip_timeout（默认 2ms）时本次不记录位置
+	GeoIPDatabase  string `yaml:"geoip_database,omitempty"`
+	GeoIPCacheSize int    `yaml:"geoip_cache_size,omitempty"`
+	GeoIPTimeout   string `yaml:"geoip_timeout,omitempty"`
+
+	// 请求 ID 的请求头，请求中没有时自动生成，并同时写入转发的请求和响应
+	RequestIDHeader string `yaml:"request_id_header,omitempty"`
+	// 请求头中以逗号分隔的 W3C traceparent（例如 "00-<trace-id>-<span-id>-01"），作为 server span 的链接，
+	// 例如 "X-Linked-Trace"，最多 8 个；为空时不读取
+	SpanLinkHeader string `yaml:"span_link_header,omitempty"`
+	// 大于 0 时记住调用方提供的请求 ID 第一次请求的 span，窗口内相同请求 ID 的请求链接到该 span，
+	// 并记录重试次数 retry-count（semconv 格式为 http.request.resend_count）；为空时不链接
+	RetryLinkWindow string `yaml:"retry_link_window,omitempty"`
+	// 返回 trace ID 的响应头，例如 "X-Trace-Id"，为空时不返回
+	TraceIDResponseHeader string `yaml:"trace_id_response_header,omitempty"`
+	// 记录为属性的响应头，例如 "Content-Type"、"X-Cache"、"X-RateLimit-Remaining"；Set-Cookie 总是记录为 REDACTED
+	CaptureResponseHeaders []string `yaml:"capture_response_headers,omitempty"`
+	// 是否记录重放请求需要的请求头 request-headers（semconv 格式为 http.request.header），供 cmd/rrl-replay 将
+	// 文件或本地缓冲中的记录导出为 HAR 或 curl 脚本；Authorization、Cookie 和匹配 redact_query_params 的请求头记录为 REDACTED
+	ReplayCapture bool `yaml:"replay_capture,omitempty"`
+
+	// 将请求的副本异步发送到 shadow_endpoint（例如 "https://staging.example.com"，请求路径追加在其路径之后），
+	// 不等待响应也不影响原请求。只镜像被日志采样且请求体完整记录的请求，再按 shadow_sample_rate 采样（默认 1）；
+	// 请求体与日志中的内容相同（表单、XML 已脱敏，encrypt_json_fields 已加密），查询参数按 redact_query_params 脱敏，
+	// 认证、Cookie 和匹配 redact_query_params 的请求头不发送。最多同时发送 shadow_concurrency 个（默认 8），
+	// 超出的副本丢弃；单个请求的超时为 shadow_timeout（默认 5s）
+	ShadowEndpoint    string  `yaml:"shadow_endpoint,omitempty"`
+	ShadowSampleRate  float64 `yaml:"shadow_sample_rate,omitempty"`
+	ShadowConcurrency int     `yaml:"shadow_concurrency,omitempty"`
+	ShadowTimeout     string  `yaml:"shadow_timeout,omitempty"`
+
+	// 是否捕获下一个处理器的 panic：记录调用栈和请求信息为 error 级别的日志并返回 500，
+	// repanic 为 true 时记录后重新 panic
+	RecoverPanics bool `yaml:"recover_panics,omitempty"`
+	Repanic       bool `yaml:"repanic,omitempty"`
+
+	// 中间件自身出错（fail_open 关闭时读取请求体失败，或捕获到 panic）时的响应。error_template 为响应体，
+	// 其中的 {message}、{code} 和 {request_id} 替换为错误信息、错误码和请求 ID，为空时返回 Reply 格式的 JSON；
+	// error_status 为读取请求体失败时写入的状态码，默认为 500，panic 总是返回 500；
+	// error_content_type 默认为 application/json。ErrorHandler 只能通过代码设置，例如 WithErrorHandler
+	ErrorTemplate    string       `yaml:"error_template,omitempty"`
+	ErrorStatus      int          `yaml:"error_status,omitempty"`
+	ErrorContentType string       `yaml:"error_content_type,omitempty"`
+	ErrorHandler     ErrorHandler `yaml:"-"`
+
+	// 导出 trace、metric 和日志时的压缩方式：none（默认）或 gzip，适用于 OTLP 和 openobserve 后端
+	Compression string `yaml:"compression,omitempty"`
+
+	// 日志导出后端：otlp-grpc（默认）、otlp-http、openobserve、stdout、stderr、file、kafka、loki、elasticsearch、syslog
+	Backend string `yaml:"backend,omitempty"`
+	// stdout 和 stderr 后端每条记录输出一行 JSON。console_fields 为输出的顶层字段（包括 time、level 和 msg），
+	// 为空时输出全部字段；console_field_names 重命名字段，例如 msg: message；
+	// console_time_format 为 rfc3339nano（默认）、rfc3339、unix、unix_ms 或 Go 的时间格式
+	ConsoleFields     []string          `yaml:"console_fields,omitempty"`
+	ConsoleFieldNames map[string]string `yaml:"console_field_names,omitempty"`
+	ConsoleTimeFormat string            `yaml:"console_time_format,omitempty"`
+
+	// file 后端写入的文件路径，每条记录一行 JSON
+	FilePath string `yaml:"file_path,omitempty"`
+	// 文件超过 file_max_size（字节）或到达 file_rotate_interval 的整点边界（例如 24h 为每天 0 点 UTC）时轮转，
+	// 备份文件名为 <name>-<UTC 时间>.<ext>，file_compress 为 true 时压缩为 .gz；
+	// 保留最近的 file_max_backups 个备份，并删除超过 file_max_age 的备份，为 0 时不限制
+	FileMaxSize        int64  `yaml:"file_max_size,omitempty"`
+	FileRotateInterval string `yaml:"file_rotate_interval,omitempty"`
+	FileCompress       bool   `yaml:"file_compress,omitempty"`
+	FileMaxBackups     int    `yaml:"file_max_backups,omitempty"`
+	FileMaxAge         string `yaml:"file_max_age,omitempty"`
+	// 使用其他后端时，后端创建失败（fail open）或拒绝记录（例如队列已满）时将记录写入 file_path
+	FileFallback bool `yaml:"file_fallback,omitempty"`
+
+	// kafka 后端的 broker 地址和 topic，topic 中的 {stream} 替换为记录的 stream；
+	// kafka_key 为消息的 key：request_id（默认）、tenant 或 none，同一 key 的消息写入同一分区
+	KafkaBrokers []string `yaml:"kafka_brokers,omitempty"`
+	KafkaTopic   string   `yaml:"kafka_topic,omitempty"`
+	KafkaKey     string   `yaml:"kafka_key,omitempty"`
+	// SASL 认证机制：PLAIN、SCRAM-SHA-256 或 SCRAM-SHA-512，为空时不认证
+	KafkaSASLMechanism string `yaml:"kafka_sasl_mechanism,omitempty"`
+	KafkaUsername      string `yaml:"kafka_username,omitempty"`
+	KafkaPassword      string `yaml:"kafka_password,omitempty"`
+	// 是否使用 TLS 连接 broker，kafka_tls_ca_file 为空时使用系统根证书
+	KafkaTLS                   bool   `yaml:"kafka_tls,omitempty"`
+	KafkaTLSCAFile             string `yaml:"kafka_tls_ca_file,omitempty"`
+	KafkaTLSInsecureSkipVerify bool   `yaml:"kafka_tls_insecure_skip_verify,omitempty"`
+
+	// loki 后端写入 endpoint 的 /loki/api/v1/push，organization 作为 X-Scope-OrgID 请求头。
+	// loki_labels 的键为标签名，值为标签来源：service、stream、level、route、status_class（例如 5xx）
+	// 或记录中的属性名，作为标签的属性不再写入日志行；默认使用 service_name、route 和 status_class
+	LokiLabels map[string]string `yaml:"loki_labels,omitempty"`
+
+	// elasticsearch 后端写入 endpoint 的 /_bulk，同样适用于 OpenSearch。索引名称中的 %{+yyyy.MM.dd}
+	// 按记录时间（UTC）替换，{stream} 替换为记录的 stream，默认为 requests-%{+yyyy.MM.dd}；
+	// 配置 API key 或用户名时代替 authorization
+	ElasticsearchIndex    string `yaml:"elasticsearch_index,omitempty"`
+	ElasticsearchAPIKey   string `yaml:"elasticsearch_api_key,omitempty"`
+	ElasticsearchUsername string `yaml:"elasticsearch_username,omitempty"`
+	ElasticsearchPassword string `yaml:"elasticsearch_password,omitempty"`
+
+	// syslog 后端的地址，例如 udp://rsyslog:514、tcp://rsyslog:601 或 tls://rsyslog:6514；
+	// facility 默认为 local0，app name 默认为服务名称，属性写入 ID 为 syslog_sd_id（默认 request@32473）的 structured data
+	SyslogAddress               string `yaml:"syslog_address,omitempty"`
+	SyslogFacility              string `yaml:"syslog_facility,omitempty"`
+	SyslogAppName               string `yaml:"syslog_app_name,omitempty"`
+	SyslogSDID                  string `yaml:"syslog_sd_id,omitempty"`
+	SyslogTLSCAFile             string `yaml:"syslog_tls_ca_file,omitempty"`
+	SyslogTLSInsecureSkipVerify bool   `yaml:"syslog_tls_insecure_skip_verify,omitempty"`
+
+	// 同时导出到的其他后端，每个后端可以单独设置过滤条件和采样率。配置后顶层后端和各后端分别使用
+	// async_* 配置的异步队列，一个后端阻塞或失败不影响其他后端
+	Sinks []SinkConfig `yaml:"sinks,omitempty"`
+
+	// 是否异步导出：记录放入有界队列后立即返回，队列满时按丢弃策略（drop-newest 或 drop-oldest）丢弃
+	Async           bool   `yaml:"async,omitempty"`
+	AsyncQueueSize  int    `yaml:"async_queue_size,omitempty"`
+	AsyncWorkers    int    `yaml:"async_workers,omitempty"`
+	AsyncDropPolicy string `yaml:"async_drop_policy,omitempty"`
+
+	// 导出失败时缓冲批次的本地目录，为空时不缓冲；超过大小上限（字节）时淘汰最早的批次，
+	// 导出恢复后按指数退避的间隔重放。配置 sinks 时各后端的批次缓冲在以 name 命名的子目录中，大小上限分别计算
+	SpoolDir              string `yaml:"spool_dir,omitempty"`
+	SpoolMaxSize          int64  `yaml:"spool_max_size,omitempty"`
+	SpoolRetryInterval    string `yaml:"spool_retry_interval,omitempty"`
+	SpoolMaxRetryInterval string `yaml:"spool_max_retry_interval,omitempty"`
+
+	// 导出失败时的重试策略：间隔从 retry_initial_interval 开始按指数增长到 retry_max_interval，并加入随机抖动，
+	// 超过 retry_max_elapsed_time 后放弃。retry_status_codes 为可重试的 gRPC 状态码名称（例如 "UNAVAILABLE"）
+	// 或 HTTP 状态码（例如 "503"），未配置的一类使用默认列表；otlp-http 后端固定重试 429、502、503、504，不能配置 HTTP 状态码
+	ExportRetry          bool     `yaml:"export_retry,omitempty"`
+	RetryInitialInterval string   `yaml:"retry_initial_interval,omitempty"`
+	RetryMaxInterval     string   `yaml:"retry_max_interval,omitempty"`
+	RetryMaxElapsedTime  string   `yaml:"retry_max_elapsed_time,omitempty"`
+	RetryStatusCodes     []string `yaml:"retry_status_codes,omitempty"`
+
+	// OTLP 和 HTTP 后端导出连接经过的代理，支持 http://、https://（HTTP CONNECT）和 socks5://，
+	// 例如 socks5://proxy:1080；export_proxy_username 不为空时覆盖地址中的认证信息。
+	// 为空时按 HTTPS_PROXY、HTTP_PROXY 和 NO_PROXY 环境变量选择代理
+	ExportProxy         string `yaml:"export_proxy,omitempty"`
+	ExportProxyUsername string `yaml:"export_proxy_username,omitempty"`
+	ExportProxyPassword string `yaml:"export_proxy_password,omitempty"`
+
+	// 连续导出失败达到阈值后熔断，熔断期间不再请求导出端，冷却时间过后放行一次探测；阈值为 0 时不熔断
+	CircuitBreakerThreshold int    `yaml:"circuit_breaker_threshold,omitempty"`
+	CircuitBreakerCooloff   string `yaml:"circuit_breaker_cooloff,omitempty"`
+
+	// 配置了多个 OTLP 端点时，当前端点连续导出失败 failover_threshold 次后切换到下一个端点；
+	// 使用备用端点期间每隔 failback_interval 探测一次主端点，导出成功后切回主端点
+	FailoverThreshold int    `yaml:"failover_threshold,omitempty"`
+	FailbackInterval  string `yaml:"failback_interval,omitempty"`
+
+	// 将请求路径转换为 http.route 的规则，按顺序匹配，使用第一条匹配的规则；
+	// collapse_path_ids 为 true 时，没有匹配规则的路径将数字、UUID 等路径段替换为 {id}。
+	// 框架适配器或 SetRoute 设置的路由模板优先
+	PathTemplates   []PathTemplateConfig `yaml:"path_templates,omitempty"`
+	CollapsePathIDs bool                 `yaml:"collapse_path_ids,omitempty"`
+
+	// 按响应状态码选择 stream 和请求体的记录级别，按顺序匹配，使用第一个匹配的规则，例如 5xx 写入 errors
+	// stream 并记录请求体，其余写入 access stream 只记录元数据。请求体仍按 capture_* 配置读取，
+	// 规则只决定记录时保留多少
+	StatusStreams []StatusStreamConfig `yaml:"status_streams,omitempty"`
+
+	// 按路由覆盖的配置，按顺序匹配，使用第一个匹配的路由
+	Routes []RouteConfig `yaml:"routes,omitempty"`
+
+	// 为每条请求记录追加自定义属性的回调，只能通过代码设置，例如 WithEnrichFunc
+	EnrichFunc EnrichFunc `yaml:"-"`
+	// 按规则为记录标记保留类别和保留时长（retention-class、retention-ttl 属性），下游存储（例如 OpenObserve、
+	// Elasticsearch ILM）据此选择生命周期策略，例如 audit=1y、access=30d、debug=3d；没有匹配的规则时不标记
+	RetentionClasses []RetentionClassConfig `yaml:"retention_classes,omitempty"`
+
+	// 导出前依次调用的记录处理器，只能通过代码设置，例如 WithRecordProcessor
+	RecordProcessors []RecordProcessor `yaml:"-"`
+	// 应用提供的 LoggerProvider，设置后代替 backend 导出请求日志，stream_name 不再作为导出请求头传递；
+	// 只能通过代码设置，例如 WithLoggerProvider
+	LoggerProvider otellog.LoggerProvider `yaml:"-"`
+
+	// 管理接口的路径前缀，例如 "/_recordrequestlog"，前缀下的请求不再转发给下一个处理器；
+	// 请求需要携带 Authorization: Bearer <admin_token>，为空时不开启
+	AdminPathPrefix string `yaml:"admin_path_prefix,omitempty"`
+	AdminToken      string `yaml:"admin_token,omitempty"`
+}
+
+// RouteConfig 按 host 和 path 匹配请求并覆盖顶层配置，未设置的字段沿用顶层配置
+type RouteConfig struct {
+	// 匹配条件，同时设置时需要全部满足；host 支持通配符，例如 "*.example.com"
+	Host       string `yaml:"host,omitempty"`
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+	PathRegex  string `yaml:"path_regex,omitempty" expand:"false"`
+
+	StreamName string   `yaml:"stream_name,omitempty"`
+	SampleRate *float64 `yaml:"sample_rate,omitempty"`
+	// 覆盖顶层的 target_records_per_second，0 表示该路由不开启自适应采样
+	TargetRecordsPerSecond *float64 `yaml:"target_records_per_second,omitempty"`
+	CaptureMethods         []string `yaml:"capture_methods,omitempty"`
+	CaptureContentTypes    []string `yaml:"capture_content_types,omitempty"`
+	Base64BinaryBody       *bool    `yaml:"base64_binary_body,omitempty"`
+	MaxBinaryBodySize      int      `yaml:"max_binary_body_size,omitempty"`
+	MaxBodySize            int      `yaml:"max_body_size,omitempty"`
+	LogMode                string   `yaml:"log_mode,omitempty"`
+	SlowThreshold          string   `yaml:"slow_threshold,omitempty"`
+	// 设置时整体替换顶层的 status_streams
+	StatusStreams []StatusStreamConfig `yaml:"status_streams,omitempty"`
+	// 覆盖顶层的 SLO，只设置其中一项时另一项沿用顶层设置
+	SLOLatencyThreshold string   `yaml:"slo_latency_threshold,omitempty"`
+	SLOObjective        *float64 `yaml:"slo_objective,omitempty"`
+}
+
+// PathTemplateConfig 路径模板规则，pattern 为正则表达式，template 中可以用 $1、${name} 引用分组，
+// 例如 pattern "^/users/\\d+$"、template "/users/{id}"
+type PathTemplateConfig struct {
+	Pattern  string `yaml:"pattern,omitempty" expand:"false"`
+	Template string `yaml:"template,omitempty" expand:"false"`
+}
+
+const (
+	defaultTraceBatchTimeout = time.Second
+	defaultMetricInterval    = 3 * time.Second
+	defaultLogExportTimeout  = 30 * time.Second
+	defaultLogBatchInterval  = time.Second
+	defaultLogQueueSize      = 2048
+	defaultLogMaxBatchSize   = 512
+	defaultShutdownTimeout   = 5 * time.Second
+	defaultExportMaxPending  = 256
+)
+
+func CreateConfig() *Config {
+	return &Config{
+		FailOpen:          true,
+		TraceBatchTimeout: defaultTraceBatchTimeout.String(),
+		TraceSampler:      SamplerParentBasedAlwaysOn,
+		TraceSampleRatio:  1,
+		MetricInterval:    defaultMetricInterval.String(),
+		LogExportTimeout:  defaultLogExportTimeout.String(),
+		LogBatchInterval:  defaultLogBatchInterval.String(),
+		ShutdownTimeout:   defaultShutdownTimeout.String(),
+		LogFormat:         LogFormatLegacy,
+		Propagators:       append([]string(nil), defaultPropagators...),
+		CaptureMethods:    append([]string(nil), defaultCaptureMethods...),
+		GraphQLPaths:      append([]string(nil), defaultGraphQLPaths...),
+
+		CaptureContentTypes: append([]string(nil), defaultCaptureContentTypes...),
+		MaxBinaryBodySize:   defaultMaxBinaryBodySize,
+		BodyMaxDepth:        defaultBodyMaxDepth,
+		MaxBodySize:         defaultMaxBodySize,
+		SpanBodyMaxSize:     defaultSpanBodyMaxSize,
+		SampleRate:          1,
+		ShadowSampleRate:    1,
+		LogMode:             LogModeAll,
+		SlowThreshold:       defaultSlowThreshold.String(),
+		StatusLevels:        maps.Clone(defaultStatusLevels),
+		LokiLabels:          maps.Clone(defaultLokiLabels),
+		RequestIDHeader:     defaultRequestIDHeader,
+		AppIDHeader:         defaultAppIDHeader,
+		RedactQueryParams:   append([]string(nil), defaultRedactQueryParams...),
+		JWTClaims:           append([]string(nil), defaultJWTClaims...),
+
+		JWTJWKSRefreshInterval: defaultJWKSRefreshInterval.String(),
+
+		AsyncQueueSize:  defaultAsyncQueueSize,
+		AsyncWorkers:    defaultAsyncWorkers,
+		AsyncDropPolicy: DropNewest,
+
+		ExportRetry:          true,
+		RetryInitialInterval: defaultRetryInitialInterval.String(),
+		RetryMaxInterval:     defaultRetryMaxInterval.String(),
+		RetryMaxElapsedTime:  defaultRetryMaxElapsedTime.String(),
+
+		CircuitBreakerThreshold: defaultCircuitBreakerThreshold,
+		CircuitBreakerCooloff:   defaultCircuitBreakerCooloff.String(),
+
+		FailoverThreshold: defaultFailoverThreshold,
+		FailbackInterval:  defaultFailbackInterval.String(),
+
+		SpoolMaxSize:          defaultSpoolMaxSize,
+		SpoolRetryInterval:    defaultSpoolRetryInterval.String(),
+		SpoolMaxRetryInterval: defaultSpoolMaxRetryInterval.String(),
+
+		DetectResource: true,
+		EnableLogs:     true,
+		EnableTraces:   true,
+		EnableMetrics:  true,
+		Exemplars:      true,
+	}
+}
+
+// parseDuration 解析配置中的时间间隔，未配置时使用默认值
+func parseDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, value)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,106 @@
+package recordrequestlog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 签名的调试令牌默认的有效期，以及允许的时钟偏差
+const (
+	defaultDebugMaxAge = time.Hour
+	debugClockSkew     = time.Minute
+)
+
+// debugAttrKey 调试请求的 span 属性，采样器据此总是采样
+const debugAttrKey = "recordrequestlog.debug"
+
+// SignDebugToken 生成 debug_header 的值：签发时间的 Unix 秒数和以 key 计算的 HMAC-SHA256，以点号分隔，
+// 例如 "1718000000.9f86d0..."；令牌在 debug_max_age 内有效
+func SignDebugToken(key string, issued time.Time) string {
+
+	ts := strconv.FormatInt(issued.Unix(), 10)
+	return ts + "." + debugSignature(key, ts)
+}
+
+func debugSignature(key, ts string) string {
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(ts))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// debugRequested 判断请求是否携带有效的调试请求头。没有配置 debug_key 时请求头不为空即可，
+// 否则需要是 SignDebugToken 生成的未过期令牌
+func (e *RecordRequestLog) debugRequested(req *http.Request) bool {
+
+	if e.debugHeader == "" {
+		return false
+	}
+
+	value := strings.TrimSpace(req.Header.Get(e.debugHeader))
+	if value == "" {
+		return false
+	}
+
+	if e.debugKey == "" {
+		return true
+	}
+
+	ts, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	issued := time.Unix(unix, 0)
+	if age := time.Since(issued); age > e.debugMaxAge || age < -debugClockSkew {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(debugSignature(e.debugKey, ts)))
+}
+
+// debug 返回调试请求使用的设置：记录所有请求方法和内容类型的请求体，不按采样率和记录模式过滤
+func (s *routeSettings) debug() *routeSettings {
+
+	settings := *s
+	settings.sampleRate = 1
+	settings.adaptive = nil
+	settings.logMode = nil
+	settings.captureAll = true
+	settings.base64Binary = true
+	settings.maxBinaryBodySize = settings.maxBodySize
+
+	return &settings
+}
+
+// debugContext 判断 ctx 是否属于调试请求
+func debugContext(ctx context.Context) bool {
+
+	x, _ := ctx.Value(exchangeKey{}).(*Exchange)
+	return x != nil && x.debug
+}
+
+// hasDebugAttr 判断 span 的初始属性中是否标记了调试请求
+func hasDebugAttr(attrs []attribute.KeyValue) bool {
+
+	for _, attr := range attrs {
+		if attr.Key == debugAttrKey && attr.Value.AsBool() {
+			return true
+		}
+	}
+
+	return false
+}
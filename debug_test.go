@@ -0,0 +1,69 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHeader(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.SampleRate = 0
+	cfg.TraceSampler = recordrequestlog.SamplerAlwaysOff
+	cfg.MinLevel = "error"
+	cfg.DebugHeader = "X-Debug-Capture"
+	cfg.DebugKey = "secret"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+
+	serve := func(token string) {
+		req := httptest.NewRequest(http.MethodDelete, "http://localhost/items", strings.NewReader("id=1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("X-Debug-Capture", token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 没有令牌、签名错误和过期的令牌不触发调试
+	serve("")
+	serve(recordrequestlog.SignDebugToken("other", time.Now()))
+	serve(recordrequestlog.SignDebugToken("secret", time.Now().Add(-2*time.Hour)))
+	serve("garbage")
+	if records := rec.Records(); len(records) != 0 {
+		t.Fatalf("expected no records without a valid debug token, got %d", len(records))
+	}
+	if spans := rec.Spans(); len(spans) != 0 {
+		t.Fatalf("expected no spans without a valid debug token, got %d", len(spans))
+	}
+
+	serve(recordrequestlog.SignDebugToken("secret", time.Now()))
+
+	record := rec.RequireRecords(t, 1)[0]
+	if v, _ := recordrequestlogtest.Attr(record, "debug"); !v.Bool() {
+		t.Fatal("expected debug attribute on the record")
+	}
+	// DELETE 不在 capture_methods 中，调试请求同样记录请求体
+	if v, _ := recordrequestlogtest.Attr(record, "body-size"); v.Int64() != 4 {
+		t.Fatalf("expected the request body to be captured, got %v", v)
+	}
+
+	span := rec.RequireSpan(t, http.MethodDelete)
+	if !span.SpanContext.IsSampled() {
+		t.Fatal("expected the debug span to be sampled")
+	}
+}
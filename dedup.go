@@ -0,0 +1,134 @@
+package recordrequestlog
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 同时去重的记录种类上限，超过时不再去重新的种类
+const maxDedupKeys = 10000
+
+// dedupKey 视为相同记录的条件：请求方法、路由（没有路由时为路径）、状态码和请求体的哈希
+type dedupKey struct {
+	method   string
+	route    string
+	status   int
+	bodyHash uint64
+}
+
+type dedupEntry struct {
+	count int
+	last  Record
+	ctx   context.Context
+}
+
+// deduper 合并 dedup_window 内相同的错误记录：窗口内的第一条记录立即写入，其余只计数，
+// 窗口结束时写入最后一条重复的记录，并附带 repeat-count 属性
+type deduper struct {
+	window time.Duration
+	// 重复次数的属性名，与日志格式一致
+	countKey string
+	emit     func(ctx context.Context, record Record)
+
+	mu      sync.Mutex
+	entries map[dedupKey]*dedupEntry
+	timers  map[dedupKey]*time.Timer
+}
+
+func newDeduper(window time.Duration, countKey string, emit func(ctx context.Context, record Record)) *deduper {
+	return &deduper{
+		window:   window,
+		countKey: countKey,
+		emit:     emit,
+		entries:  make(map[dedupKey]*dedupEntry),
+		timers:   make(map[dedupKey]*time.Timer),
+	}
+}
+
+// dedupKey 返回请求的去重条件
+func (x *Exchange) dedupKey(status int) dedupKey {
+
+	key := dedupKey{method: x.req.Method, route: x.route, status: status}
+	if key.route == "" {
+		key.route = x.req.URL.Path
+	}
+
+	if x.body != nil {
+		h := fnv.New64a()
+		h.Write([]byte(x.body.content))
+		key.bodyHash = h.Sum64()
+	}
+
+	return key
+}
+
+// admit 返回记录是否需要立即写入，窗口内重复的记录返回 false
+func (d *deduper) admit(ctx context.Context, key dedupKey, record Record) bool {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, ok := d.entries[key]; ok {
+		entry.count++
+		entry.last = record
+		entry.ctx = context.WithoutCancel(ctx)
+		return false
+	}
+
+	if len(d.entries) >= maxDedupKeys {
+		return true
+	}
+
+	d.entries[key] = &dedupEntry{}
+	d.timers[key] = time.AfterFunc(d.window, func() { d.flushKey(key) })
+
+	return true
+}
+
+// take 取出窗口结束的条目
+func (d *deduper) take(key dedupKey) *dedupEntry {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry := d.entries[key]
+	delete(d.entries, key)
+	delete(d.timers, key)
+
+	return entry
+}
+
+func (d *deduper) flushKey(key dedupKey) {
+	d.write(d.take(key))
+}
+
+func (d *deduper) write(entry *dedupEntry) {
+
+	if entry == nil || entry.count == 0 {
+		return
+	}
+
+	record := entry.last
+	record.Attrs = append(record.Attrs[:len(record.Attrs):len(record.Attrs)], slog.Int(d.countKey, entry.count))
+	d.emit(entry.ctx, record)
+}
+
+// flush 关闭时写入所有窗口尚未结束的重复记录
+func (d *deduper) flush() {
+
+	d.mu.Lock()
+	keys := make([]dedupKey, 0, len(d.timers))
+	for key, timer := range d.timers {
+		if timer.Stop() {
+			keys = append(keys, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, key := range keys {
+		d.flushKey(key)
+	}
+}
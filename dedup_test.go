@@ -0,0 +1,67 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.DedupWindow = "50ms"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/ok" {
+			return
+		}
+		rw.WriteHeader(http.StatusBadGateway)
+	}))
+
+	serve := func(path, body string) {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost"+path, strings.NewReader(body))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for i := 0; i < 5; i++ {
+		serve("/upstream", "same")
+	}
+	serve("/upstream", "other")
+	// 成功的请求不去重
+	serve("/ok", "same")
+	serve("/ok", "same")
+
+	if n := len(rec.Records()); n != 4 {
+		t.Fatalf("expected duplicates to be held back, got %d records", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(rec.Records()) < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	records := rec.RequireRecords(t, 5)
+	summary := records[4]
+	if v, _ := recordrequestlogtest.Attr(summary, "repeat-count"); v.Int64() != 4 {
+		t.Fatalf("expected repeat-count 4, got %v", v)
+	}
+	if v, _ := recordrequestlogtest.Attr(summary, "status"); v.Int64() != http.StatusBadGateway {
+		t.Fatalf("unexpected summary status %v", v)
+	}
+	for _, record := range records[:4] {
+		if _, ok := recordrequestlogtest.Attr(record, "repeat-count"); ok {
+			t.Fatal("expected repeat-count only on the summary record")
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package recordrequestlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// blockingSink 在 release 关闭前阻塞导出，模拟无响应的导出端
+type blockingSink struct {
+	noopSink
+	release chan struct{}
+	emitted chan Record
+}
+
+func (s *blockingSink) Emit(ctx context.Context, record Record) error {
+	<-s.release
+	s.emitted <- record
+	return nil
+}
+
+func TestExportTimeout(t *testing.T) {
+
+	cfg := CreateConfig()
+	cfg.Backend = BackendStdout
+	cfg.ExportTimeout = "20ms"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &blockingSink{release: make(chan struct{}), emitted: make(chan Record, 1)}
+	e := handler.(*RecordRequestLog)
+	e.sink = sink
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected export timeout to bound request latency, took %v", elapsed)
+	}
+
+	// 超时的记录在后台继续导出
+	close(sink.release)
+
+	select {
+	case <-sink.emitted:
+	case <-time.After(time.Second):
+		t.Fatal("expected record to be emitted in the background")
+	}
+}
+
+func TestExportMaxPending(t *testing.T) {
+
+	cfg := CreateConfig()
+	cfg.Backend = BackendStdout
+	cfg.ExportTimeout = "10ms"
+	cfg.ExportMaxPending = 1
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &blockingSink{release: make(chan struct{}), emitted: make(chan Record, 3)}
+	e := handler.(*RecordRequestLog)
+	e.sink = sink
+
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+	}
+
+	// 导出端阻塞时只有第一条记录在后台导出，其余记录被丢弃
+	close(sink.release)
+
+	select {
+	case <-sink.emitted:
+	case <-time.After(time.Second):
+		t.Fatal("expected the first record to be emitted in the background")
+	}
+
+	select {
+	case <-sink.emitted:
+		t.Fatal("expected records beyond export_max_pending to be dropped")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAsyncQueueFull(t *testing.T) {
+
+	for policy, survivor := range map[string]string{DropNewest: "second", DropOldest: "third"} {
+		t.Run(policy, func(t *testing.T) {
+
+			cfg := CreateConfig()
+			cfg.EnableTraces = false
+			cfg.Async = true
+			cfg.AsyncQueueSize = 1
+			cfg.AsyncWorkers = 1
+			cfg.AsyncDropPolicy = policy
+
+			sink := &blockingSink{release: make(chan struct{}), emitted: make(chan Record, 3)}
+			reader := sdkmetric.NewManualReader()
+			e, err := newRecordRequestLog(http.NotFoundHandler(), cfg, "demo-plugin", &TestExporters{Sink: sink, MetricReader: reader})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+
+			// 第一条记录被导出 goroutine 取出后阻塞，第二条留在队列中
+			e.sink.Emit(ctx, Record{Message: "first"})
+			deadline := time.Now().Add(time.Second)
+			for len(e.sink.(*asyncSink).queue) > 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("expected the worker to take the first record")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			e.sink.Emit(ctx, Record{Message: "second"})
+			e.sink.Emit(ctx, Record{Message: "third"})
+
+			if stats := e.Stats(); stats.Queued != 2 || stats.Dropped != 1 {
+				t.Errorf("expected 2 queued and 1 dropped record, got %+v", stats)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(ctx, &rm); err != nil {
+				t.Fatal(err)
+			}
+			var dropped int64
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name != "recordrequestlog.records.dropped" {
+						continue
+					}
+					for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+						reason, _ := point.Attributes.Value("reason")
+						p, _ := point.Attributes.Value("policy")
+						if reason.AsString() == "queue_full" && p.AsString() == policy {
+							dropped += point.Value
+						}
+					}
+				}
+			}
+			if dropped != 1 {
+				t.Errorf("expected 1 queue_full drop with policy %s, got %d", policy, dropped)
+			}
+
+			close(sink.release)
+			for _, want := range []string{"first", survivor} {
+				select {
+				case record := <-sink.emitted:
+					if record.Message != want {
+						t.Errorf("expected record %s, got %s", want, record.Message)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("expected record %s to be emitted", want)
+				}
+			}
+			if err := e.Shutdown(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if len(sink.emitted) != 0 {
+				t.Errorf("expected the dropped record not to be emitted, got %s", (<-sink.emitted).Message)
+			}
+		})
+	}
+}
+
+// closingSink 记录 Shutdown 是否被调用
+type closingSink struct {
+	*blockingSink
+	closed chan struct{}
+}
+
+func (s *closingSink) Shutdown(context.Context) error {
+	close(s.closed)
+	return nil
+}
+
+func TestAsyncShutdownDeadline(t *testing.T) {
+
+	cfg := CreateConfig()
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+	cfg.Async = true
+	cfg.AsyncWorkers = 1
+
+	sink := &closingSink{blockingSink: &blockingSink{release: make(chan struct{}), emitted: make(chan Record, 3)}, closed: make(chan struct{})}
+	e, err := newRecordRequestLog(http.NotFoundHandler(), cfg, "demo-plugin", &TestExporters{Sink: sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		e.sink.Emit(context.Background(), Record{})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := e.sink.Shutdown(ctx); err == nil {
+		t.Fatal("expected the deadline error")
+	}
+
+	select {
+	case <-sink.closed:
+	default:
+		t.Fatal("expected the underlying sink to be shut down after the deadline")
+	}
+
+	// 导出中的记录完成后队列计数归零
+	close(sink.release)
+	deadline := time.Now().Add(time.Second)
+	for e.Stats().Queued != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no queued records after shutdown, got %d", e.Stats().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMultiSinkQueues(t *testing.T) {
+
+	cfg := CreateConfig()
+	cfg.EnableTraces = false
+	cfg.Sinks = []SinkConfig{{Name: "archive", Backend: BackendFile, FilePath: filepath.Join(t.TempDir(), "archive.log")}}
+
+	sink := &blockingSink{release: make(chan struct{}), emitted: make(chan Record, 3)}
+	reader := sdkmetric.NewManualReader()
+	e, err := newRecordRequestLog(http.NotFoundHandler(), cfg, "demo-plugin", &TestExporters{Sink: sink, MetricReader: reader})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for range 3 {
+		e.sink.Emit(ctx, Record{})
+	}
+
+	// 顶层后端阻塞，archive 后端的记录很快写完，队列中的记录数为各后端之和
+	deadline := time.Now().Add(time.Second)
+	for e.Stats().Queued != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 queued records, got %d", e.Stats().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	depth := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "recordrequestlog.queue.depth" {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+				name, _ := point.Attributes.Value("sink")
+				depth[name.AsString()] = point.Value
+			}
+		}
+	}
+	if len(depth) != 2 || depth["backend"] != 3 || depth["archive"] != 0 {
+		t.Errorf("expected queue depth per sink, got %v", depth)
+	}
+
+	close(sink.release)
+	if err := e.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
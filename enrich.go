@@ -0,0 +1,62 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ResponseInfo 传给 EnrichFunc 的响应信息
+type ResponseInfo struct {
+	// StatusCode 响应状态码，出站请求没有收到响应时为 0
+	StatusCode int
+	Header     http.Header
+	// Size 响应体大小（字节），未知时为 -1
+	Size     int64
+	Duration time.Duration
+	// Route 路由模板，没有设置时为空
+	Route string
+	// Err 出站请求失败的错误
+	Err error
+}
+
+// EnrichFunc 为每条请求记录追加应用自定义的属性，例如订单号、功能开关。
+// 会被并发调用，不应修改 req；返回的属性追加在内置属性之后。键为 slog.LevelKey（"level"）的属性不作为属性记录，
+// 而是覆盖记录的级别（包括 status_levels 设置的级别），值可以是 slog.Level 或 "warn"、"fatal" 等级别名称
+type EnrichFunc func(req *http.Request, resp ResponseInfo) []slog.Attr
+
+// WithEnrichFunc 设置为请求记录追加属性的回调
+func WithEnrichFunc(fn EnrichFunc) Option {
+	return func(o *options) {
+		o.config.EnrichFunc = fn
+	}
+}
+
+// enrich 调用 EnrichFunc 追加属性，回调 panic 时只输出错误，不影响请求处理
+func (e *RecordRequestLog) enrich(record *Record, req *http.Request, resp ResponseInfo) {
+
+	if e.enrichFunc == nil {
+		return
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			e.logError("enrich record", fmt.Errorf("panic: %v", v))
+		}
+	}()
+
+	for _, attr := range e.enrichFunc(req, resp) {
+		if attr.Key != slog.LevelKey {
+			record.Attrs = append(record.Attrs, attr)
+			continue
+		}
+
+		level, err := levelAttr(attr)
+		if err != nil {
+			e.logError("enrich record", err)
+			continue
+		}
+		record.setLevel(level)
+	}
+}
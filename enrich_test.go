@@ -0,0 +1,62 @@
+package recordrequestlog_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+)
+
+func TestEnrichFunc(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	var got recordrequestlog.ResponseInfo
+	enrich := func(req *http.Request, resp recordrequestlog.ResponseInfo) []slog.Attr {
+		got = resp
+		return []slog.Attr{slog.String("order_id", req.Header.Get("X-Order-Id"))}
+	}
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option(), recordrequestlog.WithEnrichFunc(enrich))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Cache", "hit")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("created"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/orders", nil)
+	req.Header.Set("X-Order-Id", "A-1001")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec.RequireRecord(t, recordrequestlogtest.HasAttr("order_id", "A-1001"))
+
+	if got.StatusCode != http.StatusCreated || got.Size != int64(len("created")) || got.Header.Get("X-Cache") != "hit" {
+		t.Fatalf("unexpected response info %+v", got)
+	}
+}
+
+func TestEnrichFuncPanic(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	enrich := func(req *http.Request, resp recordrequestlog.ResponseInfo) []slog.Attr {
+		panic("boom")
+	}
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option(), recordrequestlog.WithEnrichFunc(enrich))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	// 回调 panic 时记录仍然导出
+	rec.RequireRecords(t, 1)
+}
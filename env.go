@@ -0,0 +1,124 @@
+package recordrequestlog
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// OpenTelemetry 标准的导出端环境变量，配置为空时使用
+const (
+	envOTLPEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envOTLPHeaders  = "OTEL_EXPORTER_OTLP_HEADERS"
+)
+
+// envPattern 匹配 ${VAR} 和 ${VAR:-default}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv 展开字符串中的环境变量引用，未设置或为空的变量使用默认值，没有默认值时替换为空字符串
+func expandEnv(s string) string {
+
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	return envPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		match := envPattern.FindStringSubmatch(ref)
+		if value := os.Getenv(match[1]); value != "" {
+			return value
+		}
+		return match[2]
+	})
+}
+
+// expandConfig 返回展开了所有字符串字段中环境变量引用的配置副本，不修改原配置；
+// 带有 expand:"false" 标签的字段（例如正则表达式和路径模板）保持原样。
+// endpoint 和 exporter_headers 为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 和 OTEL_EXPORTER_OTLP_HEADERS
+func expandConfig(config *Config) *Config {
+
+	expanded := reflect.New(reflect.TypeOf(*config))
+	expanded.Elem().Set(expandValue(reflect.ValueOf(*config)))
+	c := expanded.Interface().(*Config)
+
+	if c.Endpoint == "" {
+		c.Endpoint = os.Getenv(envOTLPEndpoint)
+	}
+
+	if len(c.ExporterHeaders) == 0 {
+		c.ExporterHeaders = parseOTLPHeaders(os.Getenv(envOTLPHeaders))
+	}
+
+	return c
+}
+
+// expandValue 复制 v 并展开其中的字符串，切片、map 和指针都会重新分配
+func expandValue(v reflect.Value) reflect.Value {
+
+	switch v.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(expandEnv(v.String())).Convert(v.Type())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(expandValue(v.Elem()))
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(expandValue(v.Index(i)))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), expandValue(iter.Value()))
+		}
+		return m
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("expand") == "false" {
+				continue
+			}
+			s.Field(i).Set(expandValue(v.Field(i)))
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// parseOTLPHeaders 解析 OTEL_EXPORTER_OTLP_HEADERS 格式的请求头，例如 "api-key=secret,tenant=acme"，值为 URL 编码
+func parseOTLPHeaders(value string) map[string]string {
+
+	if value == "" {
+		return nil
+	}
+
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+
+		if unescaped, err := url.PathUnescape(strings.TrimSpace(val)); err == nil {
+			headers[key] = unescaped
+		}
+	}
+
+	return headers
+}
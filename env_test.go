@@ -0,0 +1,85 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvExpansion(t *testing.T) {
+
+	type ingest struct {
+		path          string
+		authorization string
+		tenant        string
+	}
+
+	received := make(chan ingest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/_json") {
+			received <- ingest{req.URL.Path, req.Header.Get("Authorization"), req.Header.Get("X-Scope-OrgID")}
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("RRL_TEST_AUTH", "Basic c2VjcmV0")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Scope-OrgID=acme%20corp")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Organization = "default"
+	cfg.Authorization = "${RRL_TEST_AUTH}"
+	cfg.StreamName = "${RRL_TEST_STREAM:-requests}"
+	cfg.LogMaxBatchSize = 1
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Authorization != "${RRL_TEST_AUTH}" || cfg.Endpoint != "" {
+		t.Errorf("expected the caller's config to be left unchanged, got %q %q", cfg.Authorization, cfg.Endpoint)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "http://localhost/api", strings.NewReader(`{}`)))
+
+	select {
+	case got := <-received:
+		if got.path != "/api/default/requests/_json" {
+			t.Errorf("unexpected ingest path %q", got.path)
+		}
+		if got.authorization != "Basic c2VjcmV0" {
+			t.Errorf("unexpected authorization %q", got.authorization)
+		}
+		if got.tenant != "acme corp" {
+			t.Errorf("unexpected header from OTEL_EXPORTER_OTLP_HEADERS %q", got.tenant)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for ingest request")
+	}
+}
+
+func TestEnvExpansionSkipsPathTemplates(t *testing.T) {
+
+	t.Setenv("name", "not-a-group")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.PathTemplates = []recordrequestlog.PathTemplateConfig{
+		{Pattern: `^/users/(?P<name>\w+)$`, Template: "/users/${name}"},
+	}
+
+	record := captureRecord(t, cfg, httptest.NewRequest(http.MethodGet, "http://localhost/users/alice", nil))
+
+	if record["route"] != "/users/alice" {
+		t.Errorf("expected regexp group reference to be kept, got route %v", record["route"])
+	}
+}
@@ -0,0 +1,98 @@
+package recordrequestlog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// error_template 中的占位符
+const (
+	errorMessagePlaceholder   = "{message}"
+	errorCodePlaceholder      = "{code}"
+	errorRequestIDPlaceholder = "{request_id}"
+)
+
+// 读取请求体失败时默认返回的状态码
+const defaultErrorStatus = http.StatusInternalServerError
+
+// ErrorHandler 写入中间件自身出错时的响应，例如读取请求体失败或捕获到 panic，
+// 设置后 error_template、error_status 和 error_content_type 不再生效
+type ErrorHandler func(rw http.ResponseWriter, req *http.Request, err error)
+
+// WithErrorHandler 设置写入错误响应的回调
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(o *options) {
+		o.config.ErrorHandler = fn
+	}
+}
+
+// errorReply 中间件自身出错时的响应格式
+type errorReply struct {
+	handler     ErrorHandler
+	template    string
+	status      int
+	contentType string
+}
+
+func newErrorReply(config *Config) errorReply {
+
+	contentType := config.ErrorContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	status := config.ErrorStatus
+	if status == 0 {
+		status = defaultErrorStatus
+	}
+
+	return errorReply{
+		handler:     config.ErrorHandler,
+		template:    config.ErrorTemplate,
+		status:      status,
+		contentType: contentType,
+	}
+}
+
+// write 写入错误响应，code 为响应体中的错误码
+func (r errorReply) write(rw http.ResponseWriter, req *http.Request, status int, code int64, err error) {
+
+	if r.handler != nil {
+		r.handler(rw, req, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", r.contentType)
+	rw.WriteHeader(status)
+
+	requestID := RequestID(req.Context())
+	if r.template == "" {
+		reply := NewReply("", err.Error(), code)
+		reply.RequestID = requestID
+		json.NewEncoder(rw).Encode(reply)
+		return
+	}
+
+	// JSON 模板中的错误信息和请求 ID 按字符串转义，模板中需要自带引号；请求 ID 默认取自客户端的 X-Request-ID
+	message := err.Error()
+	if strings.Contains(r.contentType, "json") {
+		message = jsonEscape(message)
+		requestID = jsonEscape(requestID)
+	}
+
+	io.WriteString(rw, strings.NewReplacer(
+		errorMessagePlaceholder, message,
+		errorCodePlaceholder, strconv.FormatInt(code, 10),
+		errorRequestIDPlaceholder, requestID,
+	).Replace(r.template))
+}
+
+// jsonEscape 返回 JSON 字符串转义后不带引号的内容
+func jsonEscape(s string) string {
+
+	quoted, _ := json.Marshal(s)
+	return string(quoted[1 : len(quoted)-1])
+}
@@ -0,0 +1,115 @@
+package recordrequestlog_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+)
+
+func TestErrorTemplate(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.FailOpen = false
+	cfg.ErrorTemplate = `{"error":{"message":"{message}","code":{code},"request_id":"{request_id}"}}`
+	cfg.ErrorStatus = http.StatusBadGateway
+	cfg.ErrorContentType = "application/problem+json"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api", errReader{})
+	req.Header.Set("X-Request-ID", "req-1")
+
+	rw := httptest.NewRecorder()
+	middleware(http.NotFoundHandler()).ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", rw.Code)
+	}
+	if got := rw.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("unexpected content type %q", got)
+	}
+	if got, want := rw.Body.String(), `{"error":{"message":"broken body","code":500,"request_id":"req-1"}}`; got != want {
+		t.Errorf("expected body %s, got %s", want, got)
+	}
+}
+
+func TestErrorTemplateEscapesRequestID(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.FailOpen = false
+	cfg.ErrorTemplate = `{"message":"{message}","request_id":"{request_id}"}`
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestID := `req-1","admin":true,"x":"}`
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api", errReader{})
+	req.Header.Set("X-Request-ID", requestID)
+
+	rw := httptest.NewRecorder()
+	middleware(http.NotFoundHandler()).ServeHTTP(rw, req)
+
+	var reply map[string]any
+	if err := json.Unmarshal(rw.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("expected a JSON body, got %s: %v", rw.Body, err)
+	}
+	if len(reply) != 2 || reply["request_id"] != requestID {
+		t.Errorf("expected the request ID as a single string field, got %s", rw.Body)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.FailOpen = false
+	cfg.RecoverPanics = true
+
+	var handled []error
+	middleware, err := recordrequestlog.NewMiddleware(
+		recordrequestlog.WithConfig(cfg),
+		recordrequestlog.WithErrorHandler(func(rw http.ResponseWriter, req *http.Request, err error) {
+			if recordrequestlog.RequestID(req.Context()) == "" {
+				t.Error("expected the request ID in the handler context")
+			}
+			handled = append(handled, err)
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}),
+		rec.Option(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { panic("boom") }))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "http://localhost/api", errReader{}))
+	if rw.Code != http.StatusServiceUnavailable || rw.Body.Len() != 0 {
+		t.Errorf("expected the handler to write the body error response, got %d %q", rw.Code, rw.Body)
+	}
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the handler to write the panic response, got %d", rw.Code)
+	}
+
+	if len(handled) != 2 || handled[1].Error() != http.StatusText(http.StatusInternalServerError) {
+		t.Fatalf("expected body and panic errors, got %v", handled)
+	}
+	rec.RequireRecords(t, 2)
+}
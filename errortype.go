@@ -0,0 +1,65 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// 失败的分类，记录为 error.type；没有对应分类的 5xx 按 OTel 约定记录状态码
+const (
+	errorTypeClientError     = "client_error"
+	errorTypeUpstreamTimeout = "upstream_timeout"
+	errorTypeUpstreamRefused = "upstream_refused"
+	errorTypeBodyTooLarge    = "body_too_large"
+	errorTypePanic           = "panic"
+	errorTypeCanceled        = "canceled"
+)
+
+// classifyError 按 panic、context 状态、错误类型和状态码对失败分类，请求成功时返回空字符串；
+// err 为出站请求 RoundTrip 返回的错误，无法分类的错误记录为 _OTHER
+func classifyError(ctx context.Context, status int, err error, panicked bool) string {
+
+	var maxBytes *http.MaxBytesError
+
+	switch {
+	case panicked:
+		return errorTypePanic
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return errorTypeCanceled
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) || isTimeout(err):
+		return errorTypeUpstreamTimeout
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET):
+		return errorTypeUpstreamRefused
+	case errors.As(err, &maxBytes):
+		return errorTypeBodyTooLarge
+	case err != nil:
+		return semconv.ErrorTypeOther.Value.AsString()
+	}
+
+	switch {
+	case status == http.StatusRequestEntityTooLarge:
+		return errorTypeBodyTooLarge
+	case status == http.StatusGatewayTimeout:
+		return errorTypeUpstreamTimeout
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable:
+		return errorTypeUpstreamRefused
+	case status >= http.StatusInternalServerError:
+		return strconv.Itoa(status)
+	case status >= http.StatusBadRequest:
+		return errorTypeClientError
+	}
+
+	return ""
+}
+
+// isTimeout 判断是否为网络超时错误
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,51 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	timeout := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		status   int
+		err      error
+		panicked bool
+		want     string
+	}{
+		{"ok", context.Background(), http.StatusOK, nil, false, ""},
+		{"not found", context.Background(), http.StatusNotFound, nil, false, errorTypeClientError},
+		{"too large", context.Background(), http.StatusRequestEntityTooLarge, nil, false, errorTypeBodyTooLarge},
+		{"gateway timeout", context.Background(), http.StatusGatewayTimeout, nil, false, errorTypeUpstreamTimeout},
+		{"bad gateway", context.Background(), http.StatusBadGateway, nil, false, errorTypeUpstreamRefused},
+		{"internal", context.Background(), http.StatusInternalServerError, nil, false, "500"},
+		{"panic", context.Background(), http.StatusInternalServerError, nil, true, errorTypePanic},
+		{"canceled", canceled, http.StatusOK, nil, false, errorTypeCanceled},
+		{"refused", context.Background(), 0, fmt.Errorf("proxy: %w", refused), false, errorTypeUpstreamRefused},
+		{"timeout", context.Background(), 0, timeout, false, errorTypeUpstreamTimeout},
+		{"max bytes", context.Background(), 0, &http.MaxBytesError{Limit: 10}, false, errorTypeBodyTooLarge},
+		{"other", context.Background(), 0, errors.New("boom"), false, "_OTHER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.ctx, tt.status, tt.err, tt.panicked); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// localplugin 按 Traefik 加载本地插件的方式运行中间件：读取插件清单，将 testData
+// 写入 CreateConfig 返回的配置，再用 New 包装一个回显请求的后端。用于在不启动 Traefik 的情况下检查插件配置：
+//
+//	go run ./examples/localplugin -endpoint http://localhost:4318
+//	curl -d '{"id":1}' -H 'Content-Type: application/json' http://localhost:8080/api/orders
+//
+// 在 Traefik 中使用本地插件时，将仓库放在 plugins-local/src/github.com/sanyuanya/recordrequestlog，
+// 并使用同目录下的 traefik.yml 和 dynamic.yml
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"recordrequestlog/lite"
+	"recordrequestlog/plugin"
+)
+
+func main() {
+
+	manifestPath := flag.String("manifest", plugin.ManifestFile, "path to the plugin manifest")
+	endpoint := flag.String("endpoint", "", "override the endpoint from testData")
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+
+	f, err := os.Open(*manifestPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	manifest, err := plugin.ParseManifest(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := manifest.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	config := lite.CreateConfig()
+	if err := plugin.Decode(manifest.TestData, config); err != nil {
+		log.Fatal(err)
+	}
+	if *endpoint != "" {
+		config.Endpoint = *endpoint
+	}
+
+	backend := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		fmt.Fprintf(rw, "%s %s\n%s\n", req.Method, req.URL, body)
+	})
+
+	handler, err := lite.New(context.Background(), backend, config, "localplugin")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("%s (%s) listening on %s, exporting to %s", manifest.DisplayName, manifest.Import, *addr, config.Endpoint)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
@@ -0,0 +1,354 @@
+package recordrequestlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exchange 一次正在处理的请求，由 ServeHTTP 或框架适配器通过 Begin 和 End 驱动
+type Exchange struct {
+	e         *RecordRequestLog
+	span      trace.Span
+	start     time.Time
+	req       *http.Request
+	rw        *responseWriter
+	requestID string
+	settings  *routeSettings
+	sampled   bool
+	body      *capturedBody
+	route     string
+	// 调用方标识，取自 app_id_header
+	appID string
+	// 是否为携带 debug_header 的调试请求
+	debug bool
+	// 开启 request_record 时等待写入的请求记录
+	pending *pendingRecord
+	// 开启 hung_threshold 时检测未完成请求的计时器
+	hung *time.Timer
+	// 识别出的长连接类型，为空时表示普通请求
+	stream string
+	// 请求的 context 被取消时已经处理的时间（纳秒），stopAbort 停止等待取消
+	abortedAfter atomic.Int64
+	stopAbort    func() bool
+	// 开启 retry_link_window 时相同请求 ID 的重试次数，第一次请求为 0
+	retries int
+	// Bearer token 的解析结果
+	jwt jwtResult
+}
+
+type exchangeKey struct{}
+
+// Begin 开始记录请求：生成请求 ID、创建 server span、注入 trace 上下文并读取请求体。
+// 调用方需要使用返回的 ResponseWriter 和请求继续处理，处理完成后调用 End
+func (e *RecordRequestLog) Begin(rw http.ResponseWriter, req *http.Request) (*Exchange, http.ResponseWriter, *http.Request) {
+
+	x, req, err := e.begin(rw, req)
+	if err != nil {
+		e.logError("read request body", err)
+	}
+
+	return x, x.rw.outer, req
+}
+
+// End 结束记录请求，status 为 0 时使用写入 Begin 返回的 ResponseWriter 的状态码
+func (x *Exchange) End(status int) {
+
+	x.finish(status, nil)
+	x.span.End()
+}
+
+// SetRoute 设置请求匹配的路由模板，例如 "/users/:id"，记录为 http.route
+func (x *Exchange) SetRoute(route string) {
+
+	if x == nil || route == "" {
+		return
+	}
+
+	x.route = route
+	x.span.SetName(x.req.Method + " " + route)
+	x.span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+// SetRoute 为 ctx 所属的请求设置路由模板，ctx 不是由中间件处理的请求时不做任何事，
+// 例如在处理器中调用 SetRoute(req.Context(), "/users/{id}")
+func SetRoute(ctx context.Context, route string) {
+
+	x, _ := ctx.Value(exchangeKey{}).(*Exchange)
+	x.SetRoute(route)
+}
+
+// begin 开始记录请求，返回读取请求体的错误
+func (e *RecordRequestLog) begin(rw http.ResponseWriter, req *http.Request) (*Exchange, *http.Request, error) {
+
+	x := &Exchange{e: e, start: time.Now(), rw: newResponseWriter(rw)}
+	x.rw.onStatus = x.statusWritten
+	var suppliedID bool
+	x.requestID, suppliedID = e.requestID(rw, req)
+
+	// 整个请求使用同一份规则，UpdateConfig 不影响正在处理的请求
+	rules := e.rules.Load()
+
+	ctx := e.propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	pooled := getAttrs()
+	spanAttrs := append(*pooled,
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLPath(req.URL.Path),
+		attribute.String(requestIDKey, x.requestID),
+	)
+	spanAttrs = append(spanAttrs, newConnectionInfo(req).spanAttrs()...)
+	if rules.baggage != nil {
+		spanAttrs = append(spanAttrs, rules.baggage.spanAttrs(ctx)...)
+	}
+
+	x.debug = e.debugRequested(req)
+	if x.debug {
+		spanAttrs = append(spanAttrs, attribute.Bool(debugAttrKey, true))
+	}
+
+	// preflight_mode 为 drop 的请求不创建 span，使用不记录的空 span
+	var suppressed string
+	if !x.debug {
+		suppressed = e.suppressed(req)
+	}
+	if suppressed == PreflightModeDrop {
+		x.span = trace.SpanFromContext(context.Background())
+	} else {
+		links := x.spanLinks(req, suppliedID)
+		if x.retries > 0 {
+			spanAttrs = append(spanAttrs, attribute.Int("http.request.resend_count", x.retries))
+		}
+		ctx, x.span = e.tracer.Start(ctx, req.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(spanAttrs...),
+			trace.WithLinks(links...),
+		)
+		x.rememberSpan(suppliedID)
+	}
+	*pooled = spanAttrs
+	putAttrs(pooled)
+
+	// 将当前 span 的 trace 上下文传递给下一个处理器
+	e.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if sc := x.span.SpanContext(); e.traceIDHeader != "" && sc.HasTraceID() {
+		rw.Header().Set(e.traceIDHeader, sc.TraceID().String())
+	}
+
+	req = req.WithContext(context.WithValue(ctx, exchangeKey{}, x))
+	x.req = req
+
+	if rules.paths != nil {
+		x.SetRoute(rules.paths.route(req.URL.Path))
+	}
+
+	x.settings = rules.settings(req)
+	x.appID = req.Header.Get(e.appIDHeader)
+	x.sampled = e.sampledApp(rules, x.settings, x.appID) && suppressed == ""
+	if x.debug {
+		x.settings = x.settings.debug()
+		x.sampled = e.logging.Load()
+	}
+
+	// 未被采样的请求和 WebSocket 升级请求不读取请求体，开启 span_body_events 时被 trace 采样的请求同样读取
+	var err error
+	capture := x.sampled || (e.spanBodyEvents && x.span.IsRecording())
+	if capture && !isWebSocketUpgrade(req) && x.settings.shouldCaptureBody(req) {
+		x.body, err = e.captureBody(req, x.settings)
+	}
+
+	x.addRequestBodyEvent()
+	x.captureResponseSnippet()
+	x.scheduleRequestRecord()
+	x.watchHung()
+	x.watchAbort()
+
+	if x.body != nil && x.body.graphql != nil {
+		x.span.SetAttributes(x.body.graphql.metricAttrs()...)
+	}
+
+	if err == nil {
+		x.mirror()
+	}
+
+	return x, req, err
+}
+
+// finish 记录 span 状态和指标，并在请求完成后按记录模式写入日志；panic 总是记录，不受采样和记录模式影响
+func (x *Exchange) finish(status int, p *recoveredPanic) {
+
+	e := x.e
+	duration := time.Since(x.start)
+	defer x.body.release()
+	x.stopWatchHung()
+
+	// 客户端断开连接后请求的 context 已被取消，指标和记录使用不会被取消的 context 导出
+	abortedAfter, aborted := x.aborted()
+	ctx := context.WithoutCancel(x.req.Context())
+
+	if status == 0 {
+		status = x.rw.statusCode()
+		if aborted && x.rw.status == 0 {
+			status = statusClientClosedRequest
+		}
+	}
+
+	x.span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		x.span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	x.addResponseBodyEvent(ctx)
+
+	if aborted {
+		x.span.SetAttributes(attribute.Bool("http.request.aborted", true))
+	}
+
+	errorType := classifyError(x.req.Context(), status, nil, p != nil)
+	if errorType != "" {
+		x.span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+	}
+
+	if p != nil {
+		x.span.RecordError(fmt.Errorf("panic: %v", p.value), trace.WithAttributes(semconv.ExceptionStacktrace(string(p.stack))))
+		x.span.SetStatus(codes.Error, "panic")
+	}
+
+	pooled := getAttrs()
+	metricAttrs := append(*pooled,
+		semconv.HTTPRequestMethodKey.String(x.req.Method),
+		semconv.HTTPResponseStatusCode(status),
+		attribute.String(requestIDKey, x.requestID),
+	)
+	if x.route != "" {
+		metricAttrs = append(metricAttrs, semconv.HTTPRoute(x.route))
+	}
+	if x.body != nil && x.body.graphql != nil {
+		metricAttrs = append(metricAttrs, x.body.graphql.metricAttrs()...)
+	}
+	if errorType != "" {
+		metricAttrs = append(metricAttrs, semconv.ErrorTypeKey.String(errorType))
+	}
+	requestSize := x.req.ContentLength
+	if requestSize < 0 && x.body != nil {
+		requestSize = x.body.size
+	}
+
+	attrs := metric.WithAttributes(metricAttrs...)
+	e.requestDuration.Record(ctx, duration.Seconds(), attrs)
+	// 大小未知（分块传输且没有读取请求体）的请求不记录请求体大小
+	if requestSize >= 0 {
+		e.requestBodySize.Record(ctx, requestSize, attrs)
+	}
+	e.responseBodySize.Record(ctx, x.rw.size, attrs)
+	*pooled = metricAttrs
+	putAttrs(pooled)
+
+	// 长连接的持续时间不是请求延迟，不计入 SLO 和 Apdex
+	var (
+		sloViolation bool
+		apdexZone    string
+	)
+	if x.stream == "" {
+		sloViolation, apdexZone = e.recordSLO(ctx, x.settings, status, duration)
+	}
+	e.recordAppUsage(ctx, x.appID, requestSize, x.rw.size)
+
+	paired := x.resolvePendingRecord()
+
+	// 长连接在处理器返回时记录连接关闭，代替普通的请求记录
+	if x.stream != "" && p == nil {
+		x.emitStream(eventConnectionClosed, status)
+		return
+	}
+
+	if p == nil && (!x.sampled || !x.settings.shouldLog(status, duration)) {
+		return
+	}
+
+	record := e.completedRecord(x.req, x.body, x.settings, x.start, status, duration, false)
+	record.Attrs = append(record.Attrs, slog.String(requestIDKey, x.requestID))
+
+	if x.route != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("route", "http.route"), x.route))
+	}
+
+	if errorType != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("error-type", "error.type"), errorType))
+	}
+
+	if apdexZone != "" {
+		record.Attrs = append(record.Attrs, e.sloAttrs(sloViolation, apdexZone)...)
+	}
+
+	if x.retries > 0 {
+		record.Attrs = append(record.Attrs, e.retryAttr(x.retries))
+	}
+
+	if aborted {
+		record.Attrs = append(record.Attrs, e.abortAttrs(x.req.Context(), abortedAfter)...)
+	}
+
+	if attr, ok := x.timeToFirstByteAttr(); ok {
+		record.Attrs = append(record.Attrs, attr)
+	}
+
+	if x.rw.size > 0 {
+		record.Attrs = append(record.Attrs, slog.Int64(e.attrKey("response-size", "http.response.body.size"), x.rw.size))
+	}
+
+	if sc := x.span.SpanContext(); sc.IsValid() {
+		record.Attrs = append(record.Attrs,
+			slog.String(e.attrKey("trace-id", "trace_id"), sc.TraceID().String()),
+			slog.String(e.attrKey("span-id", "span_id"), sc.SpanID().String()),
+		)
+	}
+
+	if x.debug {
+		record.Attrs = append(record.Attrs, slog.Bool("debug", true))
+	}
+
+	if paired {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("event", "event.name"), eventResponseComplete))
+	}
+
+	if attr, ok := e.requestHeadersAttr(ctx, x.req.Header); ok {
+		record.Attrs = append(record.Attrs, attr)
+	}
+
+	if attr, ok := e.responseHeadersAttr(ctx, x.rw.Header()); ok {
+		record.Attrs = append(record.Attrs, attr)
+	}
+
+	if p != nil {
+		record.setLevel(slog.LevelError)
+		record.Attrs = append(record.Attrs, e.panicAttrs(p)...)
+	}
+
+	e.enrich(&record, x.req, ResponseInfo{
+		StatusCode: status,
+		Header:     x.rw.Header(),
+		Size:       x.rw.size,
+		Duration:   duration,
+		Route:      x.route,
+	})
+
+	// panic 和调试请求的记录不去重
+	if e.dedup != nil && p == nil && !x.debug && status >= http.StatusBadRequest {
+		if !e.dedup.admit(ctx, x.dedupKey(status), record) {
+			return
+		}
+	}
+
+	e.emit(ctx, record)
+}
@@ -0,0 +1,157 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestSetRoute(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(rw http.ResponseWriter, req *http.Request) {
+		recordrequestlog.SetRoute(req.Context(), "GET /users/{id}")
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), mux, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/users/42", nil))
+
+	records := readRecords(t, path)
+	if len(records) != 1 || records[0]["route"] != "GET /users/{id}" {
+		t.Errorf("unexpected records %v", records)
+	}
+
+	// 不在中间件中处理的请求不受影响
+	recordrequestlog.SetRoute(context.Background(), "/ignored")
+}
+
+func TestBeginEnd(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	e, err := recordrequestlog.NewRecorder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, rw, req := e.Begin(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/orders/7", nil))
+	x.SetRoute("/orders/:id")
+	if req.Header.Get("traceparent") == "" {
+		t.Error("expected traceparent to be injected")
+	}
+	rw.WriteHeader(http.StatusAccepted)
+	x.End(0)
+
+	records := readRecords(t, path)
+	if len(records) != 1 || records[0]["route"] != "/orders/:id" || records[0]["status"] != float64(http.StatusAccepted) {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestBodySizeMetrics(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.SizeBuckets = []float64{10, 100}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(strings.Repeat("a", 50)))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader("12345")))
+
+	tests := map[string][]uint64{
+		"http.server.request.body.size":  {1, 0, 0},
+		"http.server.response.body.size": {0, 1, 0},
+	}
+
+	for name, want := range tests {
+		points := rec.RequireMetric(t, name).Data.(metricdata.Histogram[int64]).DataPoints
+		if len(points) != 1 || !slices.Equal(points[0].Bounds, cfg.SizeBuckets) || !slices.Equal(points[0].BucketCounts, want) {
+			t.Fatalf("unexpected %s data points %+v", name, points)
+		}
+	}
+}
+
+func TestMetricViews(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.MetricViews = []recordrequestlog.MetricViewConfig{
+		{Instrument: "http.server.request.duration", Name: "http.requests", KeepAttributes: []string{"http.request.method"}, Buckets: []float64{1}},
+		{Instrument: "http.server.*.body.size", Drop: true},
+	}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	points := rec.RequireMetric(t, "http.requests").Data.(metricdata.Histogram[float64]).DataPoints
+	if len(points) != 1 || points[0].Attributes.Len() != 1 || !slices.Equal(points[0].Bounds, []float64{1}) {
+		t.Fatalf("unexpected data points %+v", points)
+	}
+
+	for _, m := range rec.Metrics(t) {
+		if m.Name == "http.server.request.duration" || strings.HasSuffix(m.Name, ".body.size") {
+			t.Errorf("unexpected metric %s", m.Name)
+		}
+	}
+}
+
+func TestErrorType(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	rec.RequireRecord(t, recordrequestlogtest.HasAttr("error-type", "upstream_timeout"))
+
+	span := rec.RequireSpan(t, http.MethodGet)
+	if !slices.Contains(span.Attributes, attribute.String("error.type", "upstream_timeout")) {
+		t.Fatalf("expected error.type on span, got %v", span.Attributes)
+	}
+
+	point := rec.RequireMetric(t, "http.server.request.duration").Data.(metricdata.Histogram[float64]).DataPoints[0]
+	if v, _ := point.Attributes.Value("error.type"); v.AsString() != "upstream_timeout" {
+		t.Fatalf("expected error.type on metric, got %v", point.Attributes)
+	}
+}
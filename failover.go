@@ -0,0 +1,290 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 默认连续导出失败多少次后切换到下一个端点，以及使用备用端点时探测主端点的间隔
+const (
+	defaultFailoverThreshold = 3
+	defaultFailbackInterval  = 30 * time.Second
+)
+
+// splitEndpoints 解析以逗号分隔的 endpoint，第一个为主端点
+func splitEndpoints(endpoint string) []string {
+
+	var endpoints []string
+	for _, e := range strings.Split(endpoint, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+
+	return endpoints
+}
+
+// failover 在多个 OTLP 端点之间切换：当前端点连续失败 threshold 次后切换到下一个端点；
+// 使用备用端点时每隔 failback 用一次导出探测主端点，成功后切回主端点。日志、trace 和指标共用同一个状态
+type failover struct {
+	endpoints []string
+	threshold int
+	failback  time.Duration
+	onSwitch  func(from, to string)
+
+	mu        sync.Mutex
+	active    int
+	failures  int
+	lastProbe time.Time
+}
+
+func newFailover(endpoints []string, threshold int, failback time.Duration, onSwitch func(from, to string)) *failover {
+
+	if threshold <= 0 {
+		threshold = defaultFailoverThreshold
+	}
+
+	return &failover{endpoints: endpoints, threshold: threshold, failback: failback, onSwitch: onSwitch}
+}
+
+// pick 返回本次导出使用的端点，probe 为 true 时表示探测主端点
+func (f *failover) pick() (i int, probe bool) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active != 0 && time.Since(f.lastProbe) >= f.failback {
+		f.lastProbe = time.Now()
+		return 0, true
+	}
+
+	return f.active, false
+}
+
+// report 记录端点 i 的导出结果
+func (f *failover) report(i int, probe bool, err error) {
+
+	f.mu.Lock()
+
+	from := f.active
+	switch {
+	case err == nil && probe:
+		f.active, f.failures = 0, 0
+	case err == nil:
+		if i == f.active {
+			f.failures = 0
+		}
+	case probe || i != f.active:
+	default:
+		f.failures++
+		if f.failures >= f.threshold {
+			f.active = (f.active + 1) % len(f.endpoints)
+			f.failures = 0
+			f.lastProbe = time.Now()
+		}
+	}
+	to := f.active
+
+	f.mu.Unlock()
+
+	if from != to && f.onSwitch != nil {
+		f.onSwitch(f.endpoints[from], f.endpoints[to])
+	}
+}
+
+// export 按当前端点导出。探测主端点失败，或本次失败导致切换端点时，在新的端点上重试一次
+func (f *failover) export(export func(i int) error) error {
+
+	i, probe := f.pick()
+	err := export(i)
+	f.report(i, probe, err)
+	if err == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	active := f.active
+	f.mu.Unlock()
+
+	if active == i {
+		return err
+	}
+
+	retryErr := export(active)
+	f.report(active, false, retryErr)
+	return retryErr
+}
+
+// onFailover 切换端点时记录指标并输出到本地
+func (e *RecordRequestLog) onFailover(from, to string) {
+	e.failovers.Add(context.Background(), 1, metric.WithAttributes(attribute.String("endpoint", to)))
+	e.logError("export", fmt.Errorf("failing over from %s to %s", from, to))
+}
+
+// setEndpoints 解析 endpoint，配置了多个端点时创建 failover；只支持一个端点的后端使用第一个端点
+func (e *RecordRequestLog) setEndpoints(endpoint string) {
+
+	e.endpoints = splitEndpoints(endpoint)
+	e.endpoint, e.failover = endpoint, nil
+	if len(e.endpoints) == 0 {
+		return
+	}
+
+	e.endpoint = e.endpoints[0]
+	if len(e.endpoints) > 1 {
+		e.failover = newFailover(e.endpoints, e.failoverThreshold, e.failbackInterval, e.onFailover)
+	}
+}
+
+// newEndpointExporters 为每个端点创建导出器
+func newEndpointExporters[T any](endpoints []string, create func(endpoint string) (T, error)) ([]T, error) {
+
+	exporters := make([]T, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		exp, err := create(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exp)
+	}
+
+	return exporters, nil
+}
+
+// newFailoverLogExporter 为每个端点创建日志导出器，只有一个端点时直接返回该导出器
+func (e *RecordRequestLog) newFailoverLogExporter(create func(endpoint string) (log.Exporter, error)) (log.Exporter, error) {
+
+	if e.failover == nil {
+		return create(e.endpoint)
+	}
+
+	exporters, err := newEndpointExporters(e.endpoints, create)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failoverLogExporter{exporters: exporters, failover: e.failover}, nil
+}
+
+// newFailoverSpanExporter 为每个端点创建 span 导出器，只有一个端点时直接返回该导出器
+func (e *RecordRequestLog) newFailoverSpanExporter(create func(endpoint string) (trace.SpanExporter, error)) (trace.SpanExporter, error) {
+
+	if e.failover == nil {
+		return create(e.endpoint)
+	}
+
+	exporters, err := newEndpointExporters(e.endpoints, create)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failoverSpanExporter{exporters: exporters, failover: e.failover}, nil
+}
+
+// newFailoverMetricExporter 为每个端点创建指标导出器，只有一个端点时直接返回该导出器
+func (e *RecordRequestLog) newFailoverMetricExporter(create func(endpoint string) (sdkmetric.Exporter, error)) (sdkmetric.Exporter, error) {
+
+	if e.failover == nil {
+		return create(e.endpoint)
+	}
+
+	exporters, err := newEndpointExporters(e.endpoints, create)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failoverMetricExporter{Exporter: exporters[0], exporters: exporters, failover: e.failover}, nil
+}
+
+// failoverLogExporter 按 failover 选择端点导出日志
+type failoverLogExporter struct {
+	exporters []log.Exporter
+	failover  *failover
+}
+
+func (x *failoverLogExporter) Export(ctx context.Context, records []log.Record) error {
+	return x.failover.export(func(i int) error { return x.exporters[i].Export(ctx, records) })
+}
+
+func (x *failoverLogExporter) Shutdown(ctx context.Context) error {
+
+	var err error
+	for _, exp := range x.exporters {
+		err = errors.Join(err, exp.Shutdown(ctx))
+	}
+
+	return err
+}
+
+func (x *failoverLogExporter) ForceFlush(ctx context.Context) error {
+
+	var err error
+	for _, exp := range x.exporters {
+		err = errors.Join(err, exp.ForceFlush(ctx))
+	}
+
+	return err
+}
+
+// failoverSpanExporter 按 failover 选择端点导出 span
+type failoverSpanExporter struct {
+	exporters []trace.SpanExporter
+	failover  *failover
+}
+
+func (x *failoverSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	return x.failover.export(func(i int) error { return x.exporters[i].ExportSpans(ctx, spans) })
+}
+
+func (x *failoverSpanExporter) Shutdown(ctx context.Context) error {
+
+	var err error
+	for _, exp := range x.exporters {
+		err = errors.Join(err, exp.Shutdown(ctx))
+	}
+
+	return err
+}
+
+// failoverMetricExporter 按 failover 选择端点导出指标，各端点的导出器配置相同，
+// temporality 和 aggregation 使用第一个导出器的设置
+type failoverMetricExporter struct {
+	sdkmetric.Exporter
+	exporters []sdkmetric.Exporter
+	failover  *failover
+}
+
+func (x *failoverMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return x.failover.export(func(i int) error { return x.exporters[i].Export(ctx, rm) })
+}
+
+func (x *failoverMetricExporter) ForceFlush(ctx context.Context) error {
+
+	var err error
+	for _, exp := range x.exporters {
+		err = errors.Join(err, exp.ForceFlush(ctx))
+	}
+
+	return err
+}
+
+func (x *failoverMetricExporter) Shutdown(ctx context.Context) error {
+
+	var err error
+	for _, exp := range x.exporters {
+		err = errors.Join(err, exp.Shutdown(ctx))
+	}
+
+	return err
+}
@@ -0,0 +1,97 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// fakeLogExporter 按 err 返回导出结果并统计导出次数
+type fakeLogExporter struct {
+	err     error
+	exports int
+}
+
+func (x *fakeLogExporter) Export(context.Context, []log.Record) error {
+	x.exports++
+	return x.err
+}
+
+func (x *fakeLogExporter) Shutdown(context.Context) error { return nil }
+
+func (x *fakeLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestSplitEndpoints(t *testing.T) {
+
+	got := splitEndpoints(" http://a:4317 ,http://b:4317,, ")
+	if want := []string{"http://a:4317", "http://b:4317"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFailover(t *testing.T) {
+
+	var switches []string
+	f := newFailover([]string{"primary", "standby"}, 2, 20*time.Millisecond, func(from, to string) {
+		switches = append(switches, from+"->"+to)
+	})
+
+	primary, standby := &fakeLogExporter{err: errors.New("unavailable")}, &fakeLogExporter{}
+	exp := &failoverLogExporter{exporters: []log.Exporter{primary, standby}, failover: f}
+
+	// 未达到阈值时返回主端点的错误
+	if err := exp.Export(context.Background(), nil); err == nil {
+		t.Fatal("expected primary error below the threshold")
+	}
+
+	// 达到阈值后切换到备用端点，并在备用端点上重试本次导出
+	if err := exp.Export(context.Background(), nil); err != nil {
+		t.Fatalf("expected retry on standby to succeed, got %v", err)
+	}
+	if primary.exports != 2 || standby.exports != 1 {
+		t.Fatalf("expected 2 primary and 1 standby exports, got %d and %d", primary.exports, standby.exports)
+	}
+
+	if err := exp.Export(context.Background(), nil); err != nil || primary.exports != 2 || standby.exports != 2 {
+		t.Fatalf("expected export on standby, got %v (%d, %d)", err, primary.exports, standby.exports)
+	}
+
+	// 探测主端点失败时仍由备用端点导出
+	time.Sleep(30 * time.Millisecond)
+	if err := exp.Export(context.Background(), nil); err != nil || primary.exports != 3 || standby.exports != 3 {
+		t.Fatalf("expected failed probe to fall back to standby, got %v (%d, %d)", err, primary.exports, standby.exports)
+	}
+
+	// 主端点恢复后切回
+	primary.err = nil
+	time.Sleep(30 * time.Millisecond)
+	if err := exp.Export(context.Background(), nil); err != nil || primary.exports != 4 || standby.exports != 3 {
+		t.Fatalf("expected probe to reach primary, got %v (%d, %d)", err, primary.exports, standby.exports)
+	}
+	if err := exp.Export(context.Background(), nil); err != nil || primary.exports != 5 {
+		t.Fatalf("expected export on primary after failback, got %v (%d)", err, primary.exports)
+	}
+
+	if want := []string{"primary->standby", "standby->primary"}; !reflect.DeepEqual(switches, want) {
+		t.Fatalf("expected switches %v, got %v", want, switches)
+	}
+}
+
+func TestSetEndpoints(t *testing.T) {
+
+	e := &RecordRequestLog{failoverThreshold: defaultFailoverThreshold, failbackInterval: defaultFailbackInterval}
+
+	e.setEndpoints("http://a:4317")
+	if e.endpoint != "http://a:4317" || e.failover != nil {
+		t.Fatalf("expected single endpoint without failover, got %q %v", e.endpoint, e.failover)
+	}
+
+	e.setEndpoints("http://a:4317,http://b:4317")
+	if e.endpoint != "http://a:4317" || e.failover == nil || len(e.failover.endpoints) != 2 {
+		t.Fatalf("expected failover across both endpoints, got %q %v", e.endpoint, e.failover)
+	}
+}
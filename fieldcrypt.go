@@ -0,0 +1,253 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/crypto/ssh"
+)
+
+// encryptedPrefix 加密后字段值的前缀，其后为 age 格式密文的 base64 编码
+const encryptedPrefix = "age:"
+
+// fieldEncryptor 使用公钥加密 JSON 请求体中匹配路径的字段。路径格式与 redact_xml_paths 相同，
+// 由对象的字段名组成，数组中的元素与数组使用同一路径
+type fieldEncryptor struct {
+	paths     [][]string
+	recipient age.Recipient
+}
+
+// newFieldEncryptor 没有配置 encrypt_json_fields 时返回 nil
+func newFieldEncryptor(config *Config) (*fieldEncryptor, error) {
+
+	if len(config.EncryptJSONFields) == 0 {
+		return nil, nil
+	}
+
+	paths, err := parsePaths("encrypt_json_fields", config.EncryptJSONFields)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.EncryptRecipient == "" {
+		return nil, fmt.Errorf("encrypt_recipient is required when encrypt_json_fields is set")
+	}
+
+	recipient, err := parseRecipient(config.EncryptRecipient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fieldEncryptor{paths: paths, recipient: recipient}, nil
+}
+
+// parseRecipient 解析 age X25519 公钥（age1...）或 ssh-rsa 公钥，其他值作为包含公钥或 PEM 格式 RSA 公钥的文件
+func parseRecipient(value string) (age.Recipient, error) {
+
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "age1") && !strings.HasPrefix(value, "ssh-") {
+		b, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("read encrypt_recipient: %w", err)
+		}
+		value = strings.TrimSpace(string(b))
+	}
+
+	switch {
+	case strings.HasPrefix(value, "age1"):
+		recipient, err := age.ParseX25519Recipient(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encrypt_recipient: %w", err)
+		}
+		return recipient, nil
+	case strings.HasPrefix(value, "ssh-"):
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid encrypt_recipient: %w", err)
+		}
+		return rsaRecipient(key)
+	}
+
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("invalid encrypt_recipient: expected an age1 or ssh-rsa public key or a PEM RSA public key")
+	}
+
+	var pub any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypt_recipient: %w", err)
+	}
+
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid encrypt_recipient: unsupported public key type %T", pub)
+	}
+
+	key, err := ssh.NewPublicKey(rsaKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypt_recipient: %w", err)
+	}
+
+	return rsaRecipient(key)
+}
+
+// rsaRecipient 使用 RSA-OAEP（SHA-256）加密文件密钥，只支持 RSA 公钥
+func rsaRecipient(key ssh.PublicKey) (age.Recipient, error) {
+
+	recipient, err := agessh.NewRSARecipient(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypt_recipient: %w", err)
+	}
+
+	return recipient, nil
+}
+
+// encrypt 将值序列化为 JSON 后加密
+func (f *fieldEncryptor) encrypt(value any) (string, error) {
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, f.recipient)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// encryptJSON 递归加密匹配路径的字段，stack 为从根对象到 v 的字段名，返回加密的字段数
+func (f *fieldEncryptor) encryptJSON(v any, stack []string) (int, error) {
+
+	n := 0
+
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			path := append(stack[:len(stack):len(stack)], key)
+			if !f.matched(path) {
+				m, err := f.encryptJSON(value, path)
+				if err != nil {
+					return n, err
+				}
+				n += m
+				continue
+			}
+
+			encrypted, err := f.encrypt(value)
+			if err != nil {
+				return n, err
+			}
+			v[key] = encrypted
+			n++
+		}
+	case []any:
+		for _, value := range v {
+			m, err := f.encryptJSON(value, stack)
+			if err != nil {
+				return n, err
+			}
+			n += m
+		}
+	}
+
+	return n, nil
+}
+
+func (f *fieldEncryptor) matched(path []string) bool {
+
+	for _, steps := range f.paths {
+		if matchXMLPath(steps, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encryptBody 加密 JSON 请求体中匹配 encrypt_json_fields 的字段。被截断或格式有误的请求体无法确定
+// 字段的位置，不记录内容，避免记录未加密的原始内容
+func (e *RecordRequestLog) encryptBody(ctx context.Context, body *capturedBody) {
+
+	if body.truncated {
+		body.content = ""
+		return
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body.content))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		body.content = ""
+		return
+	}
+
+	n, err := e.encryptor.encryptJSON(v, nil)
+	if err != nil {
+		e.logError("encrypt json fields", err)
+		body.content = ""
+		return
+	}
+	if n == 0 {
+		return
+	}
+
+	e.redactions.Add(ctx, int64(n), metric.WithAttributes(attribute.String("source", "json_encrypt")))
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		body.content = ""
+		return
+	}
+	body.content = string(b)
+}
+
+// encryptMessage 加密解码为 JSON 的 rpc 消息（包括 protobuf 和 gRPC-Web 请求体）中匹配 encrypt_json_fields 的字段，
+// 没有配置时返回 true；加密失败时返回 false，消息不应记录
+func (e *RecordRequestLog) encryptMessage(v any) bool {
+
+	if e.encryptor == nil {
+		return true
+	}
+
+	n, err := e.encryptor.encryptJSON(v, nil)
+	if err != nil {
+		e.logError("encrypt json fields", err)
+		return false
+	}
+
+	if n > 0 {
+		e.redactions.Add(context.Background(), int64(n), metric.WithAttributes(attribute.String("source", "json_encrypt")))
+	}
+
+	return true
+}
@@ -0,0 +1,228 @@
+package recordrequestlog_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+)
+
+func TestEncryptJSONFields(t *testing.T) {
+
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaIdentity, err := agessh.NewRSAIdentity(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "security.pub")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+
+	for _, tc := range []struct {
+		name      string
+		recipient string
+		identity  age.Identity
+	}{
+		{"x25519", x25519.Recipient().String(), x25519},
+		{"rsa-oaep", keyFile, rsaIdentity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.EncryptJSONFields = []string{"//card_number", "/customer/ssn"}
+			cfg.EncryptRecipient = tc.recipient
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var forwarded string
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				b, _ := io.ReadAll(req.Body)
+				forwarded = string(b)
+			}))
+
+			body := `{"customer":{"name":"Ada","ssn":{"number":"123-45-6789"}},"payments":[{"card_number":"4111111111111111"}]}`
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/orders", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if forwarded != body {
+				t.Fatalf("expected the original body to be forwarded, got %q", forwarded)
+			}
+
+			record := rec.RequireRecords(t, 1)[0]
+			if strings.Contains(record.Message, "4111111111111111") || strings.Contains(record.Message, "123-45-6789") {
+				t.Fatalf("expected sensitive fields to be encrypted, got %q", record.Message)
+			}
+
+			var logged struct {
+				Customer struct {
+					Name string `json:"name"`
+					SSN  string `json:"ssn"`
+				} `json:"customer"`
+				Payments []struct {
+					CardNumber string `json:"card_number"`
+				} `json:"payments"`
+			}
+			if err := json.Unmarshal([]byte(record.Message), &logged); err != nil {
+				t.Fatalf("expected a JSON message, got %q: %v", record.Message, err)
+			}
+
+			if logged.Customer.Name != "Ada" {
+				t.Errorf("expected unmatched fields to be kept, got %q", logged.Customer.Name)
+			}
+
+			decrypt := func(value string) string {
+				t.Helper()
+
+				ciphertext, ok := strings.CutPrefix(value, "age:")
+				if !ok {
+					t.Fatalf("expected an age: prefix, got %q", value)
+				}
+				b, err := base64.StdEncoding.DecodeString(ciphertext)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err := age.Decrypt(bytes.NewReader(b), tc.identity)
+				if err != nil {
+					t.Fatal(err)
+				}
+				plaintext, _ := io.ReadAll(r)
+				return string(plaintext)
+			}
+
+			if got := decrypt(logged.Payments[0].CardNumber); got != `"4111111111111111"` {
+				t.Errorf("unexpected decrypted card_number %s", got)
+			}
+			if got := decrypt(logged.Customer.SSN); got != `{"number":"123-45-6789"}` {
+				t.Errorf("unexpected decrypted ssn %s", got)
+			}
+		})
+	}
+}
+
+func TestEncryptJSONFieldsTruncated(t *testing.T) {
+
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.EncryptJSONFields = []string{"//card_number"}
+	cfg.EncryptRecipient = x25519.Recipient().String()
+	cfg.MaxBodySize = 16
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/orders", strings.NewReader(`{"card_number":"4111111111111111"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if record := rec.RequireRecords(t, 1)[0]; strings.Contains(record.Message, "4111") {
+		t.Fatalf("expected a truncated body not to be recorded, got %q", record.Message)
+	}
+}
+
+func TestEncryptProtoFields(t *testing.T) {
+
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, fd := orderFile(t)
+	msg := orderMessage(t, fd)
+
+	for contentType, body := range map[string][]byte{
+		"application/proto":          msg,
+		"application/grpc-web+proto": frame(msg),
+	} {
+		t.Run(contentType, func(t *testing.T) {
+
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.EncryptJSONFields = []string{"/id"}
+			cfg.EncryptRecipient = x25519.Recipient().String()
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), recordrequestlog.WithProtoFiles(fd), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/demo.v1.OrderService/CreateOrder", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+			record := rec.RequireRecord(t, recordrequestlogtest.HasAttr("body-encoding", "protojson"))
+			if strings.Contains(record.Message, "A-1001") {
+				t.Fatalf("expected the id to be encrypted, got %s", record.Message)
+			}
+
+			var logged struct {
+				ID    string `json:"id"`
+				Token string `json:"token"`
+			}
+			if err := json.Unmarshal([]byte(record.Message), &logged); err != nil {
+				t.Fatalf("expected a JSON message, got %q: %v", record.Message, err)
+			}
+			if logged.Token != "REDACTED" {
+				t.Errorf("expected the token to be redacted, got %q", logged.Token)
+			}
+
+			ciphertext, ok := strings.CutPrefix(logged.ID, "age:")
+			if !ok {
+				t.Fatalf("expected an age: prefix, got %q", logged.ID)
+			}
+			b, err := base64.StdEncoding.DecodeString(ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := age.Decrypt(bytes.NewReader(b), x25519)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plaintext, _ := io.ReadAll(r); string(plaintext) != `"A-1001"` {
+				t.Errorf("unexpected decrypted id %s", plaintext)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 按字段结构化记录的表单内容类型
+const (
+	formURLEncoded    = "application/x-www-form-urlencoded"
+	multipartFormData = "multipart/form-data"
+)
+
+// formFile multipart 请求中上传的文件，只记录元数据，不记录内容
+type formFile struct {
+	field       string
+	filename    string
+	contentType string
+	size        int64
+}
+
+// multipartForm 在下一个处理器读取请求体时解析 multipart 请求，文件内容直接丢弃，不缓存请求体
+type multipartForm struct {
+	body io.ReadCloser
+	pw   *io.PipeWriter
+	// 每个普通字段记录的值的大小上限
+	maxValueSize int
+
+	// 出站请求的请求体可能在 RoundTrip 返回后仍在读取
+	mu     sync.Mutex
+	closed bool
+
+	// 以下字段由解析的 goroutine 写入，done 关闭后读取
+	done     chan struct{}
+	values   url.Values
+	files    []formFile
+	complete bool
+}
+
+// newMultipartForm 替换请求体为解析 multipart 的读取器，boundary 无效时返回 nil
+func newMultipartForm(req *http.Request, contentType string, maxValueSize int) *multipartForm {
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	f := &multipartForm{
+		body:         req.Body,
+		pw:           pw,
+		maxValueSize: maxValueSize,
+		done:         make(chan struct{}),
+		values:       url.Values{},
+	}
+
+	go f.parse(multipart.NewReader(pr, params["boundary"]), pr)
+
+	req.Body = f
+	return f
+}
+
+func (f *multipartForm) parse(mr *multipart.Reader, pr *io.PipeReader) {
+
+	defer close(f.done)
+	// 解析失败后继续读取，避免阻塞下一个处理器读取请求体
+	defer io.Copy(io.Discard, pr)
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			f.complete = errors.Is(err, io.EOF)
+			return
+		}
+
+		if part.FileName() != "" {
+			size, _ := io.Copy(io.Discard, part)
+			f.files = append(f.files, formFile{
+				field:       part.FormName(),
+				filename:    part.FileName(),
+				contentType: part.Header.Get("Content-Type"),
+				size:        size,
+			})
+			continue
+		}
+
+		b, err := io.ReadAll(io.LimitReader(part, int64(f.maxValueSize)))
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, part)
+		f.values.Add(part.FormName(), string(b))
+	}
+}
+
+func (f *multipartForm) Read(p []byte) (int, error) {
+
+	n, err := f.body.Read(p)
+
+	f.mu.Lock()
+	if !f.closed {
+		f.pw.Write(p[:n])
+		if err == io.EOF {
+			f.pw.Close()
+			f.closed = true
+		}
+	}
+	f.mu.Unlock()
+
+	return n, err
+}
+
+func (f *multipartForm) Close() error {
+	return f.body.Close()
+}
+
+// wait 停止解析并等待解析结束；处理器没有读完请求体时只包含已读取的部分
+func (f *multipartForm) wait() {
+
+	f.mu.Lock()
+	if !f.closed {
+		f.pw.CloseWithError(io.ErrUnexpectedEOF)
+		f.closed = true
+	}
+	f.mu.Unlock()
+
+	<-f.done
+}
+
+// isForm 判断内容类型是否为 URL 编码的表单
+func isForm(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == formURLEncoded
+}
+
+// isMultipartForm 判断内容类型是否为 multipart/form-data
+func isMultipartForm(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == multipartFormData
+}
+
+// formAttrs 返回表单字段和上传文件的属性，字段值按查询参数的规则脱敏
+func (e *RecordRequestLog) formAttrs(ctx context.Context, redactor *queryRedactor, body *capturedBody) []slog.Attr {
+
+	var (
+		values url.Values
+		files  []formFile
+		attrs  []slog.Attr
+	)
+
+	switch {
+	case body.multipart != nil:
+		body.multipart.wait()
+		values, files = body.multipart.values, body.multipart.files
+		if !body.multipart.complete {
+			attrs = append(attrs, slog.Bool(e.attrKey("form-partial", "http.request.body.form_partial"), true))
+		}
+	case body.form:
+		values, _ = url.ParseQuery(body.content)
+	default:
+		return nil
+	}
+
+	if n := redactor.countValues(values); n > 0 {
+		e.redactions.Add(ctx, int64(n), metric.WithAttributes(attribute.String("source", "form")))
+	}
+
+	if attr, ok := redactor.valuesAttr(e.attrKey("form", "http.request.body.form"), values); ok {
+		attrs = append(attrs, attr)
+	}
+
+	if len(files) > 0 {
+		fileAttrs := make([]any, 0, len(files))
+		seen := make(map[string]int, len(files))
+		for _, file := range files {
+			// 同一字段上传多个文件时从第二个开始追加序号
+			key := file.field
+			if n := seen[file.field]; n > 0 {
+				key += "[" + strconv.Itoa(n) + "]"
+			}
+			seen[file.field]++
+
+			fileAttrs = append(fileAttrs, slog.Group(key,
+				slog.String("filename", file.filename),
+				slog.String(e.attrKey("content-type", "content_type"), file.contentType),
+				slog.Int64("size", file.size),
+			))
+		}
+		attrs = append(attrs, slog.Group(e.attrKey("files", "http.request.body.files"), fileAttrs...))
+	}
+
+	return attrs
+}
+
+// formContent 返回记录的表单内容：URL 编码的表单按查询参数的规则脱敏，multipart 请求不记录内容
+func formContent(redactor *queryRedactor, body *capturedBody) string {
+
+	if body.multipart != nil {
+		return ""
+	}
+
+	if body.form && body.content != "" {
+		return redactor.redactRaw(body.content)
+	}
+
+	return body.content
+}
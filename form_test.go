@@ -0,0 +1,130 @@
+package recordrequestlog_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+func TestFormBody(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received string
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		received = string(b)
+	}))
+
+	const form = "user=alice&password=hunter2&tag=a&tag=b"
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/login", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != form {
+		t.Fatalf("handler received %q", received)
+	}
+
+	record := rec.RequireRecord(t, recordrequestlogtest.HasAttr("form.user", "alice"))
+
+	if v, _ := recordrequestlogtest.Attr(record, "form.password"); v.String() != "REDACTED" {
+		t.Fatalf("password not redacted: %v", v)
+	}
+	if v, _ := recordrequestlogtest.Attr(record, "form.tag"); v.String() != "a,b" {
+		t.Fatalf("unexpected tag %v", v)
+	}
+	if want := "user=alice&password=REDACTED&tag=a&tag=b"; record.Message != want {
+		t.Fatalf("expected message %q, got %q", want, record.Message)
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("title", "report")
+	mw.WriteField("api_key", "k-123")
+	fw, _ := mw.CreateFormFile("upload", "report.pdf")
+	fw.Write(bytes.Repeat([]byte("%PDF"), 1000))
+	mw.Close()
+	payload := buf.String()
+
+	var uploaded int64
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+		uploaded = req.MultipartForm.File["upload"][0].Size
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/upload", strings.NewReader(payload))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if uploaded != 4000 {
+		t.Fatalf("handler received file of %d bytes", uploaded)
+	}
+
+	record := rec.RequireRecord(t, recordrequestlogtest.HasAttr("form.title", "report"))
+
+	if record.Message != "" || strings.Contains(record.Message, "%PDF") {
+		t.Fatalf("file content recorded: %q", record.Message)
+	}
+
+	for key, want := range map[string]string{
+		"form.api_key":          "REDACTED",
+		"files.upload.filename": "report.pdf",
+		"files.upload.size":     "4000",
+	} {
+		if v, _ := recordrequestlogtest.Attr(record, key); v.String() != want {
+			t.Errorf("%s: expected %q, got %q", key, want, v)
+		}
+	}
+
+	if _, ok := recordrequestlogtest.Attr(record, "form-partial"); ok {
+		t.Fatal("complete form marked partial")
+	}
+}
+
+func TestMultipartBodyUnread(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("title", "report")
+	mw.Close()
+
+	// 处理器没有读取请求体时只标记为 partial
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/upload", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec.RequireRecord(t, recordrequestlogtest.HasAttr("form-partial", "true"))
+}
@@ -0,0 +1,206 @@
+package recordrequestlog
+
+import (
+	"container/list"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP 查询的默认缓存条数和单次查询的时间预算
+const (
+	defaultGeoIPCacheSize = 10000
+	defaultGeoIPTimeout   = 2 * time.Millisecond
+)
+
+// geoIPRecord 数据库记录中用到的字段，兼容 GeoLite2/GeoIP2 City 和 Country 数据库
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// geoLocation 查询结果，数据库中没有的字段为空
+type geoLocation struct {
+	countryCode string
+	country     string
+	regionCode  string
+	region      string
+	city        string
+}
+
+// geoIP 按客户端地址查询 MaxMind 格式数据库。结果按地址缓存，超过条数上限时淘汰最久未使用的条目；
+// 未命中缓存的查询超过时间预算时本次不记录位置，查询在后台完成后写入缓存
+type geoIP struct {
+	reader  *maxminddb.Reader
+	timeout time.Duration
+	onError func(msg string, err error)
+
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	// 正在后台查询的地址，避免同一地址重复查询
+	pending map[string]chan struct{}
+}
+
+type geoIPEntry struct {
+	ip       string
+	location geoLocation
+}
+
+// newGeoIP 将数据库文件整体读入内存
+func newGeoIP(config *Config, onError func(msg string, err error)) (*geoIP, error) {
+
+	timeout, err := parseDuration("geoip_timeout", config.GeoIPTimeout, defaultGeoIPTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(config.GeoIPDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("read geoip_database: %w", err)
+	}
+
+	reader, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("open geoip_database %s: %w", config.GeoIPDatabase, err)
+	}
+
+	size := config.GeoIPCacheSize
+	if size <= 0 {
+		size = defaultGeoIPCacheSize
+	}
+
+	return &geoIP{
+		reader:  reader,
+		timeout: timeout,
+		onError: onError,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string]chan struct{}),
+	}, nil
+}
+
+// locate 返回地址的位置，超过时间预算或查询失败时返回 false
+func (g *geoIP) locate(ip string) (geoLocation, bool) {
+
+	g.mu.Lock()
+	if el, ok := g.entries[ip]; ok {
+		g.lru.MoveToFront(el)
+		location := el.Value.(*geoIPEntry).location
+		g.mu.Unlock()
+		return location, true
+	}
+
+	done, ok := g.pending[ip]
+	if !ok {
+		done = make(chan struct{})
+		g.pending[ip] = done
+		go g.lookup(ip, done)
+	}
+	g.mu.Unlock()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		return geoLocation{}, false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if el, ok := g.entries[ip]; ok {
+		return el.Value.(*geoIPEntry).location, true
+	}
+
+	return geoLocation{}, false
+}
+
+// lookup 查询数据库并写入缓存，没有记录的地址同样缓存为空结果
+func (g *geoIP) lookup(ip string, done chan struct{}) {
+
+	defer close(done)
+
+	var (
+		record   geoIPRecord
+		location geoLocation
+	)
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		g.finish(ip, location, false)
+		return
+	}
+
+	if err := g.reader.Lookup(addr, &record); err != nil {
+		g.onError("lookup geoip", err)
+		g.finish(ip, location, false)
+		return
+	}
+
+	location.countryCode = record.Country.ISOCode
+	location.country = record.Country.Names["en"]
+	if len(record.Subdivisions) > 0 {
+		location.regionCode = record.Subdivisions[0].ISOCode
+		location.region = record.Subdivisions[0].Names["en"]
+	}
+	location.city = record.City.Names["en"]
+
+	g.finish(ip, location, true)
+}
+
+func (g *geoIP) finish(ip string, location geoLocation, cache bool) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.pending, ip)
+	if !cache {
+		return
+	}
+
+	g.entries[ip] = g.lru.PushFront(&geoIPEntry{ip: ip, location: location})
+	for g.lru.Len() > g.size {
+		oldest := g.lru.Back()
+		g.lru.Remove(oldest)
+		delete(g.entries, oldest.Value.(*geoIPEntry).ip)
+	}
+}
+
+// attrs 返回位置属性，数据库中没有的字段不记录
+func (l geoLocation) attrs(e *RecordRequestLog) []slog.Attr {
+
+	var attrs []slog.Attr
+
+	for _, field := range []struct{ legacy, semconv, value string }{
+		{"geo-country-code", "geo.country.iso_code", l.countryCode},
+		{"geo-country", "geo.country.name", l.country},
+		{"geo-region-code", "geo.region.iso_code", l.regionCode},
+		{"geo-region", "geo.region.name", l.region},
+		{"geo-city", "geo.locality.name", l.city},
+	} {
+		if field.value != "" {
+			attrs = append(attrs, slog.String(e.attrKey(field.legacy, field.semconv), field.value))
+		}
+	}
+
+	return attrs
+}
@@ -0,0 +1,172 @@
+package recordrequestlog_test
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"sort"
+	"testing"
+)
+
+// writeTestMMDB 写入只包含 81.0.0.0/8 一条记录的 IPv4 数据库
+func writeTestMMDB(t *testing.T, record map[string]any) string {
+
+	const nodeCount = 8
+	const prefix = 81
+
+	var tree []byte
+	for i := 0; i < nodeCount; i++ {
+		next := uint32(i + 1)
+		if i == nodeCount-1 {
+			// 指向数据区第一个值，数据区前有 16 个字节的分隔
+			next = nodeCount + 16
+		}
+
+		left, right := uint32(nodeCount), uint32(nodeCount)
+		if prefix>>(7-i)&1 == 1 {
+			right = next
+		} else {
+			left = next
+		}
+		tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+	}
+
+	db := append(tree, make([]byte, 16)...)
+	db = append(db, encodeMMDB(record)...)
+	db = append(db, "\xAB\xCD\xEFMaxMind.com"...)
+	db = append(db, encodeMMDB(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               "GeoIP2-City",
+		"description":                 map[string]any{"en": "test"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, db, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// encodeMMDB 按 MaxMind DB 格式编码测试中用到的类型，长度都小于 29
+func encodeMMDB(v any) []byte {
+
+	control := func(typ, size int) []byte {
+		if typ <= 7 {
+			return []byte{byte(typ<<5 | size)}
+		}
+		return []byte{byte(size), byte(typ - 7)}
+	}
+
+	unsigned := func(typ int, n uint64, width int) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		b = b[8-width:]
+		for len(b) > 0 && b[0] == 0 {
+			b = b[1:]
+		}
+		return append(control(typ, len(b)), b...)
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(control(2, len(v)), v...)
+	case uint16:
+		return unsigned(5, uint64(v), 2)
+	case uint32:
+		return unsigned(6, uint64(v), 4)
+	case uint64:
+		return unsigned(9, v, 8)
+	case []any:
+		b := control(11, len(v))
+		for _, item := range v {
+			b = append(b, encodeMMDB(item)...)
+		}
+		return b
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b := control(7, len(v))
+		for _, key := range keys {
+			b = append(b, encodeMMDB(key)...)
+			b = append(b, encodeMMDB(v[key])...)
+		}
+		return b
+	default:
+		panic("unsupported type")
+	}
+}
+
+func TestGeoIP(t *testing.T) {
+
+	path := writeTestMMDB(t, map[string]any{
+		"country":      map[string]any{"iso_code": "GB", "names": map[string]any{"en": "United Kingdom"}},
+		"subdivisions": []any{map[string]any{"iso_code": "ENG", "names": map[string]any{"en": "England"}}},
+		"city":         map[string]any{"names": map[string]any{"en": "London"}},
+	})
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.GeoIPDatabase = path
+	// 测试中不受机器负载影响
+	cfg.GeoIPTimeout = "1s"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	for _, remote := range []string{"81.2.69.142:1234", "81.2.69.142:1235", "10.0.0.1:1234"} {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.RemoteAddr = remote
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	records := rec.RequireRecords(t, 3)
+
+	for _, record := range records[:2] {
+		for key, want := range map[string]string{
+			"geo-country-code": "GB",
+			"geo-country":      "United Kingdom",
+			"geo-region-code":  "ENG",
+			"geo-region":       "England",
+			"geo-city":         "London",
+		} {
+			if got, _ := recordrequestlogtest.Attr(record, key); got.String() != want {
+				t.Errorf("expected %s %q, got %q", key, want, got.String())
+			}
+		}
+	}
+
+	if _, ok := recordrequestlogtest.Attr(records[2], "geo-country-code"); ok {
+		t.Error("unexpected location for address not in database")
+	}
+}
+
+func TestGeoIPMissingDatabase(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.GeoIPDatabase = filepath.Join(t.TempDir(), "missing.mmdb")
+	cfg.FailOpen = false
+
+	if _, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg)); err == nil {
+		t.Fatal("expected error for missing geoip_database")
+	}
+}
@@ -0,0 +1,294 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 默认按 GraphQL 请求解析的路径
+var defaultGraphQLPaths = []string{"/graphql"}
+
+// graphQLOperation 从 GraphQL 请求中解析的操作信息
+type graphQLOperation struct {
+	name string
+	// query、mutation 或 subscription，文档中有多个操作且没有指定 operationName 时为空
+	kind string
+	// 去掉注释和多余空白后的文档的 SHA-256，用于按操作聚合；使用持久化查询时为其哈希
+	hash string
+	// 脱敏后的变量的 JSON
+	variables string
+}
+
+// graphQLRequest GraphQL over HTTP 的 JSON 请求体
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+	Extensions    struct {
+		PersistedQuery struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// isGraphQL 判断请求是否为发送到 graphql_paths 的 GraphQL POST 请求
+func (e *RecordRequestLog) isGraphQL(req *http.Request) bool {
+
+	if req.Method != http.MethodPost {
+		return false
+	}
+
+	for _, pattern := range e.graphQLPaths {
+		if ok, _ := path.Match(pattern, req.URL.Path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseGraphQL 解析 GraphQL 请求体，并将记录的请求体中的变量替换为脱敏后的变量；
+// 请求体被截断或不是 GraphQL 请求时返回 nil
+func (e *RecordRequestLog) parseGraphQL(ctx context.Context, body *capturedBody) *graphQLOperation {
+
+	if body.truncated || body.content == "" {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(body.contentType)
+
+	var gr graphQLRequest
+	switch mediaType {
+	case "application/graphql":
+		gr.Query = body.content
+	case "", "application/json":
+		if err := json.Unmarshal([]byte(body.content), &gr); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if gr.Query == "" && gr.Extensions.PersistedQuery.SHA256Hash == "" {
+		return nil
+	}
+
+	op := &graphQLOperation{name: gr.OperationName, hash: gr.Extensions.PersistedQuery.SHA256Hash}
+
+	if gr.Query != "" {
+		tokens := graphQLTokens(gr.Query)
+		sum := sha256.Sum256([]byte(strings.Join(tokens, " ")))
+		op.hash = hex.EncodeToString(sum[:])
+		op.kind, op.name = graphQLDefinition(tokens, gr.OperationName)
+	}
+
+	if len(gr.Variables) > 0 && !bytes.Equal(gr.Variables, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(gr.Variables))
+		decoder.UseNumber()
+
+		var variables any
+		if decoder.Decode(&variables) != nil {
+			return op
+		}
+
+		if n := e.rules.Load().query.redactJSON(variables); n > 0 {
+			e.redactions.Add(ctx, int64(n), metric.WithAttributes(attribute.String("source", "graphql_variables")))
+
+			// 记录的请求体中的变量同样需要脱敏
+			var fields map[string]any
+			decoder := json.NewDecoder(strings.NewReader(body.content))
+			decoder.UseNumber()
+			if decoder.Decode(&fields) == nil {
+				fields["variables"] = variables
+				if b, err := json.Marshal(fields); err == nil {
+					body.content = string(b)
+				}
+			}
+		}
+
+		if b, err := json.Marshal(variables); err == nil {
+			op.variables = string(b)
+		}
+	}
+
+	return op
+}
+
+// attrs 返回操作的日志属性
+func (op *graphQLOperation) attrs(e *RecordRequestLog) []slog.Attr {
+
+	attrs := []slog.Attr{slog.String(e.attrKey("graphql-document-hash", "graphql.document.sha256"), op.hash)}
+
+	if op.name != "" {
+		attrs = append(attrs, slog.String(e.attrKey("graphql-operation", "graphql.operation.name"), op.name))
+	}
+	if op.kind != "" {
+		attrs = append(attrs, slog.String(e.attrKey("graphql-operation-type", "graphql.operation.type"), op.kind))
+	}
+	if op.variables != "" {
+		attrs = append(attrs, slog.String(e.attrKey("graphql-variables", "graphql.variables"), op.variables))
+	}
+
+	return attrs
+}
+
+// metricAttrs 返回用于指标和 span 的操作属性，不包含变量和文档哈希
+func (op *graphQLOperation) metricAttrs() []attribute.KeyValue {
+
+	var attrs []attribute.KeyValue
+	if op.name != "" {
+		attrs = append(attrs, attribute.String("graphql.operation.name", op.name))
+	}
+	if op.kind != "" {
+		attrs = append(attrs, attribute.String("graphql.operation.type", op.kind))
+	}
+
+	return attrs
+}
+
+// graphQLDefinition 返回文档中要执行的操作的类型和名称：指定 name 时查找同名操作，
+// 否则文档中只能有一个操作；简写的匿名查询 "{ ... }" 视为 query
+func graphQLDefinition(tokens []string, name string) (string, string) {
+
+	type definition struct{ kind, name string }
+
+	var (
+		definitions  []definition
+		braces       int
+		parens       int
+		inDefinition bool
+	)
+
+	for i, token := range tokens {
+		switch token {
+		case "(":
+			parens++
+			continue
+		case ")":
+			parens--
+			continue
+		}
+
+		// 变量默认值中的对象不影响层级
+		if parens > 0 {
+			continue
+		}
+
+		switch token {
+		case "{":
+			if braces == 0 && !inDefinition {
+				definitions = append(definitions, definition{kind: "query"})
+			}
+			inDefinition = false
+			braces++
+		case "}":
+			braces--
+		case "query", "mutation", "subscription", "fragment":
+			if braces != 0 || inDefinition {
+				continue
+			}
+			inDefinition = true
+			if token == "fragment" {
+				continue
+			}
+
+			def := definition{kind: token}
+			if i+1 < len(tokens) && isGraphQLName(tokens[i+1]) {
+				def.name = tokens[i+1]
+			}
+			definitions = append(definitions, def)
+		}
+	}
+
+	for _, def := range definitions {
+		if name != "" && def.name == name {
+			return def.kind, def.name
+		}
+	}
+
+	if name == "" && len(definitions) == 1 {
+		return definitions[0].kind, definitions[0].name
+	}
+
+	return "", name
+}
+
+// graphQLTokens 将 GraphQL 文档拆分为词法单元，忽略空白、逗号和注释
+func graphQLTokens(doc string) []string {
+
+	var tokens []string
+
+	for i := 0; i < len(doc); {
+		c := doc[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := i + 3
+			for end < len(doc) && !strings.HasPrefix(doc[end:], `"""`) {
+				if strings.HasPrefix(doc[end:], `\"""`) {
+					end += 4
+					continue
+				}
+				end++
+			}
+			end = min(end+3, len(doc))
+			tokens = append(tokens, doc[i:end])
+			i = end
+		case c == '"':
+			end := i + 1
+			for end < len(doc) && doc[end] != '"' && doc[end] != '\n' {
+				if doc[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(doc))
+			tokens = append(tokens, doc[i:end])
+			i = end
+		case strings.HasPrefix(doc[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+			tokens = append(tokens, doc[i:i+1])
+			i++
+		default:
+			end := i
+			for end < len(doc) && strings.IndexByte(" \t\n\r,#\"!$&():=@[]{}|", doc[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, doc[i:end])
+			i = end
+		}
+	}
+
+	return tokens
+}
+
+// isGraphQLName 判断词法单元是否为名称
+func isGraphQLName(token string) bool {
+
+	for i, c := range token {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+
+	return token != ""
+}
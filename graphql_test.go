@@ -0,0 +1,136 @@
+package recordrequestlog_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestGraphQL(t *testing.T) {
+
+	const document = `
+		# 查询订单
+		query ListOrders($first: Int = 10) { orders(first: $first) { id } }
+		mutation Login($user: String!, $password: String!) { login(user: $user, password: $password) { token } }
+	`
+
+	tests := map[string]struct {
+		body      map[string]any
+		operation string
+		kind      string
+	}{
+		"named": {
+			body:      map[string]any{"query": document, "operationName": "Login", "variables": map[string]any{"user": "alice", "password": "hunter2"}},
+			operation: "Login",
+			kind:      "mutation",
+		},
+		"single": {
+			body:      map[string]any{"query": "subscription OnOrder { order { id } }"},
+			operation: "OnOrder",
+			kind:      "subscription",
+		},
+		"anonymous": {
+			body: map[string]any{"query": "{ me { id } }"},
+			kind: "query",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := recordrequestlogtest.New()
+
+			middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+			b, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/graphql", strings.NewReader(string(b)))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			record := rec.RequireRecords(t, 1)[0]
+
+			if v, ok := recordrequestlogtest.Attr(record, "graphql-operation"); ok != (tt.operation != "") || (ok && v.String() != tt.operation) {
+				t.Errorf("expected operation %q, got %q", tt.operation, v)
+			}
+			if v, _ := recordrequestlogtest.Attr(record, "graphql-operation-type"); v.String() != tt.kind {
+				t.Errorf("expected type %q, got %q", tt.kind, v)
+			}
+			if _, ok := recordrequestlogtest.Attr(record, "graphql-document-hash"); !ok {
+				t.Error("missing document hash")
+			}
+			if strings.Contains(record.Message, "hunter2") {
+				t.Errorf("variables not redacted in body: %s", record.Message)
+			}
+		})
+	}
+}
+
+func TestGraphQLRedactsVariables(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	body := `{"query":"mutation Login($user: String!, $password: String!) { login(user: $user, password: $password) }","variables":{"user":"alice","password":"hunter2"}}`
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	record := rec.RequireRecord(t, recordrequestlogtest.HasAttr("graphql-variables", `{"password":"REDACTED","user":"alice"}`))
+
+	// 操作名称和类型同时作为指标属性
+	m := rec.RequireMetric(t, "http.server.request.duration")
+	points := m.Data.(metricdata.Histogram[float64]).DataPoints
+	if name, _ := points[0].Attributes.Value("graphql.operation.name"); name.AsString() != "Login" {
+		t.Fatalf("unexpected metric attributes %v", points[0].Attributes)
+	}
+
+	if strings.Contains(record.Message, "hunter2") {
+		t.Fatalf("variables not redacted in body: %s", record.Message)
+	}
+}
+
+func TestGraphQLDocumentHash(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	// 空白、逗号和注释不同的文档哈希相同
+	for _, query := range []string{
+		`query Q { a, b }`,
+		"query Q {\n  # comment\n  a\n  b\n}",
+	} {
+		b, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/graphql", strings.NewReader(string(b)))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	records := rec.RequireRecords(t, 2)
+	first, _ := recordrequestlogtest.Attr(records[0], "graphql-document-hash")
+	second, _ := recordrequestlogtest.Attr(records[1], "graphql-document-hash")
+	if first.String() != second.String() {
+		t.Fatalf("hashes differ: %s != %s", first, second)
+	}
+}
@@ -0,0 +1,506 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnaryServerInterceptor 记录 gRPC 一元调用，请求和响应消息按 max_body_size 截断并按脱敏关键字隐去字段
+func (e *RecordRequestLog) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		call := e.startRPC(ctx, info.FullMethod, trace.SpanKindServer)
+		resp, err := handler(call.ctx, req)
+		call.end(err, req, resp)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor 记录 gRPC 流式调用，只记录收发的消息数量
+func (e *RecordRequestLog) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		call := e.startRPC(ss.Context(), info.FullMethod, trace.SpanKindServer)
+		err := handler(srv, &serverStream{ServerStream: ss, call: call})
+		call.end(err, nil, nil)
+		return err
+	}
+}
+
+// UnaryClientInterceptor 记录出站的 gRPC 一元调用，并将 trace 上下文注入 metadata
+func (e *RecordRequestLog) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+
+		call := e.startRPC(ctx, method, trace.SpanKindClient)
+		call.peer = cc.Target()
+		err := invoker(call.ctx, method, req, reply, cc, opts...)
+		call.end(err, req, reply)
+		return err
+	}
+}
+
+// StreamClientInterceptor 记录出站的 gRPC 流式调用，流结束（RecvMsg 返回错误，或者非服务端流的调用收到响应）
+// 或者 context 结束时写入日志
+func (e *RecordRequestLog) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+		call := e.startRPC(ctx, method, trace.SpanKindClient)
+		call.peer = cc.Target()
+		call.done = make(chan struct{})
+
+		cs, err := streamer(call.ctx, desc, cc, method, opts...)
+		if err != nil {
+			call.end(err, nil, nil)
+			return nil, err
+		}
+
+		// 调用方放弃读取的流在 context 取消或超时时结束记录，避免 span 泄漏
+		go func() {
+			select {
+			case <-call.done:
+			case <-call.ctx.Done():
+				call.end(status.FromContextError(call.ctx.Err()).Err(), nil, nil)
+			}
+		}()
+
+		return &clientStream{ClientStream: cs, call: call, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+// rpcCall 一次 gRPC 调用的记录状态
+type rpcCall struct {
+	e        *RecordRequestLog
+	ctx      context.Context
+	span     trace.Span
+	start    time.Time
+	kind     trace.SpanKind
+	service  string
+	method   string
+	peer     string
+	req      *http.Request
+	settings *routeSettings
+	sampled  bool
+
+	sent     atomic.Int64
+	received atomic.Int64
+	once     sync.Once
+	// done 调用结束后关闭，只用于客户端流
+	done chan struct{}
+}
+
+// startRPC 开始记录一次调用：服务端从 metadata 中提取 trace 上下文，客户端将其注入 metadata
+func (e *RecordRequestLog) startRPC(ctx context.Context, fullMethod string, kind trace.SpanKind) *rpcCall {
+
+	service, method := splitFullMethod(fullMethod)
+
+	var md metadata.MD
+	if kind == trace.SpanKindServer {
+		md, _ = metadata.FromIncomingContext(ctx)
+		ctx = e.propagator.Extract(ctx, metadataCarrier(md))
+	} else {
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+
+	rules := e.rules.Load()
+
+	spanAttrs := []attribute.KeyValue{semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(method)}
+	if rules.baggage != nil {
+		spanAttrs = append(spanAttrs, rules.baggage.spanAttrs(ctx)...)
+	}
+
+	ctx, span := e.tracer.Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(spanAttrs...),
+	)
+
+	if kind == trace.SpanKindClient {
+		md = md.Copy()
+		e.propagator.Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+
+	call := &rpcCall{
+		e:       e,
+		ctx:     ctx,
+		span:    span,
+		start:   time.Now(),
+		kind:    kind,
+		service: service,
+		method:  method,
+		req:     rpcRequest(ctx, fullMethod, md),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		call.peer = p.Addr.String()
+	}
+
+	// 路由按 :authority 和完整方法名匹配
+	call.settings = rules.settings(call.req)
+	call.sampled = e.sampled(call.settings)
+
+	return call
+}
+
+// end 结束调用，记录 span 状态、指标和日志，只执行一次
+func (c *rpcCall) end(err error, req, resp any) {
+
+	c.once.Do(func() {
+		c.finish(err, req, resp)
+		if c.done != nil {
+			close(c.done)
+		}
+	})
+}
+
+func (c *rpcCall) finish(err error, req, resp any) {
+
+	e := c.e
+	defer c.span.End()
+
+	duration := time.Since(c.start)
+	code := status.Code(err)
+
+	c.span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if rpcFailed(code, c.kind) {
+		c.span.SetStatus(codes.Error, code.String())
+	}
+
+	histogram := e.rpcServerDuration
+	if c.kind == trace.SpanKindClient {
+		histogram = e.rpcClientDuration
+	}
+	histogram.Record(c.ctx, float64(duration)/float64(time.Millisecond), metric.WithAttributes(
+		semconv.RPCSystemGRPC,
+		semconv.RPCService(c.service),
+		semconv.RPCMethod(c.method),
+		semconv.RPCGRPCStatusCodeKey.Int(int(code)),
+	))
+
+	if !c.sampled || !c.settings.shouldLog(httpStatusFromCode(code), duration) {
+		return
+	}
+
+	record := Record{
+		Time:  c.start,
+		Level: slog.LevelInfo,
+	}
+	e.setStream(&record, c.settings.streamName, c.req)
+
+	request, requestTruncated := e.rpcMessage(req, c.settings)
+	response, responseTruncated := e.rpcMessage(resp, c.settings)
+
+	if e.logFormat == LogFormatSemConv {
+		record.Message = c.service + "/" + c.method
+		record.Attrs = []slog.Attr{
+			slog.String("rpc.system", "grpc"),
+			slog.String("rpc.service", c.service),
+			slog.String("rpc.method", c.method),
+			slog.Int("rpc.grpc.status_code", int(code)),
+			slog.String("network.peer.address", c.peer),
+			slog.String("service.name", e.serverName),
+		}
+	} else {
+		record.Message = request
+		record.Attrs = []slog.Attr{
+			slog.String("rpc-service", c.service),
+			slog.String("rpc-method", c.method),
+			slog.String("grpc-code", code.String()),
+			slog.String("peer", c.peer),
+			slog.String("service", e.serverName),
+		}
+	}
+
+	durationKey := e.attrKey("duration-ms", "rpc.server.duration")
+	if c.kind == trace.SpanKindClient {
+		durationKey = e.attrKey("duration-ms", "rpc.client.duration")
+		record.Attrs = append(record.Attrs, slog.String("direction", "outbound"))
+	}
+	record.Attrs = append(record.Attrs, slog.Float64(durationKey, float64(duration)/float64(time.Millisecond)))
+
+	// 旧格式中请求消息作为日志内容
+	if request != "" && e.logFormat == LogFormatSemConv {
+		record.Attrs = append(record.Attrs, slog.String("rpc.request.body", request))
+	}
+	if response != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("response", "rpc.response.body"), response))
+	}
+	if requestTruncated || responseTruncated {
+		record.Attrs = append(record.Attrs, slog.Bool(e.attrKey("body-truncated", "rpc.body.truncated"), true))
+	}
+
+	if sent, received := c.sent.Load(), c.received.Load(); sent > 0 || received > 0 {
+		record.Attrs = append(record.Attrs,
+			slog.Int64(e.attrKey("messages-sent", "rpc.messages.sent"), sent),
+			slog.Int64(e.attrKey("messages-received", "rpc.messages.received"), received),
+		)
+	}
+
+	if e.jwt != nil {
+		record.Attrs = append(record.Attrs, e.jwt.attrs(e, c.req)...)
+	}
+
+	if code != grpccodes.OK {
+		record.setLevel(slog.LevelError)
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("error", "exception.message"), status.Convert(err).Message()))
+	}
+
+	e.emit(c.ctx, record)
+}
+
+// rpcMessage 将消息序列化为 JSON，隐去需要脱敏的字段并按大小上限截断
+func (e *RecordRequestLog) rpcMessage(msg any, settings *routeSettings) (string, bool) {
+
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return "", false
+	}
+
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return "", false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err == nil {
+		if n := e.rules.Load().query.redactJSON(v); n > 0 {
+			e.redactions.Add(context.Background(), int64(n), metric.WithAttributes(attribute.String("source", "rpc_message")))
+		}
+		if !e.encryptMessage(v) {
+			return "", false
+		}
+		if redacted, err := json.Marshal(v); err == nil {
+			data = redacted
+		} else if e.encryptor != nil {
+			return "", false
+		}
+	} else if e.encryptor != nil {
+		return "", false
+	}
+
+	if len(data) > settings.maxBodySize {
+		return string(data[:settings.maxBodySize]), true
+	}
+
+	return string(data), false
+}
+
+// redactJSON 递归隐去 JSON 对象中需要脱敏的字段，返回隐去的字段数
+func (r *queryRedactor) redactJSON(v any) int {
+
+	n := 0
+
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if r.redacted(key) {
+				v[key] = redactedValue
+				n++
+			} else {
+				n += r.redactJSON(value)
+			}
+		}
+	case []any:
+		for _, value := range v {
+			n += r.redactJSON(value)
+		}
+	}
+
+	return n
+}
+
+// serverStream 统计服务端流收发的消息数量
+type serverStream struct {
+	grpc.ServerStream
+	call *rpcCall
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.call.ctx
+}
+
+func (s *serverStream) SendMsg(m any) error {
+
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.sent.Add(1)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.received.Add(1)
+	}
+	return err
+}
+
+// clientStream 统计客户端流收发的消息数量，RecvMsg 返回错误时结束记录。
+// 非服务端流的调用只有一条响应，与 otelgrpc 一致在收到响应后结束
+type clientStream struct {
+	grpc.ClientStream
+	call          *rpcCall
+	serverStreams bool
+}
+
+func (s *clientStream) SendMsg(m any) error {
+
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.sent.Add(1)
+	} else if !errors.Is(err, io.EOF) {
+		s.call.end(err, nil, nil)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.call.received.Add(1)
+		if !s.serverStreams {
+			s.call.end(nil, nil, nil)
+		}
+	case errors.Is(err, io.EOF):
+		s.call.end(nil, nil, nil)
+	default:
+		s.call.end(err, nil, nil)
+	}
+	return err
+}
+
+// metadataCarrier 在 gRPC metadata 中读写 trace 上下文
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// rpcRequest 根据调用信息生成用于路由匹配、租户和 token 解析的请求
+func rpcRequest(ctx context.Context, fullMethod string, md metadata.MD) *http.Request {
+
+	req := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: fullMethod},
+		Header: make(http.Header, len(md)),
+	}
+
+	for key, values := range md {
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if authority := md.Get(":authority"); len(authority) > 0 {
+		req.Host = authority[0]
+	}
+
+	return req.WithContext(ctx)
+}
+
+// splitFullMethod 将 "/package.Service/Method" 拆分为服务名和方法名
+func splitFullMethod(fullMethod string) (string, string) {
+
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "", service
+	}
+	return service, method
+}
+
+// rpcFailed 按语义约定判断调用是否失败：客户端所有非 OK 状态都是失败，服务端只有服务自身的错误才是失败
+func rpcFailed(code grpccodes.Code, kind trace.SpanKind) bool {
+
+	if kind == trace.SpanKindClient {
+		return code != grpccodes.OK
+	}
+
+	switch code {
+	case grpccodes.Unknown, grpccodes.DeadlineExceeded, grpccodes.Unimplemented,
+		grpccodes.Internal, grpccodes.Unavailable, grpccodes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+// httpStatusFromCode 将 gRPC 状态码映射为 HTTP 状态码，用于按记录模式过滤
+func httpStatusFromCode(code grpccodes.Code) int {
+
+	switch code {
+	case grpccodes.OK:
+		return http.StatusOK
+	case grpccodes.Canceled:
+		return 499
+	case grpccodes.InvalidArgument, grpccodes.FailedPrecondition, grpccodes.OutOfRange:
+		return http.StatusBadRequest
+	case grpccodes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case grpccodes.NotFound:
+		return http.StatusNotFound
+	case grpccodes.AlreadyExists, grpccodes.Aborted:
+		return http.StatusConflict
+	case grpccodes.PermissionDenied:
+		return http.StatusForbidden
+	case grpccodes.Unauthenticated:
+		return http.StatusUnauthorized
+	case grpccodes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case grpccodes.Unimplemented:
+		return http.StatusNotImplemented
+	case grpccodes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
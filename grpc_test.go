@@ -0,0 +1,273 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	traceparent string
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("traceparent")) > 0 {
+		s.traceparent = md.Get("traceparent")[0]
+	}
+
+	if req.Service == "missing" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// newRecorder 创建写入临时文件的记录器
+func newRecorder(t *testing.T, configure func(*recordrequestlog.Config)) (*recordrequestlog.RecordRequestLog, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.RedactQueryParams = []string{"service"}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	e, err := recordrequestlog.NewRecorder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e, path
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+
+	server, serverLog := newRecorder(t, nil)
+	client, clientLog := newRecorder(t, func(cfg *recordrequestlog.Config) {
+		cfg.LogMode = recordrequestlog.LogModeErrors
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	health := &healthServer{}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(server.UnaryServerInterceptor()))
+	healthpb.RegisterHealthServer(srv, health)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(client.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hc := healthpb.NewHealthClient(conn)
+	if _, err := hc.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hc.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	if health.traceparent == "" {
+		t.Error("expected traceparent in incoming metadata")
+	}
+
+	records := readRecords(t, serverLog)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 server records, got %d", len(records))
+	}
+
+	ok := records[0]
+	if ok["rpc-service"] != "grpc.health.v1.Health" || ok["rpc-method"] != "Check" || ok["grpc-code"] != "OK" {
+		t.Errorf("unexpected record %v", ok)
+	}
+	if ok["msg"] != `{"service":"REDACTED"}` || ok["response"] != `{"status":"SERVING"}` {
+		t.Errorf("unexpected messages %v / %v", ok["msg"], ok["response"])
+	}
+
+	if failed := records[1]; failed["level"] != "error" || failed["grpc-code"] != "NotFound" {
+		t.Errorf("unexpected record %v", failed)
+	}
+
+	// 客户端只记录失败的调用
+	records = readRecords(t, clientLog)
+	if len(records) != 1 || records[0]["direction"] != "outbound" || records[0]["grpc-code"] != "NotFound" {
+		t.Errorf("unexpected client records %v", records)
+	}
+}
+
+// streamServer 实现服务端流、客户端流和双向流调用
+type streamServer struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (s *streamServer) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream grpc.ServerStreamingServer[testpb.StreamingOutputCallResponse]) error {
+
+	for range req.ResponseParameters {
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *streamServer) StreamingInputCall(stream grpc.ClientStreamingServer[testpb.StreamingInputCallRequest, testpb.StreamingInputCallResponse]) error {
+
+	for {
+		if _, err := stream.Recv(); errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{})
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *streamServer) FullDuplexCall(stream grpc.BidiStreamingServer[testpb.StreamingOutputCallRequest, testpb.StreamingOutputCallResponse]) error {
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{}); err != nil {
+			return err
+		}
+	}
+}
+
+// waitRecords 等待日志文件中出现 n 条记录，按方法名返回
+func waitRecords(t *testing.T, path string, n int) map[string]map[string]any {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records := readRecords(t, path)
+		if len(records) >= n {
+			methods := make(map[string]map[string]any, len(records))
+			for _, record := range records {
+				methods[record["rpc-method"].(string)] = record
+			}
+			return methods
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d records, got %v", n, records)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGRPCStreamInterceptors(t *testing.T) {
+
+	server, serverLog := newRecorder(t, nil)
+	client, clientLog := newRecorder(t, nil)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(grpc.StreamInterceptor(server.StreamServerInterceptor()))
+	testpb.RegisterTestServiceServer(srv, &streamServer{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStreamInterceptor(client.StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tc := testpb.NewTestServiceClient(conn)
+
+	// 服务端流读到 EOF 时结束
+	output, err := tc.StreamingOutputCall(context.Background(), &testpb.StreamingOutputCallRequest{
+		ResponseParameters: make([]*testpb.ResponseParameters, 3),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := output.Recv(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 客户端流的 CloseAndRecv 收到响应后不会再读到 EOF
+	input, err := tc.StreamingInputCall(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := input.Send(&testpb.StreamingInputCallRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := input.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 调用方取消 context 后不再读取的双向流
+	ctx, cancel := context.WithCancel(context.Background())
+	duplex, err := tc.FullDuplexCall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := duplex.Send(&testpb.StreamingOutputCallRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := duplex.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	records := waitRecords(t, clientLog, 3)
+	if r := records["StreamingOutputCall"]; r["grpc-code"] != "OK" || r["messages-received"] != 3.0 || r["direction"] != "outbound" {
+		t.Errorf("unexpected server streaming record %v", r)
+	}
+	if r := records["StreamingInputCall"]; r["grpc-code"] != "OK" || r["messages-sent"] != 2.0 || r["messages-received"] != 1.0 {
+		t.Errorf("unexpected client streaming record %v", r)
+	}
+	if r := records["FullDuplexCall"]; r["grpc-code"] != "Canceled" || r["level"] != "error" {
+		t.Errorf("unexpected canceled stream record %v", r)
+	}
+
+	records = waitRecords(t, serverLog, 3)
+	if r := records["StreamingOutputCall"]; r["grpc-code"] != "OK" || r["messages-sent"] != 3.0 {
+		t.Errorf("unexpected server streaming record %v", r)
+	}
+	if r := records["StreamingInputCall"]; r["grpc-code"] != "OK" || r["messages-received"] != 2.0 || r["messages-sent"] != 1.0 {
+		t.Errorf("unexpected client streaming record %v", r)
+	}
+	if r := records["FullDuplexCall"]; r["grpc-code"] != "Canceled" {
+		t.Errorf("unexpected canceled stream record %v", r)
+	}
+}
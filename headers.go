@@ -0,0 +1,135 @@
+package recordrequestlog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// alwaysRedactedResponseHeaders 即使在 capture_response_headers 中也只记录 REDACTED 的响应头
+var alwaysRedactedResponseHeaders = map[string]bool{"Set-Cookie": true}
+
+// responseHeadersAttr 将 capture_response_headers 中的响应头转换为分组属性，属性名为小写的响应头名称，
+// 多个值以逗号连接，响应中没有的头不记录
+func (e *RecordRequestLog) responseHeadersAttr(ctx context.Context, header http.Header) (slog.Attr, bool) {
+
+	if len(e.responseHeaders) == 0 || header == nil {
+		return slog.Attr{}, false
+	}
+
+	var (
+		attrs   []any
+		redacts int
+	)
+	for _, name := range e.responseHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		if alwaysRedactedResponseHeaders[name] {
+			value = redactedValue
+			redacts++
+		}
+		attrs = append(attrs, slog.String(strings.ToLower(name), value))
+	}
+
+	if redacts > 0 {
+		e.redactions.Add(ctx, int64(redacts), metric.WithAttributes(attribute.String("source", "response_header")))
+	}
+
+	if len(attrs) == 0 {
+		return slog.Attr{}, false
+	}
+
+	return slog.Group(e.attrKey("response-headers", "http.response.header"), attrs...), true
+}
+
+// alwaysRedactedRequestHeaders 开启 replay_capture 时只记录 REDACTED 的请求头
+var alwaysRedactedRequestHeaders = map[string]bool{"Authorization": true, "Proxy-Authorization": true, "Cookie": true}
+
+// replaySkippedHeaders 重放时由客户端重新生成的请求头，以及单独记录的 Content-Type 和 User-Agent
+var replaySkippedHeaders = map[string]bool{
+	"Connection": true, "Keep-Alive": true, "Proxy-Connection": true, "Te": true, "Trailer": true,
+	"Transfer-Encoding": true, "Upgrade": true, "Content-Length": true, "Content-Type": true, "User-Agent": true,
+}
+
+// requestHeadersAttr 开启 replay_capture 时将重放需要的请求头转换为分组属性，属性名为小写的请求头名称。
+// 认证、Cookie 和名称匹配 redact_query_params 的请求头记录为 REDACTED，中间件注入的 trace 上下文不记录
+func (e *RecordRequestLog) requestHeadersAttr(ctx context.Context, header http.Header) (slog.Attr, bool) {
+
+	if !e.replayCapture {
+		return slog.Attr{}, false
+	}
+
+	var (
+		attrs   []any
+		redacts int
+		query   = e.rules.Load().query
+	)
+	for _, name := range e.replayHeaderNames(header) {
+		value := strings.Join(header.Values(name), ", ")
+		if sensitiveHeader(query, name) {
+			value = redactedValue
+			redacts++
+		}
+		attrs = append(attrs, slog.String(strings.ToLower(name), value))
+	}
+
+	if redacts > 0 {
+		e.redactions.Add(ctx, int64(redacts), metric.WithAttributes(attribute.String("source", "request_header")))
+	}
+
+	if len(attrs) == 0 {
+		return slog.Attr{}, false
+	}
+
+	return slog.Group(e.attrKey("request-headers", "http.request.header"), attrs...), true
+}
+
+// replayHeaderNames 返回重放需要的请求头名称，不包括 replaySkippedHeaders 和中间件注入的 trace 上下文
+func (e *RecordRequestLog) replayHeaderNames(header http.Header) []string {
+
+	skipped := make(map[string]bool, len(e.propagator.Fields()))
+	for _, field := range e.propagator.Fields() {
+		skipped[http.CanonicalHeaderKey(field)] = true
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if !replaySkippedHeaders[name] && !skipped[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// sensitiveHeader 判断请求头是否为认证、Cookie 或名称匹配 redact_query_params 的请求头
+func sensitiveHeader(query *queryRedactor, name string) bool {
+	return alwaysRedactedRequestHeaders[name] || query.redacted(name)
+}
+
+// canonicalHeaders 将响应头名称统一为规范形式并去掉重复的名称
+func canonicalHeaders(names []string) []string {
+
+	var canonical []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		canonical = append(canonical, name)
+	}
+
+	return canonical
+}
@@ -0,0 +1,129 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 默认记录的 goroutine 栈的大小上限
+const defaultHungStackSize = 64 << 10
+
+// eventRequestHung 请求超过 hung_threshold 仍未完成时记录的事件
+const eventRequestHung = "request-hung"
+
+// goroutineID 返回当前 goroutine 的编号，解析失败时返回 0
+func goroutineID() uint64 {
+
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+
+	// 栈的第一行形如 "goroutine 42 [running]:"
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// goroutineStack 返回编号为 id 的 goroutine 的栈，找不到时返回所有 goroutine 的栈；结果不超过 limit 个字节
+func goroutineStack(id uint64, limit int) ([]byte, bool) {
+
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	if id != 0 {
+		header := []byte("goroutine " + strconv.FormatUint(id, 10) + " [")
+		for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+			if bytes.HasPrefix(stack, header) {
+				buf = stack
+				break
+			}
+		}
+	}
+
+	if len(buf) > limit {
+		return buf[:limit], true
+	}
+
+	return buf, false
+}
+
+// watchHung 在请求超过 hung_threshold 仍未完成时写入 warn 级别的记录，附带处理请求的 goroutine 的栈，
+// 不受采样和记录模式影响。长连接建立后不再计时
+func (x *Exchange) watchHung() {
+
+	e := x.e
+	if e.hungThreshold <= 0 {
+		return
+	}
+
+	id := goroutineID()
+	route := x.route
+
+	x.hung = time.AfterFunc(e.hungThreshold, func() {
+		stack, truncated := goroutineStack(id, e.hungStackSize)
+
+		record := e.newRecord(x.req, nil)
+		record.Time = time.Now()
+		e.setStream(&record, x.settings.streamName, x.req)
+		record.setLevel(slog.LevelWarn)
+		record.Attrs = append(record.Attrs,
+			slog.String(requestIDKey, x.requestID),
+			slog.String(e.attrKey("event", "event.name"), eventRequestHung),
+			slog.Float64(e.attrKey("elapsed-ms", "http.server.request.elapsed"), e.durationValue(time.Since(x.start))),
+			slog.String(e.attrKey("stack", "exception.stacktrace"), string(stack)),
+			slog.Int(e.attrKey("goroutines", "go.goroutine.count"), runtime.NumGoroutine()),
+		)
+		if truncated {
+			record.Attrs = append(record.Attrs, slog.Bool(e.attrKey("stack-truncated", "exception.stacktrace.truncated"), true))
+		}
+		if route != "" {
+			record.Attrs = append(record.Attrs, slog.String(e.attrKey("route", "http.route"), route))
+		}
+		if e.logFormat != LogFormatSemConv {
+			record.Message = eventRequestHung
+		}
+
+		attrs := []attribute.KeyValue{attribute.String("http.request.method", x.req.Method)}
+		if route != "" {
+			attrs = append(attrs, attribute.String("http.route", route))
+		}
+		e.hungRequests.Add(x.req.Context(), 1, metric.WithAttributes(attrs...))
+		x.span.AddEvent(eventRequestHung)
+
+		e.emit(x.req.Context(), record)
+	})
+}
+
+// durationValue 按日志格式返回耗时，legacy 格式为毫秒，semconv 格式为秒
+func (e *RecordRequestLog) durationValue(d time.Duration) float64 {
+
+	if e.logFormat == LogFormatSemConv {
+		return d.Seconds()
+	}
+
+	return float64(d) / float64(time.Millisecond)
+}
+
+// stopWatchHung 停止计时，请求记录已经写入时不影响后续的记录
+func (x *Exchange) stopWatchHung() {
+
+	if x.hung != nil {
+		x.hung.Stop()
+	}
+}
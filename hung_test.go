@@ -0,0 +1,65 @@
+package recordrequestlog_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestHungRequest(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	// 未被采样的请求同样检测
+	cfg.SampleRate = 0
+	cfg.HungThreshold = "20ms"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/hang" {
+			return
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for len(rec.Records()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/fast", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/hang", nil))
+	time.Sleep(40 * time.Millisecond)
+
+	record := rec.RequireRecords(t, 1)[0]
+	if record.Level != slog.LevelWarn {
+		t.Fatalf("expected a warn record, got %v", record.Level)
+	}
+	if v, _ := recordrequestlogtest.Attr(record, "event"); v.String() != "request-hung" {
+		t.Fatalf("unexpected event %q", v.String())
+	}
+	if v, _ := recordrequestlogtest.Attr(record, "url"); !strings.HasSuffix(v.String(), "/hang") {
+		t.Fatalf("expected the hung request path, got %q", v.String())
+	}
+	// 栈只包含处理该请求的 goroutine
+	stack, _ := recordrequestlogtest.Attr(record, "stack")
+	if !strings.Contains(stack.String(), "TestHungRequest") || strings.Contains(stack.String(), "\ngoroutine ") {
+		t.Fatalf("expected the handler goroutine stack, got %s", stack.String())
+	}
+
+	points := rec.RequireMetric(t, "recordrequestlog.requests.hung").Data.(metricdata.Sum[int64]).DataPoints
+	if len(points) != 1 || points[0].Value != 1 {
+		t.Fatalf("unexpected hung request count %v", points)
+	}
+}
@@ -0,0 +1,290 @@
+package recordrequestlog
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// 遇到未知 kid 时重新拉取的最小间隔，避免伪造的 token 频繁请求 JWKS
+	jwksMinRefreshInterval = 10 * time.Second
+	jwksFetchTimeout       = 5 * time.Second
+)
+
+// jwks 从 JWKS 地址拉取并缓存公钥，按刷新间隔或遇到未知 kid 时重新拉取。
+// 拉取不持有锁，同一时间只有一个拉取，刷新期间继续使用缓存的公钥
+type jwks struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	cache atomic.Pointer[jwksCache]
+
+	mu sync.Mutex
+	// 正在进行的拉取，没有时为 nil
+	inflight *jwksFetch
+}
+
+// jwksCache 缓存的公钥，拉取失败时 keys 保持不变，只更新 fetchedAt
+type jwksCache struct {
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// jwksFetch 一次拉取，done 关闭后 err 可读
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// jwk JWKS 中的单个公钥，只支持 RSA 和 EC
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJWKS(url string, refreshInterval time.Duration) *jwks {
+	return &jwks{
+		url:             url,
+		client:          &http.Client{Timeout: jwksFetchTimeout},
+		refreshInterval: refreshInterval,
+	}
+}
+
+// verify 校验 token 的签名
+func (k *jwks) verify(header jwtHeader, signed, signature []byte) error {
+
+	hash, ok := jwtHash(header.Alg)
+	if !ok {
+		return fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+
+	key, err := k.key(header.Kid)
+	if err != nil {
+		return err
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch header.Alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		case "PS":
+			err = rsa.VerifyPSS(key, hash, digest, signature, nil)
+		default:
+			err = fmt.Errorf("alg %q does not match RSA key", header.Alg)
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg[:2] != "ES" {
+			return fmt.Errorf("alg %q does not match EC key", header.Alg)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			err = errors.New("invalid signature")
+		}
+	}
+
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+// key 返回 kid 对应的公钥。缓存过期时在后台刷新并返回缓存的公钥，
+// 没有对应的公钥时等待拉取完成，距上次拉取不足 jwksMinRefreshInterval 时不拉取
+func (k *jwks) key(kid string) (crypto.PublicKey, error) {
+
+	cache := k.cache.Load()
+	if cache == nil {
+		cache = &jwksCache{}
+	}
+
+	key, ok := cache.keys[kid]
+	age := time.Since(cache.fetchedAt)
+
+	if ok {
+		if age >= k.refreshInterval {
+			k.refresh()
+		}
+		return key, nil
+	}
+
+	if age < jwksMinRefreshInterval {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	f := k.refresh()
+	<-f.done
+
+	if key, ok := k.cache.Load().keys[kid]; ok {
+		return key, nil
+	}
+	if f.err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", f.err)
+	}
+
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+// refresh 开始拉取 JWKS 并返回拉取，已经有拉取在进行时返回该拉取
+func (k *jwks) refresh() *jwksFetch {
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.inflight != nil {
+		return k.inflight
+	}
+
+	f := &jwksFetch{done: make(chan struct{})}
+	k.inflight = f
+
+	go func() {
+		keys, err := k.fetch()
+
+		// 拉取失败时继续使用缓存的公钥
+		next := &jwksCache{keys: keys, fetchedAt: time.Now()}
+		if err != nil {
+			if cache := k.cache.Load(); cache != nil {
+				next.keys = cache.keys
+			}
+		}
+		k.cache.Store(next)
+		f.err = err
+
+		k.mu.Lock()
+		k.inflight = nil
+		k.mu.Unlock()
+		close(f.done)
+	}()
+
+	return f
+}
+
+// fetch 拉取 JWKS，忽略不支持的公钥
+func (k *jwks) fetch() (map[string]crypto.PublicKey, error) {
+
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks responded %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if pub, err := key.publicKey(); err == nil {
+			keys[key.Kid] = pub
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey 解析 JWK 中的公钥
+func (key jwk) publicKey() (crypto.PublicKey, error) {
+
+	switch key.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kty %q", key.Kty)
+	}
+}
+
+// jwtHash 返回签名算法使用的哈希函数
+func jwtHash(alg string) (crypto.Hash, bool) {
+
+	if len(alg) != 5 {
+		return 0, false
+	}
+
+	switch alg[:2] {
+	case "RS", "PS", "ES":
+	default:
+		return 0, false
+	}
+
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	default:
+		return 0, false
+	}
+}
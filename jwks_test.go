@@ -0,0 +1,73 @@
+package recordrequestlog
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJWKSRefreshDoesNotBlock(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(rw).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	k := newJWKS(server.URL, time.Hour)
+	if _, err := k.key("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓存过期后刷新被阻塞，已知的 kid 继续使用缓存的公钥
+	cache := k.cache.Load()
+	k.cache.Store(&jwksCache{keys: cache.keys, fetchedAt: time.Now().Add(-2 * time.Hour)})
+
+	done := make(chan error, 10)
+	for range 10 {
+		go func() {
+			_, err := k.key("test")
+			done <- err
+		}()
+	}
+	for range 10 {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected cached keys to be served while the refresh is blocked")
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fetches.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("expected a single background refresh, got %d fetches", got)
+	}
+}
@@ -0,0 +1,204 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultJWTClaims = []string{"sub", "aud", "tenant_id"}
+
+// jwtExtractor 从 Authorization 请求头的 Bearer token 中提取声明，不记录原始 token。
+// 配置了 JWKS 时只有签名校验通过且未过期的 token 才会记录声明
+type jwtExtractor struct {
+	claims []string
+	keys   *jwks
+}
+
+// jwtHeader token 头部中校验签名需要的字段
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func newJWTExtractor(claims []string, keys *jwks) *jwtExtractor {
+
+	if claims == nil {
+		claims = defaultJWTClaims
+	}
+
+	return &jwtExtractor{claims: claims, keys: keys}
+}
+
+// jwtResult 请求中 Bearer token 的解析结果，同一请求的各条记录和租户解析共用，token 只解析和校验一次
+type jwtResult struct {
+	once   sync.Once
+	claims map[string]any
+	err    error
+}
+
+// requestClaims 解析请求的 Bearer token，请求中没有 Bearer token 时返回 false
+func (x *jwtExtractor) requestClaims(req *http.Request) (map[string]any, bool, error) {
+
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, false, nil
+	}
+
+	// 出站请求的 context 可能来自入站请求，Authorization 不同时不使用入站请求的结果
+	ex, _ := req.Context().Value(exchangeKey{}).(*Exchange)
+	if ex == nil || ex.req.Header.Get("Authorization") != req.Header.Get("Authorization") {
+		claims, err := x.parse(token)
+		return claims, true, err
+	}
+
+	ex.jwt.once.Do(func() {
+		ex.jwt.claims, ex.jwt.err = x.parse(token)
+	})
+
+	return ex.jwt.claims, true, ex.jwt.err
+}
+
+// attrs 返回 token 声明对应的属性，请求中没有 Bearer token 时返回 nil
+func (x *jwtExtractor) attrs(e *RecordRequestLog, req *http.Request) []slog.Attr {
+
+	claims, ok, err := x.requestClaims(req)
+	if !ok {
+		return nil
+	}
+
+	if err != nil {
+		return []slog.Attr{slog.String(e.attrKey("jwt-error", "jwt.error"), err.Error())}
+	}
+
+	values := make([]any, 0, len(x.claims))
+	for _, name := range x.claims {
+		if v, ok := claims[name]; ok {
+			values = append(values, slog.Attr{Key: name, Value: claimValue(v)})
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return []slog.Attr{slog.Group(e.attrKey("jwt", "jwt.claims"), values...)}
+}
+
+// parse 解析 token 的声明，配置了 JWKS 时同时校验签名和有效期
+func (x *jwtExtractor) parse(token string) (map[string]any, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	if x.keys == nil {
+		return claims, nil
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	if err := x.keys.verify(header, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	if err := validateTimes(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// bearerToken 返回 Authorization 请求头中的 Bearer token
+func bearerToken(req *http.Request) (string, bool) {
+
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// decodeSegment 解码 base64url 编码的 JSON 片段，数字保留为 json.Number
+func decodeSegment(segment string, v any) error {
+
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// validateTimes 校验 exp 和 nbf 声明
+func validateTimes(claims map[string]any, now time.Time) error {
+
+	if exp, ok := numericClaim(claims, "exp"); ok && !now.Before(time.Unix(exp, 0)) {
+		return errors.New("token expired")
+	}
+
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Before(time.Unix(nbf, 0)) {
+		return errors.New("token not yet valid")
+	}
+
+	return nil
+}
+
+func numericClaim(claims map[string]any, name string) (int64, bool) {
+
+	n, ok := claims[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+
+	return int64(f), true
+}
+
+// claimValue 将声明转换为属性值，数组以逗号连接
+func claimValue(v any) slog.Value {
+
+	switch v := v.(type) {
+	case string:
+		return slog.StringValue(v)
+	case bool:
+		return slog.BoolValue(v)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return slog.StringValue(strings.Join(items, ","))
+	default:
+		return jsonValue(v)
+	}
+}
@@ -0,0 +1,135 @@
+package recordrequestlog_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signToken 生成 RS256 签名的 token，key 为 nil 时签名为空
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if key == nil {
+		return signed + "."
+	}
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newJWKSServer 返回发布 key 的公钥（kid 为 test）的 JWKS 服务
+func newJWKSServer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(rw).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestJWTClaims(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.ParseJWT = true
+
+	token := signToken(t, nil, map[string]any{"sub": "user-1", "aud": []string{"a", "b"}, "tenant_id": 42, "email": "u@example.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	record := captureRecord(t, cfg, req)
+
+	claims, _ := record["jwt"].(map[string]any)
+	if claims["sub"] != "user-1" || claims["aud"] != "a,b" || claims["tenant_id"] != float64(42) {
+		t.Errorf("unexpected claims %v", record["jwt"])
+	}
+
+	if _, ok := claims["email"]; ok {
+		t.Errorf("expected unselected claim to be omitted, got %v", claims)
+	}
+
+	for key, value := range record {
+		if s, ok := value.(string); ok && strings.Contains(s, token) {
+			t.Errorf("raw token logged in %s", key)
+		}
+	}
+}
+
+func TestJWTVerification(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := newJWKSServer(t, key)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := map[string]struct {
+		token string
+		valid bool
+	}{
+		"valid":         {token: signToken(t, key, map[string]any{"sub": "user-1", "exp": exp}), valid: true},
+		"wrong key":     {token: signToken(t, other, map[string]any{"sub": "user-1", "exp": exp})},
+		"expired":       {token: signToken(t, key, map[string]any{"sub": "user-1", "exp": time.Now().Add(-time.Hour).Unix()})},
+		"not signed":    {token: signToken(t, nil, map[string]any{"sub": "user-1"})},
+		"not a jwt":     {token: "opaque"},
+		"not yet valid": {token: signToken(t, key, map[string]any{"sub": "user-1", "nbf": exp})},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := recordrequestlog.CreateConfig()
+			cfg.ParseJWT = true
+			cfg.JWTJWKSURL = jwksServer.URL
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			record := captureRecord(t, cfg, req)
+
+			claims, _ := record["jwt"].(map[string]any)
+			if got := claims["sub"] == "user-1"; got != tt.valid {
+				t.Errorf("expected claims recorded = %v, got record %v", tt.valid, record)
+			}
+
+			if _, ok := record["jwt-error"]; ok == tt.valid {
+				t.Errorf("expected jwt-error present = %v, got %v", !tt.valid, record["jwt-error"])
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package recordrequestlog
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// 集群内 API 查询结果的默认缓存时间和单次查询的超时
+const (
+	defaultKubernetesCacheTTL = time.Minute
+	kubernetesAPITimeout      = 2 * time.Second
+)
+
+// 服务账号的挂载目录
+const kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sEnv 各字段依次读取的环境变量，通常由 downward API 注入
+var k8sEnv = struct{ pod, namespace, node, deployment, uid []string }{
+	pod:        []string{"K8S_POD_NAME", "POD_NAME"},
+	namespace:  []string{"K8S_NAMESPACE_NAME", "K8S_NAMESPACE", "POD_NAMESPACE"},
+	node:       []string{"K8S_NODE_NAME", "NODE_NAME"},
+	deployment: []string{"K8S_DEPLOYMENT_NAME", "DEPLOYMENT_NAME"},
+	uid:        []string{"K8S_POD_UID", "POD_UID"},
+}
+
+// k8sMetadata Pod 所在的位置，未知的字段为空
+type k8sMetadata struct {
+	pod, namespace, node, deployment, uid string
+}
+
+// k8sDetector 探测 Pod 名称、命名空间、节点和 Deployment。环境变量优先，
+// 缺少 Pod 名称时使用主机名，缺少命名空间时读取服务账号；开启 api 时缺少的节点、
+// Deployment 和 UID 向集群内 API 查询
+type k8sDetector struct {
+	api bool
+	ttl time.Duration
+
+	getenv         func(string) string
+	serviceAccount string
+	// 为空时使用 KUBERNETES_SERVICE_HOST 和 KUBERNETES_SERVICE_PORT
+	apiURL string
+	client *http.Client
+}
+
+func newK8sDetector(api bool, ttl time.Duration) *k8sDetector {
+
+	return &k8sDetector{
+		api:            api,
+		ttl:            ttl,
+		getenv:         os.Getenv,
+		serviceAccount: kubernetesServiceAccountDir,
+	}
+}
+
+// k8sPodCache 按命名空间和 Pod 名称缓存 API 查询结果，配置重新加载时不重复查询
+var k8sPodCache = struct {
+	sync.Mutex
+	entries map[string]k8sPodEntry
+}{entries: map[string]k8sPodEntry{}}
+
+type k8sPodEntry struct {
+	metadata k8sMetadata
+	expires  time.Time
+}
+
+// Detect 不在 Kubernetes 中运行时返回空资源
+func (d *k8sDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+
+	m := d.fromEnv()
+	if m.pod == "" && m.namespace == "" {
+		return resource.Empty(), nil
+	}
+
+	var err error
+	if d.api && m.pod != "" && m.namespace != "" && (m.node == "" || m.deployment == "" || m.uid == "") {
+		var pod k8sMetadata
+		pod, err = d.cachedPod(ctx, m.namespace, m.pod)
+		m.node = cmp.Or(m.node, pod.node)
+		m.deployment = cmp.Or(m.deployment, pod.deployment)
+		m.uid = cmp.Or(m.uid, pod.uid)
+	}
+
+	var attrs []attribute.KeyValue
+	for _, field := range []struct {
+		value string
+		attr  func(string) attribute.KeyValue
+	}{
+		{m.pod, semconv.K8SPodName},
+		{m.uid, semconv.K8SPodUID},
+		{m.namespace, semconv.K8SNamespaceName},
+		{m.node, semconv.K8SNodeName},
+		{m.deployment, semconv.K8SDeploymentName},
+	} {
+		if field.value != "" {
+			attrs = append(attrs, field.attr(field.value))
+		}
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+	if err != nil {
+		return res, fmt.Errorf("%w: kubernetes api: %w", resource.ErrPartialResource, err)
+	}
+
+	return res, nil
+}
+
+// fromEnv 从环境变量、主机名和服务账号读取元数据
+func (d *k8sDetector) fromEnv() k8sMetadata {
+
+	lookup := func(names []string) string {
+		for _, name := range names {
+			if value := strings.TrimSpace(d.getenv(name)); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	m := k8sMetadata{
+		pod:        lookup(k8sEnv.pod),
+		namespace:  lookup(k8sEnv.namespace),
+		node:       lookup(k8sEnv.node),
+		deployment: lookup(k8sEnv.deployment),
+		uid:        lookup(k8sEnv.uid),
+	}
+
+	// 只在集群内使用主机名和服务账号，本地运行时不误报
+	if d.getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return m
+	}
+
+	if m.pod == "" {
+		m.pod = d.getenv("HOSTNAME")
+		if m.pod == "" {
+			m.pod, _ = os.Hostname()
+		}
+	}
+
+	if m.namespace == "" {
+		if b, err := os.ReadFile(filepath.Join(d.serviceAccount, "namespace")); err == nil {
+			m.namespace = strings.TrimSpace(string(b))
+		}
+	}
+
+	return m
+}
+
+// cachedPod 返回缓存的查询结果，过期或查询失败时重新查询
+func (d *k8sDetector) cachedPod(ctx context.Context, namespace, name string) (k8sMetadata, error) {
+
+	key := namespace + "/" + name
+
+	k8sPodCache.Lock()
+	entry, ok := k8sPodCache.entries[key]
+	k8sPodCache.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.metadata, nil
+	}
+
+	m, err := d.fetchPod(ctx, namespace, name)
+	if err != nil {
+		return k8sMetadata{}, err
+	}
+
+	k8sPodCache.Lock()
+	k8sPodCache.entries[key] = k8sPodEntry{metadata: m, expires: time.Now().Add(d.ttl)}
+	k8sPodCache.Unlock()
+
+	return m, nil
+}
+
+// k8sPod Pod 对象中用到的字段
+type k8sPod struct {
+	Metadata struct {
+		UID             string            `json:"uid"`
+		Labels          map[string]string `json:"labels"`
+		OwnerReferences []struct {
+			Kind       string `json:"kind"`
+			Name       string `json:"name"`
+			Controller bool   `json:"controller"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName string `json:"nodeName"`
+	} `json:"spec"`
+}
+
+// fetchPod 使用服务账号的令牌查询 Pod，需要 pods 的 get 权限
+func (d *k8sDetector) fetchPod(ctx context.Context, namespace, name string) (k8sMetadata, error) {
+
+	client, base, err := d.apiClient()
+	if err != nil {
+		return k8sMetadata{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kubernetesAPITimeout)
+	defer cancel()
+
+	u := base + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods/" + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return k8sMetadata{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token, err := os.ReadFile(filepath.Join(d.serviceAccount, "token"))
+	if err != nil {
+		return k8sMetadata{}, fmt.Errorf("read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return k8sMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return k8sMetadata{}, fmt.Errorf("get pod %s/%s: %s", namespace, name, resp.Status)
+	}
+
+	var pod k8sPod
+	if err := json.NewDecoder(resp.Body).Decode(&pod); err != nil {
+		return k8sMetadata{}, fmt.Errorf("decode pod %s/%s: %w", namespace, name, err)
+	}
+
+	m := k8sMetadata{node: pod.Spec.NodeName, uid: pod.Metadata.UID}
+	for _, owner := range pod.Metadata.OwnerReferences {
+		if owner.Controller && owner.Kind == "ReplicaSet" {
+			m.deployment = deploymentName(owner.Name, pod.Metadata.Labels["pod-template-hash"])
+		}
+	}
+
+	return m, nil
+}
+
+// deploymentName 从 ReplicaSet 名称中去掉 pod-template-hash 后缀，没有该标签的 ReplicaSet 不属于 Deployment
+func deploymentName(replicaSet, hash string) string {
+
+	if hash == "" {
+		return ""
+	}
+
+	name, ok := strings.CutSuffix(replicaSet, "-"+hash)
+	if !ok {
+		return ""
+	}
+
+	return name
+}
+
+// apiClient 返回访问集群内 API 的客户端，使用服务账号的 CA 证书校验 API 服务器
+func (d *k8sDetector) apiClient() (*http.Client, string, error) {
+
+	if d.apiURL != "" && d.client != nil {
+		return d.client, d.apiURL, nil
+	}
+
+	host, port := d.getenv("KUBERNETES_SERVICE_HOST"), d.getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" {
+		return nil, "", fmt.Errorf("KUBERNETES_SERVICE_HOST is not set")
+	}
+	if port == "" {
+		port = "443"
+	}
+
+	ca, err := os.ReadFile(filepath.Join(d.serviceAccount, "ca.crt"))
+	if err != nil {
+		return nil, "", fmt.Errorf("read service account ca.crt: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, "", fmt.Errorf("invalid service account ca.crt")
+	}
+
+	client := &http.Client{
+		Timeout: kubernetesAPITimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	return client, "https://" + net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,122 @@
+package recordrequestlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestK8sDetector(t *testing.T) {
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "namespace"), []byte("payments\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "token"), []byte("secret-token"), 0o600)
+
+	var calls atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		if req.URL.Path != "/api/v1/namespaces/payments/pods/gateway-7d4b9c-x2x9q" {
+			http.NotFound(rw, req)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer secret-token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.Write([]byte(`{
+			"metadata": {
+				"uid": "0d1c7a2e",
+				"labels": {"pod-template-hash": "7d4b9c"},
+				"ownerReferences": [{"kind": "ReplicaSet", "name": "gateway-7d4b9c", "controller": true}]
+			},
+			"spec": {"nodeName": "node-3"}
+		}`))
+	}))
+	defer server.Close()
+
+	env := map[string]string{
+		"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+		"HOSTNAME":                "gateway-7d4b9c-x2x9q",
+	}
+
+	detect := func(api bool) map[string]string {
+		t.Helper()
+
+		d := &k8sDetector{
+			api:            api,
+			ttl:            time.Minute,
+			getenv:         func(key string) string { return env[key] },
+			serviceAccount: dir,
+			apiURL:         server.URL,
+			client:         server.Client(),
+		}
+
+		res, err := d.Detect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		for _, kv := range res.Attributes() {
+			got[string(kv.Key)] = kv.Value.Emit()
+		}
+		return got
+	}
+
+	got := detect(false)
+	if got[string(semconv.K8SPodNameKey)] != "gateway-7d4b9c-x2x9q" || got[string(semconv.K8SNamespaceNameKey)] != "payments" {
+		t.Errorf("expected pod name from hostname and namespace from service account, got %v", got)
+	}
+	if _, ok := got[string(semconv.K8SNodeNameKey)]; ok || calls.Load() != 0 {
+		t.Errorf("expected no api lookup, got %v after %d calls", got, calls.Load())
+	}
+
+	for range 2 {
+		got = detect(true)
+	}
+	want := map[string]string{
+		string(semconv.K8SNodeNameKey):       "node-3",
+		string(semconv.K8SDeploymentNameKey): "gateway",
+		string(semconv.K8SPodUIDKey):         "0d1c7a2e",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, got[key])
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected cached api lookup, got %d calls", calls.Load())
+	}
+
+	// 环境变量优先于 API，本地运行时不读取主机名
+	env = map[string]string{"POD_NAME": "local", "POD_NAMESPACE": "dev", "NODE_NAME": "laptop"}
+	got = detect(false)
+	if got[string(semconv.K8SPodNameKey)] != "local" || got[string(semconv.K8SNamespaceNameKey)] != "dev" || got[string(semconv.K8SNodeNameKey)] != "laptop" {
+		t.Errorf("expected metadata from downward api env, got %v", got)
+	}
+
+	env = map[string]string{}
+	if got = detect(true); len(got) != 0 {
+		t.Errorf("expected no attributes outside kubernetes, got %v", got)
+	}
+}
+
+func TestDeploymentName(t *testing.T) {
+
+	for _, tc := range []struct{ replicaSet, hash, want string }{
+		{"web-api-5f6d8b7c9", "5f6d8b7c9", "web-api"},
+		{"standalone", "", ""},
+		{"web-api-5f6d8b7c9", "other", ""},
+	} {
+		if got := deploymentName(tc.replicaSet, tc.hash); got != tc.want {
+			t.Errorf("deploymentName(%q, %q) = %q, want %q", tc.replicaSet, tc.hash, got, tc.want)
+		}
+	}
+}
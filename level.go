@@ -0,0 +1,127 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// 默认按状态码设置的日志级别
+var defaultStatusLevels = map[string]string{
+	"4xx": "warn",
+	"5xx": "error",
+}
+
+// statusLevels 按响应状态码决定记录的级别，具体状态码优先于状态码类别
+type statusLevels struct {
+	codes   map[int]slog.Level
+	classes map[int]slog.Level
+}
+
+// newStatusLevels 解析状态码到日志级别的映射，键为状态码类别（例如 "5xx"）或具体状态码
+func newStatusLevels(config map[string]string) (*statusLevels, error) {
+
+	levels := &statusLevels{codes: map[int]slog.Level{}, classes: map[int]slog.Level{}}
+
+	for status, value := range config {
+		level, err := parseLevel(fmt.Sprintf("status_levels[%s]", status), value)
+		if err != nil {
+			return nil, err
+		}
+
+		key := strings.ToLower(status)
+		switch {
+		case len(key) == 3 && strings.HasSuffix(key, "xx") && key[0] >= '1' && key[0] <= '5':
+			levels.classes[int(key[0]-'0')] = level
+		default:
+			code, err := strconv.Atoi(key)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid status_levels key %q: must be a status class such as 5xx or a status code", status)
+			}
+			levels.codes[code] = level
+		}
+	}
+
+	return levels, nil
+}
+
+// level 返回状态码对应的级别，没有配置时返回 false
+func (l *statusLevels) level(status int) (slog.Level, bool) {
+
+	if level, ok := l.codes[status]; ok {
+		return level, true
+	}
+
+	level, ok := l.classes[status/100]
+	return level, ok
+}
+
+// slog 没有的级别，按 OTel severity 的区间（severity = level + 9）分别对应 TRACE、INFO3 和 FATAL
+const (
+	levelTrace  = slog.LevelDebug - 4
+	levelNotice = slog.LevelInfo + 2
+	levelFatal  = slog.LevelError + 4
+)
+
+// levelAliases 常见日志库使用、slog 没有的级别名称
+var levelAliases = map[string]slog.Level{
+	"trace":     levelTrace,
+	"notice":    levelNotice,
+	"warning":   slog.LevelWarn,
+	"err":       slog.LevelError,
+	"critical":  levelFatal,
+	"crit":      levelFatal,
+	"fatal":     levelFatal,
+	"panic":     levelFatal,
+	"alert":     levelFatal,
+	"emergency": levelFatal,
+}
+
+// parseLevel 解析 debug、info、warn、error 形式的日志级别，不区分大小写；
+// 同时接受 trace、notice、warning、fatal、critical 等常见名称和 slog 的 "info+2" 形式
+func parseLevel(name, value string) (slog.Level, error) {
+
+	if level, ok := levelAliases[strings.ToLower(value)]; ok {
+		return level, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be trace, debug, info, warn, error or fatal", name, value)
+	}
+
+	return level, nil
+}
+
+// levelName 返回 JSON 类后端中 level 字段的值，与原有的小写格式保持一致
+func levelName(level slog.Level) string {
+
+	switch level {
+	case levelTrace:
+		return "trace"
+	case levelNotice:
+		return "notice"
+	case levelFatal:
+		return "fatal"
+	}
+
+	return strings.ToLower(level.String())
+}
+
+// levelAttr 从 EnrichFunc 返回的 level 属性中取出级别，值可以是 slog.Level、slog.Leveler 或级别名称
+func levelAttr(attr slog.Attr) (slog.Level, error) {
+
+	switch v := attr.Value.Resolve(); v.Kind() {
+	case slog.KindString:
+		return parseLevel(slog.LevelKey, v.String())
+	case slog.KindInt64:
+		return slog.Level(v.Int64()), nil
+	case slog.KindAny:
+		if leveler, ok := v.Any().(slog.Leveler); ok {
+			return leveler.Level(), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid %s %v", slog.LevelKey, attr.Value)
+}
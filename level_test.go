@@ -0,0 +1,129 @@
+package recordrequestlog_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strconv"
+	"testing"
+)
+
+func TestStatusLevels(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.StatusLevels["404"] = "info"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		status, _ := strconv.Atoi(req.URL.Query().Get("status"))
+		rw.WriteHeader(status)
+	}))
+
+	tests := map[int]slog.Level{
+		http.StatusOK:                  slog.LevelInfo,
+		http.StatusBadRequest:          slog.LevelWarn,
+		http.StatusNotFound:            slog.LevelInfo,
+		http.StatusInternalServerError: slog.LevelError,
+	}
+
+	for status, want := range tests {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			rec.Reset()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/?status="+strconv.Itoa(status), nil))
+
+			record := rec.RequireRecords(t, 1)[0]
+			if record.Level != want {
+				t.Fatalf("expected level %v, got %v", want, record.Level)
+			}
+			// 级别只记录在 Record.Level 中，不再重复为属性
+			if _, ok := recordrequestlogtest.Attr(record, "level"); ok {
+				t.Fatal("unexpected level attribute")
+			}
+		})
+	}
+}
+
+func TestMinLevel(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.MinLevel = "warn"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/fail" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/ok", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/fail", nil))
+
+	record := rec.RequireRecords(t, 1)[0]
+	if record.Level != slog.LevelError {
+		t.Fatalf("expected only the error record, got %v", record.Level)
+	}
+}
+
+func TestEnrichLevel(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.StatusLevels["503"] = "fatal"
+
+	enrich := func(req *http.Request, resp recordrequestlog.ResponseInfo) []slog.Attr {
+		if req.URL.Path == "/deprecated" {
+			return []slog.Attr{slog.String(slog.LevelKey, "notice"), slog.String("deprecated", "true")}
+		}
+		if req.URL.Path == "/quiet" {
+			return []slog.Attr{slog.Any(slog.LevelKey, slog.LevelDebug)}
+		}
+		return nil
+	}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option(), recordrequestlog.WithEnrichFunc(enrich))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/deprecated" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	tests := map[string]slog.Level{
+		"/deprecated": slog.LevelInfo + 2,
+		"/quiet":      slog.LevelDebug,
+		"/fail":       slog.LevelError + 4,
+	}
+
+	for path, want := range tests {
+		t.Run(path, func(t *testing.T) {
+			rec.Reset()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+path, nil))
+
+			record := rec.RequireRecords(t, 1)[0]
+			if record.Level != want {
+				t.Fatalf("expected level %v, got %v", want, record.Level)
+			}
+			if _, ok := recordrequestlogtest.Attr(record, "level"); ok {
+				t.Fatal("unexpected level attribute")
+			}
+		})
+	}
+}
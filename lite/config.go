@@ -0,0 +1,86 @@
+package lite
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config lite 模式的配置，字段与完整版同名，完整版的其余配置项在 lite 模式下被忽略
+type Config struct {
+	// OTLP/HTTP 导出端地址，日志和 trace 分别发送到地址路径下的 /v1/logs 和 /v1/traces，例如 OpenObserve 的
+	// http://openobserve:5080/api/default；lite 模式只支持 JSON 编码的 OTLP/HTTP，不支持 OTLP gRPC
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// 日志导出后端，只支持 otlp-http，为空时同样按 otlp-http 导出；其他后端需要使用完整版
+	Backend       string `yaml:"backend,omitempty"`
+	Authorization string `yaml:"authorization,omitempty"`
+	Organization  string `yaml:"organization,omitempty"`
+	StreamName    string `yaml:"stream_name,omitempty"`
+	ServerName    string `yaml:"server_name,omitempty"`
+	// 导出请求额外携带的请求头，与 Authorization、organization、stream-name 重名时以后者为准
+	ExporterHeaders map[string]string `yaml:"exporter_headers,omitempty"`
+	// 读取请求体失败时，是否仍然将请求转发给下一个处理器
+	FailOpen bool `yaml:"fail_open,omitempty"`
+
+	// 资源属性 service.name，为空时使用 server_name
+	ServiceName string `yaml:"service_name,omitempty"`
+
+	EnableLogs   bool `yaml:"enable_logs,omitempty"`
+	EnableTraces bool `yaml:"enable_traces,omitempty"`
+
+	// 日志批量导出的间隔、单批条数上限、队列长度和单次导出的超时时间，队列满时丢弃新的记录
+	LogBatchInterval string `yaml:"log_batch_interval,omitempty"`
+	LogMaxBatchSize  int    `yaml:"log_max_batch_size,omitempty"`
+	LogQueueSize     int    `yaml:"log_queue_size,omitempty"`
+	LogExportTimeout string `yaml:"log_export_timeout,omitempty"`
+
+	// 记录的请求体大小上限（字节），只记录文本类型的请求体
+	MaxBodySize int `yaml:"max_body_size,omitempty"`
+	// 日志的采样率，0 到 1 之间；span 不受影响
+	SampleRate float64 `yaml:"sample_rate,omitempty"`
+
+	RequestIDHeader string `yaml:"request_id_header,omitempty"`
+	AppIDHeader     string `yaml:"app_id_header,omitempty"`
+}
+
+const (
+	defaultLogBatchInterval = time.Second
+	defaultLogMaxBatchSize  = 512
+	defaultLogQueueSize     = 2048
+	defaultLogExportTimeout = 30 * time.Second
+	defaultMaxBodySize      = 1 << 20
+	defaultRequestIDHeader  = "X-Request-ID"
+	defaultAppIDHeader      = "AppId"
+)
+
+func CreateConfig() *Config {
+	return &Config{
+		FailOpen:         true,
+		EnableLogs:       true,
+		EnableTraces:     true,
+		LogBatchInterval: defaultLogBatchInterval.String(),
+		LogMaxBatchSize:  defaultLogMaxBatchSize,
+		LogQueueSize:     defaultLogQueueSize,
+		LogExportTimeout: defaultLogExportTimeout.String(),
+		MaxBodySize:      defaultMaxBodySize,
+		SampleRate:       1,
+		RequestIDHeader:  defaultRequestIDHeader,
+		AppIDHeader:      defaultAppIDHeader,
+	}
+}
+
+func parseDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, value)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,420 @@
+// Package lite 是只依赖标准库的轻量实现，供 Traefik 的 Yaegi 解释器加载：Yaegi 无法解释 OpenTelemetry SDK 的依赖，
+// 插件清单因此导入本包。请求日志和 server span 按 OTLP/HTTP JSON 手工编码后批量导出，不导出指标，
+// 也不支持完整版的脱敏、路由规则和其他后端等功能；配置了其他后端时返回错误，地址使用 OTLP gRPC 的端口时输出警告。
+// 编译使用时仍然应该使用完整版
+package lite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const instrumentationName = "recordrequestlog/lite"
+
+// backendOTLPHTTP lite 模式唯一支持的后端，与完整版的 otlp-http 同名
+const backendOTLPHTTP = "otlp-http"
+
+// grpcPorts OTLP gRPC 的默认端口和 OpenObserve 的 gRPC 端口，向这些端口发送 OTLP/HTTP 请求通常会一直失败
+var grpcPorts = map[string]bool{"4317": true, "5081": true}
+
+// OTLP 日志的严重级别
+const (
+	severityInfo  = 9
+	severityWarn  = 13
+	severityError = 17
+)
+
+type RecordRequestLog struct {
+	next            http.Handler
+	name            string
+	serverName      string
+	failOpen        bool
+	maxBodySize     int
+	sampleRate      float64
+	requestIDHeader string
+	appIDHeader     string
+
+	logs   *exporter
+	traces *exporter
+}
+
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	return newRecordRequestLog(next, config, name)
+}
+
+func newRecordRequestLog(next http.Handler, config *Config, name string) (*RecordRequestLog, error) {
+
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("invalid sample_rate %v: must be between 0 and 1", config.SampleRate)
+	}
+
+	interval, err := parseDuration("log_batch_interval", config.LogBatchInterval, defaultLogBatchInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := parseDuration("log_export_timeout", config.LogExportTimeout, defaultLogExportTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Backend != "" && config.Backend != backendOTLPHTTP {
+		return nil, fmt.Errorf("backend %q is not supported in lite mode: only %s", config.Backend, backendOTLPHTTP)
+	}
+
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+	exporting := config.EnableLogs || config.EnableTraces
+	if exporting && !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("invalid endpoint %q: scheme must be http or https", config.Endpoint)
+	}
+
+	e := &RecordRequestLog{
+		next:            next,
+		name:            name,
+		serverName:      config.ServerName,
+		failOpen:        config.FailOpen,
+		maxBodySize:     config.MaxBodySize,
+		sampleRate:      config.SampleRate,
+		requestIDHeader: config.RequestIDHeader,
+		appIDHeader:     config.AppIDHeader,
+	}
+
+	if u, err := url.Parse(endpoint); exporting && err == nil && grpcPorts[u.Port()] {
+		e.logError("check endpoint", fmt.Errorf("port %s of %q is usually OTLP gRPC, lite mode exports OTLP/HTTP JSON", u.Port(), config.Endpoint))
+	}
+
+	if e.maxBodySize <= 0 {
+		e.maxBodySize = defaultMaxBodySize
+	}
+	if e.requestIDHeader == "" {
+		e.requestIDHeader = defaultRequestIDHeader
+	}
+	if e.appIDHeader == "" {
+		e.appIDHeader = defaultAppIDHeader
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = config.ServerName
+	}
+	res := resource{Attributes: []keyValue{stringAttr("service.name", serviceName)}}
+	instrumentation := scope{Name: instrumentationName}
+
+	headers := make(map[string]string, len(config.ExporterHeaders)+3)
+	for key, value := range config.ExporterHeaders {
+		headers[key] = value
+	}
+	for key, value := range map[string]string{
+		"Authorization": config.Authorization,
+		"organization":  config.Organization,
+		"stream-name":   config.StreamName,
+	} {
+		if value != "" {
+			headers[key] = value
+		}
+	}
+
+	newExporter := func(path string, wrap func([]json.RawMessage) any) *exporter {
+		x := &exporter{
+			client:   &http.Client{Timeout: timeout},
+			url:      signalURL(endpoint, path),
+			headers:  headers,
+			wrap:     wrap,
+			interval: interval,
+			maxBatch: positive(config.LogMaxBatchSize, defaultLogMaxBatchSize),
+			maxQueue: positive(config.LogQueueSize, defaultLogQueueSize),
+			onError:  e.logError,
+		}
+		x.start()
+		return x
+	}
+
+	if config.EnableLogs {
+		e.logs = newExporter("/v1/logs", func(items []json.RawMessage) any {
+			return exportLogsRequest{ResourceLogs: []resourceLogs{{
+				Resource:  res,
+				ScopeLogs: []scopeLogs{{Scope: instrumentation, LogRecords: items}},
+			}}}
+		})
+	}
+
+	if config.EnableTraces {
+		e.traces = newExporter("/v1/traces", func(items []json.RawMessage) any {
+			return exportTraceRequest{ResourceSpans: []resourceSpans{{
+				Resource:   res,
+				ScopeSpans: []scopeSpans{{Scope: instrumentation, Spans: items}},
+			}}}
+		})
+	}
+
+	return e, nil
+}
+
+// signalURL 在地址路径后追加信号的路径，与 OTLP/HTTP 导出器按基础地址导出的行为一致；
+// 地址已经以某个信号的路径结尾时替换为 path
+func signalURL(endpoint, path string) string {
+
+	for _, signal := range []string{"/v1/logs", "/v1/traces"} {
+		if base, ok := strings.CutSuffix(endpoint, signal); ok {
+			return base + path
+		}
+	}
+
+	return endpoint + path
+}
+
+func positive(value, def int) int {
+	if value <= 0 {
+		return def
+	}
+	return value
+}
+
+// Shutdown 导出队列中剩余的日志和 span
+func (e *RecordRequestLog) Shutdown(ctx context.Context) error {
+
+	var errs []error
+	for _, x := range []*exporter{e.logs, e.traces} {
+		if x != nil {
+			errs = append(errs, x.shutdown(ctx))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (e *RecordRequestLog) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	start := time.Now()
+
+	id := req.Header.Get(e.requestIDHeader)
+	if !validRequestID(id) {
+		id = randomHex(16)
+		req.Header.Set(e.requestIDHeader, id)
+	}
+	rw.Header().Set(e.requestIDHeader, id)
+
+	// 沿用 traceparent 中的 trace ID，并将本次的 span 作为下一个处理器的父 span
+	traceID, parentSpanID, ok := parseTraceparent(req.Header.Get("traceparent"))
+	if !ok {
+		traceID, parentSpanID = randomHex(16), ""
+	}
+	spanID := randomHex(8)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+
+	sampled := e.logs != nil && (e.sampleRate >= 1 || mathrand.Float64() < e.sampleRate)
+
+	var (
+		body      []byte
+		truncated bool
+	)
+	if sampled && req.Body != nil && req.Body != http.NoBody && isText(req.Header.Get("Content-Type")) {
+		var err error
+		body, truncated, err = e.readBody(req)
+		if err != nil {
+			e.logError("read request body", err)
+			if !e.failOpen {
+				writeError(rw, id, err)
+				return
+			}
+		}
+	}
+
+	w := &responseWriter{ResponseWriter: rw}
+	e.next.ServeHTTP(w, req)
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	end := time.Now()
+
+	if sampled {
+		e.logs.add(e.logRecord(req, id, body, truncated, status, start, end, traceID, spanID))
+	}
+
+	if e.traces != nil {
+		e.traces.add(e.span(req, id, status, start, end, traceID, spanID, parentSpanID))
+	}
+}
+
+// readBody 读取不超过 max_body_size 的请求体，转发给下一个处理器的请求体保持完整
+func (e *RecordRequestLog) readBody(req *http.Request) ([]byte, bool, error) {
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, int64(e.maxBodySize)+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+
+	if len(body) > e.maxBodySize {
+		return body[:e.maxBodySize], true, nil
+	}
+
+	return body, false, nil
+}
+
+func (e *RecordRequestLog) logRecord(req *http.Request, id string, body []byte, truncated bool, status int, start, end time.Time, traceID, spanID string) logRecord {
+
+	level, severity := "info", severityInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level, severity = "error", severityError
+	case status >= http.StatusBadRequest:
+		level, severity = "warn", severityWarn
+	}
+
+	message := strings.ToValidUTF8(string(body), "")
+	attrs := []keyValue{
+		stringAttr("level", level),
+		stringAttr("method", req.Method),
+		stringAttr("url", req.URL.String()),
+		stringAttr("host", req.Host),
+		stringAttr("user-agent", req.UserAgent()),
+		stringAttr("appid", req.Header.Get(e.appIDHeader)),
+		stringAttr("service", e.serverName),
+		stringAttr("request.id", id),
+		intAttr("status", int64(status)),
+		doubleAttr("duration-ms", float64(end.Sub(start))/float64(time.Millisecond)),
+	}
+	if body != nil {
+		size := int64(len(body))
+		if truncated {
+			size = req.ContentLength
+		}
+		attrs = append(attrs,
+			stringAttr("content-type", req.Header.Get("Content-Type")),
+			intAttr("body-size", size),
+		)
+	}
+	if truncated {
+		attrs = append(attrs, keyValue{Key: "body-truncated", Value: anyValue{BoolValue: &truncated}})
+	}
+
+	return logRecord{
+		TimeUnixNano:         unixNano(start),
+		ObservedTimeUnixNano: unixNano(end),
+		SeverityNumber:       severity,
+		SeverityText:         strings.ToUpper(level),
+		Body:                 anyValue{StringValue: &message},
+		Attributes:           attrs,
+		TraceID:              traceID,
+		SpanID:               spanID,
+	}
+}
+
+func (e *RecordRequestLog) span(req *http.Request, id string, status int, start, end time.Time, traceID, spanID, parentSpanID string) span {
+
+	s := span{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		ParentSpanID:      parentSpanID,
+		Name:              req.Method,
+		Kind:              spanKindServer,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes: []keyValue{
+			stringAttr("http.request.method", req.Method),
+			stringAttr("url.path", req.URL.Path),
+			stringAttr("request.id", id),
+			intAttr("http.response.status_code", int64(status)),
+		},
+	}
+
+	if status >= http.StatusInternalServerError {
+		s.Status = spanStatus{Code: statusCodeError, Message: http.StatusText(status)}
+	}
+
+	return s
+}
+
+func (e *RecordRequestLog) logError(msg string, err error) {
+	os.Stderr.WriteString(fmt.Sprintf("recordrequestlog[%s]: %s: %v\n", e.name, msg, err))
+}
+
+// isText 只记录 JSON、XML、表单和 text/* 请求体
+func isText(contentType string) bool {
+
+	contentType = strings.ToLower(contentType)
+	for _, t := range []string{"json", "xml", "x-www-form-urlencoded", "text/"} {
+		if strings.Contains(contentType, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validRequestID 与完整版相同，客户端提供的请求 ID 不超过 128 个字节且只包含可见 ASCII 字符时才使用
+func validRequestID(id string) bool {
+
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseTraceparent 解析 W3C traceparent，返回 trace ID 和父 span ID
+func parseTraceparent(value string) (string, string, bool) {
+
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+
+	for _, id := range parts[1:3] {
+		if _, err := hex.DecodeString(id); err != nil || strings.Trim(id, "0") == "" {
+			return "", "", false
+		}
+	}
+
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), true
+}
+
+func randomHex(n int) string {
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// writeError 读取请求体失败且关闭 fail_open 时返回与完整版相同格式的错误
+func writeError(rw http.ResponseWriter, id string, err error) {
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(rw).Encode(map[string]any{
+		"data":       "",
+		"errmsg":     err.Error(),
+		"errcode":    http.StatusInternalServerError,
+		"request_id": id,
+	})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,214 @@
+package lite
+
+import (
+	"context"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// collector 记录收到的 OTLP/HTTP JSON 请求
+type collector struct {
+	mu       sync.Mutex
+	headers  []http.Header
+	requests map[string][]map[string]any
+}
+
+func newCollector(t *testing.T) (*collector, string) {
+
+	c := &collector{requests: map[string][]map[string]any{}}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("decode export request: %v", err)
+		}
+
+		c.mu.Lock()
+		c.headers = append(c.headers, req.Header.Clone())
+		c.requests[req.URL.Path] = append(c.requests[req.URL.Path], body)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	return c, server.URL
+}
+
+func TestLite(t *testing.T) {
+
+	c, endpoint := newCollector(t)
+
+	cfg := CreateConfig()
+	cfg.Endpoint = endpoint
+	cfg.Authorization = "Basic secret"
+	cfg.StreamName = "default"
+	cfg.ServerName = "announcement"
+
+	var forwarded, traceparent string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		forwarded = string(b)
+		traceparent = req.Header.Get("traceparent")
+		rw.WriteHeader(http.StatusBadGateway)
+	})
+
+	e, err := newRecordRequestLog(next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rw := httptest.NewRecorder()
+	e.ServeHTTP(rw, req)
+
+	if forwarded != `{"id":1}` {
+		t.Fatalf("expected the full body to be forwarded, got %q", forwarded)
+	}
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Fatalf("expected a child traceparent, got %q", traceparent)
+	}
+	id := rw.Header().Get(defaultRequestIDHeader)
+	if id == "" {
+		t.Fatal("expected a generated request ID")
+	}
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.requests["/v1/logs"]) != 1 || len(c.requests["/v1/traces"]) != 1 {
+		t.Fatalf("expected one logs and one traces export, got %v", c.requests)
+	}
+	if got := c.headers[0].Get("Authorization"); got != "Basic secret" {
+		t.Errorf("unexpected Authorization %q", got)
+	}
+
+	record := c.requests["/v1/logs"][0]["resourceLogs"].([]any)[0].(map[string]any)["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)[0].(map[string]any)
+	if record["severityText"] != "ERROR" || record["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected record %v", record)
+	}
+	if body := record["body"].(map[string]any)["stringValue"]; body != `{"id":1}` {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	attrs := map[string]map[string]any{}
+	for _, attr := range record["attributes"].([]any) {
+		kv := attr.(map[string]any)
+		attrs[kv["key"].(string)] = kv["value"].(map[string]any)
+	}
+	if attrs["status"]["intValue"] != strconv.Itoa(http.StatusBadGateway) || attrs["request.id"]["stringValue"] != id || attrs["service"]["stringValue"] != "announcement" {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+
+	span := c.requests["/v1/traces"][0]["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if span["parentSpanId"] != "00f067aa0ba902b7" || span["status"].(map[string]any)["code"] != float64(statusCodeError) {
+		t.Errorf("unexpected span %v", span)
+	}
+}
+
+func TestLiteTruncatesBody(t *testing.T) {
+
+	c, endpoint := newCollector(t)
+
+	cfg := CreateConfig()
+	cfg.Endpoint = endpoint
+	cfg.EnableTraces = false
+	cfg.MaxBodySize = 4
+
+	var forwarded string
+	e, err := newRecordRequestLog(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		forwarded = string(b)
+	}), cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader("0123456789"))
+	req.Header.Set("Content-Type", "text/plain")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if forwarded != "0123456789" {
+		t.Fatalf("expected the full body to be forwarded, got %q", forwarded)
+	}
+
+	record := c.requests["/v1/logs"][0]["resourceLogs"].([]any)[0].(map[string]any)["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)[0].(map[string]any)
+	if body := record["body"].(map[string]any)["stringValue"]; body != "0123" {
+		t.Errorf("expected truncated body, got %v", body)
+	}
+}
+
+func TestSignalURL(t *testing.T) {
+
+	for endpoint, want := range map[string]string{
+		"http://collector:4318":                         "http://collector:4318/v1/logs",
+		"http://openobserve:5080/api/default":           "http://openobserve:5080/api/default/v1/logs",
+		"http://openobserve:5080/api/default/v1/logs":   "http://openobserve:5080/api/default/v1/logs",
+		"http://openobserve:5080/api/default/v1/traces": "http://openobserve:5080/api/default/v1/logs",
+	} {
+		if got := signalURL(endpoint, "/v1/logs"); got != want {
+			t.Errorf("signalURL(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
+
+func TestLiteBackend(t *testing.T) {
+
+	for backend, supported := range map[string]bool{"": true, "otlp-http": true, "otlp-grpc": false, "openobserve": false} {
+		cfg := CreateConfig()
+		cfg.Endpoint = "http://collector:4318"
+		cfg.Backend = backend
+
+		e, err := newRecordRequestLog(http.NotFoundHandler(), cfg, "demo-plugin")
+		if supported != (err == nil) {
+			t.Errorf("backend %q: unexpected error %v", backend, err)
+		}
+		if e != nil {
+			e.Shutdown(context.Background())
+		}
+	}
+}
+
+// TestStandardLibraryOnly Yaegi 只能加载标准库，lite 包不能引入第三方或本模块的其他包
+func TestStandardLibraryOnly(t *testing.T) {
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") || first == "recordrequestlog" {
+				t.Errorf("%s imports %s", file, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package lite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 以下类型按 OTLP/HTTP 的 JSON 编码手写，字段名为 protobuf JSON 映射的驼峰形式，
+// 64 位整数编码为字符串，trace ID 和 span ID 编码为十六进制字符串
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int64) keyValue {
+	s := strconv.FormatInt(value, 10)
+	return keyValue{Key: key, Value: anyValue{IntValue: &s}}
+}
+
+func doubleAttr(key string, value float64) keyValue {
+	return keyValue{Key: key, Value: anyValue{DoubleValue: &value}}
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope             `json:"scope"`
+	LogRecords []json.RawMessage `json:"logRecords"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type exportLogsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+// span 类型和状态码
+const (
+	spanKindServer  = 2
+	statusCodeError = 2
+)
+
+type spanStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes"`
+	Status            spanStatus `json:"status"`
+}
+
+type scopeSpans struct {
+	Scope scope             `json:"scope"`
+	Spans []json.RawMessage `json:"spans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type exportTraceRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// exporter 在后台按间隔或单批条数上限批量导出一类信号，每条数据在写入队列时编码
+type exporter struct {
+	client   *http.Client
+	url      string
+	headers  map[string]string
+	wrap     func(items []json.RawMessage) any
+	interval time.Duration
+	maxBatch int
+	maxQueue int
+	onError  func(msg string, err error)
+
+	mu      sync.Mutex
+	queue   []json.RawMessage
+	dropped bool
+
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func (x *exporter) start() {
+
+	x.flush = make(chan struct{}, 1)
+	x.done = make(chan struct{})
+
+	x.wg.Add(1)
+	go x.loop()
+}
+
+func (x *exporter) loop() {
+
+	defer x.wg.Done()
+
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-x.flush:
+		case <-x.done:
+			return
+		}
+
+		for x.export(context.Background()) {
+		}
+	}
+}
+
+// add 将 item 编码后放入队列，队列满时丢弃并在恢复前只输出一次错误
+func (x *exporter) add(item any) {
+
+	b, err := json.Marshal(item)
+	if err != nil {
+		x.onError("encode", err)
+		return
+	}
+
+	x.mu.Lock()
+	if len(x.queue) >= x.maxQueue {
+		dropped := x.dropped
+		x.dropped = true
+		x.mu.Unlock()
+		if !dropped {
+			x.onError("export", fmt.Errorf("queue full, dropping records sent to %s", x.url))
+		}
+		return
+	}
+
+	x.queue = append(x.queue, b)
+	x.dropped = false
+	full := len(x.queue) >= x.maxBatch
+	x.mu.Unlock()
+
+	if full {
+		select {
+		case x.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// export 导出一批数据，队列中还有剩余时返回 true
+func (x *exporter) export(ctx context.Context) bool {
+
+	x.mu.Lock()
+	n := len(x.queue)
+	if n > x.maxBatch {
+		n = x.maxBatch
+	}
+	batch := x.queue[:n:n]
+	x.queue = x.queue[n:]
+	more := len(x.queue) > 0
+	x.mu.Unlock()
+
+	if n == 0 {
+		return false
+	}
+
+	if err := x.post(ctx, batch); err != nil {
+		x.onError("export", err)
+	}
+
+	return more
+}
+
+func (x *exporter) post(ctx context.Context, batch []json.RawMessage) error {
+
+	body, err := json.Marshal(x.wrap(batch))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, x.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range x.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := x.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("export to %s: %s: %s", x.url, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// shutdown 停止后台导出并导出队列中剩余的数据
+func (x *exporter) shutdown(ctx context.Context) error {
+
+	close(x.done)
+	x.wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if !x.export(ctx) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package recordrequestlog
+
+import (
+	"reflect"
+	"strings"
+
+	"recordrequestlog/lite"
+)
+
+// liteConfig 返回 lite 模式使用的配置，lite 模式不支持的字段被忽略
+func (c *Config) liteConfig() *lite.Config {
+	return &lite.Config{
+		Endpoint:         c.Endpoint,
+		Backend:          c.Backend,
+		Authorization:    c.Authorization,
+		Organization:     c.Organization,
+		StreamName:       c.StreamName,
+		ServerName:       c.ServerName,
+		ExporterHeaders:  c.ExporterHeaders,
+		FailOpen:         c.FailOpen,
+		ServiceName:      c.ServiceName,
+		EnableLogs:       c.EnableLogs,
+		EnableTraces:     c.EnableTraces,
+		LogBatchInterval: c.LogBatchInterval,
+		LogMaxBatchSize:  c.LogMaxBatchSize,
+		LogQueueSize:     c.LogQueueSize,
+		LogExportTimeout: c.LogExportTimeout,
+		MaxBodySize:      c.MaxBodySize,
+		SampleRate:       c.SampleRate,
+		RequestIDHeader:  c.RequestIDHeader,
+		AppIDHeader:      c.AppIDHeader,
+	}
+}
+
+// liteIgnored 返回 lite 模式不支持、但修改了默认值的配置项
+func (c *Config) liteIgnored() []string {
+
+	supported := map[string]bool{"lite_mode": true}
+	liteType := reflect.TypeOf(lite.Config{})
+	for i := 0; i < liteType.NumField(); i++ {
+		name, _, _ := strings.Cut(liteType.Field(i).Tag.Get("yaml"), ",")
+		supported[name] = true
+	}
+
+	var (
+		ignored  []string
+		value    = reflect.ValueOf(c).Elem()
+		defaults = reflect.ValueOf(CreateConfig()).Elem()
+	)
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || supported[name] {
+			continue
+		}
+		if !reflect.DeepEqual(value.Field(i).Interface(), defaults.Field(i).Interface()) {
+			ignored = append(ignored, name)
+		}
+	}
+
+	return ignored
+}
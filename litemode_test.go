@@ -0,0 +1,26 @@
+package recordrequestlog
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLiteIgnored(t *testing.T) {
+
+	cfg := CreateConfig()
+	cfg.LiteMode = true
+	cfg.Endpoint = "http://collector:4318"
+	cfg.Backend = BackendOTLPHTTP
+	if ignored := cfg.liteIgnored(); len(ignored) != 0 {
+		t.Errorf("expected no ignored settings, got %v", ignored)
+	}
+
+	// lite 模式不支持的配置项修改了默认值时列出
+	cfg.RedactQueryParams = []string{"token"}
+	cfg.Async = true
+	ignored := cfg.liteIgnored()
+	slices.Sort(ignored)
+	if !slices.Equal(ignored, []string{"async", "redact_query_params"}) {
+		t.Errorf("unexpected ignored settings %v", ignored)
+	}
+}
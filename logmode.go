@@ -0,0 +1,62 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 日志记录模式，可以用逗号组合，例如 "errors,slow"
+const (
+	LogModeAll    = "all"
+	LogModeErrors = "errors"
+	LogModeSlow   = "slow"
+)
+
+const defaultSlowThreshold = time.Second
+
+// logMode 决定哪些请求在完成后写入日志，指标不受影响
+type logMode struct {
+	errors bool
+	slow   bool
+}
+
+// parseLogMode 解析逗号分隔的记录模式，为空或包含 all 时记录所有请求
+func parseLogMode(name, value string) (*logMode, error) {
+
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	mode := &logMode{}
+
+	for _, part := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case LogModeAll:
+			return nil, nil
+		case LogModeErrors:
+			mode.errors = true
+		case LogModeSlow:
+			mode.slow = true
+		default:
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+	}
+
+	return mode, nil
+}
+
+// shouldLog 根据响应状态码和耗时判断是否记录请求，满足任一已启用的条件即记录
+func (s *routeSettings) shouldLog(status int, duration time.Duration) bool {
+
+	if s.logMode == nil {
+		return true
+	}
+
+	if s.logMode.errors && status >= http.StatusBadRequest {
+		return true
+	}
+
+	return s.logMode.slow && duration > s.slowThreshold
+}
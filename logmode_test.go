@@ -0,0 +1,79 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+	"time"
+)
+
+func TestLogMode(t *testing.T) {
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{mode: "all", want: []string{"/ok", "/missing", "/slow"}},
+		{mode: "errors", want: []string{"/missing"}},
+		{mode: "slow", want: []string{"/slow"}},
+		{mode: "errors,slow", want: []string{"/missing", "/slow"}},
+	}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+		case "/slow":
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "requests.log")
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.Backend = recordrequestlog.BackendFile
+			cfg.FilePath = path
+			cfg.LogMode = tt.mode
+			cfg.SlowThreshold = "10ms"
+
+			handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, target := range []string{"/ok", "/missing", "/slow"} {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+target, nil))
+			}
+
+			records := readRecords(t, path)
+			if len(records) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d", len(tt.want), len(records))
+			}
+
+			for i, record := range records {
+				if want := "http://localhost" + tt.want[i]; record["url"] != want {
+					t.Errorf("record %d: expected url %q, got %v", i, want, record["url"])
+				}
+			}
+		})
+	}
+}
+
+func TestRecordStatus(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	record := captureRecord(t, recordrequestlog.CreateConfig(), req)
+
+	if record["status"] != float64(http.StatusOK) {
+		t.Errorf("expected status 200, got %v", record["status"])
+	}
+
+	if _, ok := record["duration-ms"].(float64); !ok {
+		t.Errorf("expected duration-ms, got %v", record["duration-ms"])
+	}
+}
@@ -0,0 +1,136 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// 指标的导出方式
+const (
+	// 按 metric_interval 通过 OTLP 推送到 endpoint
+	MetricsBackendOTLP = "otlp"
+	// 由 Prometheus 抓取 /metrics，不再推送 OTLP 指标
+	MetricsBackendPrometheus = "prometheus"
+)
+
+// newPrometheusReader 创建 Prometheus 抓取使用的 reader，每个中间件使用独立的 registry，
+// 避免多个实例在默认 registry 中重复注册
+func (e *RecordRequestLog) newPrometheusReader() (sdkmetric.Reader, error) {
+
+	registry := prometheus.NewRegistry()
+
+	reader, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, err
+	}
+
+	e.metricsRegistry = registry
+	// exemplar 只在 OpenMetrics 格式中返回，抓取方没有请求该格式时仍返回文本格式
+	e.metricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorLog:          promErrorLog(func(msg string) { e.logError("serve metrics", errors.New(msg)) }),
+		EnableOpenMetrics: true,
+	})
+
+	return reader, nil
+}
+
+// prometheusServer 监听 prometheus_address 的服务。Traefik 为每个路由创建中间件实例，重新加载配置时新旧实例同时存在，
+// 使用同一地址的实例共用一个监听，/metrics 合并返回各实例的指标，最后一个实例关闭时停止监听
+type prometheusServer struct {
+	server     *http.Server
+	registries map[*prometheus.Registry]bool
+}
+
+var prometheusServers = struct {
+	sync.Mutex
+	byAddress map[string]*prometheusServer
+}{byAddress: map[string]*prometheusServer{}}
+
+// servePrometheus 在 prometheus_address 上提供 /metrics，返回注销该实例的函数
+func (e *RecordRequestLog) servePrometheus() (func(context.Context) error, error) {
+
+	prometheusServers.Lock()
+	defer prometheusServers.Unlock()
+
+	address := e.prometheusAddress
+	s, ok := prometheusServers.byAddress[address]
+	if !ok {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("listen prometheus_address %q: %w", address, err)
+		}
+
+		s = &prometheusServer{registries: map[*prometheus.Registry]bool{}}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /metrics", s.serveMetrics)
+		s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				e.logError("serve metrics", err)
+			}
+		}()
+
+		prometheusServers.byAddress[address] = s
+	}
+
+	registry := e.metricsRegistry
+	s.registries[registry] = true
+
+	return func(ctx context.Context) error {
+		prometheusServers.Lock()
+		delete(s.registries, registry)
+		last := len(s.registries) == 0
+		if last {
+			delete(prometheusServers.byAddress, address)
+		}
+		prometheusServers.Unlock()
+
+		if !last {
+			return nil
+		}
+		return s.server.Shutdown(ctx)
+	}, nil
+}
+
+func (s *prometheusServer) serveMetrics(rw http.ResponseWriter, req *http.Request) {
+
+	prometheusServers.Lock()
+	gatherers := make(prometheus.Gatherers, 0, len(s.registries))
+	for registry := range s.registries {
+		gatherers = append(gatherers, registry)
+	}
+	prometheusServers.Unlock()
+
+	// 各实例的资源属性相同时部分指标会重复，跳过重复的指标继续返回其余指标
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError, EnableOpenMetrics: true}).ServeHTTP(rw, req)
+}
+
+// adminMetrics 在管理接口下提供 /metrics，metrics_backend 不是 prometheus 或指标未开启时返回 404
+func (e *RecordRequestLog) adminMetrics(rw http.ResponseWriter, req *http.Request) {
+
+	if e.metricsHandler == nil {
+		writeAdminReply(rw, http.StatusNotFound, "prometheus metrics are not enabled")
+		return
+	}
+
+	e.metricsHandler.ServeHTTP(rw, req)
+}
+
+// promErrorLog 将 promhttp 的错误转为中间件的错误日志
+type promErrorLog func(msg string)
+
+func (l promErrorLog) Println(v ...any) {
+	l(fmt.Sprint(v...))
+}
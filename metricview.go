@@ -0,0 +1,102 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricViewConfig 修改导出的指标，instrument 为指标名称，支持 * 和 ? 通配符；一个指标匹配多条规则时使用第一条
+type MetricViewConfig struct {
+	Instrument string `yaml:"instrument,omitempty"`
+	// 导出时使用的名称和描述，instrument 包含通配符时不能设置 name
+	Name        string `yaml:"name,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// 丢弃的属性；设置 keep_attributes 时只保留其中的属性，drop_attributes 不生效
+	DropAttributes []string `yaml:"drop_attributes,omitempty"`
+	KeepAttributes []string `yaml:"keep_attributes,omitempty"`
+	// 直方图的分桶上界，需要递增
+	Buckets []float64 `yaml:"buckets,omitempty"`
+	// 是否不导出该指标
+	Drop bool `yaml:"drop,omitempty"`
+}
+
+// validateMetricViews 检查 metric_views
+func validateMetricViews(views []MetricViewConfig) error {
+
+	for i, view := range views {
+		if view.Instrument == "" {
+			return fmt.Errorf("metric_views[%d]: instrument is required", i)
+		}
+		if _, err := path.Match(view.Instrument, ""); err != nil {
+			return fmt.Errorf("metric_views[%d]: invalid instrument %q: %w", i, view.Instrument, err)
+		}
+		if view.Name != "" && strings.ContainsAny(view.Instrument, "*?") {
+			return fmt.Errorf("metric_views[%d]: name cannot be set when instrument %q contains wildcards", i, view.Instrument)
+		}
+		if err := checkBuckets(fmt.Sprintf("metric_views[%d].buckets", i), view.Buckets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// metricView 按 metric_views 修改指标。SDK 对匹配多个 View 的指标分别导出，因此规则合并为一个 View，
+// 所有指标都不以 request.id 作为维度，它的基数过高，只保留在 exemplar 的过滤属性中
+func metricView(views []MetricViewConfig) sdkmetric.View {
+
+	return func(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+
+		stream := sdkmetric.Stream{
+			Name:            inst.Name,
+			Description:     inst.Description,
+			Unit:            inst.Unit,
+			AttributeFilter: attribute.NewDenyKeysFilter(requestIDKey),
+		}
+
+		for _, view := range views {
+			if ok, _ := path.Match(view.Instrument, inst.Name); !ok {
+				continue
+			}
+
+			if view.Name != "" {
+				stream.Name = view.Name
+			}
+			if view.Description != "" {
+				stream.Description = view.Description
+			}
+
+			switch {
+			case len(view.KeepAttributes) > 0:
+				keys := make([]attribute.Key, 0, len(view.KeepAttributes))
+				for _, key := range view.KeepAttributes {
+					if key != requestIDKey {
+						keys = append(keys, attribute.Key(key))
+					}
+				}
+				stream.AttributeFilter = attribute.NewAllowKeysFilter(keys...)
+			case len(view.DropAttributes) > 0:
+				keys := []attribute.Key{requestIDKey}
+				for _, key := range view.DropAttributes {
+					keys = append(keys, attribute.Key(key))
+				}
+				stream.AttributeFilter = attribute.NewDenyKeysFilter(keys...)
+			}
+
+			switch {
+			case view.Drop:
+				stream.Aggregation = sdkmetric.AggregationDrop{}
+			case len(view.Buckets) > 0:
+				stream.Aggregation = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: view.Buckets}
+			}
+
+			return stream, true
+		}
+
+		return stream, true
+	}
+}
@@ -0,0 +1,163 @@
+package recordrequestlog
+
+import (
+	"net/http"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option 配置 NewMiddleware 创建的中间件
+type Option func(*options)
+
+type options struct {
+	config    *Config
+	name      string
+	exporters *TestExporters
+}
+
+// NewMiddleware 按选项创建中间件，供不经过 Traefik 直接使用的服务按常规 HTTP 中间件组合，
+// 未设置的选项使用 CreateConfig 的默认值。所有被包装的处理器共用同一组导出器
+func NewMiddleware(opts ...Option) (func(http.Handler) http.Handler, error) {
+
+	o := &options{config: CreateConfig(), name: instrumentationName}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	e, err := newRecordRequestLog(nil, o.config, o.name, o.exporters)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		wrapped := *e
+		wrapped.next = next
+		return &wrapped
+	}, nil
+}
+
+// WithConfig 使用完整的配置，之后的选项在其基础上修改
+func WithConfig(config *Config) Option {
+	return func(o *options) {
+		copied := *config
+		o.config = &copied
+	}
+}
+
+// TestExporters 代替配置的导出端接收中间件导出的数据，用于测试；为空的字段仍然按配置创建。
+// span 同步导出，请求结束后即可读取
+type TestExporters struct {
+	Sink         LogSink
+	SpanExporter sdktrace.SpanExporter
+	MetricReader sdkmetric.Reader
+}
+
+// WithTestExporters 使用测试导出器，recordrequestlogtest 包提供了内存实现
+func WithTestExporters(exporters TestExporters) Option {
+	return func(o *options) {
+		o.exporters = &exporters
+	}
+}
+
+// WithLoggerProvider 使用应用的 LoggerProvider 导出请求日志，多个中间件实例可以共用同一个 provider，
+// 关闭中间件时只刷新不关闭 provider
+func WithLoggerProvider(provider otellog.LoggerProvider) Option {
+	return func(o *options) {
+		o.config.LoggerProvider = provider
+	}
+}
+
+// WithName 设置中间件名称，用于本地错误输出
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithEndpoint 设置导出端地址
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.config.Endpoint = endpoint
+	}
+}
+
+// WithAuthorization 设置导出请求的 Authorization 请求头
+func WithAuthorization(authorization string) Option {
+	return func(o *options) {
+		o.config.Authorization = authorization
+	}
+}
+
+// WithOrganization 设置导出的组织
+func WithOrganization(organization string) Option {
+	return func(o *options) {
+		o.config.Organization = organization
+	}
+}
+
+// WithStreamName 设置默认 stream，支持 {tenant} 占位符
+func WithStreamName(streamName string) Option {
+	return func(o *options) {
+		o.config.StreamName = streamName
+	}
+}
+
+// WithServiceName 设置服务名称
+func WithServiceName(serviceName string) Option {
+	return func(o *options) {
+		o.config.ServerName = serviceName
+		o.config.ServiceName = serviceName
+	}
+}
+
+// WithBackend 设置日志导出后端
+func WithBackend(backend string) Option {
+	return func(o *options) {
+		o.config.Backend = backend
+	}
+}
+
+// WithFile 将日志写入本地文件
+func WithFile(path string) Option {
+	return func(o *options) {
+		o.config.Backend = BackendFile
+		o.config.FilePath = path
+	}
+}
+
+// WithLogFormat 设置日志格式
+func WithLogFormat(format string) Option {
+	return func(o *options) {
+		o.config.LogFormat = format
+	}
+}
+
+// WithLogMode 设置日志记录模式，例如 "errors,slow"
+func WithLogMode(mode string) Option {
+	return func(o *options) {
+		o.config.LogMode = mode
+	}
+}
+
+// WithSampleRate 设置日志采样率，取值 0 到 1
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.config.SampleRate = rate
+	}
+}
+
+// WithRedaction 设置查询字符串中需要脱敏的参数名关键字，替换默认列表
+func WithRedaction(params ...string) Option {
+	return func(o *options) {
+		o.config.RedactQueryParams = params
+	}
+}
+
+// WithRoutes 设置按路由覆盖的配置
+func WithRoutes(routes ...RouteConfig) Option {
+	return func(o *options) {
+		o.config.Routes = routes
+	}
+}
@@ -0,0 +1,116 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"sync"
+	"testing"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestNewMiddleware(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	middleware, err := recordrequestlog.NewMiddleware(
+		recordrequestlog.WithFile(path),
+		recordrequestlog.WithServiceName("orders"),
+		recordrequestlog.WithRedaction("session"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := map[string]int{}
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			calls[name]++
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/a", middleware(handler("a")))
+	mux.Handle("/b", middleware(handler("b")))
+
+	for _, target := range []string{"/a?session=1&token=2", "/b"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+target, nil))
+	}
+
+	if calls["a"] != 1 || calls["b"] != 1 {
+		t.Errorf("expected each handler to be called once, got %v", calls)
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	if want := "http://localhost/a?session=REDACTED&token=2"; records[0]["url"] != want {
+		t.Errorf("expected url %q, got %v", want, records[0]["url"])
+	}
+
+	if records[0]["service"] != "orders" {
+		t.Errorf("expected service orders, got %v", records[0]["service"])
+	}
+}
+
+func TestNewMiddlewareInvalidOption(t *testing.T) {
+
+	if _, err := recordrequestlog.NewMiddleware(recordrequestlog.WithLogFormat("xml")); err == nil {
+		t.Fatal("expected error for invalid log format")
+	}
+}
+
+// memoryProcessor 收集 SDK 生成的日志记录
+type memoryProcessor struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (p *memoryProcessor) OnEmit(ctx context.Context, record sdklog.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, record.Clone())
+	return nil
+}
+
+func (p *memoryProcessor) Enabled(context.Context, sdklog.Record) bool { return true }
+
+func (p *memoryProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *memoryProcessor) ForceFlush(context.Context) error { return nil }
+
+func TestWithLoggerProvider(t *testing.T) {
+
+	processor := &memoryProcessor{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(processor))
+	defer provider.Shutdown(context.Background())
+
+	// 两个中间件实例共用同一个 provider
+	var handlers []http.Handler
+	for _, name := range []string{"orders", "users"} {
+		middleware, err := recordrequestlog.NewMiddleware(
+			recordrequestlog.WithServiceName(name),
+			recordrequestlog.WithLoggerProvider(provider),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handlers = append(handlers, middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})))
+	}
+
+	for _, handler := range handlers {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	}
+
+	processor.mu.Lock()
+	defer processor.mu.Unlock()
+
+	if len(processor.records) != 2 {
+		t.Fatalf("expected both instances to emit through the shared provider, got %d records", len(processor.records))
+	}
+}
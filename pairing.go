@@ -0,0 +1,91 @@
+package recordrequestlog
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 开启 request_record 时请求记录和响应记录的事件
+const (
+	eventRequestReceived  = "request-received"
+	eventResponseComplete = "response-completed"
+)
+
+// pendingRecord 尚未写入的请求记录，request_record_delay 内完成的请求取消写入，只写入一条记录
+type pendingRecord struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	done    bool
+	emitted bool
+}
+
+// scheduleRequestRecord 在收到请求时或 request_record_delay 后写入请求记录，便于发现长时间未响应的请求；
+// 请求记录只包含请求的属性，与响应记录通过请求 ID 关联
+func (x *Exchange) scheduleRequestRecord() {
+
+	e := x.e
+	if !e.requestRecord || !x.sampled {
+		return
+	}
+
+	// 处理器可能在处理过程中修改路由，请求记录使用开始时的路由
+	record := e.newRecord(x.req, x.body)
+	record.Time = x.start
+	e.setStream(&record, x.settings.streamName, x.req)
+	record.Attrs = append(record.Attrs, newConnectionInfo(x.req).attrs(e)...)
+	record.Attrs = append(record.Attrs,
+		slog.String(requestIDKey, x.requestID),
+		slog.String(e.attrKey("event", "event.name"), eventRequestReceived),
+	)
+	if x.route != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("route", "http.route"), x.route))
+	}
+	if x.debug {
+		record.Attrs = append(record.Attrs, slog.Bool("debug", true))
+	}
+	if e.logFormat != LogFormatSemConv {
+		record.Message = eventRequestReceived
+	}
+
+	p := &pendingRecord{}
+	x.pending = p
+
+	emit := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.done {
+			return
+		}
+		p.done, p.emitted = true, true
+		e.emit(x.req.Context(), record)
+	}
+
+	if e.requestRecordDelay <= 0 {
+		emit()
+		return
+	}
+
+	p.timer = time.AfterFunc(e.requestRecordDelay, emit)
+}
+
+// resolvePendingRecord 取消尚未写入的请求记录，返回请求记录是否已经写入；
+// 正在写入时等待写入完成，之后才能释放请求体
+func (x *Exchange) resolvePendingRecord() bool {
+
+	p := x.pending
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.done {
+		p.done = true
+		p.timer.Stop()
+	}
+
+	return p.emitted
+}
@@ -0,0 +1,86 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+	"time"
+)
+
+func TestRequestRecord(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		delay  string
+		wait   bool
+		paired bool
+	}{
+		{name: "immediate", paired: true},
+		{name: "collapsed", delay: "1h"},
+		// 处理器在请求记录写入后才返回
+		{name: "slow response", delay: "10ms", wait: true, paired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.RequestRecord = true
+			cfg.RequestRecordDelay = tt.delay
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var inflight int
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				deadline := time.Now().Add(time.Second)
+				for tt.wait && len(rec.Records()) == 0 && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+				inflight = len(rec.Records())
+				rw.Write([]byte("ok"))
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+			if !tt.paired {
+				record := rec.RequireRecords(t, 1)[0]
+				if _, ok := recordrequestlogtest.Attr(record, "event"); ok {
+					t.Fatal("expected a single record without event")
+				}
+				if _, ok := recordrequestlogtest.Attr(record, "status"); !ok {
+					t.Fatal("expected the collapsed record to carry the status")
+				}
+				return
+			}
+
+			if inflight != 1 {
+				t.Fatalf("expected the request record before the response, got %d records", inflight)
+			}
+
+			records := rec.RequireRecords(t, 2)
+			request, response := records[0], records[1]
+
+			if v, _ := recordrequestlogtest.Attr(request, "event"); v.String() != "request-received" {
+				t.Fatalf("unexpected request record event %q", v.String())
+			}
+			if _, ok := recordrequestlogtest.Attr(request, "status"); ok {
+				t.Fatal("expected no status on the request record")
+			}
+			if v, _ := recordrequestlogtest.Attr(response, "event"); v.String() != "response-completed" {
+				t.Fatalf("unexpected response record event %q", v.String())
+			}
+
+			id, _ := recordrequestlogtest.Attr(request, "request.id")
+			if v, _ := recordrequestlogtest.Attr(response, "request.id"); id.String() == "" || v.String() != id.String() {
+				t.Fatalf("expected records linked by request id, got %q and %q", id.String(), v.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// idPlaceholder 自动折叠的路径段使用的占位符
+const idPlaceholder = "{id}"
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// pathTemplate 单条路径模板规则
+type pathTemplate struct {
+	pattern  *regexp.Regexp
+	template string
+}
+
+// pathTemplater 将请求路径转换为低基数的路由模板，用作 http.route
+type pathTemplater struct {
+	templates []pathTemplate
+	collapse  bool
+}
+
+// newPathTemplater 根据配置生成路径模板，没有规则也不折叠路径段时返回 nil
+func newPathTemplater(configs []PathTemplateConfig, collapse bool) (*pathTemplater, error) {
+
+	if len(configs) == 0 && !collapse {
+		return nil, nil
+	}
+
+	t := &pathTemplater{collapse: collapse}
+
+	for i, config := range configs {
+		re, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path_templates[%d].pattern %q: %w", i, config.Pattern, err)
+		}
+
+		if config.Template == "" {
+			return nil, fmt.Errorf("path_templates[%d].template is required", i)
+		}
+
+		t.templates = append(t.templates, pathTemplate{pattern: re, template: config.Template})
+	}
+
+	return t, nil
+}
+
+// route 返回路径对应的路由模板：使用第一条匹配的规则，模板中可以用 $1、${name} 引用分组；
+// 都不匹配时按配置折叠数字、UUID 和长十六进制路径段，否则返回空字符串
+func (t *pathTemplater) route(path string) string {
+
+	for _, tmpl := range t.templates {
+		if match := tmpl.pattern.FindStringSubmatchIndex(path); match != nil {
+			return string(tmpl.pattern.ExpandString(nil, tmpl.template, path, match))
+		}
+	}
+
+	if !t.collapse {
+		return ""
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if isIDSegment(segment) {
+			segments[i] = idPlaceholder
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// isIDSegment 判断路径段是否像 ID：纯数字、UUID 或至少 16 位的十六进制
+func isIDSegment(segment string) bool {
+
+	if segment == "" {
+		return false
+	}
+
+	if strings.Trim(segment, "0123456789") == "" {
+		return true
+	}
+
+	return uuidSegment.MatchString(segment) || hexSegment.MatchString(segment)
+}
@@ -0,0 +1,44 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"testing"
+)
+
+func TestPathTemplates(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.PathTemplates = []recordrequestlog.PathTemplateConfig{
+		{Pattern: `^/api/(v\d+)/orders/[^/]+$`, Template: "/api/$1/orders/{order}"},
+	}
+	cfg.CollapsePathIDs = true
+
+	tests := map[string]string{
+		"/api/v2/orders/abc-123":                                "/api/v2/orders/{order}",
+		"/users/123/posts/456":                                  "/users/{id}/posts/{id}",
+		"/files/6f1c1d3e-9a4b-4c1e-8f53-2b1d3c4e5f60":           "/files/{id}",
+		"/commits/0123456789abcdef0123456789abcdef01234567/raw": "/commits/{id}/raw",
+		"/health": "/health",
+	}
+
+	for target, want := range tests {
+		record := captureRecord(t, cfg, httptest.NewRequest(http.MethodGet, "http://localhost"+target, nil))
+		if record["route"] != want {
+			t.Errorf("%s: expected route %q, got %v", target, want, record["route"])
+		}
+	}
+}
+
+func TestInvalidPathTemplate(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.PathTemplates = []recordrequestlog.PathTemplateConfig{{Pattern: "(", Template: "/x"}}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	if _, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin"); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
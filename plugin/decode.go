@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Decode 按字段的 yaml 标签将 settings 写入 config 指向的结构体，支持字符串、布尔和数字字段，
+// 与 Traefik 一样允许数字和布尔值以字符串形式出现；没有对应字段的配置返回错误
+func Decode(settings []Setting, config any) error {
+
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", config)
+	}
+	v = v.Elem()
+
+	fields := make(map[string]int, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+
+	for _, s := range settings {
+		i, ok := fields[s.Key]
+		if !ok {
+			return fmt.Errorf("unknown setting %q", s.Key)
+		}
+
+		if err := set(v.Field(i), s.Value); err != nil {
+			return fmt.Errorf("setting %q: %w", s.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func set(field reflect.Value, value any) error {
+
+	if value == nil {
+		return nil
+	}
+
+	if s, ok := value.(string); ok && field.Kind() != reflect.String {
+		parsed, err := parseScalar(s)
+		if err != nil {
+			return err
+		}
+		value = parsed
+	}
+
+	rv := reflect.ValueOf(value)
+	switch field.Kind() {
+	case reflect.String, reflect.Bool:
+		if rv.Kind() != field.Kind() {
+			return fmt.Errorf("cannot use %v as %s", value, field.Type())
+		}
+		field.Set(rv.Convert(field.Type()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Kind() != reflect.Int64 {
+			return fmt.Errorf("cannot use %v as %s", value, field.Type())
+		}
+		field.SetInt(rv.Int())
+	case reflect.Float32, reflect.Float64:
+		switch rv.Kind() {
+		case reflect.Int64:
+			field.SetFloat(float64(rv.Int()))
+		case reflect.Float64:
+			field.SetFloat(rv.Float())
+		default:
+			return fmt.Errorf("cannot use %v as %s", value, field.Type())
+		}
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
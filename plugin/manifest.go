@@ -0,0 +1,216 @@
+// Package plugin 提供作为 Traefik 插件（本地插件或插件目录）安装时用到的辅助函数：
+// 生成和解析插件清单 .traefik.yml，并按配置字段的 yaml 标签将 testData 写入 CreateConfig 返回的配置
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ManifestFile 插件清单的文件名，位于模块根目录
+const ManifestFile = ".traefik.yml"
+
+// 插件类型
+const (
+	TypeMiddleware = "middleware"
+	TypeProvider   = "provider"
+)
+
+// Setting testData 中的一项配置，按清单中的顺序保存
+type Setting struct {
+	Key   string
+	Value any
+}
+
+// Manifest Traefik 插件清单，只包含本插件用到的字段。Import 为 Traefik 加载的包，
+// BasePkg 为空时使用 Import 的最后一段作为包名
+type Manifest struct {
+	DisplayName string
+	Type        string
+	Import      string
+	BasePkg     string
+	Summary     string
+	TestData    []Setting
+}
+
+// Validate 检查 Traefik 加载插件必需的字段
+func (m *Manifest) Validate() error {
+
+	var errs []error
+
+	if m.DisplayName == "" {
+		errs = append(errs, errors.New("missing displayName"))
+	}
+
+	switch m.Type {
+	case TypeMiddleware, TypeProvider:
+	default:
+		errs = append(errs, fmt.Errorf("invalid type %q", m.Type))
+	}
+
+	if m.Import == "" {
+		errs = append(errs, errors.New("missing import"))
+	}
+
+	if len(m.TestData) == 0 {
+		errs = append(errs, errors.New("missing testData"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Package 返回 Traefik 查找 CreateConfig 和 New 时使用的包名
+func (m *Manifest) Package() string {
+
+	if m.BasePkg != "" {
+		return m.BasePkg
+	}
+
+	return strings.ReplaceAll(path.Base(m.Import), "-", "_")
+}
+
+// Marshal 生成清单的 YAML，字符串一律加引号
+func (m *Manifest) Marshal() []byte {
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "displayName: %s\n", quote(m.DisplayName))
+	fmt.Fprintf(&b, "type: %s\n", m.Type)
+	fmt.Fprintf(&b, "\nimport: %s\n", m.Import)
+	if m.BasePkg != "" {
+		fmt.Fprintf(&b, "basePkg: %s\n", m.BasePkg)
+	}
+	fmt.Fprintf(&b, "\nsummary: %s\n", quote(m.Summary))
+
+	b.WriteString("\ntestData:\n")
+	for _, s := range m.TestData {
+		fmt.Fprintf(&b, "  %s: %s\n", s.Key, scalar(s.Value))
+	}
+
+	return b.Bytes()
+}
+
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+func scalar(v any) string {
+
+	switch v := v.(type) {
+	case string:
+		return quote(v)
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ParseManifest 解析清单，只支持 Marshal 生成的格式：顶层为标量字段，testData 为一层键值
+func ParseManifest(r io.Reader) (*Manifest, error) {
+
+	m := &Manifest{}
+	inTestData := false
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		nested := strings.HasPrefix(line, " ")
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: expected key: value", ManifestFile, n)
+		}
+		value = strings.TrimSpace(value)
+
+		if nested {
+			if !inTestData {
+				return nil, fmt.Errorf("%s line %d: unexpected indentation", ManifestFile, n)
+			}
+			v, err := parseScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", ManifestFile, n, err)
+			}
+			m.TestData = append(m.TestData, Setting{Key: key, Value: v})
+			continue
+		}
+
+		inTestData = false
+		s, err := parseString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", ManifestFile, n, err)
+		}
+
+		switch key {
+		case "displayName":
+			m.DisplayName = s
+		case "type":
+			m.Type = s
+		case "import":
+			m.Import = s
+		case "basePkg":
+			m.BasePkg = s
+		case "summary":
+			m.Summary = s
+		case "testData":
+			inTestData = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// parseString 去掉单引号或双引号
+func parseString(value string) (string, error) {
+
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	default:
+		return value, nil
+	}
+}
+
+// parseScalar 未加引号的 true/false 和数字按对应类型解析，其余作为字符串
+func parseScalar(value string) (any, error) {
+
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		return parseString(value)
+	}
+
+	switch value {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "~", "":
+		return nil, nil
+	}
+
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, nil
+	}
+
+	return value, nil
+}
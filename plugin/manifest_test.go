@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+
+	m := &Manifest{
+		DisplayName: "record request log Plugin",
+		Type:        TypeMiddleware,
+		Import:      "github.com/sanyuanya/recordrequestlog/lite",
+		Summary:     "记录请求的日志信息",
+		TestData: []Setting{
+			{Key: "endpoint", Value: "http://collector:4318"},
+			{Key: "sample_rate", Value: 0.5},
+			{Key: "max_body_size", Value: int64(1024)},
+			{Key: "fail_open", Value: false},
+		},
+	}
+
+	parsed, err := ParseManifest(bytes.NewReader(m.Marshal()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, m) {
+		t.Fatalf("expected %+v, got %+v", m, parsed)
+	}
+
+	if err := parsed.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Package(); got != "lite" {
+		t.Fatalf("expected package lite, got %q", got)
+	}
+}
+
+func TestParseManifestQuoting(t *testing.T) {
+
+	m, err := ParseManifest(strings.NewReader("displayName: demo Plugin\ntype: middleware\nsummary: 'it''s a demo'\ntestData:\n  name: demo\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.DisplayName != "demo Plugin" || m.Summary != "it's a demo" || m.TestData[0].Value != "demo" {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "missing import") {
+		t.Fatalf("expected missing import, got %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+
+	var config struct {
+		Endpoint   string  `yaml:"endpoint,omitempty"`
+		FailOpen   bool    `yaml:"fail_open,omitempty"`
+		MaxSize    int     `yaml:"max_size,omitempty"`
+		SampleRate float64 `yaml:"sample_rate,omitempty"`
+		Internal   string  `yaml:"-"`
+	}
+
+	err := Decode([]Setting{
+		{Key: "endpoint", Value: "http://collector:4318"},
+		{Key: "fail_open", Value: "true"},
+		{Key: "max_size", Value: int64(10)},
+		{Key: "sample_rate", Value: int64(1)},
+	}, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Endpoint != "http://collector:4318" || !config.FailOpen || config.MaxSize != 10 || config.SampleRate != 1 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	if err := Decode([]Setting{{Key: "-", Value: "x"}}, &config); err == nil {
+		t.Fatal("expected unknown setting error")
+	}
+	if err := Decode([]Setting{{Key: "max_size", Value: "many"}}, &config); err == nil {
+		t.Fatal("expected type error")
+	}
+}
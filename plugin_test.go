@@ -0,0 +1,100 @@
+package recordrequestlog_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/lite"
+	"recordrequestlog/plugin"
+	"strings"
+	"testing"
+)
+
+// Traefik 通过反射调用插件包的 CreateConfig 和 New，签名不匹配时插件无法加载
+var (
+	_ func() *recordrequestlog.Config                                                             = recordrequestlog.CreateConfig
+	_ func(context.Context, http.Handler, *recordrequestlog.Config, string) (http.Handler, error) = recordrequestlog.New
+	_ func() *lite.Config                                                                         = lite.CreateConfig
+	_ func(context.Context, http.Handler, *lite.Config, string) (http.Handler, error)             = lite.New
+)
+
+func readManifest(t *testing.T) (*plugin.Manifest, []byte) {
+
+	t.Helper()
+
+	b, err := os.ReadFile(plugin.ManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := plugin.ParseManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return m, b
+}
+
+func TestPluginManifest(t *testing.T) {
+
+	m, b := readManifest(t)
+
+	if !bytes.Equal(m.Marshal(), b) {
+		t.Fatalf("%s is not in the format generated by plugin.Manifest.Marshal:\n%s", plugin.ManifestFile, m.Marshal())
+	}
+
+	// Traefik 按 import 在 GOPATH 中查找插件包，包目录在模块中需要存在且包名一致
+	dir, ok := strings.CutPrefix(m.Import, "github.com/sanyuanya/recordrequestlog")
+	if !ok {
+		t.Fatalf("unexpected import %q", m.Import)
+	}
+	if _, err := os.Stat(filepath.Join(".", filepath.FromSlash(dir), "lite.go")); err != nil || m.Package() != "lite" {
+		t.Fatalf("expected import to point at the lite package, got %q", m.Import)
+	}
+}
+
+func TestPluginTestData(t *testing.T) {
+
+	m, _ := readManifest(t)
+
+	liteConfig := lite.CreateConfig()
+	if err := plugin.Decode(m.TestData, liteConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	// 插件清单使用 lite 包，只能导出到 OTLP/HTTP 的地址
+	if u, err := url.Parse(liteConfig.Endpoint); err != nil || u.Port() == "4317" || u.Port() == "5081" {
+		t.Errorf("expected an OTLP/HTTP endpoint in testData, got %q", liteConfig.Endpoint)
+	}
+
+	handler, err := lite.New(context.Background(), http.NotFoundHandler(), liteConfig, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.(*lite.RecordRequestLog).Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// testData 在完整版中同样有效
+	config := recordrequestlog.CreateConfig()
+	if err := plugin.Decode(m.TestData, config); err != nil {
+		t.Fatal(err)
+	}
+	config.EnableLogs, config.EnableTraces, config.EnableMetrics = false, false, false
+
+	handler, err = recordrequestlog.New(context.Background(), http.NotFoundHandler(), config, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.(*recordrequestlog.RecordRequestLog).Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,46 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 放回池中的缓冲区容量上限，避免偶尔的大请求体长期占用内存
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// getBuffer 从池中取出一个空的缓冲区，用完后调用 putBuffer 放回
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+
+	if b.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// span 和指标的属性在调用返回前即被复制，属性切片可以在请求内复用
+var attrsPool = sync.Pool{New: func() any {
+	attrs := make([]attribute.KeyValue, 0, 16)
+	return &attrs
+}}
+
+// getAttrs 从池中取出一个空的属性切片，用完后调用 putAttrs 放回
+func getAttrs() *[]attribute.KeyValue {
+	return attrsPool.Get().(*[]attribute.KeyValue)
+}
+
+func putAttrs(attrs *[]attribute.KeyValue) {
+
+	clear(*attrs)
+	*attrs = (*attrs)[:0]
+	attrsPool.Put(attrs)
+}
@@ -0,0 +1,44 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// OPTIONS、HEAD 和 CORS 预检请求的记录方式
+const (
+	// 与其他请求一样记录
+	PreflightModeLog = "log"
+	// 不写入日志，span 和指标照常记录
+	PreflightModeMetrics = "metrics"
+	// 不写入日志也不创建 span，只计入 http.server.request.duration 等请求指标
+	PreflightModeDrop = "drop"
+)
+
+// parsePreflightMode 检查 preflight_mode，为空时为 log
+func parsePreflightMode(mode string) (string, error) {
+
+	switch mode {
+	case "":
+		return PreflightModeLog, nil
+	case PreflightModeLog, PreflightModeMetrics, PreflightModeDrop:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid preflight_mode %q", mode)
+	}
+}
+
+// isPreflight 判断是否为 OPTIONS、HEAD 请求或带有 Access-Control-Request-Method 的 CORS 预检请求
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions || req.Method == http.MethodHead || req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// suppressed 返回请求按 preflight_mode 不写入日志时的记录方式，需要记录时返回空字符串
+func (e *RecordRequestLog) suppressed(req *http.Request) string {
+
+	if e.preflightMode == PreflightModeLog || !isPreflight(req) {
+		return ""
+	}
+
+	return e.preflightMode
+}
@@ -0,0 +1,67 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPreflightMode(t *testing.T) {
+
+	tests := map[string]int{
+		recordrequestlog.PreflightModeMetrics: 4,
+		recordrequestlog.PreflightModeDrop:    1,
+	}
+
+	for mode, spans := range tests {
+		t.Run(mode, func(t *testing.T) {
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.PreflightMode = mode
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+			preflight := httptest.NewRequest(http.MethodOptions, "http://localhost/api", nil)
+			preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			cors := httptest.NewRequest(http.MethodPost, "http://localhost/api", nil)
+			cors.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+			for _, req := range []*http.Request{
+				preflight,
+				cors,
+				httptest.NewRequest(http.MethodHead, "http://localhost/api", nil),
+				httptest.NewRequest(http.MethodGet, "http://localhost/api", nil),
+			} {
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			record := rec.RequireRecords(t, 1)[0]
+			if v, _ := recordrequestlogtest.Attr(record, "method"); v.String() != http.MethodGet {
+				t.Fatalf("expected only the GET record, got %v", v)
+			}
+
+			if got := len(rec.Spans()); got != spans {
+				t.Fatalf("expected %d spans, got %d", spans, got)
+			}
+
+			// 所有请求仍计入请求指标
+			var count uint64
+			for _, point := range rec.RequireMetric(t, "http.server.request.duration").Data.(metricdata.Histogram[float64]).DataPoints {
+				count += point.Count
+			}
+			if count != 4 {
+				t.Fatalf("expected 4 requests in metrics, got %d", count)
+			}
+		})
+	}
+}
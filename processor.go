@@ -0,0 +1,53 @@
+package recordrequestlog
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RecordProcessor 在记录导出前修改或过滤记录，例如自定义的丢弃规则、改写属性或加密指定字段。
+// 多个 RecordProcessor 按添加顺序调用，返回 false 时丢弃记录，之后的 RecordProcessor 不再调用。
+// 会被并发调用
+type RecordProcessor interface {
+	Process(ctx context.Context, record *Record) (keep bool)
+}
+
+// RecordProcessorFunc 将函数转换为 RecordProcessor
+type RecordProcessorFunc func(ctx context.Context, record *Record) bool
+
+func (f RecordProcessorFunc) Process(ctx context.Context, record *Record) bool {
+	return f(ctx, record)
+}
+
+// WithRecordProcessor 追加导出前调用的 RecordProcessor
+func WithRecordProcessor(processors ...RecordProcessor) Option {
+	return func(o *options) {
+		// 不修改 WithConfig 传入的配置中的切片
+		o.config.RecordProcessors = append(slices.Clip(o.config.RecordProcessors), processors...)
+	}
+}
+
+// process 依次调用 RecordProcessor，返回是否导出记录。RecordProcessor panic 时丢弃记录，
+// 避免导出未按预期处理（例如未加密）的数据
+func (e *RecordRequestLog) process(ctx context.Context, record *Record) (keep bool) {
+
+	defer func() {
+		if v := recover(); v != nil {
+			e.droppedRecords.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "processor_panic")))
+			e.logError("process record", fmt.Errorf("panic: %v", v))
+			keep = false
+		}
+	}()
+
+	for _, p := range e.processors {
+		if !p.Process(ctx, record) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+)
+
+func TestRecordProcessor(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	// 丢弃健康检查，并改写 appid
+	dropHealth := recordrequestlog.RecordProcessorFunc(func(ctx context.Context, record *recordrequestlog.Record) bool {
+		v, _ := recordrequestlogtest.Attr(*record, "url")
+		return v.String() != "http://localhost/healthz"
+	})
+	rewrite := recordrequestlog.RecordProcessorFunc(func(ctx context.Context, record *recordrequestlog.Record) bool {
+		for i, attr := range record.Attrs {
+			if attr.Key == "appid" {
+				record.Attrs[i] = slog.String("appid", "masked")
+			}
+		}
+		return true
+	})
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option(), recordrequestlog.WithRecordProcessor(dropHealth, rewrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/healthz", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/api", nil)
+	req.Header.Set("AppId", "app-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := rec.RequireRecords(t, 1)
+	if v, _ := recordrequestlogtest.Attr(records[0], "appid"); v.String() != "masked" {
+		t.Fatalf("unexpected appid %q", v)
+	}
+}
+
+func TestRecordProcessorPanic(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	panicking := recordrequestlog.RecordProcessorFunc(func(ctx context.Context, record *recordrequestlog.Record) bool {
+		panic("boom")
+	})
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option(), recordrequestlog.WithRecordProcessor(panicking))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	// 处理器 panic 时不导出未处理的记录
+	rec.RequireRecords(t, 0)
+}
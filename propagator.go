@@ -0,0 +1,53 @@
+package recordrequestlog
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// 可组合的 trace 上下文传播格式，名称与 OTEL_PROPAGATORS 一致
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	// PropagatorB3 单个 b3 请求头，PropagatorB3Multi 多个 X-B3-* 请求头
+	PropagatorB3      = "b3"
+	PropagatorB3Multi = "b3multi"
+	PropagatorJaeger  = "jaeger"
+	PropagatorXRay    = "xray"
+)
+
+var defaultPropagators = []string{PropagatorTraceContext, PropagatorBaggage}
+
+// newPropagator 按顺序组合传播器，提取时后面的传播器覆盖前面提取的结果，注入时写入全部格式的请求头
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+
+	if len(names) == 0 {
+		names = defaultPropagators
+	}
+
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		case PropagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		case PropagatorXRay:
+			propagators = append(propagators, xray.Propagator{})
+		default:
+			return nil, fmt.Errorf("invalid propagators %q", name)
+		}
+	}
+
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
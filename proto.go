@@ -0,0 +1,262 @@
+package recordrequestlog
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobuf 请求体的封装方式
+const (
+	// 不分帧的单个消息，例如 Connect 一元调用
+	protoUnframed = iota + 1
+	// 每个消息以 1 字节标志和 4 字节长度开头，例如 gRPC-Web、Connect 流式调用
+	protoFramed
+	// base64 编码的分帧消息，即 application/grpc-web-text
+	protoFramedText
+)
+
+// protoFraming 返回内容类型对应的 protobuf 封装方式，不是 protobuf 时返回 0。
+// 原生 gRPC 请求由拦截器记录，不在此处理
+func protoFraming(contentType string) int {
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "application/proto", "application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf":
+		return protoUnframed
+	case "application/grpc-web", "application/grpc-web+proto", "application/connect+proto":
+		return protoFramed
+	case "application/grpc-web-text", "application/grpc-web-text+proto":
+		return protoFramedText
+	default:
+		return 0
+	}
+}
+
+// WithProtoFiles 注册用于解码 protobuf 请求体的文件描述符，例如生成代码中的 File_xxx_proto
+func WithProtoFiles(files ...protoreflect.FileDescriptor) Option {
+	return func(o *options) {
+		o.config.ProtoFiles = append(slices.Clip(o.config.ProtoFiles), files...)
+	}
+}
+
+// newProtoFiles 加载 proto_descriptor_sets 中的描述符集合和 WithProtoFiles 注册的文件描述符，都没有时返回 nil
+func newProtoFiles(paths []string, descriptors []protoreflect.FileDescriptor) (*protoregistry.Files, error) {
+
+	if len(paths) == 0 && len(descriptors) == 0 {
+		return nil, nil
+	}
+
+	files := &protoregistry.Files{}
+
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read proto descriptor set: %w", err)
+		}
+
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(b, &set); err != nil {
+			return nil, fmt.Errorf("parse proto descriptor set %s: %w", path, err)
+		}
+
+		loaded, err := protodesc.NewFiles(&set)
+		if err != nil {
+			return nil, fmt.Errorf("load proto descriptor set %s: %w", path, err)
+		}
+
+		var rerr error
+		loaded.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			rerr = registerProtoFile(files, fd)
+			return rerr == nil
+		})
+		if rerr != nil {
+			return nil, rerr
+		}
+	}
+
+	for _, fd := range descriptors {
+		if err := registerProtoFile(files, fd); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// registerProtoFile 注册文件描述符，同一个文件重复注册时忽略
+func registerProtoFile(files *protoregistry.Files, fd protoreflect.FileDescriptor) error {
+
+	if _, err := files.FindFileByPath(fd.Path()); err == nil {
+		return nil
+	}
+
+	if err := files.RegisterFile(fd); err != nil {
+		return fmt.Errorf("register proto file %s: %w", fd.Path(), err)
+	}
+
+	return nil
+}
+
+// protoMessageType 查找请求体的消息类型：优先使用内容类型的 proto 或 messageType 参数，
+// 否则按 /package.Service/Method 形式的路径查找方法的请求消息。先查找注册的描述符，再查找程序中生成代码注册的描述符
+func (e *RecordRequestLog) protoMessageType(req *http.Request, contentType string) (protoreflect.MessageDescriptor, bool) {
+
+	_, params, _ := mime.ParseMediaType(contentType)
+
+	name := params["proto"]
+	if name == "" {
+		name = params["messagetype"]
+	}
+
+	resolvers := []*protoregistry.Files{protoregistry.GlobalFiles}
+	if e.protoFiles != nil {
+		resolvers = []*protoregistry.Files{e.protoFiles, protoregistry.GlobalFiles}
+	}
+
+	for _, files := range resolvers {
+		if name != "" {
+			if d, err := files.FindDescriptorByName(protoreflect.FullName(name)); err == nil {
+				if md, ok := d.(protoreflect.MessageDescriptor); ok {
+					return md, true
+				}
+			}
+			continue
+		}
+
+		p := strings.Trim(req.URL.Path, "/")
+		i := strings.LastIndex(p, "/")
+		if i < 0 {
+			return nil, false
+		}
+		// 路径可能带有前缀，服务名取方法前的一段
+		service, method := p[:i], p[i+1:]
+		service = service[strings.LastIndex(service, "/")+1:]
+
+		d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+		if err != nil {
+			continue
+		}
+		if sd, ok := d.(protoreflect.ServiceDescriptor); ok {
+			if md := sd.Methods().ByName(protoreflect.Name(method)); md != nil {
+				return md.Input(), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// captureProto 读取并解码 protobuf 请求体，无法解码时返回 false，按二进制内容处理。
+// 分帧的请求体只在长度已知时读取，避免阻塞客户端流式调用
+func (e *RecordRequestLog) captureProto(req *http.Request, s *routeSettings, body *capturedBody) (bool, error) {
+
+	framing := protoFraming(body.contentType)
+	if framing == 0 || (framing != protoUnframed && req.ContentLength < 0) {
+		return false, nil
+	}
+
+	md, ok := e.protoMessageType(req, body.contentType)
+	if !ok || (body.contentEncoding != "" && !decodableEncoding(body.contentEncoding)) {
+		return false, nil
+	}
+
+	b, err := readBody(req, int64(s.maxBodySize))
+	if err != nil {
+		return false, err
+	}
+
+	rawTruncated := len(b) > s.maxBodySize
+	if rawTruncated && framing == protoUnframed {
+		return false, nil
+	}
+
+	var raw []byte
+	if body.contentEncoding != "" {
+		if raw, _, err = decodeBody(body.contentEncoding, b, s.maxBodySize, rawTruncated); err != nil {
+			e.logError("decode request body", err)
+			return false, nil
+		}
+	} else {
+		raw = []byte(b)
+	}
+
+	content, truncated, ok := e.decodeProto(md, framing, raw, s)
+	if !ok {
+		return false, nil
+	}
+
+	body.content = content
+	body.truncated = truncated || rawTruncated
+	body.encoding = "protojson"
+	return true, nil
+}
+
+// decodeProto 将 protobuf 请求体解码为脱敏后的 JSON，分帧的多个消息各占一行。
+// 压缩的帧和被截断的帧不解码；没有解码出任何消息时返回 false
+func (e *RecordRequestLog) decodeProto(md protoreflect.MessageDescriptor, framing int, raw []byte, s *routeSettings) (string, bool, bool) {
+
+	if framing == protoFramedText {
+		text := strings.TrimRight(string(raw), "=")
+		decoded, err := base64.RawStdEncoding.DecodeString(text)
+		if err != nil {
+			// 截断的内容可能在编码单元中间结束
+			decoded, _ = base64.RawStdEncoding.DecodeString(text[:len(text)/4*4])
+		}
+		raw = decoded
+	}
+
+	var frames [][]byte
+	if framing == protoUnframed {
+		frames = [][]byte{raw}
+	} else {
+		for len(raw) >= 5 {
+			flags, size := raw[0], binary.BigEndian.Uint32(raw[1:5])
+			if uint64(len(raw)-5) < uint64(size) {
+				break
+			}
+			// 只解码未压缩的数据帧，忽略 gRPC-Web 的 trailer 帧
+			if flags == 0 {
+				frames = append(frames, raw[5:5+size])
+			}
+			raw = raw[5+size:]
+		}
+	}
+
+	var (
+		messages  []string
+		truncated bool
+	)
+	for _, frame := range frames {
+		msg := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(frame, msg); err != nil {
+			break
+		}
+
+		content, cut := e.rpcMessage(msg, s)
+		messages = append(messages, content)
+		if cut {
+			truncated = true
+			break
+		}
+	}
+
+	if len(messages) == 0 {
+		return "", false, false
+	}
+
+	return strings.Join(messages, "\n"), truncated, true
+}
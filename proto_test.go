@@ -0,0 +1,179 @@
+package recordrequestlog_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// orderFile 构造测试用的 demo/v1/order.proto 文件描述符
+func orderFile(t *testing.T) (*descriptorpb.FileDescriptorProto, protoreflect.FileDescriptor) {
+
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("demo/v1/order.proto"),
+		Package: proto.String("demo.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("CreateOrderRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("token", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("OrderService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("CreateOrder"),
+				InputType:  proto.String(".demo.v1.CreateOrderRequest"),
+				OutputType: proto.String(".demo.v1.CreateOrderRequest"),
+			}},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fdp, fd
+}
+
+func orderMessage(t *testing.T, fd protoreflect.FileDescriptor) []byte {
+
+	md := fd.Messages().ByName("CreateOrderRequest")
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("id"), protoreflect.ValueOfString("A-1001"))
+	msg.Set(md.Fields().ByName("token"), protoreflect.ValueOfString("t-secret"))
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func frame(msg []byte) []byte {
+
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+func TestProtoBody(t *testing.T) {
+
+	fdp, fd := orderFile(t)
+	msg := orderMessage(t, fd)
+
+	set, _ := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fdp}})
+	setPath := filepath.Join(t.TempDir(), "order.binpb")
+	if err := os.WriteFile(setPath, set, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		options     []recordrequestlog.Option
+		contentType string
+		body        []byte
+	}{
+		"connect": {
+			options:     []recordrequestlog.Option{recordrequestlog.WithProtoFiles(fd)},
+			contentType: "application/proto",
+			body:        msg,
+		},
+		"grpc-web": {
+			options:     []recordrequestlog.Option{recordrequestlog.WithProtoFiles(fd)},
+			contentType: "application/grpc-web+proto",
+			body:        frame(msg),
+		},
+		"grpc-web-text": {
+			options:     []recordrequestlog.Option{recordrequestlog.WithProtoFiles(fd)},
+			contentType: "application/grpc-web-text",
+			body:        []byte(base64.StdEncoding.EncodeToString(frame(msg))),
+		},
+		"descriptor set": {
+			options: []recordrequestlog.Option{recordrequestlog.WithConfig(func() *recordrequestlog.Config {
+				cfg := recordrequestlog.CreateConfig()
+				cfg.ProtoDescriptorSets = []string{setPath}
+				return cfg
+			}())},
+			contentType: "application/proto",
+			body:        msg,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := recordrequestlogtest.New()
+
+			middleware, err := recordrequestlog.NewMiddleware(append(tt.options, rec.Option())...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var received []byte
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				received, _ = io.ReadAll(req.Body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/demo.v1.OrderService/CreateOrder", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !bytes.Equal(received, tt.body) {
+				t.Fatal("handler received a modified body")
+			}
+
+			record := rec.RequireRecord(t, recordrequestlogtest.HasAttr("body-encoding", "protojson"))
+			if want := `{"id":"A-1001","token":"REDACTED"}`; record.Message != want {
+				t.Fatalf("expected message %s, got %s", want, record.Message)
+			}
+		})
+	}
+}
+
+func TestProtoBodyUnknownType(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/demo.v1.OrderService/CreateOrder", bytes.NewReader([]byte{0x0a, 0x01, 'x'}))
+	req.Header.Set("Content-Type", "application/proto")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 找不到消息类型时按二进制内容处理，只记录大小
+	record := rec.RequireRecords(t, 1)[0]
+	if record.Message != "" {
+		t.Fatalf("expected no content, got %q", record.Message)
+	}
+}
@@ -0,0 +1,83 @@
+package recordrequestlog
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// connectionInfo 请求所在连接的协议信息，用于排查客户端兼容性问题
+type connectionInfo struct {
+	// HTTP 版本，例如 "1.1"、"2"、"3"
+	protocolVersion string
+	// 传输层，tcp、udp 或 unix，未知时为空
+	transport string
+	// TLS 版本，例如 "1.3"，明文连接时为空
+	tlsVersion string
+	tlsCipher  string
+}
+
+// newConnectionInfo 从服务端收到的请求中读取协议信息
+func newConnectionInfo(req *http.Request) connectionInfo {
+
+	info := connectionInfo{protocolVersion: strconv.Itoa(req.ProtoMajor)}
+	if req.ProtoMajor < 2 {
+		info.protocolVersion += "." + strconv.Itoa(req.ProtoMinor)
+	}
+
+	// unixpacket 等同样视为 unix
+	if addr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		info.transport = addr.Network()
+		if strings.HasPrefix(info.transport, "unix") {
+			info.transport = "unix"
+		}
+	}
+
+	if req.TLS != nil {
+		info.tlsVersion = strings.TrimPrefix(tls.VersionName(req.TLS.Version), "TLS ")
+		info.tlsCipher = tls.CipherSuiteName(req.TLS.CipherSuite)
+	}
+
+	return info
+}
+
+// attrs 返回记录中的协议属性
+func (c connectionInfo) attrs(e *RecordRequestLog) []slog.Attr {
+
+	attrs := []slog.Attr{slog.String(e.attrKey("protocol-version", "network.protocol.version"), c.protocolVersion)}
+
+	if c.transport != "" {
+		attrs = append(attrs, slog.String(e.attrKey("transport", "network.transport"), c.transport))
+	}
+
+	if c.tlsVersion != "" {
+		attrs = append(attrs,
+			slog.String(e.attrKey("tls-version", "tls.protocol.version"), c.tlsVersion),
+			slog.String(e.attrKey("tls-cipher", "tls.cipher"), c.tlsCipher),
+		)
+	}
+
+	return attrs
+}
+
+// spanAttrs 返回 server span 的协议属性
+func (c connectionInfo) spanAttrs() []attribute.KeyValue {
+
+	attrs := []attribute.KeyValue{semconv.NetworkProtocolVersion(c.protocolVersion)}
+
+	if c.transport != "" {
+		attrs = append(attrs, semconv.NetworkTransportKey.String(c.transport))
+	}
+
+	if c.tlsVersion != "" {
+		attrs = append(attrs, semconv.TLSProtocolVersion(c.tlsVersion), semconv.TLSCipher(c.tlsCipher))
+	}
+
+	return attrs
+}
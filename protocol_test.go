@@ -0,0 +1,100 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+)
+
+func TestConnectionAttributes(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	t.Run("tls", func(t *testing.T) {
+		rec.Reset()
+
+		server := httptest.NewUnstartedServer(handler)
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		resp, err := server.Client().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		record := rec.RequireRecords(t, 1)[0]
+		for key, want := range map[string]string{
+			"protocol-version": "2",
+			"transport":        "tcp",
+			"tls-version":      "1.3",
+		} {
+			if got, _ := recordrequestlogtest.Attr(record, key); got.String() != want {
+				t.Errorf("expected %s %q, got %q", key, want, got.String())
+			}
+		}
+		if cipher, _ := recordrequestlogtest.Attr(record, "tls-cipher"); cipher.String() == "" {
+			t.Error("expected tls-cipher")
+		}
+
+		span := rec.RequireSpan(t, http.MethodGet)
+		var version string
+		for _, attr := range span.Attributes {
+			if attr.Key == "tls.protocol.version" {
+				version = attr.Value.AsString()
+			}
+		}
+		if version != "1.3" {
+			t.Errorf("expected span tls.protocol.version 1.3, got %q", version)
+		}
+	})
+
+	t.Run("unix", func(t *testing.T) {
+		rec.Reset()
+
+		path := filepath.Join(t.TempDir(), "server.sock")
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skip(err)
+		}
+
+		server := &http.Server{Handler: handler}
+		go server.Serve(listener)
+		defer server.Close()
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+		resp, err := client.Get("http://localhost/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		record := rec.RequireRecords(t, 1)[0]
+		if got, _ := recordrequestlogtest.Attr(record, "transport"); got.String() != "unix" {
+			t.Errorf("expected transport unix, got %q", got.String())
+		}
+		if got, _ := recordrequestlogtest.Attr(record, "protocol-version"); got.String() != "1.1" {
+			t.Errorf("expected protocol-version 1.1, got %q", got.String())
+		}
+		if _, ok := recordrequestlogtest.Attr(record, "tls-version"); ok {
+			t.Error("unexpected tls-version on plaintext connection")
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package recordrequestlog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+)
+
+// 连接代理的超时时间，上下文没有截止时间时使用
+const proxyDialTimeout = 10 * time.Second
+
+// exportProxy 导出连接经过的 HTTP CONNECT 或 SOCKS5 代理，未配置 export_proxy 时为 nil，
+// 此时 HTTP 导出器和 gRPC 导出器按 HTTPS_PROXY、HTTP_PROXY 和 NO_PROXY 环境变量选择代理
+type exportProxy struct {
+	url *url.URL
+}
+
+// newExportProxy 解析 export_proxy，export_proxy_username 和 export_proxy_password 覆盖地址中的认证信息
+func newExportProxy(config *Config) (*exportProxy, error) {
+
+	if config.ExportProxy == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(config.ExportProxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export_proxy: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid export_proxy %q: scheme must be http, https or socks5", u.Redacted())
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid export_proxy %q: missing host", u.Redacted())
+	}
+
+	if config.ExportProxyUsername != "" {
+		u.User = url.UserPassword(config.ExportProxyUsername, config.ExportProxyPassword)
+	}
+
+	return &exportProxy{url: u}, nil
+}
+
+// httpProxy 作为 http.Transport 的 Proxy，所有导出请求都经过代理
+func (p *exportProxy) httpProxy(*http.Request) (*url.URL, error) {
+	return p.url, nil
+}
+
+// transport 返回 HTTP 后端使用的 Transport，未配置代理时为 nil，使用 http.DefaultTransport
+func (p *exportProxy) transport() http.RoundTripper {
+
+	if p == nil {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = p.httpProxy
+	return transport
+}
+
+// dialOptions 返回 OTLP gRPC 导出器经代理建立连接的 dialer，未配置代理时为空
+func (p *exportProxy) dialOptions() []grpc.DialOption {
+
+	if p == nil {
+		return nil
+	}
+
+	return []grpc.DialOption{grpc.WithContextDialer(p.dial)}
+}
+
+// dial 经代理连接 address
+func (p *exportProxy) dial(ctx context.Context, address string) (net.Conn, error) {
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, proxyDialTimeout)
+		defer cancel()
+	}
+
+	if p.url.Scheme == "socks5" {
+		return p.dialSOCKS5(ctx, address)
+	}
+
+	return p.dialConnect(ctx, address)
+}
+
+func (p *exportProxy) dialSOCKS5(ctx context.Context, address string) (net.Conn, error) {
+
+	var auth *proxy.Auth
+	if p.url.User != nil {
+		password, _ := p.url.User.Password()
+		auth = &proxy.Auth{User: p.url.User.Username(), Password: password}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", p.proxyAddress(), auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s through socks5 proxy: %w", address, err)
+	}
+
+	return conn, nil
+}
+
+// dialConnect 通过 HTTP CONNECT 建立到 address 的隧道，https 代理先与代理建立 TLS 连接
+func (p *exportProxy) dialConnect(ctx context.Context, address string) (_ net.Conn, err error) {
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.proxyAddress())
+	if err != nil {
+		return nil, fmt.Errorf("dial export_proxy: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+
+	if p.url.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: p.url.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("tls handshake with export_proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if p.url.User != nil {
+		password, _ := p.url.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.url.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("write CONNECT to export_proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("read CONNECT response from export_proxy: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("export_proxy CONNECT %s: %s", address, resp.Status)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	// 代理在响应之后已经发送的数据保留在 br 中
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+
+	return conn, nil
+}
+
+// proxyAddress 返回代理的 host:port，地址中没有端口时使用协议的默认端口
+func (p *exportProxy) proxyAddress() string {
+
+	if p.url.Port() != "" {
+		return p.url.Host
+	}
+
+	port := "80"
+	switch p.url.Scheme {
+	case "https":
+		port = "443"
+	case "socks5":
+		port = "1080"
+	}
+
+	return net.JoinHostPort(p.url.Hostname(), port)
+}
+
+// bufferedConn 先读取 CONNECT 响应之后已缓冲的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
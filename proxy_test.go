@@ -0,0 +1,221 @@
+package recordrequestlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// echoServer 将收到的数据原样返回
+func echoServer(t *testing.T) string {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// connectProxy 只接受携带 Proxy-Authorization 的 CONNECT 请求
+func connectProxy(t *testing.T, authorization string) (string, *[]string) {
+
+	var targets []string
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != authorization {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				targets = append(targets, req.Host)
+
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+
+				io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), &targets
+}
+
+// socks5Proxy 不需要认证的 SOCKS5 代理，只支持 IPv4 地址的 CONNECT
+func socks5Proxy(t *testing.T) string {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				greeting := make([]byte, 2)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+
+				request := make([]byte, 10)
+				if _, err := io.ReadFull(conn, request); err != nil || request[3] != 1 {
+					return
+				}
+				address := net.JoinHostPort(net.IP(request[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(request[8:]))))
+
+				target, err := net.Dial("tcp", address)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// requireEcho 经 p 连接 address 并检查回显
+func requireEcho(t *testing.T, p *exportProxy, address string) {
+
+	t.Helper()
+
+	conn, err := p.dial(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Fatalf("expected echo through proxy, got %q (%v)", reply, err)
+	}
+}
+
+func TestExportProxyConnect(t *testing.T) {
+
+	backend := echoServer(t)
+	address, targets := connectProxy(t, "Basic dXNlcjpzZWNyZXQ=")
+
+	p, err := newExportProxy(&Config{ExportProxy: "http://" + address, ExportProxyUsername: "user", ExportProxyPassword: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireEcho(t, p, backend)
+	if len(*targets) != 1 || (*targets)[0] != backend {
+		t.Fatalf("expected CONNECT to %s, got %v", backend, *targets)
+	}
+
+	// 没有认证信息时代理拒绝连接
+	p, err = newExportProxy(&Config{ExportProxy: "http://" + address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.dial(context.Background(), backend); err == nil {
+		t.Fatal("expected CONNECT without credentials to fail")
+	}
+}
+
+func TestExportProxySOCKS5(t *testing.T) {
+
+	p, err := newExportProxy(&Config{ExportProxy: "socks5://" + socks5Proxy(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireEcho(t, p, echoServer(t))
+}
+
+func TestExportProxyTransport(t *testing.T) {
+
+	var proxied *url.URL
+	forward := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		proxied = req.URL
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer forward.Close()
+
+	p, err := newExportProxy(&Config{ExportProxy: forward.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: p.transport()}
+	resp, err := client.Get("http://openobserve.invalid:5080/api/default/_json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if proxied == nil || proxied.Host != "openobserve.invalid:5080" {
+		t.Fatalf("expected request to go through the proxy, got %v", proxied)
+	}
+
+	var unset *exportProxy
+	if unset.transport() != nil || unset.dialOptions() != nil {
+		t.Fatal("expected no proxy without export_proxy")
+	}
+}
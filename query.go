@@ -0,0 +1,130 @@
+package recordrequestlog
+
+import (
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// redactedValue 替换敏感参数值的占位符
+const redactedValue = "REDACTED"
+
+var defaultRedactQueryParams = []string{"token", "key", "password", "secret"}
+
+// queryRedactor 按参数名脱敏查询字符串，参数名包含任一关键字（不区分大小写）即脱敏
+type queryRedactor struct {
+	keywords []string
+	dropRaw  bool
+}
+
+func newQueryRedactor(keywords []string, dropRaw bool) *queryRedactor {
+
+	if keywords == nil {
+		keywords = defaultRedactQueryParams
+	}
+
+	return &queryRedactor{keywords: lowerAll(keywords), dropRaw: dropRaw}
+}
+
+// redacted 判断参数是否需要脱敏
+func (r *queryRedactor) redacted(name string) bool {
+
+	name = strings.ToLower(name)
+	for _, keyword := range r.keywords {
+		if keyword != "" && strings.Contains(name, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// url 返回脱敏后的 URL，保留参数的原始顺序和编码；开启 drop_raw_query 时去掉整个查询字符串
+func (r *queryRedactor) url(u *url.URL) *url.URL {
+
+	if u.RawQuery == "" && !u.ForceQuery {
+		return u
+	}
+
+	redacted := *u
+	redacted.ForceQuery = false
+
+	if r.dropRaw {
+		redacted.RawQuery = ""
+		return &redacted
+	}
+
+	redacted.RawQuery = r.redactRaw(u.RawQuery)
+	return &redacted
+}
+
+// redactRaw 脱敏 URL 编码的参数列表，保留参数的原始顺序和编码
+func (r *queryRedactor) redactRaw(raw string) string {
+
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if name, err := url.QueryUnescape(key); err == nil && r.redacted(name) {
+			parts[i] = key + "=" + redactedValue
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// count 返回查询字符串中需要脱敏的参数个数，同名参数只计一次
+func (r *queryRedactor) count(u *url.URL) int {
+
+	if u.RawQuery == "" {
+		return 0
+	}
+
+	values, _ := url.ParseQuery(u.RawQuery)
+	return r.countValues(values)
+}
+
+// countValues 返回需要脱敏的参数个数
+func (r *queryRedactor) countValues(values url.Values) int {
+
+	n := 0
+	for name := range values {
+		if r.redacted(name) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// attr 将查询参数解析为分组属性，同名参数的多个值以逗号连接
+func (r *queryRedactor) attr(key string, u *url.URL) (slog.Attr, bool) {
+
+	values, _ := url.ParseQuery(u.RawQuery)
+	return r.valuesAttr(key, values)
+}
+
+// valuesAttr 将参数转换为分组属性，参数按名称排序，需要脱敏的值替换为 REDACTED
+func (r *queryRedactor) valuesAttr(key string, values url.Values) (slog.Attr, bool) {
+
+	if len(values) == 0 {
+		return slog.Attr{}, false
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(values[name], ",")
+		if r.redacted(name) {
+			value = redactedValue
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+
+	return slog.Group(key, attrs...), true
+}
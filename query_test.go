@@ -0,0 +1,48 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"testing"
+)
+
+func TestQueryRedaction(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/search?q=go&api_key=abc&Token=xyz&tag=a&tag=b", nil)
+	record := captureRecord(t, recordrequestlog.CreateConfig(), req)
+
+	if want := "http://localhost/search?q=go&api_key=REDACTED&Token=REDACTED&tag=a&tag=b"; record["url"] != want {
+		t.Errorf("expected url %q, got %v", want, record["url"])
+	}
+
+	query, _ := record["query"].(map[string]any)
+	want := map[string]string{"q": "go", "api_key": "REDACTED", "Token": "REDACTED", "tag": "a,b"}
+	if len(query) != len(want) {
+		t.Fatalf("expected query %v, got %v", want, record["query"])
+	}
+	for name, value := range want {
+		if query[name] != value {
+			t.Errorf("expected query %s=%q, got %v", name, value, query[name])
+		}
+	}
+}
+
+func TestDropRawQuery(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.LogFormat = recordrequestlog.LogFormatSemConv
+	cfg.DropRawQuery = true
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/search?password=hunter2", nil)
+	record := captureRecord(t, cfg, req)
+
+	if want := "http://localhost/search"; record["url.full"] != want {
+		t.Errorf("expected url.full %q, got %v", want, record["url.full"])
+	}
+
+	query, _ := record["url.query.params"].(map[string]any)
+	if query["password"] != "REDACTED" {
+		t.Errorf("expected redacted password, got %v", record["url.query.params"])
+	}
+}
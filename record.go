@@ -0,0 +1,296 @@
+package recordrequestlog
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 日志记录格式
+const (
+	// LogFormatLegacy 使用请求体作为日志内容，属性名为自定义的简短名称
+	LogFormatLegacy = "legacy"
+	// LogFormatSemConv 使用 OpenTelemetry HTTP 语义约定的属性名
+	LogFormatSemConv = "semconv"
+)
+
+// Record 一条请求日志记录，由 LogSink 负责导出
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr
+	// 记录写入的 stream，为空时由 LogSink 使用其默认配置
+	StreamName string
+	// 请求的租户，没有配置租户解析时为空
+	Tenant string
+}
+
+// slogRecord 转换为 slog.Record，供基于 slog.Handler 的 LogSink 使用
+func (r Record) slogRecord() slog.Record {
+
+	record := slog.NewRecord(r.Time, r.Level, r.Message, 0)
+	record.AddAttrs(r.Attrs...)
+	return record
+}
+
+// setLevel 设置记录级别
+func (r *Record) setLevel(level slog.Level) {
+	r.Level = level
+}
+
+// fields 将记录转换为扁平的字段集合，供 JSON 类后端序列化使用，分组属性会转换为嵌套对象
+func (r Record) fields() map[string]any {
+
+	fields := make(map[string]any, len(r.Attrs)+3)
+	fields[slog.LevelKey] = levelName(r.Level)
+	fields["message"] = r.Message
+
+	for _, attr := range r.Attrs {
+		// 属性与内置字段重名时以属性为准，与原有的日志格式保持一致
+		fields[attr.Key] = attrValue(attr.Value)
+	}
+
+	return fields
+}
+
+// attrValue 将 slog.Value 转换为可以 JSON 序列化的值
+func attrValue(v slog.Value) any {
+
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := make(map[string]any, len(v.Group()))
+		for _, attr := range v.Group() {
+			group[attr.Key] = attrValue(attr.Value)
+		}
+		return group
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	default:
+		return v.Any()
+	}
+}
+
+// 记录属性切片的初始容量，覆盖常见请求的属性个数，避免追加属性时多次扩容
+const recordAttrsCap = 24
+
+// newRecord 根据日志格式生成请求日志记录
+func (e *RecordRequestLog) newRecord(req *http.Request, body *capturedBody) Record {
+
+	record := Record{
+		Time:  time.Now(),
+		Level: slog.LevelInfo,
+	}
+
+	rules := e.rules.Load()
+	u := rules.query.url(req.URL)
+
+	if n := rules.query.count(req.URL); n > 0 {
+		e.redactions.Add(req.Context(), int64(n), metric.WithAttributes(attribute.String("source", "query")))
+	}
+
+	if e.logFormat == LogFormatSemConv {
+		record.Message = req.Method + " " + req.URL.Path
+		record.Attrs = append(make([]slog.Attr, 0, recordAttrsCap),
+			slog.String("http.request.method", req.Method),
+			slog.String("url.full", fullURL(req, u)),
+			slog.String("server.address", req.Host),
+			slog.String("user_agent.original", req.UserAgent()),
+			slog.String("appid", req.Header.Get(e.appIDHeader)),
+			slog.String("service.name", e.serverName),
+			slog.String(schemaVersionKey, RecordSchemaVersion),
+		)
+		if body != nil && body.digest == nil && body.multipart == nil && !body.metadataOnly && !e.bodyAttribute {
+			record.Attrs = append(record.Attrs, slog.String("http.request.body.content", formContent(rules.query, body)))
+		}
+	} else {
+		switch {
+		case e.bodyAttribute:
+			record.Message = req.Method + " " + req.URL.Path
+		case body != nil:
+			record.Message = formContent(rules.query, body)
+		}
+		record.Attrs = append(make([]slog.Attr, 0, recordAttrsCap),
+			slog.String("method", req.Method),
+			slog.String("url", u.String()),
+			slog.String("host", req.Host),
+			slog.String("user-agent", req.UserAgent()),
+			slog.String("appid", req.Header.Get(e.appIDHeader)),
+			slog.String("service", e.serverName),
+			slog.String(schemaVersionKey, RecordSchemaVersion),
+		)
+	}
+
+	if attr, ok := rules.query.attr(e.attrKey("query", "url.query.params"), req.URL); ok {
+		record.Attrs = append(record.Attrs, attr)
+	}
+
+	if ip := e.clientIP.resolve(req); ip != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("client-ip", "client.address"), ip))
+
+		if e.geoIP != nil {
+			if location, ok := e.geoIP.locate(ip); ok {
+				record.Attrs = append(record.Attrs, location.attrs(e)...)
+			}
+		}
+	}
+
+	if e.parseUserAgent {
+		record.Attrs = append(record.Attrs, cachedUserAgent(req.UserAgent()).attrs(e)...)
+	}
+
+	if e.jwt != nil {
+		record.Attrs = append(record.Attrs, e.jwt.attrs(e, req)...)
+	}
+
+	if rules.baggage != nil {
+		if attr, ok := rules.baggage.attr(req.Context()); ok {
+			record.Attrs = append(record.Attrs, attr)
+		}
+	}
+
+	if body != nil {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("content-type", "http.request.header.content-type"), body.contentType))
+
+		if body.digest != nil {
+			record.Attrs = append(record.Attrs, body.digest.attrs(e)...)
+		} else if body.size >= 0 {
+			record.Attrs = append(record.Attrs, slog.Int64(e.attrKey("body-size", "http.request.body.size"), body.size))
+		}
+
+		if body.encoding != "" {
+			record.Attrs = append(record.Attrs, slog.String(e.attrKey("body-encoding", "http.request.body.encoding"), body.encoding))
+		}
+
+		if body.contentEncoding != "" {
+			record.Attrs = append(record.Attrs, slog.String(e.attrKey("content-encoding", "http.request.header.content-encoding"), body.contentEncoding))
+		}
+
+		if body.truncated {
+			record.Attrs = append(record.Attrs, slog.Bool(e.attrKey("body-truncated", "http.request.body.truncated"), true))
+		}
+
+		if e.bodyAttribute {
+			if attr, ok := e.bodyAttr(req.Context(), rules.query, body); ok {
+				record.Attrs = append(record.Attrs, attr)
+			}
+		}
+
+		record.Attrs = append(record.Attrs, e.formAttrs(req.Context(), rules.query, body)...)
+
+		if body.graphql != nil {
+			record.Attrs = append(record.Attrs, body.graphql.attrs(e)...)
+		}
+
+		if body.soap != nil {
+			record.Attrs = append(record.Attrs, body.soap.attrs(e)...)
+		}
+
+		if len(body.xmlFields) > 0 {
+			record.Attrs = append(record.Attrs, slog.Attr{Key: e.attrKey("xml", "http.request.body.xml"), Value: slog.GroupValue(body.xmlFields...)})
+		}
+	}
+
+	return record
+}
+
+// completedRecord 生成已完成请求的记录，包含状态码和耗时；status 为 0 时表示没有收到响应，
+// client 为 true 时表示出站请求
+func (e *RecordRequestLog) completedRecord(req *http.Request, body *capturedBody, settings *routeSettings, start time.Time, status int, duration time.Duration, client bool) Record {
+
+	streamName := settings.streamName
+	if rule := settings.statusStream(status); rule != nil {
+		body = rule.body(body)
+		if rule.streamName != "" {
+			streamName = rule.streamName
+		}
+	}
+
+	record := e.newRecord(req, body)
+	record.Time = start
+	if e.bodyAttribute {
+		record.Message = summaryMessage(req, status, duration)
+	}
+	e.setStream(&record, streamName, req)
+
+	// 出站请求的协议由响应决定，只记录服务端收到的请求
+	if !client {
+		record.Attrs = append(record.Attrs, newConnectionInfo(req).attrs(e)...)
+	}
+
+	if status > 0 {
+		record.Attrs = append(record.Attrs, slog.Int(e.attrKey("status", "http.response.status_code"), status))
+	}
+
+	if level, ok := e.statusLevels.level(status); ok {
+		record.setLevel(level)
+	}
+
+	record.Attrs = append(record.Attrs, e.timestampAttrs(start, duration)...)
+
+	switch {
+	case e.logFormat != LogFormatSemConv:
+		record.Attrs = append(record.Attrs, slog.Float64("duration-ms", float64(duration)/float64(time.Millisecond)))
+	case client:
+		record.Attrs = append(record.Attrs, slog.Float64("http.client.request.duration", duration.Seconds()))
+	default:
+		record.Attrs = append(record.Attrs, slog.Float64("http.server.request.duration", duration.Seconds()))
+	}
+
+	return record
+}
+
+// attrKey 根据日志格式选择属性名
+func (e *RecordRequestLog) attrKey(legacy, semconv string) string {
+
+	if e.logFormat == LogFormatSemConv {
+		return semconv
+	}
+
+	return legacy
+}
+
+// fullURL 还原客户端请求的完整地址，服务端收到的 req.URL 通常只包含路径
+func fullURL(req *http.Request, u *url.URL) string {
+
+	if u.IsAbs() {
+		return u.String()
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + req.Host + u.RequestURI()
+}
+
+// recordValue 返回记录中顶层属性的值
+func recordValue(record Record, key string) (slog.Value, bool) {
+
+	for _, attr := range record.Attrs {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+
+	return slog.Value{}, false
+}
+
+// recordAttr 返回记录中顶层属性的字符串形式，没有时返回空字符串
+func recordAttr(record Record, key string) string {
+
+	if v, ok := recordValue(record, key); ok {
+		return v.String()
+	}
+
+	return ""
+}
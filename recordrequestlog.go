@@ -1,27 +1,25 @@
 package recordrequestlog
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"go.opentelemetry.io/contrib/bridges/otelslog"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/log/global"
+	"github.com/prometheus/client_golang/prometheus"
+	"recordrequestlog/lite"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/log"
-	"go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 var logger *slog.Logger
@@ -30,6 +28,8 @@ type Reply struct {
 	Data string `json:"data"`
 	Msg  string `json:"errmsg"`
 	Code int64  `json:"errcode"`
+	// 中间件自身出错时返回请求 ID，便于按请求 ID 查找日志
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func NewReply(data string, msg string, code int64) *Reply {
@@ -40,216 +40,729 @@ func NewReply(data string, msg string, code int64) *Reply {
 	}
 }
 
-type Config struct {
-	Endpoint      string `yaml:"endpoint,omitempty"`
-	Authorization string `yaml:"authorization,omitempty"`
-	Organization  string `yaml:"organization,omitempty"`
-	StreamName    string `yaml:"stream_name,omitempty"`
-	ServerName    string `yaml:"server_name,omitempty"`
-}
-
-func CreateConfig() *Config {
-	return &Config{}
-}
-
 type RecordRequestLog struct {
 	next          http.Handler
+	name          string
 	endpoint      string
 	authorization string
 	organization  string
-	streamName    string
 	serverName    string
+	// 导出请求额外携带的请求头
+	exporterHeaders map[string]string
+	failOpen        bool
+
+	recoverPanics bool
+	repanic       bool
+	errorReply    errorReply
+	enrichFunc    EnrichFunc
+	hashBody      bool
+	bodyHashKey   []byte
+	encryptor     *fieldEncryptor
+	processors    []RecordProcessor
+	audit         *auditChain
+	graphQLPaths  []string
+	flattenXML    bool
+	// 开启 body_attribute 时请求体记录为属性，日志内容为摘要
+	bodyAttribute bool
+	// OPTIONS、HEAD 和 CORS 预检请求的记录方式
+	preflightMode string
+	bodyMaxDepth  int
+	protoFiles    *protoregistry.Files
+	statusLevels  *statusLevels
+	minLevel      slog.Level
+
+	// 作为 span 事件记录的请求体和响应体
+	spanBodyEvents  bool
+	spanBodyMaxSize int
+
+	traceBatchTimeout time.Duration
+	traceMaxBatchSize int
+	metricInterval    time.Duration
+	logQueueSize      int
+	logExportTimeout  time.Duration
+	logBatchInterval  time.Duration
+	logMaxBatchSize   int
+	logFormat         string
+	// 请求开始和结束时间的格式，由 timestamp_precision 决定
+	timestampLayout string
+	compression     string
+	shutdownTimeout time.Duration
+	exportTimeout   time.Duration
+	// 开启 export_timeout 时限制同时在导出的记录数
+	exportSlots       chan struct{}
+	enableTraces      bool
+	enableMetrics     bool
+	metricsBackend    string
+	prometheusAddress string
+	exemplars         bool
+	runtimeMetrics    bool
+	hostMetrics       bool
+
+	// 采样、过滤和脱敏规则，UpdateConfig 时整体替换，各中间件副本共用
+	rules *atomic.Pointer[rules]
+	// 请求日志开关，管理接口可以临时关闭
+	logging *atomic.Bool
+
+	adminPathPrefix string
+	adminToken      string
+	admin           http.Handler
+	// metrics_backend 为 prometheus 时抓取指标的 registry 和处理器
+	metricsRegistry *prometheus.Registry
+	metricsHandler  http.Handler
+
+	// 默认 stream，trace 和 metric 导出使用
+	streamName string
+	sink       LogSink
+	shutdown   func(context.Context) error
+	flush      func(context.Context) error
+	// 各中间件副本共用的关闭状态和丢弃计数
+	state *shutdownState
+	// 测试使用的导出器，为空时按配置创建
+	exporters *TestExporters
+
+	spool                 *spool
+	spoolRetryInterval    time.Duration
+	spoolMaxRetryInterval time.Duration
+
+	resource *resource.Resource
+	retry    *retryPolicy
+	proxy    *exportProxy
+	breaker  *circuitBreaker
+	// endpoint 为第一个端点，配置了多个端点时由 failover 选择 OTLP 导出使用的端点
+	endpoints         []string
+	failover          *failover
+	failoverThreshold int
+	failbackInterval  time.Duration
+
+	clientIP *clientIPResolver
+	jwt      *jwtExtractor
+	tenant   *tenantResolver
+
+	requestIDHeader string
+	traceIDHeader   string
+	spanLinkHeader  string
+	retryLinks      *retryLinks
+	appIDHeader     string
+	appIDs          *appIDLimiter
+	responseHeaders []string
+	replayCapture   bool
+	shadow          *shadowMirror
+	parseUserAgent  bool
+	debugHeader     string
+	debugKey        string
+	debugMaxAge     time.Duration
+	geoIP           *geoIP
+
+	requestRecord      bool
+	requestRecordDelay time.Duration
+	hungThreshold      time.Duration
+	hungStackSize      int
+	budget             sizeBudget
+	dedup              *deduper
+
+	propagator         propagation.TextMapPropagator
+	setGlobalProviders bool
+	tracerProvider     trace.TracerProvider
+	meterProvider      metric.MeterProvider
+	tracer             trace.Tracer
+	requestDuration    metric.Float64Histogram
+	requestBodySize    metric.Int64Histogram
+	sloRequests        metric.Int64Counter
+	responseBodySize   metric.Int64Histogram
+	// 请求体和响应体大小直方图的分桶
+	sizeBuckets           []float64
+	metricViews           []MetricViewConfig
+	clientRequestDuration metric.Float64Histogram
+	rpcServerDuration     metric.Float64Histogram
+	rpcClientDuration     metric.Float64Histogram
+	droppedRecords        metric.Int64Counter
+	emitDuration          metric.Float64Histogram
+	emitTimeouts          metric.Int64Counter
+	breakerTrips          metric.Int64Counter
+	failovers             metric.Int64Counter
+	recordsEmitted        metric.Int64Counter
+	exportFailures        metric.Int64Counter
+	redactions            metric.Int64Counter
+	shadowRequests        metric.Int64Counter
+	overhead              metric.Float64Histogram
+	appRequests           metric.Int64Counter
+	appRequestBytes       metric.Int64Counter
+	appResponseBytes      metric.Int64Counter
+	hungRequests          metric.Int64Counter
+	truncations           metric.Int64Counter
 }
 
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
 
-	return &RecordRequestLog{
-		next:          next,
-		endpoint:      config.Endpoint,
-		authorization: config.Authorization,
-		organization:  config.Organization,
-		streamName:    config.StreamName,
-		serverName:    config.ServerName,
-	}, nil
+	if config.LiteMode {
+		config = expandConfig(config)
+		if ignored := config.liteIgnored(); len(ignored) > 0 {
+			os.Stderr.WriteString(fmt.Sprintf("recordrequestlog[%s]: lite mode ignores %s\n", name, strings.Join(ignored, ", ")))
+		}
+		return lite.New(ctx, next, config.liteConfig(), name)
+	}
+
+	e, err := newRecordRequestLog(next, config, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
 }
 
-func (e *RecordRequestLog) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+// newRecordRequestLog 创建中间件，exporters 不为空时使用其中的导出器代替配置的导出端
+func newRecordRequestLog(next http.Handler, config *Config, name string, exporters *TestExporters) (*RecordRequestLog, error) {
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	config = expandConfig(config)
 
-	defer stop()
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
 
-	otelShutdown, err := e.setupOTelSDK(ctx)
+	traceBatchTimeout, err := parseDuration("trace_batch_timeout", config.TraceBatchTimeout, defaultTraceBatchTimeout)
+	if err != nil {
+		return nil, err
+	}
 
+	metricInterval, err := parseDuration("metric_interval", config.MetricInterval, defaultMetricInterval)
 	if err != nil {
-		json.NewEncoder(rw).Encode(NewReply("", err.Error(), http.StatusInternalServerError))
-		return
+		return nil, err
 	}
 
-	defer func() {
-		err = errors.Join(err, otelShutdown(context.Background()))
-	}()
+	logExportTimeout, err := parseDuration("log_export_timeout", config.LogExportTimeout, defaultLogExportTimeout)
+	if err != nil {
+		return nil, err
+	}
 
-	logger := otelslog.NewLogger(e.serverName)
+	logBatchInterval, err := parseDuration("log_batch_interval", config.LogBatchInterval, defaultLogBatchInterval)
+	if err != nil {
+		return nil, err
+	}
 
-	var body []byte
+	exportTimeout, err := parseDuration("export_timeout", config.ExportTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	if req.Method == http.MethodPost {
-		// 读取请求的内容
-		body, err = io.ReadAll(req.Body)
+	exportMaxPending := config.ExportMaxPending
+	if exportMaxPending <= 0 {
+		exportMaxPending = defaultExportMaxPending
+	}
 
-		if err != nil {
-			json.NewEncoder(rw).Encode(NewReply("", err.Error(), http.StatusInternalServerError))
-			return
-		}
+	shutdownTimeout, err := parseDuration("shutdown_timeout", config.ShutdownTimeout, defaultShutdownTimeout)
+	if err != nil {
+		return nil, err
 	}
-	logger.InfoContext(ctx,
-		string(body),
-		"level", "info",
-		"method", req.Method,
-		"url", req.URL.String(),
-		"host", req.Host,
-		"user-agent", req.UserAgent(),
-		"appid", req.Header.Get("AppId"),
-		"service", e.serverName,
-	)
-
-	// 将读取的内容重新放回请求体，以便下一个处理器可以读取
-	req.Body = io.NopCloser(bytes.NewBuffer(body))
-	e.next.ServeHTTP(rw, req)
-
-	
-}
 
-func (e *RecordRequestLog) setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	spoolRetryInterval, err := parseDuration("spool_retry_interval", config.SpoolRetryInterval, defaultSpoolRetryInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolMaxRetryInterval, err := parseDuration("spool_max_retry_interval", config.SpoolMaxRetryInterval, defaultSpoolMaxRetryInterval)
+	if err != nil {
+		return nil, err
+	}
 
-	var shutdownFuncs []func(context.Context) error
+	logFormat := config.LogFormat
+	switch logFormat {
+	case "":
+		logFormat = LogFormatLegacy
+	case LogFormatLegacy, LogFormatSemConv:
+	default:
+		return nil, fmt.Errorf("invalid log_format %q", config.LogFormat)
+	}
 
-	shutdown = func(ctx context.Context) error {
-		var err error
+	sizeBuckets, err := parseSizeBuckets(config.SizeBuckets)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, fn := range shutdownFuncs {
-			err = errors.Join(err, fn(ctx))
-		}
+	if err := validateMetricViews(config.MetricViews); err != nil {
+		return nil, err
+	}
 
-		shutdownFuncs = nil
-		return err
+	preflightMode, err := parsePreflightMode(config.PreflightMode)
+	if err != nil {
+		return nil, err
 	}
 
-	handleErr := func(inErr error) {
-		err = errors.Join(inErr, shutdown(ctx))
+	bodyMaxDepth := config.BodyMaxDepth
+	if bodyMaxDepth <= 0 {
+		bodyMaxDepth = defaultBodyMaxDepth
 	}
 
-	// 设置传播器
-	prop := newPropagator()
-	otel.SetTextMapPropagator(prop)
+	timestampLayout, err := parseTimestampPrecision(config.TimestampPrecision)
+	if err != nil {
+		return nil, err
+	}
 
-	// 设置 trace provider
+	compression := config.Compression
+	switch compression {
+	case "":
+		compression = CompressionNone
+	case CompressionNone, CompressionGzip:
+	default:
+		return nil, fmt.Errorf("invalid compression %q", config.Compression)
+	}
 
-	traceProvider, err := e.newTraceProvider()
+	debugMaxAge, err := parseDuration("debug_max_age", config.DebugMaxAge, defaultDebugMaxAge)
+	if err != nil {
+		return nil, err
+	}
 
+	requestRecordDelay, err := parseDuration("request_record_delay", config.RequestRecordDelay, 0)
 	if err != nil {
-		handleErr(err)
-		return
+		return nil, err
 	}
 
-	shutdownFuncs = append(shutdownFuncs, traceProvider.Shutdown)
-	otel.SetTracerProvider(traceProvider)
+	hungThreshold, err := parseDuration("hung_threshold", config.HungThreshold, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	meterProvider, err := e.newMeterProvider()
+	hungStackSize := config.HungStackSize
+	if hungStackSize <= 0 {
+		hungStackSize = defaultHungStackSize
+	}
 
+	dedupWindow, err := parseDuration("dedup_window", config.DedupWindow, 0)
 	if err != nil {
-		handleErr(err)
-		return
+		return nil, err
 	}
 
-	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
-	otel.SetMeterProvider(meterProvider)
+	encryptor, err := newFieldEncryptor(config)
+	if err != nil {
+		return nil, err
+	}
 
-	loggerProvider, err := e.newLoggerProvider()
+	shadow, err := newShadowMirror(config)
+	if err != nil {
+		return nil, err
+	}
 
+	retryLinkWindow, err := parseDuration("retry_link_window", config.RetryLinkWindow, 0)
 	if err != nil {
-		handleErr(err)
-		return
+		return nil, err
 	}
 
-	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
-	global.SetLoggerProvider(loggerProvider)
-	return
-}
+	failbackInterval, err := parseDuration("failback_interval", config.FailbackInterval, defaultFailbackInterval)
+	if err != nil {
+		return nil, err
+	}
 
-func newPropagator() propagation.TextMapPropagator {
-	return propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	)
-}
+	appIDHeader := config.AppIDHeader
+	if appIDHeader == "" {
+		appIDHeader = defaultAppIDHeader
+	}
 
-func (e *RecordRequestLog) newTraceProvider() (*trace.TracerProvider, error) {
+	spanBodyMaxSize := config.SpanBodyMaxSize
+	if spanBodyMaxSize <= 0 {
+		spanBodyMaxSize = defaultSpanBodyMaxSize
+	}
 
-	exp, err := otlptracegrpc.New(context.Background(),
-		otlptracegrpc.WithEndpointURL(e.endpoint),
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithHeaders(map[string]string{
-			"Authorization": e.authorization,
-			"organization":  e.organization,
-			"stream-name":   e.streamName,
-		}),
-	)
+	r, err := newRules(config)
 	if err != nil {
 		return nil, err
 	}
 
-	traceProvider := trace.NewTracerProvider(
-		trace.WithBatcher(exp,
-			trace.WithBatchTimeout(time.Second)),
-	)
-	return traceProvider, nil
-}
+	propagator, err := newPropagator(config.Propagators)
+	if err != nil {
+		return nil, err
+	}
 
-func (e *RecordRequestLog) newMeterProvider() (*metric.MeterProvider, error) {
+	retry, err := newRetryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
 
-	exp, err := otlpmetricgrpc.New(context.Background(),
-		otlpmetricgrpc.WithEndpointURL(e.endpoint),
-		otlpmetricgrpc.WithInsecure(),
-		otlpmetricgrpc.WithHeaders(map[string]string{
-			"Authorization": e.authorization,
-			"organization":  e.organization,
-			"stream-name":   e.streamName,
-		}),
-	)
+	exportProxy, err := newExportProxy(config)
+	if err != nil {
+		return nil, err
+	}
 
+	clientIP, err := newClientIPResolver(config.TrustedProxies, config.AnonymizeClientIP)
 	if err != nil {
 		return nil, err
 	}
 
-	meterProvider := metric.NewMeterProvider(
-		metric.WithReader(metric.NewPeriodicReader(exp,
-			metric.WithInterval(3*time.Second))),
-	)
+	var keys *jwks
+	if config.JWTJWKSURL != "" {
+		refreshInterval, err := parseDuration("jwt_jwks_refresh_interval", config.JWTJWKSRefreshInterval, defaultJWKSRefreshInterval)
+		if err != nil {
+			return nil, err
+		}
+		keys = newJWKS(config.JWTJWKSURL, refreshInterval)
+	}
 
-	return meterProvider, nil
-}
+	var jwt *jwtExtractor
+	if config.ParseJWT {
+		jwt = newJWTExtractor(config.JWTClaims, keys)
+	}
+
+	tenant, err := newTenantResolver(config, keys)
+	if err != nil {
+		return nil, err
+	}
 
-func (e *RecordRequestLog) newLoggerProvider() (*log.LoggerProvider, error) {
+	protoFiles, err := newProtoFiles(config.ProtoDescriptorSets, config.ProtoFiles)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx := context.Background()
-	exp, err := otlploggrpc.New(ctx,
-		otlploggrpc.WithEndpointURL(e.endpoint),
-		otlploggrpc.WithInsecure(),
-		otlploggrpc.WithHeaders(map[string]string{
-			"Authorization": e.authorization,
-			"organization":  e.organization,
-			"stream-name":   e.streamName,
-		}),
-	)
+	statusLevels, err := newStatusLevels(config.StatusLevels)
 	if err != nil {
 		return nil, err
 	}
 
-	loggerProvider := log.NewLoggerProvider(
-		log.WithProcessor(log.NewBatchProcessor(exp)),
-	)
+	minLevel := slog.LevelDebug
+	if config.MinLevel != "" {
+		if minLevel, err = parseLevel("min_level", config.MinLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	var audit *auditChain
+	if config.AuditMode {
+		if audit, err = newAuditChain([]byte(config.AuditKey)); err != nil {
+			return nil, err
+		}
+	}
+
+	// trace 和 metric 导出使用默认租户的 stream
+	streamName := config.StreamName
+	if tenant != nil {
+		streamName = strings.ReplaceAll(streamName, tenantPlaceholder, tenant.fallback)
+	}
+
+	e := &RecordRequestLog{
+		next:          next,
+		name:          name,
+		authorization: config.Authorization,
+		organization:  config.Organization,
+		serverName:    config.ServerName,
+
+		exporterHeaders: config.ExporterHeaders,
+		failOpen:        config.FailOpen,
+
+		recoverPanics: config.RecoverPanics,
+		repanic:       config.Repanic,
+		errorReply:    newErrorReply(config),
+		enrichFunc:    config.EnrichFunc,
+		hashBody:      config.BodyMode == BodyModeHash,
+		bodyHashKey:   []byte(config.BodyHashKey),
+		encryptor:     encryptor,
+		processors:    config.RecordProcessors,
+		audit:         audit,
+		graphQLPaths:  config.GraphQLPaths,
+		flattenXML:    config.FlattenXML,
+		bodyAttribute: config.BodyAttribute,
+		preflightMode: preflightMode,
+		bodyMaxDepth:  bodyMaxDepth,
+		protoFiles:    protoFiles,
+		statusLevels:  statusLevels,
+		minLevel:      minLevel,
+
+		spanBodyEvents:  config.SpanBodyEvents,
+		spanBodyMaxSize: spanBodyMaxSize,
+
+		traceBatchTimeout: traceBatchTimeout,
+		traceMaxBatchSize: config.TraceMaxBatchSize,
+		metricInterval:    metricInterval,
+		logQueueSize:      config.LogQueueSize,
+		logExportTimeout:  logExportTimeout,
+		logBatchInterval:  logBatchInterval,
+		logMaxBatchSize:   config.LogMaxBatchSize,
+		logFormat:         logFormat,
+		timestampLayout:   timestampLayout,
+		compression:       compression,
+		shutdownTimeout:   shutdownTimeout,
+		exportTimeout:     exportTimeout,
+		exportSlots:       make(chan struct{}, exportMaxPending),
+		enableTraces:      config.EnableTraces,
+		enableMetrics:     config.EnableMetrics,
+		metricsBackend:    config.MetricsBackend,
+		prometheusAddress: config.PrometheusAddress,
+		exemplars:         config.Exemplars,
+		sizeBuckets:       sizeBuckets,
+		metricViews:       config.MetricViews,
+		runtimeMetrics:    config.RuntimeMetrics,
+		hostMetrics:       config.HostMetrics,
+
+		setGlobalProviders: config.SetGlobalProviders,
+
+		rules:      &atomic.Pointer[rules]{},
+		logging:    &atomic.Bool{},
+		streamName: streamName,
+		retry:      retry,
+		proxy:      exportProxy,
+		propagator: propagator,
+		clientIP:   clientIP,
+		jwt:        jwt,
+		tenant:     tenant,
+		state:      &shutdownState{},
+		exporters:  exporters,
+
+		requestIDHeader: config.RequestIDHeader,
+		traceIDHeader:   config.TraceIDResponseHeader,
+		spanLinkHeader:  config.SpanLinkHeader,
+		retryLinks:      newRetryLinks(retryLinkWindow),
+		appIDHeader:     appIDHeader,
+		appIDs:          newAppIDLimiter(config.AppIDMetricsLimit),
+		responseHeaders: canonicalHeaders(config.CaptureResponseHeaders),
+		replayCapture:   config.ReplayCapture,
+		shadow:          shadow,
+		parseUserAgent:  config.ParseUserAgent,
+		debugHeader:     config.DebugHeader,
+		debugKey:        config.DebugKey,
+		debugMaxAge:     debugMaxAge,
+
+		requestRecord:      config.RequestRecord,
+		requestRecordDelay: requestRecordDelay,
+		hungThreshold:      hungThreshold,
+		hungStackSize:      hungStackSize,
+		budget: sizeBudget{
+			attribute: config.MaxAttributeSize,
+			message:   config.MaxMessageSize,
+			record:    config.MaxRecordSize,
+		},
+
+		adminPathPrefix: strings.TrimSuffix(config.AdminPathPrefix, "/"),
+		adminToken:      config.AdminToken,
+
+		spoolRetryInterval:    spoolRetryInterval,
+		spoolMaxRetryInterval: max(spoolRetryInterval, spoolMaxRetryInterval),
+	}
+
+	e.rules.Store(r)
+	e.logging.Store(true)
+
+	if e.adminPathPrefix != "" {
+		e.admin = e.AdminHandler()
+	}
+
+	e.failoverThreshold, e.failbackInterval = config.FailoverThreshold, failbackInterval
+	e.setEndpoints(config.Endpoint)
+
+	if dedupWindow > 0 {
+		e.dedup = newDeduper(dedupWindow, e.attrKey("repeat-count", "repeat_count"), e.emit)
+	}
+
+	if config.CircuitBreakerThreshold > 0 {
+		cooloff, err := parseDuration("circuit_breaker_cooloff", config.CircuitBreakerCooloff, defaultCircuitBreakerCooloff)
+		if err != nil {
+			return nil, err
+		}
+
+		e.breaker = newCircuitBreaker(config.CircuitBreakerThreshold, cooloff, e.onBreakerOpen)
+	}
+
+	if config.GeoIPDatabase != "" {
+		e.geoIP, err = newGeoIP(config, e.logError)
+		if err != nil {
+			if !e.failOpen {
+				return nil, err
+			}
+
+			e.logError("setup geoip", err)
+		}
+	}
+
+	if config.SpoolDir != "" {
+		e.spool, err = newSpool(config.SpoolDir, config.SpoolMaxSize)
+		if err != nil {
+			if !e.failOpen {
+				return nil, err
+			}
+
+			e.logError("setup spool", err)
+		}
+	}
+
+	e.resource, err = newResource(context.Background(), config)
+	if err != nil {
+		if !e.failOpen {
+			return nil, err
+		}
+
+		e.logError("detect resource", err)
+		e.resource = resource.Default()
+	}
+
+	// 遥测初始化失败时，fail open 模式下仍然加载中间件，只是不再导出数据
+	e.shutdown, err = e.setupOTelSDK(context.Background())
+	if err != nil {
+		if !e.failOpen {
+			return nil, err
+		}
+
+		e.logError("setup telemetry", err)
+		e.shutdown = func(context.Context) error { return nil }
+	}
+
+	if err := e.newInstruments(); err != nil {
+		e.logError("create instruments", err)
+	}
+	e.droppedRecords = dropCounter{Int64Counter: e.droppedRecords, total: &e.state.dropped}
+
+	e.sink = noopSink{}
+	if config.EnableLogs {
+		e.sink, err = e.newSink(config)
+		if err != nil {
+			if !e.failOpen {
+				return nil, errors.Join(err, e.shutdown(context.Background()))
+			}
+
+			e.logError("setup log sink", err)
+			e.sink = noopSink{}
+		}
+
+		if config.FileFallback && config.Backend != BackendFile {
+			fallback, err := e.newFileSink(config)
+			if err != nil {
+				if !e.failOpen {
+					return nil, errors.Join(err, e.sink.Shutdown(context.Background()), e.shutdown(context.Background()))
+				}
+
+				e.logError("setup fallback file", err)
+			} else if _, ok := e.sink.(noopSink); ok {
+				// 后端创建失败时直接写入文件
+				e.sink = fallback
+			} else {
+				e.sink = &fallbackSink{LogSink: e.sink, fallback: fallback}
+			}
+		}
+
+		if len(config.Sinks) > 0 {
+			multi, err := e.newMultiSink(e.sink, config)
+			if err != nil {
+				return nil, errors.Join(err, e.shutdown(context.Background()))
+			}
+			e.sink = multi
+		}
+	}
+
+	if _, ok := e.sink.(noopSink); !ok {
+		e.sink = countingSink{LogSink: e.sink, exported: &e.state.exported, emitted: e.recordsEmitted}
+	}
+
+	// 多路导出时各后端已经使用独立的异步队列
+	if config.Async && len(config.Sinks) == 0 {
+		e.sink = e.newAsyncSink(e.sink, "", config.AsyncQueueSize, config.AsyncWorkers, config.AsyncDropPolicy)
+	}
+
+	register(e)
+
+	return e, nil
+}
+
+// NewRecorder 创建不包装 HTTP 处理器的记录器，用于 Transport 和 gRPC 拦截器
+func NewRecorder(config *Config) (*RecordRequestLog, error) {
+	return newRecordRequestLog(nil, config, instrumentationName, nil)
+}
+
+func (e *RecordRequestLog) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	if e.serveAdmin(rw, req) {
+		return
+	}
+
+	x, req, err := e.begin(rw, req)
+	defer x.span.End()
+
+	if err != nil {
+		if !e.failOpen {
+			e.errorReply.write(x.rw, req, e.errorReply.status, http.StatusInternalServerError, err)
+			x.finish(0, nil)
+			return
+		}
+
+		e.logError("read request body", err)
+	}
+
+	nextStart := time.Now()
+	p := e.callNext(x.rw, req)
+	nextDuration := time.Since(nextStart)
+	x.finish(0, p)
+
+	// 中间件自身增加的延迟，不包括下一个处理器的耗时
+	e.overhead.Record(req.Context(), (time.Since(x.start) - nextDuration).Seconds())
+
+	if p != nil && e.repanic {
+		panic(p.value)
+	}
+}
+
+// emit 导出一条记录，导出失败的记录计入丢弃数。配置了 export_timeout 时请求最多等待该时长，
+// 超时后导出在后台继续完成
+func (e *RecordRequestLog) emit(ctx context.Context, record Record) {
+
+	start := time.Now()
+	defer func() {
+		e.emitDuration.Record(ctx, time.Since(start).Seconds())
+	}()
+
+	// 调试请求的记录不受 min_level 限制
+	if record.Level < e.minLevel && !debugContext(ctx) {
+		return
+	}
+
+	if !e.process(ctx, &record) {
+		return
+	}
+
+	e.applyBudget(ctx, &record)
+	e.applyRetention(ctx, &record)
+
+	if e.audit != nil {
+		if err := e.audit.sign(&record); err != nil {
+			e.droppedRecords.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "audit_error")))
+			e.logError("sign audit record", err)
+			return
+		}
+	}
+
+	if e.exportTimeout <= 0 {
+		e.emitRecord(ctx, record)
+		return
+	}
+
+	// 超时的导出在后台继续，导出端无响应时不再创建新的导出
+	select {
+	case e.exportSlots <- struct{}{}:
+	default:
+		e.droppedRecords.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "emit_backlog")))
+		return
+	}
+
+	// 请求结束后 context 会被取消，后台导出时只保留其中的 trace 等信息
+	bctx := context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer func() { <-e.exportSlots }()
+		defer close(done)
+		e.emitRecord(bctx, record)
+	}()
+
+	timer := time.NewTimer(e.exportTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		e.emitTimeouts.Add(ctx, 1)
+	}
+}
+
+func (e *RecordRequestLog) emitRecord(ctx context.Context, record Record) {
+
+	if err := e.sink.Emit(ctx, record); err != nil {
+		e.droppedRecords.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "emit_error")))
+		e.logError("emit record", err)
+	}
+}
 
-	return loggerProvider, nil
+// logError 将中间件自身的错误输出到本地，Traefik 会收集插件的标准错误输出
+func (e *RecordRequestLog) logError(msg string, err error) {
+	os.Stderr.WriteString(fmt.Sprintf("recordrequestlog[%s]: %s: %v\n", e.name, msg, err))
 }
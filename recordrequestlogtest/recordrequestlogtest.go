@@ -0,0 +1,204 @@
+// Package recordrequestlogtest 提供在内存中收集中间件导出数据的导出器和断言，测试不需要连接 collector 或 OpenObserve：
+//
+//	rec := recordrequestlogtest.New()
+//	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+//	...
+//	record := rec.RequireRecord(t, recordrequestlogtest.HasAttr("method", "POST"))
+package recordrequestlogtest
+
+import (
+	"context"
+	"log/slog"
+	"recordrequestlog"
+	"strings"
+	"sync"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder 收集中间件导出的日志记录、span 和指标。中间件 Shutdown 后 span 和指标会被清空，需要在此之前读取
+type Recorder struct {
+	sink    *Sink
+	spans   *tracetest.InMemoryExporter
+	metrics *sdkmetric.ManualReader
+}
+
+// New 创建 Recorder
+func New() *Recorder {
+	return &Recorder{
+		sink:    NewSink(),
+		spans:   tracetest.NewInMemoryExporter(),
+		metrics: sdkmetric.NewManualReader(),
+	}
+}
+
+// Option 返回让 NewMiddleware 使用 Recorder 导出器的选项，一个 Recorder 只应用于一个中间件
+func (r *Recorder) Option() recordrequestlog.Option {
+	return recordrequestlog.WithTestExporters(recordrequestlog.TestExporters{
+		Sink:         r.sink,
+		SpanExporter: r.spans,
+		MetricReader: r.metrics,
+	})
+}
+
+// Records 返回收集到的日志记录
+func (r *Recorder) Records() []recordrequestlog.Record {
+	return r.sink.Records()
+}
+
+// Spans 返回已结束的 span
+func (r *Recorder) Spans() tracetest.SpanStubs {
+	return r.spans.GetSpans()
+}
+
+// Metrics 采集并返回当前的指标
+func (r *Recorder) Metrics(t testing.TB) []metricdata.Metrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := r.metrics.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+
+	var metrics []metricdata.Metrics
+	for _, sm := range rm.ScopeMetrics {
+		metrics = append(metrics, sm.Metrics...)
+	}
+
+	return metrics
+}
+
+// Reset 清空已经收集的日志记录和 span，指标按累计值采集，不受影响
+func (r *Recorder) Reset() {
+	r.sink.Reset()
+	r.spans.Reset()
+}
+
+// RequireRecord 返回第一条满足 match 的记录，没有时测试失败
+func (r *Recorder) RequireRecord(t testing.TB, match func(recordrequestlog.Record) bool) recordrequestlog.Record {
+	t.Helper()
+
+	records := r.Records()
+	for _, record := range records {
+		if match(record) {
+			return record
+		}
+	}
+
+	t.Fatalf("no matching record among %d records", len(records))
+	return recordrequestlog.Record{}
+}
+
+// RequireRecords 断言收集到 n 条记录并返回
+func (r *Recorder) RequireRecords(t testing.TB, n int) []recordrequestlog.Record {
+	t.Helper()
+
+	records := r.Records()
+	if len(records) != n {
+		t.Fatalf("expected %d records, got %d", n, len(records))
+	}
+
+	return records
+}
+
+// RequireSpan 返回第一个名称为 name 的 span，没有时测试失败
+func (r *Recorder) RequireSpan(t testing.TB, name string) tracetest.SpanStub {
+	t.Helper()
+
+	spans := r.Spans()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+
+	t.Fatalf("no span named %q among %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+// RequireMetric 返回名称为 name 的指标，没有时测试失败
+func (r *Recorder) RequireMetric(t testing.TB, name string) metricdata.Metrics {
+	t.Helper()
+
+	metrics := r.Metrics(t)
+	for _, m := range metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+
+	t.Fatalf("no metric named %q among %d metrics", name, len(metrics))
+	return metricdata.Metrics{}
+}
+
+// Attr 返回记录中 key 对应的属性值，分组中的属性用点号连接，例如 "query.token"
+func Attr(record recordrequestlog.Record, key string) (slog.Value, bool) {
+	return findAttr(record.Attrs, key)
+}
+
+func findAttr(attrs []slog.Attr, key string) (slog.Value, bool) {
+
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value.Resolve(), true
+		}
+
+		if rest, ok := strings.CutPrefix(key, attr.Key+"."); ok && attr.Value.Kind() == slog.KindGroup {
+			if v, ok := findAttr(attr.Value.Group(), rest); ok {
+				return v, true
+			}
+		}
+	}
+
+	return slog.Value{}, false
+}
+
+// HasAttr 匹配属性 key 的值格式化后等于 value 的记录
+func HasAttr(key, value string) func(recordrequestlog.Record) bool {
+	return func(record recordrequestlog.Record) bool {
+		v, ok := Attr(record, key)
+		return ok && v.String() == value
+	}
+}
+
+// Sink 在内存中保存记录的 LogSink
+type Sink struct {
+	mu      sync.Mutex
+	records []recordrequestlog.Record
+}
+
+// NewSink 创建 Sink
+func NewSink() *Sink {
+	return &Sink{}
+}
+
+func (s *Sink) Emit(ctx context.Context, record recordrequestlog.Record) error {
+
+	s.mu.Lock()
+	s.records = append(s.records, record)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *Sink) Shutdown(context.Context) error { return nil }
+
+// Records 返回已经写入的记录
+func (s *Sink) Records() []recordrequestlog.Record {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]recordrequestlog.Record(nil), s.records...)
+}
+
+// Reset 清空已经写入的记录
+func (s *Sink) Reset() {
+
+	s.mu.Lock()
+	s.records = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package recordrequestlog
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// errInternal 捕获到 panic 时返回给客户端的错误，不暴露 panic 的内容
+var errInternal = errors.New(http.StatusText(http.StatusInternalServerError))
+
+// recoveredPanic 下一个处理器 panic 时捕获的值和调用栈
+type recoveredPanic struct {
+	value any
+	stack []byte
+}
+
+// callNext 调用下一个处理器，开启 recover_panics 时捕获 panic 并返回 500
+func (e *RecordRequestLog) callNext(rw *responseWriter, req *http.Request) (p *recoveredPanic) {
+
+	if !e.recoverPanics {
+		e.next.ServeHTTP(rw.outer, req)
+		return nil
+	}
+
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+
+		// http.ErrAbortHandler 用于主动中断响应，不应被捕获
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+
+		p = &recoveredPanic{value: v, stack: debug.Stack()}
+
+		// 已经写入响应时无法再修改状态码
+		if rw.status == 0 {
+			e.errorReply.write(rw, req, http.StatusInternalServerError, http.StatusInternalServerError, errInternal)
+		}
+	}()
+
+	e.next.ServeHTTP(rw.outer, req)
+	return nil
+}
+
+// panicAttrs 返回记录 panic 的属性
+func (e *RecordRequestLog) panicAttrs(p *recoveredPanic) []slog.Attr {
+
+	attrs := []slog.Attr{
+		slog.String(e.attrKey("panic", "exception.message"), fmt.Sprint(p.value)),
+		slog.String(e.attrKey("stack", "exception.stacktrace"), string(p.stack)),
+	}
+
+	if e.logFormat == LogFormatSemConv {
+		attrs = append(attrs, slog.String("exception.type", fmt.Sprintf("%T", p.value)))
+	}
+
+	return attrs
+}
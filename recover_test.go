@@ -0,0 +1,87 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.RecoverPanics = true
+	// panic 不受采样影响
+	cfg.SampleRate = 0
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rw.Code)
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	record := records[0]
+	if record["level"] != "error" {
+		t.Errorf("expected level error, got %v", record["level"])
+	}
+	if record["panic"] != "boom" {
+		t.Errorf("expected panic boom, got %v", record["panic"])
+	}
+	if stack, _ := record["stack"].(string); !strings.Contains(stack, "TestRecoverPanics") {
+		t.Errorf("expected stack trace, got %q", stack)
+	}
+	if record["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("expected status 500, got %v", record["status"])
+	}
+}
+
+func TestRepanic(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = filepath.Join(t.TempDir(), "requests.log")
+	cfg.RecoverPanics = true
+	cfg.Repanic = true
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("expected repanic with boom, got %v", v)
+		}
+		if records := readRecords(t, cfg.FilePath); len(records) != 1 {
+			t.Errorf("expected 1 record before repanic, got %d", len(records))
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+}
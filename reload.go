@@ -0,0 +1,117 @@
+package recordrequestlog
+
+import (
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// rules 可以在运行时通过 UpdateConfig 整体替换的采样、过滤和脱敏规则
+type rules struct {
+	// 顶层配置对应的请求级设置，以及按路由覆盖的设置
+	defaults *routeSettings
+	routes   []*route
+
+	query   *queryRedactor
+	xml     *xmlRedactor
+	baggage *baggageFilter
+	paths   *pathTemplater
+	sampler sdktrace.Sampler
+	// 按顺序匹配的记录保留类别
+	retention []retentionClass
+	// 按调用方覆盖的日志采样率
+	appIDRates map[string]float64
+
+	// 生成规则的配置，管理接口修改采样率时在此基础上生成新的规则
+	config *Config
+}
+
+// newRules 根据配置生成规则
+func newRules(config *Config) (*rules, error) {
+
+	defaults, err := newRouteSettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := newRoutes(config.Routes, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := newSampler(config.TraceSampler, config.TraceSampleRatio)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := newPathTemplater(config.PathTemplates, config.CollapsePathIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	xml, err := newXMLRedactor(config.RedactXMLPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	retention, err := newRetentionClasses(config.RetentionClasses)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rules{
+		defaults: defaults,
+		routes:   routes,
+		query:    newQueryRedactor(config.RedactQueryParams, config.DropRawQuery),
+		xml:      xml,
+		baggage:  newBaggageFilter(config.BaggageKeys),
+		paths:    paths,
+		sampler:  sampler,
+		config:   config,
+
+		retention: retention,
+
+		appIDRates: config.AppIDSampleRates,
+	}, nil
+}
+
+// UpdateConfig 在运行时替换采样、过滤和脱敏规则，不重建导出器，正在处理的请求沿用原来的规则。
+// 生效的字段：sample_rate、target_records_per_second、capture_methods、capture_content_types、base64_binary_body、max_binary_body_size、
+// max_body_size、log_mode、slow_threshold、status_streams、routes、redact_query_params、drop_raw_query、redact_xml_paths、baggage_keys、
+// path_templates、collapse_path_ids、trace_sampler、trace_sample_ratio、app_id_sample_rates 和 retention_classes；其余字段保持创建时的值。
+// 配置有误时返回错误，原来的规则不变
+func (e *RecordRequestLog) UpdateConfig(config *Config) error {
+	return e.applyConfig(expandConfig(config))
+}
+
+// applyConfig 校验已经展开环境变量的配置并替换规则
+func (e *RecordRequestLog) applyConfig(config *Config) error {
+
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+
+	r, err := newRules(config)
+	if err != nil {
+		return err
+	}
+
+	e.rules.Store(r)
+	return nil
+}
+
+// reloadableSampler 使用当前规则中的 trace 采样器，UpdateConfig 后新的 span 立即按新的采样器采样
+type reloadableSampler struct {
+	rules *atomic.Pointer[rules]
+}
+
+func (s reloadableSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if hasDebugAttr(p.Attributes) {
+		return sdktrace.AlwaysSample().ShouldSample(p)
+	}
+	return s.rules.Load().sampler.ShouldSample(p)
+}
+
+func (s reloadableSampler) Description() string {
+	return s.rules.Load().sampler.Description()
+}
@@ -0,0 +1,66 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestUpdateConfig(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.SampleRate = 0
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := handler.(*recordrequestlog.RecordRequestLog)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/?token=secret", nil))
+
+	if records := readRecords(t, path); len(records) != 0 {
+		t.Fatalf("expected no records at sample_rate 0, got %d", len(records))
+	}
+
+	updated := *cfg
+	updated.SampleRate = 1
+	updated.RedactQueryParams = []string{"token"}
+	if err := e.UpdateConfig(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/?token=secret", nil))
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record after update, got %d", len(records))
+	}
+
+	if url, _ := records[0]["url"].(string); strings.Contains(url, "secret") {
+		t.Fatalf("query not redacted after update: %q", url)
+	}
+
+	// 配置有误时保留原来的规则
+	invalid := updated
+	invalid.SampleRate = 2
+	if err := e.UpdateConfig(&invalid); err == nil {
+		t.Fatal("expected error for invalid sample_rate")
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	if records := readRecords(t, path); len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+}
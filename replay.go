@@ -0,0 +1,348 @@
+package recordrequestlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ReplayRequest 从请求记录还原的请求，用于在测试环境重现生产流量
+type ReplayRequest struct {
+	Time      time.Time
+	RequestID string
+	Method    string
+	// 绝对 URL，记录中只有路径时使用 http 和记录的 host
+	URL    string
+	Header http.Header
+	Body   []byte
+
+	// 原始请求的响应状态码和耗时，没有记录时为 0
+	Status   int
+	Duration time.Duration
+
+	// 请求体被截断、只记录了元数据，或者没有开启 replay_capture 时为 true，重放的请求与原始请求不同
+	Incomplete bool
+}
+
+// ReadRequestRecords 读取文件后端写入 file_path 的 JSON 行（包括 file_fallback 写入的记录）或本地缓冲（spool_dir）的
+// 批次文件中的记录，两种日志格式都可以读取
+func ReadRequestRecords(r io.Reader) ([]RequestRecord, error) {
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	var records []RequestRecord
+	for {
+		var values map[string]any
+		if err := decoder.Decode(&values); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return records, fmt.Errorf("decode record %d: %w", len(records)+1, err)
+		}
+
+		batch, ok := values["records"].([]any)
+		if !ok {
+			// 文件后端使用 slog 的内置字段名 msg
+			if msg, ok := values["msg"]; ok {
+				if _, ok := values["message"]; !ok {
+					values["message"] = msg
+				}
+				delete(values, "msg")
+			}
+			records = append(records, newRequestRecord(values))
+			continue
+		}
+
+		for _, v := range batch {
+			record, _ := v.(map[string]any)
+			fields, _ := record["attrs"].(map[string]any)
+			if fields == nil {
+				fields = make(map[string]any)
+			}
+			fields["message"] = record["message"]
+			fields["time"] = record["time"]
+			if level, ok := record["level"].(float64); ok {
+				fields["level"] = levelName(slog.Level(int(level)))
+			}
+			records = append(records, newRequestRecord(fields))
+		}
+	}
+}
+
+// NewReplayRequest 从请求记录还原请求，request-received、request-hung 等不对应完整请求的记录返回 false
+func NewReplayRequest(rr RequestRecord) (ReplayRequest, bool) {
+
+	switch event, _ := replayField(rr, "event", "event.name").(string); event {
+	case "", eventResponseComplete:
+	default:
+		return ReplayRequest{}, false
+	}
+
+	if !replayMethod(rr.Method) || rr.URL == "" {
+		return ReplayRequest{}, false
+	}
+
+	req := ReplayRequest{
+		RequestID: rr.RequestID,
+		Method:    rr.Method,
+		URL:       rr.URL,
+		Header:    make(http.Header),
+		Status:    rr.Status,
+	}
+
+	if u, err := url.Parse(rr.URL); err == nil && !u.IsAbs() && rr.Host != "" {
+		req.URL = "http://" + rr.Host + u.String()
+	}
+
+	// 没有记录 start-time 时使用记录的写入时间
+	startTime, _ := rr.Attributes["time"].(string)
+	if rr.StartTime != "" {
+		startTime = rr.StartTime
+	}
+	if t, err := time.Parse(time.RFC3339Nano, startTime); err == nil {
+		req.Time = t
+	}
+
+	// legacy 格式的耗时为毫秒，semconv 格式为秒
+	if rr.format == LogFormatSemConv {
+		req.Duration = time.Duration(rr.Duration * float64(time.Second))
+	} else {
+		req.Duration = time.Duration(rr.Duration * float64(time.Millisecond))
+	}
+
+	headers, captured := replayField(rr, "request-headers", "http.request.header").(map[string]any)
+	for name, value := range headers {
+		if s, ok := value.(string); ok {
+			req.Header.Set(name, s)
+		}
+	}
+	if rr.ContentType != "" {
+		req.Header.Set("Content-Type", rr.ContentType)
+	}
+	if rr.UserAgent != "" {
+		req.Header.Set("User-Agent", rr.UserAgent)
+	}
+
+	req.Body, req.Incomplete = replayBody(rr)
+	req.Incomplete = req.Incomplete || !captured
+
+	return req, true
+}
+
+// replayMethod 判断方法名是否只包含大写字母。方法名来自客户端，RFC 7230 允许的 ` | & $ 等字符写入脚本后会被 shell 执行
+func replayMethod(method string) bool {
+
+	if method == "" {
+		return false
+	}
+
+	for _, c := range method {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// replayField 返回 RequestRecord 中不属于固定字段的属性，先查找 legacy 格式的属性名
+func replayField(rr RequestRecord, legacy, semconv string) any {
+
+	if v, ok := rr.Attributes[legacy]; ok {
+		return v
+	}
+
+	return rr.Attributes[semconv]
+}
+
+// replayBody 还原请求体，base64 编码的二进制请求体解码后返回；请求体不完整时返回 true
+func replayBody(rr RequestRecord) ([]byte, bool) {
+
+	truncated, _ := replayField(rr, "body-truncated", "http.request.body.truncated").(bool)
+	metadataOnly := rr.RequestBodySize > 0 && rr.RequestBody == ""
+
+	body := []byte(rr.RequestBody)
+
+	// 开启 body_attribute 时 JSON 请求体记录为嵌套对象
+	switch v := rr.Attributes["body"].(type) {
+	case map[string]any, []any:
+		body, _ = json.Marshal(v)
+		metadataOnly = false
+	}
+
+	if encoding, _ := replayField(rr, "body-encoding", "http.request.body.encoding").(string); encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(rr.RequestBody)
+		if err != nil {
+			return nil, true
+		}
+		body = decoded
+	}
+
+	return body, truncated || metadataOnly
+}
+
+// WriteCurl 将请求写为 shell 脚本，每个请求一条 curl 命令，方法名不是大写字母时返回错误
+func WriteCurl(w io.Writer, requests []ReplayRequest) error {
+
+	var buf bytes.Buffer
+	buf.WriteString("#!/bin/sh\n")
+
+	for _, req := range requests {
+		if !replayMethod(req.Method) {
+			return fmt.Errorf("invalid method %q in request %q", req.Method, req.RequestID)
+		}
+
+		var comment []string
+		if !req.Time.IsZero() {
+			comment = append(comment, req.Time.Format(time.RFC3339Nano))
+		}
+		if req.RequestID != "" {
+			comment = append(comment, req.RequestID)
+		}
+		if req.Incomplete {
+			comment = append(comment, "(incomplete)")
+		}
+
+		buf.WriteString("\n")
+		if len(comment) > 0 {
+			buf.WriteString("# " + shellComment(strings.Join(comment, " ")) + "\n")
+		}
+
+		buf.WriteString("curl -sS -X " + shellQuote(req.Method) + " " + shellQuote(req.URL))
+		for _, name := range sortedHeaderNames(req.Header) {
+			for _, value := range req.Header.Values(name) {
+				buf.WriteString(" \\\n  -H " + shellQuote(name+": "+value))
+			}
+		}
+		if len(req.Body) > 0 {
+			buf.WriteString(" \\\n  --data-binary " + shellQuote(string(req.Body)))
+		}
+		buf.WriteString("\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// shellQuote 使用单引号转义 shell 参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellComment 将控制字符替换为空格，避免请求 ID 中的换行结束注释行
+func shellComment(s string) string {
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func sortedHeaderNames(header http.Header) []string {
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// harNameValue HAR 中的请求头和查询参数
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WriteHAR 将请求写为 HAR 1.2 文件，原始请求的状态码和耗时记录在响应和 timings 中，响应内容为空
+func WriteHAR(w io.Writer, requests []ReplayRequest) error {
+
+	entries := make([]map[string]any, 0, len(requests))
+	for _, req := range requests {
+		headers := []harNameValue{}
+		for _, name := range sortedHeaderNames(req.Header) {
+			for _, value := range req.Header.Values(name) {
+				headers = append(headers, harNameValue{Name: name, Value: value})
+			}
+		}
+
+		query := []harNameValue{}
+		if u, err := url.Parse(req.URL); err == nil {
+			for _, param := range strings.Split(u.RawQuery, "&") {
+				if param == "" {
+					continue
+				}
+				name, value, _ := strings.Cut(param, "=")
+				name, _ = url.QueryUnescape(name)
+				value, _ = url.QueryUnescape(value)
+				query = append(query, harNameValue{Name: name, Value: value})
+			}
+		}
+
+		request := map[string]any{
+			"method":      req.Method,
+			"url":         req.URL,
+			"httpVersion": "HTTP/1.1",
+			"headers":     headers,
+			"queryString": query,
+			"cookies":     []any{},
+			"headersSize": -1,
+			"bodySize":    len(req.Body),
+		}
+		if len(req.Body) > 0 {
+			request["postData"] = map[string]any{"mimeType": req.Header.Get("Content-Type"), "text": string(req.Body)}
+		}
+
+		wait := float64(req.Duration) / float64(time.Millisecond)
+		entry := map[string]any{
+			"startedDateTime": req.Time.Format(time.RFC3339Nano),
+			"time":            wait,
+			"request":         request,
+			"response": map[string]any{
+				"status":      req.Status,
+				"statusText":  http.StatusText(req.Status),
+				"httpVersion": "HTTP/1.1",
+				"headers":     []any{},
+				"cookies":     []any{},
+				"content":     map[string]any{"size": 0, "mimeType": ""},
+				"redirectURL": "",
+				"headersSize": -1,
+				"bodySize":    -1,
+			},
+			"cache":   map[string]any{},
+			"timings": map[string]any{"send": 0, "wait": wait, "receive": 0},
+		}
+		if req.RequestID != "" {
+			entry["comment"] = "request.id " + req.RequestID
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"log": map[string]any{
+			"version": "1.2",
+			"creator": map[string]any{"name": "rrl-replay", "version": RecordSchemaVersion},
+			"entries": entries,
+		},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
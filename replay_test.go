@@ -0,0 +1,187 @@
+package recordrequestlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.ReplayCapture = true
+	cfg.CaptureResponseHeaders = []string{"X-Cache"}
+
+	handler, err := recordrequestlog.New(context.Background(), http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+	}), cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://shop.example.com/api/orders?page=2", strings.NewReader(`{"sku":"it's"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "k-123")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Request-Id", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := recordrequestlog.ReadRequestRecords(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	replay, ok := recordrequestlog.NewReplayRequest(records[0])
+	if !ok {
+		t.Fatalf("expected a replayable record, got %+v", records[0])
+	}
+
+	if replay.Method != http.MethodPost || replay.URL != "http://shop.example.com/api/orders?page=2" || string(replay.Body) != `{"sku":"it's"}` {
+		t.Fatalf("unexpected request %+v", replay)
+	}
+	if replay.Status != http.StatusCreated || replay.RequestID != "req-1" || replay.Incomplete || replay.Time.IsZero() {
+		t.Errorf("unexpected request metadata %+v", replay)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":  "application/json",
+		"X-Tenant":      "acme",
+		"Authorization": "REDACTED",
+		"X-Api-Key":     "REDACTED",
+		"Traceparent":   "",
+	} {
+		if got := replay.Header.Get(name); got != want {
+			t.Errorf("expected header %s %q, got %q", name, want, got)
+		}
+	}
+
+	var script bytes.Buffer
+	if err := recordrequestlog.WriteCurl(&script, []recordrequestlog.ReplayRequest{replay}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`curl -sS -X 'POST' 'http://shop.example.com/api/orders?page=2'`,
+		`-H 'X-Tenant: acme'`,
+		`--data-binary '{"sku":"it'\''s"}'`,
+	} {
+		if !strings.Contains(script.String(), want) {
+			t.Errorf("expected %s in curl script:\n%s", want, script.String())
+		}
+	}
+
+	var har bytes.Buffer
+	if err := recordrequestlog.WriteHAR(&har, []recordrequestlog.ReplayRequest{replay}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				Request struct {
+					Method      string `json:"method"`
+					QueryString []struct {
+						Name, Value string
+					} `json:"queryString"`
+					PostData struct {
+						MimeType string `json:"mimeType"`
+						Text     string `json:"text"`
+					} `json:"postData"`
+				} `json:"request"`
+				Response struct {
+					Status int `json:"status"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(har.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 1 {
+		t.Fatalf("unexpected har %s", har.String())
+	}
+	entry := doc.Log.Entries[0]
+	if entry.Request.Method != http.MethodPost || entry.Request.PostData.Text != `{"sku":"it's"}` || entry.Response.Status != http.StatusCreated {
+		t.Errorf("unexpected har entry %+v", entry)
+	}
+	if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0].Value != "2" {
+		t.Errorf("unexpected query string %+v", entry.Request.QueryString)
+	}
+}
+
+func TestReadSpoolRecords(t *testing.T) {
+
+	batch := `{"stream":"default","records":[
+		{"time":"2024-05-01T10:00:00Z","level":8,"message":"{\"id\":1}","attrs":{"method":"PUT","url":"/items/1","host":"api.local","status":500,"body-truncated":true}},
+		{"time":"2024-05-01T10:00:01Z","level":0,"message":"","attrs":{"method":"GET","url":"/items/1","host":"api.local","event":"request-hung"}}
+	]}`
+
+	records, err := recordrequestlog.ReadRequestRecords(strings.NewReader(batch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Level != "error" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	replay, ok := recordrequestlog.NewReplayRequest(records[0])
+	if !ok || replay.URL != "http://api.local/items/1" || !replay.Incomplete || replay.Time.IsZero() {
+		t.Errorf("unexpected request %+v", replay)
+	}
+
+	if _, ok := recordrequestlog.NewReplayRequest(records[1]); ok {
+		t.Error("expected request-hung records to be skipped")
+	}
+}
+
+func TestWriteCurlHostileRequest(t *testing.T) {
+
+	req := recordrequestlog.ReplayRequest{
+		Method:    http.MethodGet,
+		URL:       "http://api.local/items",
+		RequestID: "req-1\nreboot",
+		Header:    http.Header{},
+	}
+
+	var script bytes.Buffer
+	if err := recordrequestlog.WriteCurl(&script, []recordrequestlog.ReplayRequest{req}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(script.String(), "\n") {
+		if strings.HasPrefix(line, "reboot") {
+			t.Fatalf("expected the request ID to stay in the comment line:\n%s", script.String())
+		}
+	}
+	if !strings.Contains(script.String(), "# req-1 reboot\n") {
+		t.Errorf("expected the request ID in the comment line:\n%s", script.String())
+	}
+
+	req.Method = "X`reboot`"
+	if err := recordrequestlog.WriteCurl(&bytes.Buffer{}, []recordrequestlog.ReplayRequest{req}); err == nil {
+		t.Error("expected an error for a method that is not a token of capital letters")
+	}
+
+	rr := recordrequestlog.RequestRecord{Method: "X$(reboot)", URL: "/items", Host: "api.local"}
+	if _, ok := recordrequestlog.NewReplayRequest(rr); ok {
+		t.Error("expected records with a hostile method to be skipped")
+	}
+}
@@ -0,0 +1,76 @@
+package recordrequestlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// 默认的请求 ID 请求头
+const defaultRequestIDHeader = "X-Request-ID"
+
+// 请求携带的请求 ID 的最大长度，超过时生成新的请求 ID
+const maxRequestIDLength = 128
+
+// requestIDKey 日志、span 和指标中请求 ID 的属性名
+const requestIDKey = "request.id"
+
+// requestID 返回请求携带的请求 ID，没有或不合法时生成一个新的，并写入转发的请求和响应头
+func (e *RecordRequestLog) requestID(rw http.ResponseWriter, req *http.Request) (string, bool) {
+
+	header := e.requestIDHeader
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+
+	id := req.Header.Get(header)
+	supplied := validRequestID(id)
+	if !supplied {
+		id = newRequestID()
+		req.Header.Set(header, id)
+	}
+
+	rw.Header().Set(header, id)
+	return id, supplied
+}
+
+// validRequestID 判断客户端提供的请求 ID 是否可以使用：不超过 maxRequestIDLength 个字节且只包含可见 ASCII 字符。
+// 请求 ID 会写入响应头、日志、span 属性和 Kafka key，并作为 retry_link_window 的缓存 key
+func validRequestID(id string) bool {
+
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequestID 返回 ctx 所属请求的请求 ID，ctx 不是由中间件处理的请求时返回空字符串，
+// 例如在 ErrorHandler 中调用 RequestID(req.Context())
+func RequestID(ctx context.Context) string {
+
+	x, _ := ctx.Value(exchangeKey{}).(*Exchange)
+	if x == nil {
+		return ""
+	}
+
+	return x.requestID
+}
+
+// newRequestID 生成 32 位十六进制的随机请求 ID
+func newRequestID() string {
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,65 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+
+	for name, tt := range map[string]struct {
+		incoming string
+		reused   bool
+	}{
+		"generated":     {},
+		"reused":        {incoming: "support-ticket-42", reused: true},
+		"too long":      {incoming: strings.Repeat("a", 129)},
+		"control bytes": {incoming: "ticket\x01-42"},
+		"spaces":        {incoming: "ticket 42"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "requests.log")
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.Backend = recordrequestlog.BackendFile
+			cfg.FilePath = path
+
+			var forwarded string
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Get("X-Request-ID")
+			})
+
+			handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			id := recorder.Header().Get("X-Request-ID")
+			if id == "" || (id == tt.incoming) != tt.reused {
+				t.Fatalf("unexpected response request id %q", id)
+			}
+
+			if forwarded != id {
+				t.Fatalf("forwarded request id %q does not match %q", forwarded, id)
+			}
+
+			records := readRecords(t, path)
+			if len(records) != 1 || records[0]["request.id"] != id {
+				t.Fatalf("expected record with request.id %q, got %v", id, records)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// newResource 生成 trace、metric 和日志共用的资源。service.name 依次使用 service_name、server_name
+// 和中间件名称；开启自动探测时附加主机、操作系统、进程和容器信息，不包含进程的命令行参数，
+// 开启 kubernetes_metadata 时附加 Pod 所在的命名空间、节点和 Deployment。
+// OTEL_RESOURCE_ATTRIBUTES 和 OTEL_SERVICE_NAME 环境变量优先于配置
+func newResource(ctx context.Context, config *Config) (*resource.Resource, error) {
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = config.ServerName
+	}
+	if serviceName == "" {
+		serviceName = instrumentationName
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(config.ResourceAttributes)+3)
+	for key, value := range config.ResourceAttributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+
+	attrs = append(attrs, semconv.ServiceName(serviceName))
+	if config.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(config.ServiceVersion))
+	}
+	if config.DeploymentEnvironment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(config.DeploymentEnvironment))
+	}
+
+	options := []resource.Option{
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attrs...),
+	}
+
+	if config.DetectResource {
+		options = append(options,
+			resource.WithHost(),
+			resource.WithOS(),
+			resource.WithContainer(),
+			resource.WithProcessPID(),
+			resource.WithProcessExecutableName(),
+			resource.WithProcessRuntimeName(),
+			resource.WithProcessRuntimeVersion(),
+		)
+	}
+
+	if config.KubernetesMetadata {
+		ttl, err := parseDuration("kubernetes_cache_ttl", config.KubernetesCacheTTL, defaultKubernetesCacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, resource.WithDetectors(newK8sDetector(config.KubernetesAPI, ttl)))
+	}
+
+	options = append(options, resource.WithFromEnv())
+
+	res, err := resource.New(ctx, options...)
+
+	// 部分探测失败时仍然使用已探测到的属性
+	if errors.Is(err, resource.ErrPartialResource) {
+		return res, nil
+	}
+
+	return res, err
+}
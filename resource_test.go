@@ -0,0 +1,51 @@
+package recordrequestlog
+
+import (
+	"context"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestNewResource(t *testing.T) {
+
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+
+	config := CreateConfig()
+	config.ServerName = "gateway"
+	config.ServiceVersion = "1.2.3"
+	config.DeploymentEnvironment = "staging"
+	config.ResourceAttributes = map[string]string{"team": "platform"}
+
+	res, err := newResource(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		string(semconv.ServiceNameKey):           "gateway",
+		string(semconv.ServiceVersionKey):        "1.2.3",
+		string(semconv.DeploymentEnvironmentKey): "staging",
+		"team":                                   "platform",
+	}
+
+	got := make(map[string]string)
+	for _, kv := range res.Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, got[key])
+		}
+	}
+
+	if got[string(semconv.HostNameKey)] == "" {
+		t.Errorf("expected detected host.name, got %v", got)
+	}
+
+	if _, ok := got[string(semconv.ProcessCommandArgsKey)]; ok {
+		t.Error("expected process.command_args to be omitted")
+	}
+}
@@ -0,0 +1,260 @@
+package recordrequestlog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// responseWriter 记录下一个处理器写入的状态码和响应大小
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+
+	// outer 传给下一个处理器的包装，只实现原始 ResponseWriter 支持的可选接口
+	outer http.ResponseWriter
+
+	// firstWrite 首次写出响应头的时间，用于计算首字节耗时
+	firstWrite time.Time
+	// onStatus 在首次确定状态码（响应头即将写出）时调用
+	onStatus func()
+	// conn 处理器接管的连接，统计收发的字节数
+	conn *countingConn
+	// snippet 不为空时保留响应体的前一部分，作为 span 事件记录
+	snippet *bodySnippet
+}
+
+func newResponseWriter(rw http.ResponseWriter) *responseWriter {
+
+	w := &responseWriter{ResponseWriter: rw}
+	w.outer = w.wrap()
+	return w
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+
+	// 1xx 的中间响应不是最终状态码
+	if code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+		w.setStatus(code)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+
+	w.setStatus(http.StatusOK)
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	if w.snippet != nil {
+		w.snippet.Write(b[:n])
+	}
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问原始的 ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setStatus 记录首次写入的状态码
+func (w *responseWriter) setStatus(code int) {
+
+	if w.status != 0 {
+		return
+	}
+
+	w.status = code
+	w.firstWrite = time.Now()
+	if w.onStatus != nil {
+		w.onStatus()
+	}
+}
+
+// statusCode 返回写入的状态码，未显式写入时为 200
+func (w *responseWriter) statusCode() int {
+
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// flusher、hijacker、readerFrom 和 pusher 转发对应的可选接口，并同步记录状态码和响应大小
+type (
+	flusher    struct{ w *responseWriter }
+	hijacker   struct{ w *responseWriter }
+	readerFrom struct{ w *responseWriter }
+	pusher     struct{ w *responseWriter }
+)
+
+func (f flusher) Flush() {
+
+	f.w.setStatus(http.StatusOK)
+
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+
+	conn, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+
+	h.w.conn = &countingConn{Conn: conn}
+
+	// 读写缓冲改为经过计数的连接，保留已经缓冲的数据
+	if rw != nil {
+		var reader io.Reader = h.w.conn
+		if n := rw.Reader.Buffered(); n > 0 {
+			buffered, _ := rw.Reader.Peek(n)
+			reader = io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), h.w.conn)
+		}
+		rw = bufio.NewReadWriter(bufio.NewReader(reader), bufio.NewWriter(h.w.conn))
+	}
+
+	// 接管连接后由处理器自行写入响应，通常是 WebSocket 的 101
+	h.w.setStatus(http.StatusSwitchingProtocols)
+
+	return h.w.conn, rw, nil
+}
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+
+	r.w.setStatus(http.StatusOK)
+
+	if r.w.snippet != nil {
+		src = io.TeeReader(src, r.w.snippet)
+	}
+
+	n, err := r.w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	r.w.size += n
+	return n, err
+}
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// wrap 返回只实现原始 ResponseWriter 所支持的 http.Flusher、http.Hijacker、io.ReaderFrom 和 http.Pusher 的包装，
+// 避免处理器通过类型断言误判支持的能力，例如对 HTTP/2 连接尝试 Hijack
+func (w *responseWriter) wrap() http.ResponseWriter {
+
+	var (
+		_, isFlusher    = w.ResponseWriter.(http.Flusher)
+		_, isHijacker   = w.ResponseWriter.(http.Hijacker)
+		_, isReaderFrom = w.ResponseWriter.(io.ReaderFrom)
+		_, isPusher     = w.ResponseWriter.(http.Pusher)
+	)
+
+	f, h, r, p := flusher{w}, hijacker{w}, readerFrom{w}, pusher{w}
+
+	type base interface {
+		http.ResponseWriter
+		Unwrap() http.ResponseWriter
+	}
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom && isPusher:
+		return struct {
+			base
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, f, h, r, p}
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			base
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, f, h, r}
+	case isFlusher && isHijacker && isPusher:
+		return struct {
+			base
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, f, h, p}
+	case isFlusher && isReaderFrom && isPusher:
+		return struct {
+			base
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{w, f, r, p}
+	case isHijacker && isReaderFrom && isPusher:
+		return struct {
+			base
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, h, r, p}
+	case isFlusher && isHijacker:
+		return struct {
+			base
+			http.Flusher
+			http.Hijacker
+		}{w, f, h}
+	case isFlusher && isReaderFrom:
+		return struct {
+			base
+			http.Flusher
+			io.ReaderFrom
+		}{w, f, r}
+	case isFlusher && isPusher:
+		return struct {
+			base
+			http.Flusher
+			http.Pusher
+		}{w, f, p}
+	case isHijacker && isReaderFrom:
+		return struct {
+			base
+			http.Hijacker
+			io.ReaderFrom
+		}{w, h, r}
+	case isHijacker && isPusher:
+		return struct {
+			base
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case isReaderFrom && isPusher:
+		return struct {
+			base
+			io.ReaderFrom
+			http.Pusher
+		}{w, r, p}
+	case isFlusher:
+		return struct {
+			base
+			http.Flusher
+		}{w, f}
+	case isHijacker:
+		return struct {
+			base
+			http.Hijacker
+		}{w, h}
+	case isReaderFrom:
+		return struct {
+			base
+			io.ReaderFrom
+		}{w, r}
+	case isPusher:
+		return struct {
+			base
+			http.Pusher
+		}{w, p}
+	default:
+		return w
+	}
+}
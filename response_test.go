@@ -0,0 +1,241 @@
+package recordrequestlog_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+// plainWriter 只实现 http.ResponseWriter
+type plainWriter struct {
+	header http.Header
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *plainWriter) WriteHeader(int)             {}
+
+// fullWriter 实现所有可选接口并记录调用
+type fullWriter struct {
+	plainWriter
+	flushed  bool
+	hijacked bool
+	readFrom int64
+	pushed   string
+}
+
+func (w *fullWriter) Flush() { w.flushed = true }
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func (w *fullWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.Copy(io.Discard, r)
+	w.readFrom += n
+	return n, err
+}
+
+func (w *fullWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+// serveWith 使用 rw 处理一个请求，返回写入的记录
+func serveWith(t *testing.T, rw http.ResponseWriter, next http.HandlerFunc) map[string]any {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	return records[0]
+}
+
+func TestResponseWriterOptionalInterfaces(t *testing.T) {
+
+	rw := &fullWriter{plainWriter: plainWriter{header: make(http.Header)}}
+
+	serveWith(t, rw, func(w http.ResponseWriter, req *http.Request) {
+		w.(http.Flusher).Flush()
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Error(err)
+		}
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello")); err != nil {
+			t.Error(err)
+		}
+		if err := w.(http.Pusher).Push("/style.css", nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if !rw.flushed || !rw.hijacked || rw.readFrom != 5 || rw.pushed != "/style.css" {
+		t.Errorf("expected all calls to be forwarded, got %+v", rw)
+	}
+}
+
+func TestResponseWriterWithoutOptionalInterfaces(t *testing.T) {
+
+	serveWith(t, &plainWriter{header: make(http.Header)}, func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Error("unexpected http.Flusher")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("unexpected http.Hijacker")
+		}
+		if _, ok := w.(io.ReaderFrom); ok {
+			t.Error("unexpected io.ReaderFrom")
+		}
+		if _, ok := w.(http.Pusher); ok {
+			t.Error("unexpected http.Pusher")
+		}
+	})
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+
+	rw := httptest.NewRecorder()
+
+	record := serveWith(t, rw, func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	if !rw.Flushed {
+		t.Error("expected response to be flushed")
+	}
+	if record["status"] != float64(http.StatusOK) {
+		t.Errorf("expected status 200, got %v", record["status"])
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", resp.StatusCode)
+	}
+
+	// WebSocket 记录连接建立和关闭两条记录
+	records := readRecords(t, path)
+	if len(records) != 2 || records[0]["status"] != float64(http.StatusSwitchingProtocols) {
+		t.Fatalf("unexpected records %v", records)
+	}
+
+	if sent, _ := records[1]["bytes-sent"].(float64); sent == 0 {
+		t.Errorf("expected bytes written to the hijacked connection, got %v", records[1])
+	}
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+
+	rw := &fullWriter{plainWriter: plainWriter{header: make(http.Header)}}
+
+	serveWith(t, rw, func(w http.ResponseWriter, req *http.Request) {
+		// io.Copy 使用 ReaderFrom 时才能走 sendfile 等优化路径
+		if _, err := io.Copy(w, io.LimitReader(strings.NewReader(strings.Repeat("x", 1024)), 1024)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if rw.readFrom != 1024 {
+		t.Errorf("expected ReadFrom to receive 1024 bytes, got %d", rw.readFrom)
+	}
+}
+
+func TestCaptureResponseHeaders(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.CaptureResponseHeaders = []string{"x-cache", "Content-Type", "Set-Cookie", "X-RateLimit-Remaining"}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Add("X-Cache", "HIT")
+		rw.Header().Add("X-Cache", "MISS")
+		rw.Header().Set("Set-Cookie", "session=secret")
+		rw.Header().Set("X-Other", "ignored")
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	record := rec.RequireRecords(t, 1)[0]
+
+	for key, want := range map[string]string{
+		"response-headers.content-type": "application/json",
+		"response-headers.x-cache":      "HIT, MISS",
+		"response-headers.set-cookie":   "REDACTED",
+	} {
+		if got, _ := recordrequestlogtest.Attr(record, key); got.String() != want {
+			t.Errorf("expected %s %q, got %q", key, want, got.String())
+		}
+	}
+
+	for _, key := range []string{"response-headers.x-other", "response-headers.x-ratelimit-remaining"} {
+		if _, ok := recordrequestlogtest.Attr(record, key); ok {
+			t.Errorf("unexpected attribute %s", key)
+		}
+	}
+}
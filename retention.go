@@ -0,0 +1,213 @@
+package recordrequestlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionClassConfig 记录的保留类别。规则按顺序匹配，第一个满足全部条件的规则生效，条件都为空时匹配所有记录
+type RetentionClassConfig struct {
+	// 类别名称，例如 "audit"、"access"、"debug"
+	Class string `yaml:"class"`
+	// 保留时长，例如 "1y"、"30d"、"72h"，除 Go 的时长格式外支持 d（天）、w（周）和 y（365 天）；为空时只记录类别
+	TTL string `yaml:"ttl,omitempty"`
+
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+	// 状态码类别或具体状态码，例如 "5xx"、"401"，没有状态码的记录（例如 request-received）不匹配
+	Status []string `yaml:"status,omitempty"`
+	// 匹配该级别及以上的记录
+	MinLevel string `yaml:"min_level,omitempty"`
+	// 记录的 event 属性，例如 "request-hung"
+	Events []string `yaml:"events,omitempty"`
+	// 只匹配调试请求的记录
+	Debug bool `yaml:"debug,omitempty"`
+}
+
+// retentionClass 解析后的保留类别规则
+type retentionClass struct {
+	class string
+	// 下游存储使用的保留时长，例如 "30d"，为空时不记录
+	ttl string
+
+	pathPrefix string
+	// 状态码类别（1 到 5）或具体状态码
+	statuses []struct{ class, code int }
+	minLevel *slog.Level
+	events   []string
+	debug    bool
+}
+
+// newRetentionClasses 解析保留类别规则
+func newRetentionClasses(configs []RetentionClassConfig) ([]retentionClass, error) {
+
+	classes := make([]retentionClass, 0, len(configs))
+
+	for i, config := range configs {
+		if config.Class == "" {
+			return nil, fmt.Errorf("retention_classes[%d].class is required", i)
+		}
+
+		c := retentionClass{
+			class:      config.Class,
+			pathPrefix: config.PathPrefix,
+			events:     config.Events,
+			debug:      config.Debug,
+		}
+
+		if config.TTL != "" {
+			ttl, err := parseRetention(fmt.Sprintf("retention_classes[%d].ttl", i), config.TTL)
+			if err != nil {
+				return nil, err
+			}
+			c.ttl = formatRetention(ttl)
+		}
+
+		for _, status := range config.Status {
+			class, code, ok := parseStatusMatch(status)
+			if !ok {
+				return nil, fmt.Errorf("invalid retention_classes[%d].status %q: must be a status class such as 5xx or a status code", i, status)
+			}
+			c.statuses = append(c.statuses, struct{ class, code int }{class, code})
+		}
+
+		if config.MinLevel != "" {
+			level, err := parseLevel(fmt.Sprintf("retention_classes[%d].min_level", i), config.MinLevel)
+			if err != nil {
+				return nil, err
+			}
+			c.minLevel = &level
+		}
+
+		classes = append(classes, c)
+	}
+
+	return classes, nil
+}
+
+// parseRetention 解析保留时长，支持 d、w 和 y 单位，例如 "30d"、"1y"
+func parseRetention(name, value string) (time.Duration, error) {
+
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour}
+	for suffix, unit := range units {
+		n, ok := strings.CutSuffix(value, suffix)
+		if !ok {
+			continue
+		}
+		count, err := strconv.Atoi(n)
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid %s %q: must be a positive number of %s", name, value, suffix)
+		}
+		return time.Duration(count) * unit, nil
+	}
+
+	d, err := parseDuration(name, value, 0)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("invalid %s %q: must be at least 1s", name, value)
+	}
+
+	return d, nil
+}
+
+// formatRetention 使用 Elasticsearch 的时间单位格式化保留时长，整天时为 "30d"，否则依次使用 h、m、s
+func formatRetention(d time.Duration) string {
+
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+	} {
+		if d%unit.size == 0 {
+			return strconv.FormatInt(int64(d/unit.size), 10) + unit.suffix
+		}
+	}
+
+	return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+}
+
+// matches 判断记录是否满足规则的全部条件
+func (c *retentionClass) matches(ctx context.Context, e *RecordRequestLog, record *Record) bool {
+
+	if c.debug && !debugContext(ctx) {
+		return false
+	}
+
+	if c.minLevel != nil && record.Level < *c.minLevel {
+		return false
+	}
+
+	if c.pathPrefix != "" {
+		x, _ := ctx.Value(exchangeKey{}).(*Exchange)
+		if x == nil || !strings.HasPrefix(x.req.URL.Path, c.pathPrefix) {
+			return false
+		}
+	}
+
+	if len(c.statuses) > 0 {
+		status, ok := recordValue(*record, e.attrKey("status", "http.response.status_code"))
+		if !ok || status.Kind() != slog.KindInt64 {
+			return false
+		}
+		code := int(status.Int64())
+
+		matched := false
+		for _, s := range c.statuses {
+			if s.code == code || (s.code == 0 && s.class == code/100) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(c.events) > 0 {
+		event, ok := recordValue(*record, e.attrKey("event", "event.name"))
+		if !ok {
+			return false
+		}
+
+		matched := false
+		for _, name := range c.events {
+			if name == event.String() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyRetention 为记录追加第一个匹配规则的保留类别和保留时长，
+// 属性为 retention-class、retention-ttl（semconv 格式为 retention.class、retention.ttl）
+func (e *RecordRequestLog) applyRetention(ctx context.Context, record *Record) {
+
+	for _, c := range e.rules.Load().retention {
+		if !c.matches(ctx, e, record) {
+			continue
+		}
+
+		// 不修改可能与其他记录共享的底层数组
+		attrs := append(slices.Clip(record.Attrs), slog.String(e.attrKey("retention-class", "retention.class"), c.class))
+		if c.ttl != "" {
+			attrs = append(attrs, slog.String(e.attrKey("retention-ttl", "retention.ttl"), c.ttl))
+		}
+		record.Attrs = attrs
+		return
+	}
+}
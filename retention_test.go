@@ -0,0 +1,52 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+)
+
+func TestRetentionClasses(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.RetentionClasses = []recordrequestlog.RetentionClassConfig{
+		{Class: "audit", TTL: "1y", PathPrefix: "/admin"},
+		{Class: "errors", TTL: "2160h", Status: []string{"5xx", "429"}},
+		{Class: "access", TTL: "30d"},
+	}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/admin/users", "/fail":
+			rw.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+
+	for _, tc := range []struct {
+		path, class, ttl string
+	}{
+		{"/admin/users", "audit", "365d"},
+		{"/fail", "errors", "90d"},
+		{"/", "access", "30d"},
+	} {
+		rec.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+tc.path, nil))
+
+		record := rec.RequireRecords(t, 1)[0]
+		if v, _ := recordrequestlogtest.Attr(record, "retention-class"); v.String() != tc.class {
+			t.Errorf("%s: expected retention-class %q, got %q", tc.path, tc.class, v)
+		}
+		if v, _ := recordrequestlogtest.Attr(record, "retention-ttl"); v.String() != tc.ttl {
+			t.Errorf("%s: expected retention-ttl %q, got %q", tc.path, tc.ttl, v)
+		}
+	}
+}
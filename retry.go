@@ -0,0 +1,247 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 默认的导出重试间隔，与 OTLP 导出器的默认值一致
+const (
+	defaultRetryInitialInterval = 5 * time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = time.Minute
+)
+
+// retryMultiplier 每次重试间隔的增长倍数，retryJitter 间隔随机浮动的比例
+const (
+	retryMultiplier = 1.5
+	retryJitter     = 0.5
+)
+
+// defaultRetryGRPCCodes 默认可重试的 gRPC 状态码，与 OTLP 导出器一致
+var defaultRetryGRPCCodes = []grpccodes.Code{
+	grpccodes.Canceled,
+	grpccodes.DeadlineExceeded,
+	grpccodes.ResourceExhausted,
+	grpccodes.Aborted,
+	grpccodes.OutOfRange,
+	grpccodes.Unavailable,
+	grpccodes.DataLoss,
+}
+
+// defaultRetryHTTPCodes 默认可重试的 HTTP 状态码
+var defaultRetryHTTPCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryPolicy 导出失败时按指数退避加随机抖动重试，直到成功、遇到不可重试的错误或超过最长重试时间
+type retryPolicy struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxElapsedTime  time.Duration
+	grpcCodes       map[grpccodes.Code]bool
+	httpCodes       map[int]bool
+}
+
+// newRetryPolicy 根据配置创建重试策略，关闭重试时返回 nil
+func newRetryPolicy(config *Config) (*retryPolicy, error) {
+
+	initialInterval, err := parseDuration("retry_initial_interval", config.RetryInitialInterval, defaultRetryInitialInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	maxInterval, err := parseDuration("retry_max_interval", config.RetryMaxInterval, defaultRetryMaxInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	maxElapsedTime, err := parseDuration("retry_max_elapsed_time", config.RetryMaxElapsedTime, defaultRetryMaxElapsedTime)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &retryPolicy{
+		initialInterval: initialInterval,
+		maxInterval:     max(initialInterval, maxInterval),
+		maxElapsedTime:  maxElapsedTime,
+		grpcCodes:       make(map[grpccodes.Code]bool),
+		httpCodes:       make(map[int]bool),
+	}
+
+	for _, value := range config.RetryStatusCodes {
+		value = strings.TrimSpace(value)
+
+		if n, err := strconv.Atoi(value); err == nil {
+			if n < 100 || n > 599 {
+				return nil, fmt.Errorf("invalid retry_status_codes %q", value)
+			}
+			p.httpCodes[n] = true
+			continue
+		}
+
+		var code grpccodes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(value)))); err != nil {
+			return nil, fmt.Errorf("invalid retry_status_codes %q", value)
+		}
+		p.grpcCodes[code] = true
+	}
+
+	// 只配置了其中一类状态码时，另一类使用默认列表
+	if len(p.grpcCodes) == 0 {
+		for _, code := range defaultRetryGRPCCodes {
+			p.grpcCodes[code] = true
+		}
+	}
+
+	if len(p.httpCodes) == 0 {
+		for _, code := range defaultRetryHTTPCodes {
+			p.httpCodes[code] = true
+		}
+	}
+
+	if !config.ExportRetry {
+		return nil, nil
+	}
+
+	return p, nil
+}
+
+// do 调用 fn，retryable 判断错误是否可以重试并返回服务端要求的最短等待时间
+func (p *retryPolicy) do(ctx context.Context, fn func(ctx context.Context) error, retryable func(err error) (bool, time.Duration)) error {
+
+	if p == nil {
+		return fn(ctx)
+	}
+
+	deadline := time.Now().Add(p.maxElapsedTime)
+	interval := p.initialInterval
+
+	for {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		ok, throttle := retryable(err)
+		if !ok {
+			return err
+		}
+
+		delay := max(jitter(interval), throttle)
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("max retry time elapsed: %w", err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		}
+
+		interval = min(time.Duration(float64(interval)*retryMultiplier), p.maxInterval)
+	}
+}
+
+func jitter(interval time.Duration) time.Duration {
+	return time.Duration(float64(interval) * (1 - retryJitter + 2*retryJitter*rand.Float64()))
+}
+
+// unaryInterceptor 按重试策略重试 OTLP gRPC 导出请求
+func (p *retryPolicy) unaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return p.do(ctx, func(ctx context.Context) error {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}, p.grpcRetryable)
+}
+
+// dialOptions 返回 OTLP gRPC 导出器使用的重试拦截器，关闭重试时为空
+func (p *retryPolicy) dialOptions() []grpc.DialOption {
+
+	if p == nil {
+		return nil
+	}
+
+	return []grpc.DialOption{grpc.WithChainUnaryInterceptor(p.unaryInterceptor)}
+}
+
+func (p *retryPolicy) grpcRetryable(err error) (bool, time.Duration) {
+
+	s := status.Convert(err)
+	if !p.grpcCodes[s.Code()] {
+		return false, 0
+	}
+
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return true, info.GetRetryDelay().AsDuration()
+		}
+	}
+
+	return true, 0
+}
+
+// statusError 导出端返回的非 2xx HTTP 响应
+type statusError struct {
+	code       int
+	retryAfter time.Duration
+	msg        string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// httpRetryable 状态码在可重试列表中时重试；没有收到响应的网络错误同样重试
+func (p *retryPolicy) httpRetryable(err error) (bool, time.Duration) {
+
+	var serr *statusError
+	if errors.As(err, &serr) {
+		return p.httpCodes[serr.code], serr.retryAfter
+	}
+
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded), 0
+}
+
+// parseRetryAfter 解析以秒为单位的 Retry-After 响应头
+func parseRetryAfter(value string) time.Duration {
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// httpConfig 返回 OTLP HTTP 导出器的重试配置。导出器只重试固定的状态码，退避倍数和随机抖动与
+// retryMultiplier、retryJitter 一致
+func (p *retryPolicy) httpConfig() otlploghttp.RetryConfig {
+
+	if p == nil {
+		return otlploghttp.RetryConfig{Enabled: false}
+	}
+
+	return otlploghttp.RetryConfig{
+		Enabled:         true,
+		InitialInterval: p.initialInterval,
+		MaxInterval:     p.maxInterval,
+		MaxElapsedTime:  p.maxElapsedTime,
+	}
+}
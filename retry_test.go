@@ -0,0 +1,78 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExportRetry(t *testing.T) {
+
+	tests := map[string]struct {
+		codes    []string
+		attempts int32
+	}{
+		"default codes":    {attempts: 3},
+		"configured codes": {codes: []string{"500", "UNAVAILABLE"}, attempts: 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var attempts atomic.Int32
+			received := make(chan []map[string]any, 1)
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				// 前两次写入返回 503
+				if attempts.Add(1) <= 2 {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				var records []map[string]any
+				if err := json.NewDecoder(req.Body).Decode(&records); err != nil {
+					t.Error(err)
+				}
+				received <- records
+			}))
+			defer server.Close()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.Backend = recordrequestlog.BackendOpenObserve
+			cfg.Endpoint = server.URL
+			cfg.Organization = "default"
+			cfg.StreamName = "requests"
+			cfg.LogMaxBatchSize = 1
+			cfg.RetryInitialInterval = "1ms"
+			cfg.RetryMaxInterval = "5ms"
+			cfg.RetryStatusCodes = tt.codes
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			select {
+			case records := <-received:
+				if len(records) != 1 || records[0]["message"] != `{"id":1}` {
+					t.Fatalf("unexpected records %v", records)
+				}
+			case <-time.After(500 * time.Millisecond):
+			}
+
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, got)
+			}
+		})
+	}
+}
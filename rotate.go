@@ -0,0 +1,248 @@
+package recordrequestlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 轮转后文件名中的时间格式，按文件名排序即为轮转顺序
+const rotateTimeFormat = "20060102T150405.000"
+
+// rotatingFile 追加写入文件，超过大小上限或到达轮转时间时将当前文件改名为带时间的备份文件并重新创建，
+// 备份文件在后台压缩，并按个数和保留时间删除最早的备份
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	onError    func(msg string, err error)
+
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	rotateAt time.Time
+	// 后台压缩和清理的 goroutine，cleanupMu 使各次清理依次进行
+	wg        sync.WaitGroup
+	cleanupMu sync.Mutex
+}
+
+// newRotatingFile 按 file_* 配置打开文件，没有配置大小上限和轮转间隔时不轮转
+func newRotatingFile(config *Config, onError func(msg string, err error)) (*rotatingFile, error) {
+
+	interval, err := parseDuration("file_rotate_interval", config.FileRotateInterval, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAge, err := parseDuration("file_max_age", config.FileMaxAge, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &rotatingFile{
+		path:       config.FilePath,
+		maxSize:    config.FileMaxSize,
+		interval:   interval,
+		maxBackups: config.FileMaxBackups,
+		maxAge:     maxAge,
+		compress:   config.FileCompress,
+		onError:    onError,
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *rotatingFile) open() error {
+
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.f = f
+	w.size = info.Size()
+	if w.interval > 0 {
+		// 按整点边界轮转，例如 24h 在每天 0 点（UTC）轮转；已有的文件按修改时间计算
+		w.rotateAt = info.ModTime().Truncate(w.interval).Add(w.interval)
+	}
+
+	return nil
+}
+
+func (w *rotatingFile) Write(p []byte) (int, error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.size > 0 && ((w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize) || (w.interval > 0 && !time.Now().Before(w.rotateAt))) {
+		// 轮转失败时继续写入原文件
+		if err := w.rotate(); err != nil {
+			w.onError("rotate file", err)
+		}
+		if w.f == nil {
+			return 0, os.ErrClosed
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件并改名为备份文件，调用时持有 mu
+func (w *rotatingFile) rotate() error {
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	ext := filepath.Ext(w.path)
+	backup := ""
+	// 同一毫秒内多次轮转时顺延时间，避免覆盖已有的备份
+	for t := time.Now().UTC(); backup == "" || fileExists(backup) || fileExists(backup+".gz"); t = t.Add(time.Millisecond) {
+		backup = strings.TrimSuffix(w.path, ext) + "-" + t.Format(rotateTimeFormat) + ext
+	}
+	if err := os.Rename(w.path, backup); err != nil {
+		return errors.Join(err, w.open())
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.cleanup(backup)
+	}()
+
+	return nil
+}
+
+// cleanup 压缩刚轮转的备份文件，并删除超出个数和保留时间的备份
+func (w *rotatingFile) cleanup(backup string) {
+
+	w.cleanupMu.Lock()
+	defer w.cleanupMu.Unlock()
+
+	if w.compress {
+		if err := gzipFile(backup); err != nil {
+			w.onError("compress rotated file", err)
+		}
+	}
+
+	if w.maxBackups <= 0 && w.maxAge <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(w.path)
+	matches, err := filepath.Glob(strings.TrimSuffix(w.path, ext) + "-*" + ext + "*")
+	if err != nil {
+		w.onError("list rotated files", err)
+		return
+	}
+
+	// 只处理文件名符合备份格式的文件，按时间从新到旧排序
+	var backups []string
+	prefix := strings.TrimSuffix(filepath.Base(w.path), ext) + "-"
+	for _, m := range matches {
+		stamp := strings.TrimPrefix(filepath.Base(m), prefix)
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		if _, err := time.Parse(rotateTimeFormat, stamp); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	slices.Sort(backups)
+	slices.Reverse(backups)
+
+	for i, b := range backups {
+		expired := w.maxBackups > 0 && i >= w.maxBackups
+		if !expired && w.maxAge > 0 {
+			if info, err := os.Stat(b); err == nil && time.Since(info.ModTime()) > w.maxAge {
+				expired = true
+			}
+		}
+
+		if expired {
+			if err := os.Remove(b); err != nil && !os.IsNotExist(err) {
+				w.onError("remove rotated file", err)
+			}
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipFile 将文件压缩为 .gz 文件并删除原文件
+func gzipFile(path string) error {
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func (w *rotatingFile) Close() error {
+
+	w.mu.Lock()
+	var err error
+	if w.f != nil {
+		err = w.f.Close()
+		w.f = nil
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return err
+}
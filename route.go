@@ -0,0 +1,295 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// routeSettings 单个请求生效的设置，由顶层配置和匹配的路由覆盖合并而来
+type routeSettings struct {
+	streamName          string
+	sampleRate          float64
+	captureMethods      map[string]struct{}
+	captureContentTypes []string
+	base64Binary        bool
+	maxBinaryBodySize   int
+	maxBodySize         int
+	logMode             *logMode
+	slowThreshold       time.Duration
+	statusStreams       []statusStream
+	// 调试请求记录所有请求方法和内容类型的请求体
+	captureAll bool
+	// 开启 target_records_per_second 时按请求速率调整采样概率
+	adaptive *adaptiveSampler
+	// 配置 slo_latency_threshold 时的 SLO
+	slo *slo
+}
+
+// route 路由匹配条件及其对应的设置
+type route struct {
+	host       string
+	pathPrefix string
+	pathRegex  *regexp.Regexp
+	settings   *routeSettings
+}
+
+// newRouteSettings 根据顶层配置生成默认设置
+func newRouteSettings(config *Config) (*routeSettings, error) {
+
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("invalid sample_rate %v: must be between 0 and 1", config.SampleRate)
+	}
+
+	captureMethods := config.CaptureMethods
+	if captureMethods == nil {
+		captureMethods = defaultCaptureMethods
+	}
+
+	captureContentTypes := config.CaptureContentTypes
+	if captureContentTypes == nil {
+		captureContentTypes = defaultCaptureContentTypes
+	}
+
+	maxBinaryBodySize := config.MaxBinaryBodySize
+	if maxBinaryBodySize <= 0 {
+		maxBinaryBodySize = defaultMaxBinaryBodySize
+	}
+
+	maxBodySize := config.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+
+	logMode, err := parseLogMode("log_mode", config.LogMode)
+	if err != nil {
+		return nil, err
+	}
+
+	slowThreshold, err := parseDuration("slow_threshold", config.SlowThreshold, defaultSlowThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	statusStreams, err := newStatusStreams("status_streams", config.StatusStreams)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TargetRecordsPerSecond < 0 {
+		return nil, fmt.Errorf("invalid target_records_per_second %v: must not be negative", config.TargetRecordsPerSecond)
+	}
+
+	var adaptive *adaptiveSampler
+	if config.TargetRecordsPerSecond > 0 {
+		adaptive = newAdaptiveSampler("default", config.TargetRecordsPerSecond)
+	}
+
+	slo, err := newSLO("default", "", config.SLOLatencyThreshold, config.SLOObjective)
+	if err != nil {
+		return nil, err
+	}
+
+	return &routeSettings{
+		streamName:          config.StreamName,
+		sampleRate:          config.SampleRate,
+		captureMethods:      methodSet(captureMethods),
+		captureContentTypes: lowerAll(captureContentTypes),
+		base64Binary:        config.Base64BinaryBody,
+		maxBinaryBodySize:   maxBinaryBodySize,
+		maxBodySize:         maxBodySize,
+		logMode:             logMode,
+		slowThreshold:       slowThreshold,
+		statusStreams:       statusStreams,
+		adaptive:            adaptive,
+		slo:                 slo,
+	}, nil
+}
+
+// newRoutes 根据路由配置生成路由，未覆盖的设置沿用 defaults
+func newRoutes(configs []RouteConfig, defaults *routeSettings) ([]*route, error) {
+
+	routes := make([]*route, 0, len(configs))
+
+	for i, config := range configs {
+		r, err := newRoute(i, config, defaults)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// newRoute 生成第 i 个路由，错误信息中使用 routes[i] 标识字段
+func newRoute(i int, config RouteConfig, defaults *routeSettings) (*route, error) {
+
+	r := &route{
+		host:       strings.ToLower(config.Host),
+		pathPrefix: config.PathPrefix,
+	}
+
+	if config.PathRegex != "" {
+		re, err := regexp.Compile(config.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid routes[%d].path_regex %q: %w", i, config.PathRegex, err)
+		}
+		r.pathRegex = re
+	}
+
+	if _, err := path.Match(r.host, ""); err != nil {
+		return nil, fmt.Errorf("invalid routes[%d].host %q: %w", i, config.Host, err)
+	}
+
+	settings := *defaults
+
+	if config.StreamName != "" {
+		settings.streamName = config.StreamName
+	}
+
+	if config.SampleRate != nil {
+		if *config.SampleRate < 0 || *config.SampleRate > 1 {
+			return nil, fmt.Errorf("invalid routes[%d].sample_rate %v: must be between 0 and 1", i, *config.SampleRate)
+		}
+		settings.sampleRate = *config.SampleRate
+	}
+
+	if config.CaptureMethods != nil {
+		settings.captureMethods = methodSet(config.CaptureMethods)
+	}
+
+	if config.CaptureContentTypes != nil {
+		settings.captureContentTypes = lowerAll(config.CaptureContentTypes)
+	}
+
+	if config.Base64BinaryBody != nil {
+		settings.base64Binary = *config.Base64BinaryBody
+	}
+
+	if config.MaxBinaryBodySize > 0 {
+		settings.maxBinaryBodySize = config.MaxBinaryBodySize
+	}
+
+	if config.MaxBodySize > 0 {
+		settings.maxBodySize = config.MaxBodySize
+	}
+
+	if config.LogMode != "" {
+		logMode, err := parseLogMode(fmt.Sprintf("routes[%d].log_mode", i), config.LogMode)
+		if err != nil {
+			return nil, err
+		}
+		settings.logMode = logMode
+	}
+
+	if config.SlowThreshold != "" {
+		slowThreshold, err := parseDuration(fmt.Sprintf("routes[%d].slow_threshold", i), config.SlowThreshold, defaultSlowThreshold)
+		if err != nil {
+			return nil, err
+		}
+		settings.slowThreshold = slowThreshold
+	}
+
+	if config.StatusStreams != nil {
+		statusStreams, err := newStatusStreams(fmt.Sprintf("routes[%d].status_streams", i), config.StatusStreams)
+		if err != nil {
+			return nil, err
+		}
+		settings.statusStreams = statusStreams
+	}
+
+	// 每个路由单独统计请求速率
+	target := config.TargetRecordsPerSecond
+	if target == nil && defaults.adaptive != nil {
+		target = &defaults.adaptive.target
+	}
+	switch {
+	case target == nil:
+	case *target < 0:
+		return nil, fmt.Errorf("invalid routes[%d].target_records_per_second %v: must not be negative", i, *target)
+	case *target == 0:
+		settings.adaptive = nil
+	default:
+		settings.adaptive = newAdaptiveSampler(fmt.Sprintf("routes[%d]", i), *target)
+	}
+
+	if config.SLOLatencyThreshold != "" || config.SLOObjective != nil {
+		threshold, objective := config.SLOLatencyThreshold, defaultSLOObjective
+		if defaults.slo != nil {
+			objective = defaults.slo.objective
+			if threshold == "" {
+				threshold = defaults.slo.threshold.String()
+			}
+		}
+		if config.SLOObjective != nil {
+			objective = *config.SLOObjective
+		}
+
+		slo, err := newSLO(fmt.Sprintf("routes[%d]", i), fmt.Sprintf("routes[%d].", i), threshold, objective)
+		if err != nil {
+			return nil, err
+		}
+		settings.slo = slo
+	}
+
+	r.settings = &settings
+	return r, nil
+}
+
+// match 判断请求是否匹配路由
+func (r *route) match(req *http.Request) bool {
+
+	if r.host != "" {
+		host := strings.ToLower(req.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if ok, _ := path.Match(r.host, host); !ok {
+			return false
+		}
+	}
+
+	if r.pathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.pathPrefix) {
+		return false
+	}
+
+	if r.pathRegex != nil && !r.pathRegex.MatchString(req.URL.Path) {
+		return false
+	}
+
+	return true
+}
+
+// settings 返回请求生效的设置，使用第一个匹配的路由，都不匹配时使用顶层配置
+func (rules *rules) settings(req *http.Request) *routeSettings {
+
+	for _, r := range rules.routes {
+		if r.match(req) {
+			return r.settings
+		}
+	}
+
+	return rules.defaults
+}
+
+// sampled 按采样率决定是否记录当前请求，开启自适应采样时概率同时受请求速率限制
+func (s *routeSettings) sampled() bool {
+
+	rate := s.sampleRate
+	if s.adaptive != nil {
+		rate = min(rate, s.adaptive.observe(time.Now()))
+	}
+
+	if rate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < rate
+}
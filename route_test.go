@@ -0,0 +1,68 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestRouteOverrides(t *testing.T) {
+
+	never := 0.0
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.StreamName = "default"
+	cfg.Routes = []recordrequestlog.RouteConfig{
+		{Host: "*.example.com", PathPrefix: "/health", SampleRate: &never},
+		{PathRegex: `^/api/v\d+/orders`, StreamName: "orders", CaptureMethods: []string{}},
+	}
+
+	calls := 0
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{
+		"http://api.example.com:8080/health",
+		"http://localhost/api/v1/orders",
+	} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected every request to be forwarded, got %d", calls)
+	}
+}
+
+func TestInvalidRouteConfig(t *testing.T) {
+
+	invalid := 1.5
+
+	tests := map[string]recordrequestlog.RouteConfig{
+		"path_regex":  {PathRegex: "(("},
+		"host":        {Host: "[a-"},
+		"sample_rate": {SampleRate: &invalid},
+	}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	for name, route := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := recordrequestlog.CreateConfig()
+			cfg.Routes = []recordrequestlog.RouteConfig{route}
+
+			if _, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin"); err == nil {
+				t.Fatalf("expected error for invalid %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package recordrequestlog_test
+
+import (
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"testing"
+)
+
+func TestRuntimeAndHostMetrics(t *testing.T) {
+
+	for _, enabled := range []bool{true, false} {
+		rec := recordrequestlogtest.New()
+
+		cfg := recordrequestlog.CreateConfig()
+		cfg.RuntimeMetrics = enabled
+		cfg.HostMetrics = enabled
+
+		if _, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option()); err != nil {
+			t.Fatal(err)
+		}
+
+		names := make(map[string]bool)
+		for _, m := range rec.Metrics(t) {
+			names[m.Name] = true
+		}
+
+		for _, name := range []string{"process.runtime.go.goroutines", "process.cpu.time"} {
+			if names[name] != enabled {
+				t.Errorf("runtime_metrics=%v host_metrics=%v: expected %s reported = %v, got %v", enabled, enabled, name, enabled, names[name])
+			}
+		}
+	}
+}
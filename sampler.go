@@ -0,0 +1,42 @@
+package recordrequestlog
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// trace 采样器，名称与 OTEL_TRACES_SAMPLER 一致。采样只影响 span 的导出，日志和指标仍然记录所有请求
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// newSampler 根据采样器名称和采样率创建 trace 采样器，parentbased 采样器沿用上游的采样决定
+func newSampler(name string, ratio float64) (trace.Sampler, error) {
+
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid trace_sample_ratio %v: must be between 0 and 1", ratio)
+	}
+
+	switch name {
+	case "", SamplerParentBasedAlwaysOn:
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	case SamplerParentBasedAlwaysOff:
+		return trace.ParentBased(trace.NeverSample()), nil
+	case SamplerParentBasedTraceIDRatio:
+		return trace.ParentBased(trace.TraceIDRatioBased(ratio)), nil
+	case SamplerAlwaysOn:
+		return trace.AlwaysSample(), nil
+	case SamplerAlwaysOff:
+		return trace.NeverSample(), nil
+	case SamplerTraceIDRatio:
+		return trace.TraceIDRatioBased(ratio), nil
+	default:
+		return nil, fmt.Errorf("invalid trace_sampler %q", name)
+	}
+}
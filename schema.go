@@ -0,0 +1,225 @@
+package recordrequestlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// RecordSchemaVersion 请求记录字段的版本，记录为 schema_version 属性。
+// 字段被删除、改名或改变类型时递增，新增字段不递增
+const RecordSchemaVersion = "1"
+
+// schemaVersionKey 记录中 schema_version 属性的名称，两种日志格式相同
+const schemaVersionKey = "schema_version"
+
+// RequestRecord 请求记录中的常用字段。json 标签为 legacy 格式的属性名，semconv 标签为 semconv 格式的属性名，
+// legacy 格式的记录可以直接用 json.Unmarshal 解析；其余属性保存在 Attributes 中
+type RequestRecord struct {
+	SchemaVersion string `json:"schema_version" semconv:"schema_version"`
+	Level         string `json:"level" semconv:"level"`
+	Message       string `json:"message" semconv:"message"`
+	RequestID     string `json:"request.id,omitempty" semconv:"request.id,omitempty"`
+
+	Method string `json:"method" semconv:"http.request.method"`
+	URL    string `json:"url" semconv:"url.full"`
+	Host   string `json:"host" semconv:"server.address"`
+	Route  string `json:"route,omitempty" semconv:"http.route,omitempty"`
+	Status int    `json:"status,omitempty" semconv:"http.response.status_code,omitempty"`
+
+	// 耗时和首字节耗时，legacy 格式为毫秒，semconv 格式为秒
+	Duration        float64 `json:"duration-ms,omitempty" semconv:"http.server.request.duration,omitempty"`
+	TimeToFirstByte float64 `json:"time-to-first-byte-ms,omitempty" semconv:"http.server.time_to_first_byte,omitempty"`
+	StartTime       string  `json:"start-time,omitempty" semconv:"http.request.start_time,omitempty" format:"date-time"`
+	EndTime         string  `json:"end-time,omitempty" semconv:"http.request.end_time,omitempty" format:"date-time"`
+
+	RequestBodySize int64  `json:"body-size,omitempty" semconv:"http.request.body.size,omitempty"`
+	ResponseSize    int64  `json:"response-size,omitempty" semconv:"http.response.body.size,omitempty"`
+	ContentType     string `json:"content-type,omitempty" semconv:"http.request.header.content-type,omitempty"`
+	// 请求体，legacy 格式记录在 Message 中
+	RequestBody string `json:"-" semconv:"http.request.body.content,omitempty"`
+
+	ClientIP  string `json:"client-ip,omitempty" semconv:"client.address,omitempty"`
+	UserAgent string `json:"user-agent" semconv:"user_agent.original"`
+	AppID     string `json:"appid" semconv:"appid"`
+	Service   string `json:"service" semconv:"service.name"`
+
+	TraceID string `json:"trace-id,omitempty" semconv:"trace_id,omitempty"`
+	SpanID  string `json:"span-id,omitempty" semconv:"span_id,omitempty"`
+
+	// 不属于以上字段的属性，分组属性为嵌套的 map
+	Attributes map[string]any `json:"-" semconv:"-"`
+
+	// 记录的日志格式，决定 Duration 等字段的单位
+	format string
+}
+
+// schemaField RequestRecord 字段在某种日志格式下的属性名
+type schemaField struct {
+	index    int
+	key      string
+	optional bool
+	format   string
+}
+
+// schemaFields 返回 RequestRecord 在日志格式下记录的字段
+func schemaFields(format string) []schemaField {
+
+	tagName := "json"
+	if format == LogFormatSemConv {
+		tagName = "semconv"
+	}
+
+	t := reflect.TypeOf(RequestRecord{})
+	fields := make([]schemaField, 0, t.NumField())
+	for i := range t.NumField() {
+		tag := t.Field(i).Tag.Get(tagName)
+		key, options, _ := strings.Cut(tag, ",")
+		if key == "-" || key == "" {
+			continue
+		}
+		fields = append(fields, schemaField{
+			index:    i,
+			key:      key,
+			optional: options == "omitempty",
+			format:   t.Field(i).Tag.Get("format"),
+		})
+	}
+
+	return fields
+}
+
+// NewRequestRecord 从记录中取出 RequestRecord 的字段，日志格式按记录的属性名识别
+func NewRequestRecord(record Record) RequestRecord {
+	return newRequestRecord(record.fields())
+}
+
+// newRequestRecord 从记录的字段集合中取出 RequestRecord 的字段，values 中其余的字段保存在 Attributes 中
+func newRequestRecord(values map[string]any) RequestRecord {
+
+	format := LogFormatLegacy
+	if _, ok := values["http.request.method"]; ok {
+		format = LogFormatSemConv
+	}
+
+	rr := RequestRecord{format: format}
+	v := reflect.ValueOf(&rr).Elem()
+	for _, field := range schemaFields(format) {
+		value, ok := values[field.key]
+		if !ok {
+			continue
+		}
+		if setSchemaField(v.Field(field.index), value) {
+			delete(values, field.key)
+		}
+	}
+
+	// legacy 格式的请求体记录在日志内容中，开启 body_attribute 时记录在 body 属性中
+	if format == LogFormatLegacy {
+		switch body := values["body"].(type) {
+		case nil:
+			rr.RequestBody = rr.Message
+		case string:
+			rr.RequestBody = body
+			delete(values, "body")
+		}
+	}
+
+	if len(values) > 0 {
+		rr.Attributes = values
+	}
+
+	return rr
+}
+
+// setSchemaField 将属性值写入字段，类型不匹配时保留零值并返回 false
+func setSchemaField(field reflect.Value, value any) bool {
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case field.Kind() == reflect.String && rv.Kind() == reflect.String:
+		field.SetString(rv.String())
+	case field.CanInt() && rv.CanInt():
+		field.SetInt(rv.Int())
+	case field.CanInt() && rv.CanFloat():
+		field.SetInt(int64(rv.Float()))
+	case field.CanFloat() && rv.CanFloat():
+		field.SetFloat(rv.Float())
+	case field.CanFloat() && rv.CanInt():
+		field.SetFloat(float64(rv.Int()))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// RecordJSONSchema 返回日志格式（legacy 或 semconv）下请求记录的 JSON Schema（draft 2020-12），
+// 供下游校验写入的记录；没有列出的属性不做限制
+func RecordJSONSchema(format string) ([]byte, error) {
+
+	switch format {
+	case "":
+		format = LogFormatLegacy
+	case LogFormatLegacy, LogFormatSemConv:
+	default:
+		return nil, fmt.Errorf("invalid log_format %q", format)
+	}
+
+	t := reflect.TypeOf(RequestRecord{})
+	properties := make(map[string]any)
+	required := []string{}
+
+	for _, field := range schemaFields(format) {
+		property := map[string]any{"type": schemaType(t.Field(field.index).Type.Kind())}
+		if field.format != "" {
+			property["format"] = field.format
+		}
+		if field.key == schemaVersionKey {
+			property["const"] = RecordSchemaVersion
+		}
+		properties[field.key] = property
+
+		if !field.optional {
+			required = append(required, field.key)
+		}
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"$id":                  fmt.Sprintf("https://github.com/sanyuanya/recordrequestlog/schema/v%s/%s.json", RecordSchemaVersion, format),
+		"title":                "recordrequestlog request record (" + format + ")",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": true,
+	}, "", "  ")
+}
+
+// schemaType 返回字段类型对应的 JSON 类型
+func schemaType(kind reflect.Kind) string {
+
+	switch kind {
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// adminSchema 返回当前日志格式下请求记录的 JSON Schema
+func (e *RecordRequestLog) adminSchema(rw http.ResponseWriter, req *http.Request) {
+
+	schema, err := RecordJSONSchema(e.logFormat)
+	if err != nil {
+		writeAdminReply(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/schema+json")
+	rw.Write(schema)
+}
@@ -0,0 +1,102 @@
+package recordrequestlog_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+func TestRequestRecordSchema(t *testing.T) {
+
+	for _, format := range []string{recordrequestlog.LogFormatLegacy, recordrequestlog.LogFormatSemConv} {
+		t.Run(format, func(t *testing.T) {
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.LogFormat = format
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				recordrequestlog.SetRoute(req.Context(), "/orders/{id}")
+				rw.WriteHeader(http.StatusCreated)
+				rw.Write([]byte("created"))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/orders/1?page=2", strings.NewReader(`{"id":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			record := rec.RequireRecords(t, 1)[0]
+			rr := recordrequestlog.NewRequestRecord(record)
+
+			if rr.SchemaVersion != recordrequestlog.RecordSchemaVersion || rr.Level != "info" {
+				t.Fatalf("unexpected schema version or level %+v", rr)
+			}
+			if rr.Method != http.MethodPost || rr.Route != "/orders/{id}" || rr.Status != http.StatusCreated {
+				t.Fatalf("unexpected request fields %+v", rr)
+			}
+			if rr.RequestBody != `{"id":1}` || rr.RequestBodySize != 8 || rr.ResponseSize != int64(len("created")) {
+				t.Fatalf("unexpected body fields %+v", rr)
+			}
+			if rr.Duration <= 0 || rr.StartTime == "" || rr.EndTime == "" || rr.RequestID == "" {
+				t.Fatalf("unexpected timing fields %+v", rr)
+			}
+			if len(rr.TraceID) != 32 || len(rr.SpanID) != 16 {
+				t.Fatalf("unexpected trace fields %+v", rr)
+			}
+			if _, ok := rr.Attributes[map[string]string{
+				recordrequestlog.LogFormatLegacy:  "query",
+				recordrequestlog.LogFormatSemConv: "url.query.params",
+			}[format]]; !ok {
+				t.Fatalf("expected query in attributes, got %v", rr.Attributes)
+			}
+
+			// 记录包含 JSON Schema 中的必填字段，类型与 schema 一致
+			var schema struct {
+				Properties map[string]struct {
+					Type string `json:"type"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			}
+			b, err := recordrequestlog.RecordJSONSchema(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, &schema); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, key := range schema.Required {
+				if key == "level" || key == "message" {
+					continue
+				}
+				if _, ok := recordrequestlogtest.Attr(record, key); !ok {
+					t.Errorf("missing required field %s", key)
+				}
+			}
+
+			kinds := map[string]slog.Kind{"string": slog.KindString, "integer": slog.KindInt64, "number": slog.KindFloat64}
+			for key, property := range schema.Properties {
+				if value, ok := recordrequestlogtest.Attr(record, key); ok && value.Kind() != kinds[property.Type] {
+					t.Errorf("field %s is %v, schema type %s", key, value.Kind(), property.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordJSONSchemaFormat(t *testing.T) {
+
+	if _, err := recordrequestlog.RecordJSONSchema("xml"); err == nil {
+		t.Fatal("expected error for unknown log format")
+	}
+}
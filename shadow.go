@@ -0,0 +1,173 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 默认同时发送的影子请求个数和单个影子请求的超时
+const (
+	defaultShadowConcurrency = 8
+	defaultShadowTimeout     = 5 * time.Second
+)
+
+// ShadowHeader 影子请求携带的请求头，带有该请求头的请求不再镜像，避免影子环境使用同一配置时循环发送
+const ShadowHeader = "X-Shadow-Request"
+
+// 影子请求的结果，记录为 recordrequestlog.shadow.requests 的 result 属性
+const (
+	shadowSent    = "sent"
+	shadowFailed  = "failed"
+	shadowDropped = "dropped"
+	shadowSkipped = "skipped"
+)
+
+// shadowMirror 将请求的副本异步发送到影子地址，不等待响应，同时发送的请求超过上限时丢弃副本
+type shadowMirror struct {
+	endpoint *url.URL
+	rate     float64
+	client   *http.Client
+	slots    chan struct{}
+}
+
+// newShadowMirror 没有配置 shadow_endpoint 时返回 nil
+func newShadowMirror(config *Config) (*shadowMirror, error) {
+
+	if config.ShadowEndpoint == "" {
+		return nil, nil
+	}
+
+	endpoint, err := url.Parse(config.ShadowEndpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid shadow_endpoint %q: must be an http or https URL", config.ShadowEndpoint)
+	}
+
+	if config.ShadowSampleRate < 0 || config.ShadowSampleRate > 1 {
+		return nil, fmt.Errorf("invalid shadow_sample_rate %v: must be between 0 and 1", config.ShadowSampleRate)
+	}
+
+	timeout, err := parseDuration("shadow_timeout", config.ShadowTimeout, defaultShadowTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := config.ShadowConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultShadowConcurrency
+	}
+
+	return &shadowMirror{
+		endpoint: endpoint,
+		rate:     config.ShadowSampleRate,
+		client:   &http.Client{Timeout: timeout},
+		slots:    make(chan struct{}, concurrency),
+	}, nil
+}
+
+// shadowBody 返回影子请求的请求体，请求体没有完整记录（未读取、被截断、只记录摘要或元数据）时返回 false
+func shadowBody(req *http.Request, query *queryRedactor, body *capturedBody) ([]byte, bool) {
+
+	if body == nil {
+		return nil, req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0
+	}
+
+	if body.truncated || body.digest != nil || body.multipart != nil || body.metadataOnly || body.xmlFields != nil {
+		return nil, false
+	}
+
+	// 解压或加密失败时不记录内容
+	if body.content == "" && req.ContentLength > 0 {
+		return nil, false
+	}
+
+	if body.encoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(body.content)
+		return b, err == nil
+	}
+
+	return []byte(formContent(query, body)), true
+}
+
+// mirror 按 shadow_sample_rate 采样被记录的请求，将脱敏后的副本发送到 shadow_endpoint：请求体与日志中记录的内容相同，
+// 查询参数按 redact_query_params 脱敏，认证、Cookie 和名称匹配 redact_query_params 的请求头不发送
+func (x *Exchange) mirror() {
+
+	e, s := x.e, x.e.shadow
+	if s == nil || !x.sampled || x.req.Header.Get(ShadowHeader) != "" {
+		return
+	}
+
+	if s.rate < 1 && rand.Float64() >= s.rate {
+		return
+	}
+
+	ctx := context.WithoutCancel(x.req.Context())
+	query := e.rules.Load().query
+
+	body, ok := shadowBody(x.req, query, x.body)
+	if !ok {
+		e.shadowRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", shadowSkipped)))
+		return
+	}
+
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + x.req.URL.Path
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + x.req.URL.EscapedPath()
+	u.RawQuery = query.url(x.req.URL).RawQuery
+
+	req, err := http.NewRequestWithContext(ctx, x.req.Method, u.String(), nil)
+	if err != nil {
+		e.shadowRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", shadowFailed)))
+		return
+	}
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	for _, name := range e.replayHeaderNames(x.req.Header) {
+		if !sensitiveHeader(query, name) {
+			req.Header[name] = append([]string(nil), x.req.Header.Values(name)...)
+		}
+	}
+	for _, name := range []string{"Content-Type", "User-Agent"} {
+		if value := x.req.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	// 记录的请求体已经解压
+	req.Header.Del("Content-Encoding")
+	req.Header.Set(ShadowHeader, "1")
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		e.shadowRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", shadowDropped)))
+		return
+	}
+
+	go func() {
+		defer func() { <-s.slots }()
+
+		result := shadowSent
+		resp, err := s.client.Do(req)
+		if err != nil {
+			result = shadowFailed
+		} else {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		e.shadowRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	}()
+}
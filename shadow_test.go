@@ -0,0 +1,114 @@
+package recordrequestlog_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type mirroredRequest struct {
+	method, uri, body string
+	header            http.Header
+}
+
+func TestShadowMirror(t *testing.T) {
+
+	mirrored := make(chan mirroredRequest, 4)
+	shadow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mirrored <- mirroredRequest{req.Method, req.RequestURI, string(body), req.Header}
+	}))
+	defer shadow.Close()
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.ShadowEndpoint = shadow.URL + "/mirror/"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var forwarded string
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		forwarded = string(b)
+	}))
+
+	receive := func() mirroredRequest {
+		t.Helper()
+		select {
+		case m := <-mirrored:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a mirrored request")
+			return mirroredRequest{}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://shop.example.com/api/orders?token=abc&page=1", strings.NewReader(`{"sku":"A-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Tenant", "acme")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if forwarded != `{"sku":"A-1"}` {
+		t.Fatalf("expected the original body to be forwarded, got %q", forwarded)
+	}
+
+	m := receive()
+	if m.method != http.MethodPost || m.uri != "/mirror/api/orders?token=REDACTED&page=1" || m.body != `{"sku":"A-1"}` {
+		t.Fatalf("unexpected mirrored request %+v", m)
+	}
+	if m.header.Get("Authorization") != "" || m.header.Get("X-Tenant") != "acme" || m.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected mirrored headers %v", m.header)
+	}
+	if m.header.Get(recordrequestlog.ShadowHeader) == "" || m.header.Get("Traceparent") != "" {
+		t.Errorf("expected the shadow header without trace context, got %v", m.header)
+	}
+
+	// 表单按日志的脱敏规则处理
+	req = httptest.NewRequest(http.MethodPost, "http://shop.example.com/login", strings.NewReader("user=ada&password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if m := receive(); m.body != "user=ada&password=REDACTED" {
+		t.Errorf("expected a redacted form body, got %q", m.body)
+	}
+
+	// 影子请求不再镜像
+	req = httptest.NewRequest(http.MethodGet, "http://shop.example.com/api/orders", nil)
+	req.Header.Set(recordrequestlog.ShadowHeader, "1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case m := <-mirrored:
+		t.Fatalf("expected no mirror for a shadow request, got %+v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var sent int64
+		for _, point := range rec.RequireMetric(t, "recordrequestlog.shadow.requests").Data.(metricdata.Sum[int64]).DataPoints {
+			if v, _ := point.Attributes.Value("result"); v.AsString() == "sent" {
+				sent = point.Value
+			}
+		}
+		if sent == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 sent shadow requests, got %d", sent)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,137 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// shutdownState 中间件的关闭状态和记录计数，NewMiddleware 包装出的副本共用同一个
+type shutdownState struct {
+	once     sync.Once
+	err      error
+	dropped  atomic.Int64
+	exported atomic.Int64
+}
+
+// dropCounter 在导出丢弃指标的同时累计丢弃总数，供 Dropped 返回
+type dropCounter struct {
+	metric.Int64Counter
+	total *atomic.Int64
+}
+
+func (c dropCounter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	c.total.Add(incr)
+	c.Int64Counter.Add(ctx, incr, options...)
+}
+
+// instances 尚未关闭的中间件，供包级别的 Flush 使用
+var instances = struct {
+	sync.Mutex
+	set map[*shutdownState]*RecordRequestLog
+}{set: make(map[*shutdownState]*RecordRequestLog)}
+
+func register(e *RecordRequestLog) {
+	instances.Lock()
+	instances.set[e.state] = e
+	instances.Unlock()
+}
+
+func unregister(e *RecordRequestLog) {
+	instances.Lock()
+	delete(instances.set, e.state)
+	instances.Unlock()
+}
+
+// Flush 立即导出所有尚未关闭的中间件缓冲的日志、trace 和指标
+func Flush(ctx context.Context) error {
+
+	instances.Lock()
+	handlers := make([]*RecordRequestLog, 0, len(instances.set))
+	for _, e := range instances.set {
+		handlers = append(handlers, e)
+	}
+	instances.Unlock()
+
+	// 并发刷新，整体耗时不超过单个中间件的截止时间
+	errs := make([]error, len(handlers))
+	var wg sync.WaitGroup
+	for i, e := range handlers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = e.Flush(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Flush 立即导出缓冲的日志、trace 和指标，ctx 没有截止时间时最多等待 shutdown_timeout
+func (e *RecordRequestLog) Flush(ctx context.Context) error {
+
+	ctx, cancel := e.withShutdownTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if f, ok := e.sink.(sinkFlusher); ok {
+		err = f.ForceFlush(ctx)
+	}
+
+	if e.flush != nil {
+		err = errors.Join(err, e.flush(ctx))
+	}
+
+	return err
+}
+
+// Shutdown 导出缓冲的记录后关闭导出器，ctx 没有截止时间时最多等待 shutdown_timeout；
+// 截止时间前没有导出的记录计入 Dropped。重复调用返回第一次的结果
+func (e *RecordRequestLog) Shutdown(ctx context.Context) error {
+
+	e.state.once.Do(func() {
+		unregister(e)
+
+		ctx, cancel := e.withShutdownTimeout(ctx)
+		defer cancel()
+
+		// 写入去重窗口中尚未写入的重复记录
+		if e.dedup != nil {
+			e.dedup.flush()
+		}
+
+		// 先关闭日志后端，丢弃指标随 MeterProvider 关闭时导出
+		err := e.sink.Shutdown(ctx)
+		e.state.err = errors.Join(err, e.shutdown(ctx))
+
+		if n := e.Dropped(); n > 0 {
+			e.logError("shutdown", fmt.Errorf("%d records dropped", n))
+		}
+	})
+
+	return e.state.err
+}
+
+// Close 使用 shutdown_timeout 作为截止时间调用 Shutdown
+func (e *RecordRequestLog) Close() error {
+	return e.Shutdown(context.Background())
+}
+
+// Dropped 返回创建以来丢弃的记录数，包括队列已满、熔断、导出失败和关闭时未导出的记录
+func (e *RecordRequestLog) Dropped() int64 {
+	return e.state.dropped.Load()
+}
+
+func (e *RecordRequestLog) withShutdownTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, e.shutdownTimeout)
+}
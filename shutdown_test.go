@@ -0,0 +1,83 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+	"time"
+)
+
+func newAsyncFileHandler(t *testing.T) (*recordrequestlog.RecordRequestLog, string) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.Async = true
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return handler.(*recordrequestlog.RecordRequestLog), path
+}
+
+func TestFlush(t *testing.T) {
+
+	handler, path := newAsyncFileHandler(t)
+	defer handler.Close()
+
+	const requests = 20
+	for i := 0; i < requests; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+	}
+
+	// 测试环境没有 collector，trace 和指标导出会一直重试到截止时间，不影响日志记录的刷新
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	recordrequestlog.Flush(ctx)
+
+	if records := readRecords(t, path); len(records) != requests {
+		t.Errorf("expected %d records after flush, got %d", requests, len(records))
+	}
+}
+
+func TestShutdown(t *testing.T) {
+
+	handler, path := newAsyncFileHandler(t)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+
+	err := handler.Shutdown(context.Background())
+
+	if records := readRecords(t, path); len(records) != 1 {
+		t.Errorf("expected 1 record after shutdown, got %d", len(records))
+	}
+
+	if n := handler.Dropped(); n != 0 {
+		t.Errorf("expected no dropped records, got %d", n)
+	}
+
+	// 关闭后的请求仍然转发，但记录计入丢弃数
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected request to be served after shutdown, got %d", rec.Code)
+	}
+
+	if n := handler.Dropped(); n != 1 {
+		t.Errorf("expected 1 dropped record, got %d", n)
+	}
+
+	if again := handler.Shutdown(context.Background()); again != err {
+		t.Errorf("expected repeated shutdown to return %v, got %v", err, again)
+	}
+}
@@ -0,0 +1,151 @@
+package recordrequestlog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// 日志导出后端
+const (
+	BackendOTLPGRPC = "otlp-grpc"
+	BackendOTLPHTTP = "otlp-http"
+	// BackendOpenObserve 直接调用 OpenObserve 的 _json 接口批量写入，不经过 OTLP
+	BackendOpenObserve = "openobserve"
+	BackendStdout      = "stdout"
+	BackendStderr      = "stderr"
+	BackendFile        = "file"
+	// BackendKafka 将记录序列化为 JSON 写入 Kafka topic
+	BackendKafka = "kafka"
+	// BackendLoki 通过 Loki 的 push 接口批量写入
+	BackendLoki = "loki"
+	// BackendElasticsearch 通过 _bulk 接口写入 Elasticsearch 或 OpenSearch
+	BackendElasticsearch = "elasticsearch"
+	// BackendSyslog 按 RFC 5424 格式发送到 syslog 服务
+	BackendSyslog = "syslog"
+)
+
+// 导出数据的压缩方式
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// LogSink 请求日志记录的导出后端
+type LogSink interface {
+	// Emit 导出一条记录，实现需要支持并发调用
+	Emit(ctx context.Context, record Record) error
+	// Shutdown 刷新缓冲的记录并释放资源
+	Shutdown(ctx context.Context) error
+}
+
+// sinkFlusher 由缓冲记录的 LogSink 实现，立即导出已缓冲的记录
+type sinkFlusher interface {
+	ForceFlush(ctx context.Context) error
+}
+
+// sinkQueue 由带异步队列的 LogSink 实现，返回尚未导出完成的记录数
+type sinkQueue interface {
+	queued() int64
+}
+
+// newSink 根据配置的后端创建 LogSink
+func (e *RecordRequestLog) newSink(config *Config) (LogSink, error) {
+
+	if e.exporters != nil && e.exporters.Sink != nil {
+		return e.exporters.Sink, nil
+	}
+
+	if config.LoggerProvider != nil {
+		return e.newProviderSink(config.LoggerProvider), nil
+	}
+
+	switch config.Backend {
+	case "", BackendOTLPGRPC:
+		return e.newOTLPSink(e.newOTLPGRPCExporter), nil
+	case BackendOTLPHTTP:
+		return e.newOTLPSink(e.newOTLPHTTPExporter), nil
+	case BackendOpenObserve:
+		return e.newOpenObserveSink(), nil
+	case BackendStdout:
+		return newConsoleSink(os.Stdout, config), nil
+	case BackendStderr:
+		return newConsoleSink(os.Stderr, config), nil
+	case BackendFile:
+		return e.newFileSink(config)
+	case BackendKafka:
+		return e.newKafkaSink(config)
+	case BackendLoki:
+		return e.newLokiSink(config), nil
+	case BackendElasticsearch:
+		return e.newElasticsearchSink(config), nil
+	case BackendSyslog:
+		return e.newSyslogSink(config)
+	default:
+		return nil, fmt.Errorf("invalid backend %q", config.Backend)
+	}
+}
+
+// fallbackSink 后端拒绝记录时写入本地文件
+type fallbackSink struct {
+	LogSink
+	fallback *writerSink
+}
+
+func (s *fallbackSink) Emit(ctx context.Context, record Record) error {
+
+	err := s.LogSink.Emit(ctx, record)
+	if err == nil {
+		return nil
+	}
+
+	if ferr := s.fallback.Emit(ctx, record); ferr != nil {
+		return errors.Join(err, ferr)
+	}
+
+	return nil
+}
+
+func (s *fallbackSink) ForceFlush(ctx context.Context) error {
+
+	if f, ok := s.LogSink.(sinkFlusher); ok {
+		return f.ForceFlush(ctx)
+	}
+
+	return nil
+}
+
+func (s *fallbackSink) Shutdown(ctx context.Context) error {
+	return errors.Join(s.LogSink.Shutdown(ctx), s.fallback.Shutdown(ctx))
+}
+
+// noopSink 丢弃所有记录，用于遥测初始化失败时继续处理请求
+type noopSink struct{}
+
+func (noopSink) Emit(context.Context, Record) error { return nil }
+
+func (noopSink) Shutdown(context.Context) error { return nil }
+
+// newTLSConfig 创建连接导出端使用的 TLS 配置，caFile 为空时使用系统根证书，name 为错误信息中使用的字段名
+func newTLSConfig(name, caFile string, insecureSkipVerify bool) (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s %s contains no certificates", name, caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,224 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 异步队列满时的丢弃策略
+const (
+	DropNewest = "drop-newest"
+	DropOldest = "drop-oldest"
+)
+
+// 默认的异步队列长度和导出 goroutine 数量
+const (
+	defaultAsyncQueueSize = 1024
+	defaultAsyncWorkers   = 1
+)
+
+// asyncFlushPollInterval ForceFlush 检查队列是否清空的间隔
+const asyncFlushPollInterval = 10 * time.Millisecond
+
+// asyncSink 将记录放入有界队列后立即返回，由固定数量的 goroutine 调用下层 LogSink 导出，
+// 避免导出端的背压阻塞请求处理
+type asyncSink struct {
+	sink       LogSink
+	queue      chan asyncItem
+	dropOldest bool
+	dropped    metric.Int64Counter
+	onError    func(msg string, err error)
+	// attrs 多路导出时标识所属后端，附加到队列深度和丢弃数上
+	attrs []attribute.KeyValue
+	// depth 队列深度的回调，Shutdown 时注销
+	depth metric.Registration
+	// pending 已入队但尚未导出完成的记录数
+	pending atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type asyncItem struct {
+	ctx    context.Context
+	record Record
+}
+
+// newAsyncSink 创建异步队列，name 非空时队列深度和丢弃数带上 sink 属性
+func (e *RecordRequestLog) newAsyncSink(sink LogSink, name string, queueSize, workers int, dropPolicy string) *asyncSink {
+
+	if queueSize <= 0 {
+		queueSize = defaultAsyncQueueSize
+	}
+
+	if workers <= 0 {
+		workers = defaultAsyncWorkers
+	}
+
+	s := &asyncSink{
+		sink:       sink,
+		queue:      make(chan asyncItem, queueSize),
+		dropOldest: dropPolicy == DropOldest,
+		dropped:    e.droppedRecords,
+		onError:    e.logError,
+	}
+
+	if name != "" {
+		s.attrs = []attribute.KeyValue{attribute.String("sink", name)}
+	}
+
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.run()
+	}
+
+	// 每次采集指标时读取队列深度。同名的 gauge 只保留第一次创建时的回调，多路导出的各队列分别注册
+	meter := e.meterProvider.Meter(instrumentationName)
+	depth, err := meter.Int64ObservableGauge("recordrequestlog.queue.depth",
+		metric.WithDescription("Number of request records waiting in the async queue."),
+		metric.WithUnit("{record}"))
+	if err == nil {
+		s.depth, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			o.ObserveInt64(depth, s.pending.Load(), metric.WithAttributes(s.attrs...))
+			return nil
+		}, depth)
+	}
+	if err != nil {
+		e.logError("create instruments", err)
+	}
+
+	return s
+}
+
+func (s *asyncSink) Emit(ctx context.Context, record Record) error {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return errors.New("log sink is shut down")
+	}
+
+	// 请求结束后 context 会被取消，导出时只保留其中的 trace 等信息
+	item := asyncItem{ctx: context.WithoutCancel(ctx), record: record}
+
+	s.pending.Add(1)
+
+	select {
+	case s.queue <- item:
+		return nil
+	default:
+	}
+
+	if s.dropOldest {
+		select {
+		case <-s.queue:
+			s.pending.Add(-1)
+			s.drop(ctx, DropOldest)
+		default:
+		}
+
+		select {
+		case s.queue <- item:
+			return nil
+		default:
+		}
+	}
+
+	s.pending.Add(-1)
+
+	s.drop(ctx, DropNewest)
+	return nil
+}
+
+func (s *asyncSink) drop(ctx context.Context, policy string) {
+	s.dropped.Add(ctx, 1, s.dropAttrs(attribute.String("reason", "queue_full"), attribute.String("policy", policy)))
+}
+
+// dropAttrs 丢弃原因加上所属后端
+func (s *asyncSink) dropAttrs(attrs ...attribute.KeyValue) metric.AddOption {
+	return metric.WithAttributes(append(attrs, s.attrs...)...)
+}
+
+// queued 已入队但尚未导出完成的记录数
+func (s *asyncSink) queued() int64 {
+	return s.pending.Load()
+}
+
+func (s *asyncSink) Shutdown(ctx context.Context) error {
+
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+		if s.depth != nil {
+			s.depth.Unregister()
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// 截止时间前没有导出的记录计入丢弃数，队列已关闭，取完后循环结束
+		var n int64
+		for range s.queue {
+			n++
+		}
+		if n > 0 {
+			s.pending.Add(-n)
+			s.dropped.Add(context.Background(), n, s.dropAttrs(attribute.String("reason", "shutdown")))
+		}
+		// 仍然关闭下层 LogSink，释放导出端的连接、goroutine 和文件
+		return errors.Join(ctx.Err(), s.sink.Shutdown(ctx))
+	}
+
+	return s.sink.Shutdown(ctx)
+}
+
+// ForceFlush 等待队列中的记录导出完成，再刷新下层 LogSink
+func (s *asyncSink) ForceFlush(ctx context.Context) error {
+
+	ticker := time.NewTicker(asyncFlushPollInterval)
+	defer ticker.Stop()
+
+	for s.pending.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if f, ok := s.sink.(sinkFlusher); ok {
+		return f.ForceFlush(ctx)
+	}
+
+	return nil
+}
+
+func (s *asyncSink) run() {
+
+	defer s.wg.Done()
+
+	for item := range s.queue {
+		if err := s.sink.Emit(item.ctx, item.record); err != nil {
+			s.dropped.Add(item.ctx, 1, s.dropAttrs(attribute.String("reason", "emit_error")))
+			s.onError("emit record", err)
+		}
+		s.pending.Add(-1)
+	}
+}
@@ -0,0 +1,45 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsyncEmit(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.Async = true
+	cfg.AsyncWorkers = 2
+	cfg.AsyncDropPolicy = recordrequestlog.DropOldest
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 10
+	for i := 0; i < requests; i++ {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/api", strings.NewReader(`{}`))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(readRecords(t, path)) < requests {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d records to be written asynchronously", requests)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,248 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// batchSink 按 stream 缓冲记录，定时或达到批量大小时调用 push 批量写入，写入失败时按重试策略重试，
+// 仍然失败时放入本地缓冲。openobserve、loki、elasticsearch 等 HTTP 后端共用
+type batchSink struct {
+	name          string
+	push          func(ctx context.Context, stream string, records []Record) error
+	defaultStream string
+	interval      time.Duration
+	batchSize     int
+	queueSize     int
+	onError       func(msg string, err error)
+	onExport      func(ctx context.Context, err error)
+	spool         *spool
+	retry         *retryPolicy
+
+	mu      sync.Mutex
+	batches map[string][]Record
+	queued  int
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	// 重放缓冲批次的 goroutine 退出后关闭，未启用缓冲时为 nil
+	replayDone chan struct{}
+}
+
+// partialError 批次中部分记录写入失败，重试和缓冲时只处理这些记录
+type partialError struct {
+	records []Record
+	err     error
+}
+
+func (e *partialError) Error() string {
+	return fmt.Sprintf("%d records failed: %v", len(e.records), e.err)
+}
+
+func (e *partialError) Unwrap() error {
+	return e.err
+}
+
+// newBatchSink 创建并启动批量写入的 LogSink，name 为错误信息中使用的后端名称
+func (e *RecordRequestLog) newBatchSink(name string, push func(ctx context.Context, stream string, records []Record) error) *batchSink {
+
+	batchSize := e.logMaxBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLogMaxBatchSize
+	}
+
+	queueSize := e.logQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultLogQueueSize
+	}
+
+	s := &batchSink{
+		name:          name,
+		push:          push,
+		defaultStream: e.streamName,
+		interval:      e.logBatchInterval,
+		batchSize:     batchSize,
+		queueSize:     queueSize,
+		onError:       e.logError,
+		onExport:      e.exportResult("logs"),
+		spool:         e.spool,
+		retry:         e.retry,
+		batches:       make(map[string][]Record),
+		flush:         make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go s.run()
+
+	if s.spool != nil {
+		s.replayDone = make(chan struct{})
+		go func() {
+			defer close(s.replayDone)
+			s.spool.replay(s.stop, e.spoolRetryInterval, e.spoolMaxRetryInterval, s.replayBatch, s.onError)
+		}()
+	}
+
+	return s
+}
+
+func (s *batchSink) Emit(ctx context.Context, record Record) error {
+
+	stream := record.StreamName
+	if stream == "" {
+		stream = s.defaultStream
+	}
+
+	s.mu.Lock()
+	if s.queued >= s.queueSize {
+		s.mu.Unlock()
+		return fmt.Errorf("%s queue is full, record dropped", s.name)
+	}
+
+	s.batches[stream] = append(s.batches[stream], record)
+	s.queued++
+	full := len(s.batches[stream]) >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (s *batchSink) Shutdown(ctx context.Context) error {
+
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+
+	for _, done := range []chan struct{}{s.done, s.replayDone} {
+		if done == nil {
+			continue
+		}
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return s.export(ctx)
+}
+
+func (s *batchSink) ForceFlush(ctx context.Context) error {
+	return s.export(ctx)
+}
+
+func (s *batchSink) run() {
+
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.flush:
+		}
+
+		if err := s.export(context.Background()); err != nil {
+			s.onError("export to "+s.name, err)
+		}
+	}
+}
+
+// export 取出当前缓冲的全部记录并按 stream 分批写入
+func (s *batchSink) export(ctx context.Context) error {
+
+	s.mu.Lock()
+	batches := s.batches
+	s.batches = make(map[string][]Record)
+	s.queued = 0
+	s.mu.Unlock()
+
+	var err error
+	for stream, records := range batches {
+		for len(records) > 0 {
+			n := min(len(records), s.batchSize)
+			batch := records[:n]
+			perr := s.retry.do(ctx, func(ctx context.Context) error {
+				err := s.push(ctx, stream, batch)
+				if partial := (*partialError)(nil); errors.As(err, &partial) {
+					batch = partial.records
+				}
+				return err
+			}, s.retry.httpRetryable)
+			s.onExport(ctx, perr)
+			if perr != nil {
+				err = errors.Join(err, s.spoolBatch(stream, batch, perr))
+			}
+			records = records[n:]
+		}
+	}
+
+	return err
+}
+
+// spoolBatch 将写入失败的批次放入本地缓冲，未启用缓冲时返回原始错误
+func (s *batchSink) spoolBatch(stream string, records []Record, err error) error {
+
+	if s.spool == nil {
+		return err
+	}
+
+	batch := spoolBatch{Stream: stream}
+	for _, record := range records {
+		batch.Records = append(batch.Records, newSpoolRecord(context.Background(), record))
+	}
+
+	if serr := s.spool.write(batch); serr != nil {
+		return errors.Join(err, serr)
+	}
+
+	s.onError(fmt.Sprintf("export to %s failed, batch spooled for replay", s.name), err)
+	return nil
+}
+
+// replayBatch 重新写入一个缓冲的批次
+func (s *batchSink) replayBatch(ctx context.Context, batch spoolBatch) error {
+
+	records := make([]Record, 0, len(batch.Records))
+	for _, r := range batch.Records {
+		_, record := r.record(ctx, batch.Stream)
+		records = append(records, record)
+	}
+
+	return s.push(ctx, batch.Stream, records)
+}
+
+// gzipBytes 返回 gzip 压缩后的请求体
+func gzipBytes(body []byte) ([]byte, error) {
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
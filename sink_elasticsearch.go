@@ -0,0 +1,214 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 默认的索引名称，按记录时间（UTC）每天一个索引
+const defaultElasticsearchIndex = "requests-%{+yyyy.MM.dd}"
+
+// elasticsearchDate 匹配索引名称中 Logstash 形式的日期格式，例如 %{+yyyy.MM.dd}
+var elasticsearchDate = regexp.MustCompile(`%\{\+([^}]+)\}`)
+
+// elasticsearchLayout 将 Joda 日期格式转换为 Go 的时间格式
+var elasticsearchLayout = strings.NewReplacer(
+	"yyyy", "2006",
+	"yy", "06",
+	"MM", "01",
+	"dd", "02",
+	"HH", "15",
+	"mm", "04",
+	"ss", "05",
+)
+
+// elasticsearchSink 通过 _bulk 接口写入 Elasticsearch 或 OpenSearch。
+// 返回 429 的记录按重试策略重新写入，其余被拒绝的记录记录错误后丢弃
+type elasticsearchSink struct {
+	endpoint      string
+	index         string
+	authorization string
+	headers       map[string]string
+	client        *http.Client
+	gzip          bool
+	onError       func(msg string, err error)
+}
+
+func (e *RecordRequestLog) newElasticsearchSink(config *Config) *batchSink {
+
+	index := config.ElasticsearchIndex
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+
+	authorization := e.authorization
+	switch {
+	case config.ElasticsearchAPIKey != "":
+		authorization = "ApiKey " + config.ElasticsearchAPIKey
+	case config.ElasticsearchUsername != "":
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(config.ElasticsearchUsername+":"+config.ElasticsearchPassword))
+	}
+
+	s := &elasticsearchSink{
+		endpoint:      strings.TrimRight(e.endpoint, "/"),
+		index:         index,
+		authorization: authorization,
+		headers:       e.exporterHeaders,
+		client:        &http.Client{Timeout: e.logExportTimeout, Transport: e.proxy.transport()},
+		gzip:          e.compression == CompressionGzip,
+		onError:       e.logError,
+	}
+
+	sink := e.newBatchSink("elasticsearch", s.push)
+	// 集群繁忙时 _bulk 返回 429，关闭 export_retry 时仍然按默认间隔重试
+	if sink.retry == nil {
+		sink.retry = &retryPolicy{
+			initialInterval: defaultRetryInitialInterval,
+			maxInterval:     defaultRetryMaxInterval,
+			maxElapsedTime:  defaultRetryMaxElapsedTime,
+			httpCodes:       map[int]bool{http.StatusTooManyRequests: true},
+		}
+	}
+
+	return sink
+}
+
+// indexName 返回记录写入的索引，{stream} 替换为记录的 stream
+func (s *elasticsearchSink) indexName(stream string, t time.Time) string {
+
+	index := strings.ReplaceAll(s.index, streamPlaceholder, stream)
+
+	return elasticsearchDate.ReplaceAllStringFunc(index, func(m string) string {
+		format := elasticsearchDate.FindStringSubmatch(m)[1]
+		return t.UTC().Format(elasticsearchLayout.Replace(format))
+	})
+}
+
+// bulkResponse _bulk 接口的响应，只解析每条记录的结果
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func (s *elasticsearchSink) push(ctx context.Context, stream string, records []Record) error {
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+
+	for _, record := range records {
+		// 使用 create 操作，索引名称指向数据流时同样可以写入
+		action := map[string]any{"create": map[string]string{"_index": s.indexName(stream, record.Time)}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+
+		fields := record.fields()
+		fields["@timestamp"] = record.Time.Format(time.RFC3339Nano)
+		if err := encoder.Encode(fields); err != nil {
+			return err
+		}
+	}
+
+	payload := body.Bytes()
+	if s.gzip {
+		var err error
+		if payload, err = gzipBytes(payload); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/_bulk", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			msg:        fmt.Sprintf("elasticsearch responded %s: %s", resp.Status, bytes.TrimSpace(msg)),
+		}
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode elasticsearch bulk response: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	if !result.Errors {
+		return nil
+	}
+
+	var (
+		throttled []Record
+		rejected  int
+		reason    error
+	)
+	for i, item := range result.Items {
+		if i >= len(records) {
+			break
+		}
+		for _, r := range item {
+			switch {
+			case r.Status == http.StatusTooManyRequests:
+				throttled = append(throttled, records[i])
+			case r.Status >= 300:
+				if rejected == 0 {
+					reason = fmt.Errorf("%d %s: %s", r.Status, r.Error.Type, r.Error.Reason)
+				}
+				rejected++
+			}
+		}
+	}
+
+	// 映射冲突等错误重试也不会成功，只记录错误
+	if rejected > 0 {
+		s.onError(fmt.Sprintf("elasticsearch rejected %d records", rejected), reason)
+	}
+
+	if len(throttled) > 0 {
+		return &partialError{
+			records: throttled,
+			err: &statusError{
+				code: http.StatusTooManyRequests,
+				msg:  fmt.Sprintf("elasticsearch rejected %d records with 429", len(throttled)),
+			},
+		}
+	}
+
+	return nil
+}
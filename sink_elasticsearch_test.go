@@ -0,0 +1,101 @@
+package recordrequestlog_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchBackend(t *testing.T) {
+
+	type bulk struct {
+		authorization string
+		indexes       []string
+		urls          []string
+	}
+
+	received := make(chan bulk, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// 同一地址也会收到 trace 和指标的 OTLP 请求
+		if req.URL.Path != "/_bulk" {
+			return
+		}
+
+		got := bulk{authorization: req.Header.Get("Authorization")}
+		scanner := bufio.NewScanner(req.Body)
+		for i := 0; scanner.Scan(); i++ {
+			var line map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Error(err)
+			}
+			if i%2 == 0 {
+				got.indexes = append(got.indexes, line["create"].(map[string]any)["_index"].(string))
+				continue
+			}
+			if _, ok := line["@timestamp"]; !ok {
+				t.Error("expected @timestamp field")
+			}
+			got.urls = append(got.urls, line["url"].(string))
+		}
+
+		// 第一次写入时拒绝第二条记录，重试时只重新写入这一条
+		items := []string{`{"create":{"status":201}}`}
+		if len(received) == 0 && len(got.urls) == 2 {
+			items = append(items, `{"create":{"status":429,"error":{"type":"es_rejected_execution_exception"}}}`)
+		}
+		received <- got
+
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(rw, `{"errors":%t,"items":[%s]}`, len(items) > 1, strings.Join(items, ","))
+	}))
+	defer server.Close()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendElasticsearch
+	cfg.Endpoint = server.URL
+	cfg.ElasticsearchIndex = "requests-{stream}-%{+yyyy.MM.dd}"
+	cfg.ElasticsearchAPIKey = "secret"
+	cfg.StreamName = "access"
+	cfg.LogMaxBatchSize = 2
+	cfg.ExportRetry = true
+	cfg.RetryInitialInterval = "10ms"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/first", "/second"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+path, nil))
+	}
+
+	want := "requests-access-" + time.Now().UTC().Format("2006.01.02")
+
+	for i, urls := range [][]string{{"http://localhost/first", "http://localhost/second"}, {"http://localhost/second"}} {
+		select {
+		case got := <-received:
+			if got.authorization != "ApiKey secret" {
+				t.Errorf("unexpected authorization %q", got.authorization)
+			}
+			if strings.Join(got.urls, ",") != strings.Join(urls, ",") {
+				t.Errorf("bulk request %d: expected %v, got %v", i, urls, got.urls)
+			}
+			for _, index := range got.indexes {
+				if index != want {
+					t.Errorf("expected index %s, got %s", want, index)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for bulk request %d", i)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package recordrequestlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// kafka_topic 中的 stream 占位符
+const streamPlaceholder = "{stream}"
+
+// Kafka 消息的 key
+const (
+	KafkaKeyRequestID = "request_id"
+	KafkaKeyTenant    = "tenant"
+	KafkaKeyNone      = "none"
+)
+
+// Kafka SASL 认证机制
+const (
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLSCRAMSHA256 = "SCRAM-SHA-256"
+	KafkaSASLSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// kafkaSink 将记录序列化为 JSON 异步批量写入 Kafka，同一 key 的消息写入同一分区
+type kafkaSink struct {
+	writer        *kafka.Writer
+	topic         string
+	defaultStream string
+	key           string
+}
+
+func (e *RecordRequestLog) newKafkaSink(config *Config) (*kafkaSink, error) {
+
+	transport := &kafka.Transport{}
+
+	if config.KafkaSASLMechanism != "" {
+		mechanism, err := newKafkaSASL(config.KafkaSASLMechanism, config.KafkaUsername, config.KafkaPassword)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+
+	if config.KafkaTLS {
+		tlsConfig, err := newTLSConfig("kafka_tls_ca_file", config.KafkaTLSCAFile, config.KafkaTLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLS = tlsConfig
+	}
+
+	batchSize := e.logMaxBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLogMaxBatchSize
+	}
+
+	onExport := e.exportResult("logs")
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(config.KafkaBrokers...),
+		Balancer:     &kafka.Hash{},
+		BatchSize:    batchSize,
+		BatchTimeout: e.logBatchInterval,
+		WriteTimeout: e.logExportTimeout,
+		RequiredAcks: kafka.RequireAll,
+		Async:        true,
+		Transport:    transport,
+		// Async 模式下写入结果只能通过 Completion 得到，每个批次调用一次
+		Completion: func(messages []kafka.Message, err error) {
+			onExport(context.Background(), err)
+			if err != nil {
+				e.logError(fmt.Sprintf("export %d records to kafka", len(messages)), err)
+			}
+		},
+	}
+
+	if e.compression == CompressionGzip {
+		writer.Compression = kafka.Gzip
+	}
+
+	key := config.KafkaKey
+	if key == "" {
+		key = KafkaKeyRequestID
+	}
+
+	return &kafkaSink{
+		writer:        writer,
+		topic:         config.KafkaTopic,
+		defaultStream: e.streamName,
+		key:           key,
+	}, nil
+}
+
+func newKafkaSASL(mechanism, username, password string) (sasl.Mechanism, error) {
+
+	switch strings.ToUpper(mechanism) {
+	case KafkaSASLPlain:
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case KafkaSASLSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, username, password)
+	case KafkaSASLSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("invalid kafka_sasl_mechanism %q", mechanism)
+	}
+}
+
+func (s *kafkaSink) Emit(ctx context.Context, record Record) error {
+
+	message, err := s.message(record)
+	if err != nil {
+		return err
+	}
+
+	return s.writer.WriteMessages(ctx, message)
+}
+
+// message 将记录转换为 Kafka 消息，字段与 openobserve 后端相同，另加 RFC 3339 格式的 timestamp
+func (s *kafkaSink) message(record Record) (kafka.Message, error) {
+
+	stream := record.StreamName
+	if stream == "" {
+		stream = s.defaultStream
+	}
+
+	fields := record.fields()
+	fields["timestamp"] = record.Time.Format(time.RFC3339Nano)
+
+	value, err := json.Marshal(fields)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	message := kafka.Message{
+		Topic: strings.ReplaceAll(s.topic, streamPlaceholder, stream),
+		Value: value,
+		Time:  record.Time,
+	}
+
+	switch s.key {
+	case KafkaKeyRequestID:
+		message.Key = []byte(recordAttr(record, requestIDKey))
+	case KafkaKeyTenant:
+		message.Key = []byte(record.Tenant)
+	}
+
+	// 没有 key 的消息轮流写入各分区
+	if len(message.Key) == 0 {
+		message.Key = nil
+	}
+
+	return message, nil
+}
+
+func (s *kafkaSink) Shutdown(ctx context.Context) error {
+
+	// Close 等待缓冲的消息写入完成
+	done := make(chan error, 1)
+	go func() {
+		done <- s.writer.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), errors.New("kafka writer did not close in time"))
+	}
+}
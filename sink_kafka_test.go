@@ -0,0 +1,69 @@
+package recordrequestlog
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestKafkaMessage(t *testing.T) {
+
+	record := Record{
+		Time:       time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		Level:      slog.LevelInfo,
+		Message:    "request",
+		StreamName: "errors",
+		Tenant:     "acme",
+		Attrs:      []slog.Attr{slog.String(requestIDKey, "req-1"), slog.Int("status", 500)},
+	}
+
+	tests := map[string]string{
+		KafkaKeyRequestID: "req-1",
+		KafkaKeyTenant:    "acme",
+		KafkaKeyNone:      "",
+	}
+
+	for key, want := range tests {
+		t.Run(key, func(t *testing.T) {
+			s := &kafkaSink{topic: "logs.{stream}", defaultStream: "default", key: key}
+
+			message, err := s.message(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if message.Topic != "logs.errors" {
+				t.Errorf("expected topic logs.errors, got %s", message.Topic)
+			}
+			if string(message.Key) != want {
+				t.Errorf("expected key %q, got %q", want, message.Key)
+			}
+			if want == "" && message.Key != nil {
+				t.Error("expected nil key")
+			}
+
+			var fields map[string]any
+			if err := json.Unmarshal(message.Value, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if fields["timestamp"] != "2024-05-01T08:00:00Z" || fields["status"] != float64(500) || fields["message"] != "request" {
+				t.Errorf("unexpected value %v", fields)
+			}
+		})
+	}
+}
+
+func TestKafkaMessageDefaultStream(t *testing.T) {
+
+	s := &kafkaSink{topic: "logs.{stream}", defaultStream: "default", key: KafkaKeyRequestID}
+
+	message, err := s.message(Record{Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.Topic != "logs.default" {
+		t.Errorf("expected topic logs.default, got %s", message.Topic)
+	}
+}
@@ -0,0 +1,246 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// loki_labels 中由中间件计算的标签来源，其余来源为记录中的属性名
+const (
+	LokiLabelService     = "service"
+	LokiLabelStream      = "stream"
+	LokiLabelLevel       = "level"
+	LokiLabelRoute       = "route"
+	LokiLabelStatusClass = "status_class"
+)
+
+// 默认的 Loki 标签，键为标签名，值为标签来源
+var defaultLokiLabels = map[string]string{
+	"service_name": LokiLabelService,
+	"route":        LokiLabelRoute,
+	"status_class": LokiLabelStatusClass,
+}
+
+var lokiLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateLokiLabels 检查标签名是否符合 Loki 的要求
+func validateLokiLabels(labels map[string]string) error {
+
+	var errs []error
+	for name, source := range labels {
+		if !lokiLabelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, fmt.Errorf("invalid loki_labels name %q", name))
+		}
+		if source == "" {
+			errs = append(errs, fmt.Errorf("loki_labels[%s] source is empty", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// lokiSink 通过 POST /loki/api/v1/push 写入 Loki。配置为标签的属性从日志行中移除，其余字段以 JSON 写入日志行
+type lokiSink struct {
+	endpoint      string
+	tenant        string
+	authorization string
+	headers       map[string]string
+	service       string
+	labels        map[string]string
+	// 以状态码计算 status_class、以路由作为标签时读取的属性名
+	statusKey, routeKey string
+	client              *http.Client
+	gzip                bool
+}
+
+func (e *RecordRequestLog) newLokiSink(config *Config) *batchSink {
+
+	service, _ := e.resource.Set().Value(semconv.ServiceNameKey)
+
+	s := &lokiSink{
+		endpoint:      strings.TrimRight(e.endpoint, "/"),
+		tenant:        e.organization,
+		authorization: e.authorization,
+		headers:       e.exporterHeaders,
+		service:       service.AsString(),
+		labels:        config.LokiLabels,
+		statusKey:     e.attrKey("status", "http.response.status_code"),
+		routeKey:      e.attrKey("route", "http.route"),
+		client:        &http.Client{Timeout: e.logExportTimeout, Transport: e.proxy.transport()},
+		gzip:          e.compression == CompressionGzip,
+	}
+
+	return e.newBatchSink("loki", s.push)
+}
+
+// lokiStream push 接口中标签相同的一组日志行
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// entry 返回记录的标签和日志行
+func (s *lokiSink) entry(stream string, record Record) (map[string]string, []byte, error) {
+
+	labels := make(map[string]string, len(s.labels))
+	// 作为标签的属性不再写入日志行
+	var consumed []string
+
+	for name, source := range s.labels {
+		var value string
+		switch source {
+		case LokiLabelService:
+			value = s.service
+		case LokiLabelStream:
+			value = stream
+		case LokiLabelLevel:
+			value = strings.ToLower(record.Level.String())
+		case LokiLabelStatusClass:
+			// 从本地缓冲重放的记录中数值为 float64
+			if status, ok := recordValue(record, s.statusKey); ok && status.Kind() == slog.KindInt64 {
+				value = strconv.FormatInt(status.Int64()/100, 10) + "xx"
+			} else if ok && status.Kind() == slog.KindFloat64 {
+				value = strconv.Itoa(int(status.Float64())/100) + "xx"
+			}
+		case LokiLabelRoute:
+			value = recordAttr(record, s.routeKey)
+			consumed = append(consumed, s.routeKey)
+		default:
+			if v, ok := recordValue(record, source); ok {
+				value = v.String()
+				consumed = append(consumed, source)
+			}
+		}
+
+		// Loki 不保存值为空的标签
+		if value != "" {
+			labels[name] = value
+		}
+	}
+
+	fields := record.fields()
+	for _, key := range consumed {
+		delete(fields, key)
+	}
+
+	line, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return labels, line, nil
+}
+
+func (s *lokiSink) push(ctx context.Context, stream string, records []Record) error {
+
+	// 较早版本的 Loki 要求同一组日志行按时间排序
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(a, b Record) int { return a.Time.Compare(b.Time) })
+
+	streams := make(map[string]*lokiStream)
+	var keys []string
+
+	for _, record := range records {
+		labels, line, err := s.entry(stream, record)
+		if err != nil {
+			return err
+		}
+
+		key := lokiLabelsKey(labels)
+		ls, ok := streams[key]
+		if !ok {
+			ls = &lokiStream{Stream: labels}
+			streams[key] = ls
+			keys = append(keys, key)
+		}
+		ls.Values = append(ls.Values, [2]string{strconv.FormatInt(record.Time.UnixNano(), 10), string(line)})
+	}
+
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range keys {
+		payload.Streams = append(payload.Streams, streams[key])
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if s.gzip {
+		if body, err = gzipBytes(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/loki/api/v1/push", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	if s.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.tenant)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			msg:        fmt.Sprintf("loki responded %s: %s", resp.Status, bytes.TrimSpace(msg)),
+		}
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// lokiLabelsKey 返回标签集合的唯一表示，用于将标签相同的日志行合并为一组
+func lokiLabelsKey(labels map[string]string) string {
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+
+	return b.String()
+}
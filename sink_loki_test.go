@@ -0,0 +1,97 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLokiBackend(t *testing.T) {
+
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	type push struct {
+		tenant  string
+		streams []stream
+	}
+
+	received := make(chan push, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// 同一地址也会收到 trace 和指标的 OTLP 请求
+		if req.URL.Path != "/loki/api/v1/push" {
+			return
+		}
+
+		var payload struct {
+			Streams []stream `json:"streams"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		received <- push{req.Header.Get("X-Scope-OrgID"), payload.Streams}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendLoki
+	cfg.Endpoint = server.URL
+	cfg.Organization = "acme"
+	cfg.ServiceName = "orders"
+	cfg.LogMaxBatchSize = 1
+	cfg.LokiLabels["method"] = "method"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		recordrequestlog.SetRoute(req.Context(), "/api/orders/{id}")
+		rw.WriteHeader(http.StatusNotFound)
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders/1", strings.NewReader(`{"id":1}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case got := <-received:
+		if got.tenant != "acme" {
+			t.Errorf("expected X-Scope-OrgID acme, got %q", got.tenant)
+		}
+		if len(got.streams) != 1 || len(got.streams[0].Values) != 1 {
+			t.Fatalf("unexpected streams %v", got.streams)
+		}
+
+		labels := got.streams[0].Stream
+		want := map[string]string{"service_name": "orders", "route": "/api/orders/{id}", "status_class": "4xx", "method": "POST"}
+		for name, value := range want {
+			if labels[name] != value {
+				t.Errorf("expected label %s=%q, got %q", name, value, labels[name])
+			}
+		}
+
+		var line map[string]any
+		if err := json.Unmarshal([]byte(got.streams[0].Values[0][1]), &line); err != nil {
+			t.Fatal(err)
+		}
+		if line["message"] != `{"id":1}` || line["status"] != float64(http.StatusNotFound) {
+			t.Errorf("unexpected line %v", line)
+		}
+		for _, key := range []string{"route", "method"} {
+			if _, ok := line[key]; ok {
+				t.Errorf("expected label attribute %s to be removed from the line", key)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for push request")
+	}
+}
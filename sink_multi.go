@@ -0,0 +1,243 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// SinkConfig 与顶层后端同时使用的其他后端。连接参数为空时使用顶层配置，
+// 各后端的其他参数（例如 kafka_*、loki_labels）与顶层共用
+type SinkConfig struct {
+	// 错误信息中使用的名称，为空时为 sinks[i]
+	Name            string            `yaml:"name,omitempty"`
+	Backend         string            `yaml:"backend"`
+	Endpoint        string            `yaml:"endpoint,omitempty"`
+	Authorization   string            `yaml:"authorization,omitempty"`
+	Organization    string            `yaml:"organization,omitempty"`
+	StreamName      string            `yaml:"stream_name,omitempty"`
+	ExporterHeaders map[string]string `yaml:"exporter_headers,omitempty"`
+	FilePath        string            `yaml:"file_path,omitempty"`
+
+	// 只导出不低于 min_level 的记录，sample_rate 为导出的比例，streams 不为空时只导出这些 stream 的记录
+	MinLevel   string   `yaml:"min_level,omitempty"`
+	SampleRate *float64 `yaml:"sample_rate,omitempty"`
+	Streams    []string `yaml:"streams,omitempty"`
+}
+
+// sinkConfig 返回使用该后端连接参数的配置副本
+func (c SinkConfig) sinkConfig(config *Config) *Config {
+
+	merged := *config
+	merged.Backend = c.Backend
+	merged.Sinks = nil
+
+	if c.Endpoint != "" {
+		merged.Endpoint = c.Endpoint
+	}
+	if c.Authorization != "" {
+		merged.Authorization = c.Authorization
+	}
+	if c.Organization != "" {
+		merged.Organization = c.Organization
+	}
+	if c.StreamName != "" {
+		merged.StreamName = c.StreamName
+	}
+	if c.ExporterHeaders != nil {
+		merged.ExporterHeaders = c.ExporterHeaders
+	}
+	if c.FilePath != "" {
+		merged.FilePath = c.FilePath
+	}
+
+	return &merged
+}
+
+// sinkTarget 多路导出中的一个后端及其过滤条件
+type sinkTarget struct {
+	name       string
+	sink       LogSink
+	minLevel   slog.Level
+	sampleRate float64
+	streams    map[string]bool
+}
+
+// accept 判断记录是否导出到该后端
+func (t *sinkTarget) accept(record Record, defaultStream string) bool {
+
+	if record.Level < t.minLevel {
+		return false
+	}
+
+	if t.streams != nil {
+		stream := record.StreamName
+		if stream == "" {
+			stream = defaultStream
+		}
+		if !t.streams[stream] {
+			return false
+		}
+	}
+
+	return t.sampleRate >= 1 || rand.Float64() < t.sampleRate
+}
+
+// multiSink 将记录分发到多个后端。每个后端使用独立的异步队列，一个后端阻塞或失败不影响其他后端
+type multiSink struct {
+	targets       []*sinkTarget
+	defaultStream string
+}
+
+// newMultiSink 创建顶层后端和 sinks 中各后端组成的多路导出
+func (e *RecordRequestLog) newMultiSink(primary LogSink, config *Config) (*multiSink, error) {
+
+	s := &multiSink{defaultStream: e.streamName}
+
+	queue := func(sink LogSink, name string) LogSink {
+		return e.newAsyncSink(sink, name, config.AsyncQueueSize, config.AsyncWorkers, config.AsyncDropPolicy)
+	}
+
+	s.targets = append(s.targets, &sinkTarget{name: "backend", sink: queue(primary, "backend"), minLevel: slog.LevelDebug, sampleRate: 1})
+
+	for i, c := range config.Sinks {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("sinks[%d]", i)
+		}
+
+		target := &sinkTarget{name: name, minLevel: slog.LevelDebug, sampleRate: 1}
+		if c.MinLevel != "" {
+			level, err := parseLevel(fmt.Sprintf("sinks[%d].min_level", i), c.MinLevel)
+			if err != nil {
+				return nil, errors.Join(err, s.Shutdown(context.Background()))
+			}
+			target.minLevel = level
+		}
+		if c.SampleRate != nil {
+			target.sampleRate = *c.SampleRate
+		}
+		if len(c.Streams) > 0 {
+			target.streams = make(map[string]bool, len(c.Streams))
+			for _, stream := range c.Streams {
+				target.streams[stream] = true
+			}
+		}
+
+		// 各后端的构造函数读取 RecordRequestLog 中的连接参数，使用覆盖后的副本创建
+		merged := c.sinkConfig(config)
+		sub := *e
+		sub.exporters = nil
+		sub.setEndpoints(merged.Endpoint)
+		sub.authorization = merged.Authorization
+		sub.organization = merged.Organization
+		sub.exporterHeaders = merged.ExporterHeaders
+		if c.StreamName != "" {
+			sub.streamName = c.StreamName
+			if e.tenant != nil {
+				sub.streamName = strings.ReplaceAll(sub.streamName, tenantPlaceholder, e.tenant.fallback)
+			}
+		}
+
+		// 各后端使用缓冲目录下以名称命名的子目录，只重放自己写入的批次
+		var (
+			sink LogSink
+			err  error
+		)
+		if e.spool != nil {
+			sub.spool, err = e.spool.sub(name)
+		}
+		if err == nil {
+			sink, err = sub.newSink(merged)
+		}
+		if err != nil {
+			if !e.failOpen {
+				return nil, errors.Join(fmt.Errorf("setup %s: %w", name, err), s.Shutdown(context.Background()))
+			}
+
+			e.logError("setup "+name, err)
+			continue
+		}
+
+		target.sink = queue(sink, name)
+		s.targets = append(s.targets, target)
+	}
+
+	return s, nil
+}
+
+func (s *multiSink) Emit(ctx context.Context, record Record) error {
+
+	var errs []error
+	for _, t := range s.targets {
+		if !t.accept(record, s.defaultStream) {
+			continue
+		}
+
+		// 各后端可能修改属性切片，分别使用副本
+		r := record
+		r.Attrs = append([]slog.Attr(nil), record.Attrs...)
+		if err := t.sink.Emit(ctx, r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *multiSink) ForceFlush(ctx context.Context) error {
+	return s.each(func(sink LogSink) error {
+		if f, ok := sink.(sinkFlusher); ok {
+			return f.ForceFlush(ctx)
+		}
+		return nil
+	})
+}
+
+// queued 各后端队列中尚未导出完成的记录数之和
+func (s *multiSink) queued() int64 {
+
+	var n int64
+	for _, t := range s.targets {
+		if q, ok := t.sink.(sinkQueue); ok {
+			n += q.queued()
+		}
+	}
+
+	return n
+}
+
+func (s *multiSink) Shutdown(ctx context.Context) error {
+	return s.each(func(sink LogSink) error {
+		return sink.Shutdown(ctx)
+	})
+}
+
+// each 并行调用各后端，等待全部完成后返回所有错误
+func (s *multiSink) each(fn func(sink LogSink) error) error {
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, t := range s.targets {
+		wg.Add(1)
+		go func(t *sinkTarget) {
+			defer wg.Done()
+			if err := fn(t.sink); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+				mu.Unlock()
+			}
+		}(t)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// openObserveSink 通过 POST /api/{organization}/{stream}/_json 批量写入 OpenObserve
+type openObserveSink struct {
+	endpoint      string
+	organization  string
+	authorization string
+	headers       map[string]string
+	client        *http.Client
+	gzip          bool
+}
+
+func (e *RecordRequestLog) newOpenObserveSink() *batchSink {
+
+	s := &openObserveSink{
+		endpoint:      strings.TrimRight(e.endpoint, "/"),
+		organization:  e.organization,
+		authorization: e.authorization,
+		headers:       e.exporterHeaders,
+		client:        &http.Client{Timeout: e.logExportTimeout, Transport: e.proxy.transport()},
+		gzip:          e.compression == CompressionGzip,
+	}
+
+	return e.newBatchSink("openobserve", s.push)
+}
+
+func (s *openObserveSink) push(ctx context.Context, stream string, records []Record) error {
+
+	rows := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		fields := record.fields()
+		// OpenObserve 使用微秒级的 _timestamp 作为记录时间
+		fields["_timestamp"] = record.Time.UnixMicro()
+		rows = append(rows, fields)
+	}
+
+	body, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	if s.gzip {
+		if body, err = gzipBytes(body); err != nil {
+			return err
+		}
+	}
+
+	target := fmt.Sprintf("%s/api/%s/%s/_json", s.endpoint, url.PathEscape(s.organization), url.PathEscape(stream))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			msg:        fmt.Sprintf("openobserve responded %s: %s", resp.Status, bytes.TrimSpace(msg)),
+		}
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
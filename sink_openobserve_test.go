@@ -0,0 +1,129 @@
+package recordrequestlog_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenObserveBackend(t *testing.T) {
+
+	type ingest struct {
+		path          string
+		authorization string
+		tenant        string
+		records       []map[string]any
+	}
+
+	received := make(chan ingest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var records []map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&records); err != nil {
+			t.Error(err)
+		}
+		received <- ingest{req.URL.Path, req.Header.Get("Authorization"), req.Header.Get("X-Scope-OrgID"), records}
+	}))
+	defer server.Close()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = server.URL
+	cfg.Organization = "default"
+	cfg.StreamName = "requests"
+	cfg.Authorization = "Basic dGVzdDp0ZXN0"
+	cfg.ExporterHeaders = map[string]string{"X-Scope-OrgID": "acme", "Authorization": "Bearer ignored"}
+	cfg.LogMaxBatchSize = 1
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case got := <-received:
+		if got.path != "/api/default/requests/_json" {
+			t.Errorf("unexpected ingest path %q", got.path)
+		}
+		if got.authorization != cfg.Authorization {
+			t.Errorf("unexpected authorization %q", got.authorization)
+		}
+		if got.tenant != "acme" {
+			t.Errorf("expected exporter header X-Scope-OrgID, got %q", got.tenant)
+		}
+		if len(got.records) != 1 || got.records[0]["message"] != `{"id":1}` {
+			t.Errorf("unexpected records %v", got.records)
+		}
+		if _, ok := got.records[0]["_timestamp"]; !ok {
+			t.Error("expected _timestamp field")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for ingest request")
+	}
+}
+
+func TestOpenObserveCompression(t *testing.T) {
+
+	received := make(chan []map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// 同一地址也会收到 trace 和指标的 OTLP 请求
+		if !strings.HasSuffix(req.URL.Path, "/_json") {
+			return
+		}
+
+		if req.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("expected gzip content encoding, got %q", req.Header.Get("Content-Encoding"))
+			return
+		}
+
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var records []map[string]any
+		if err := json.NewDecoder(zr).Decode(&records); err != nil {
+			t.Error(err)
+		}
+		received <- records
+	}))
+	defer server.Close()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = server.URL
+	cfg.Organization = "default"
+	cfg.StreamName = "requests"
+	cfg.LogMaxBatchSize = 1
+	cfg.Compression = recordrequestlog.CompressionGzip
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case records := <-received:
+		if len(records) != 1 || records[0]["message"] != `{"id":1}` {
+			t.Errorf("unexpected records %v", records)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for ingest request")
+	}
+}
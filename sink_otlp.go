@@ -0,0 +1,386 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// otlpSink 通过 OTLP 导出记录。stream 名称通过导出请求头传递，
+// 因此每个 stream 使用独立的 LoggerProvider，首次使用时创建
+type otlpSink struct {
+	scope         string
+	resource      *resource.Resource
+	defaultStream string
+	newExporter   func(ctx context.Context, streamName string) (log.Exporter, error)
+	options       []log.BatchProcessorOption
+	spool         *spool
+	breaker       *circuitBreaker
+	dropped       metric.Int64Counter
+	onError       func(msg string, err error)
+	onExport      func(ctx context.Context, err error)
+
+	mu      sync.Mutex
+	streams map[string]*otlpStream
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type otlpStream struct {
+	provider *log.LoggerProvider
+	handler  slog.Handler
+
+	// 重放缓冲记录时，通过 collector 收集 SDK 生成的记录后直接调用 exporter 同步导出
+	exporter  log.Exporter
+	collector *collectProcessor
+	replay    slog.Handler
+}
+
+func (e *RecordRequestLog) newOTLPSink(newExporter func(ctx context.Context, streamName string) (log.Exporter, error)) *otlpSink {
+
+	options := []log.BatchProcessorOption{
+		log.WithExportTimeout(e.logExportTimeout),
+		log.WithExportInterval(e.logBatchInterval),
+	}
+
+	if e.logQueueSize > 0 {
+		options = append(options, log.WithMaxQueueSize(e.logQueueSize))
+	}
+
+	if e.logMaxBatchSize > 0 {
+		options = append(options, log.WithExportMaxBatchSize(e.logMaxBatchSize))
+	}
+
+	s := &otlpSink{
+		scope:         e.serverName,
+		resource:      e.resource,
+		defaultStream: e.streamName,
+		newExporter:   newExporter,
+		options:       options,
+		spool:         e.spool,
+		breaker:       e.breaker,
+		dropped:       e.droppedRecords,
+		onError:       e.logError,
+		onExport:      e.exportResult("logs"),
+		streams:       make(map[string]*otlpStream),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	if s.spool == nil {
+		close(s.done)
+		return s
+	}
+
+	go func() {
+		defer close(s.done)
+		s.spool.replay(s.stop, e.spoolRetryInterval, e.spoolMaxRetryInterval, s.replayBatch, s.onError)
+	}()
+
+	return s
+}
+
+func (s *otlpSink) Emit(ctx context.Context, record Record) error {
+
+	stream, err := s.stream(record.StreamName)
+	if err != nil {
+		return err
+	}
+
+	return stream.handler.Handle(ctx, record.slogRecord())
+}
+
+func (s *otlpSink) Shutdown(ctx context.Context) error {
+
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for name, stream := range s.streams {
+		err = errors.Join(err, stream.provider.Shutdown(ctx))
+		delete(s.streams, name)
+	}
+
+	return err
+}
+
+func (s *otlpSink) ForceFlush(ctx context.Context) error {
+
+	s.mu.Lock()
+	providers := make([]*log.LoggerProvider, 0, len(s.streams))
+	for _, stream := range s.streams {
+		providers = append(providers, stream.provider)
+	}
+	s.mu.Unlock()
+
+	var err error
+	for _, provider := range providers {
+		err = errors.Join(err, provider.ForceFlush(ctx))
+	}
+
+	return err
+}
+
+// providerSink 通过应用提供的 LoggerProvider 导出记录，多个中间件实例可以共用同一个 provider。
+// handler 在创建中间件时生成一次；provider 由应用负责关闭，Shutdown 只刷新缓冲的记录
+type providerSink struct {
+	provider otellog.LoggerProvider
+	handler  slog.Handler
+}
+
+func (e *RecordRequestLog) newProviderSink(provider otellog.LoggerProvider) *providerSink {
+	return &providerSink{
+		provider: provider,
+		handler:  otelslog.NewHandler(e.serverName, otelslog.WithLoggerProvider(provider)),
+	}
+}
+
+func (s *providerSink) Emit(ctx context.Context, record Record) error {
+	return s.handler.Handle(ctx, record.slogRecord())
+}
+
+func (s *providerSink) Shutdown(ctx context.Context) error {
+	return s.ForceFlush(ctx)
+}
+
+func (s *providerSink) ForceFlush(ctx context.Context) error {
+
+	if f, ok := s.provider.(sinkFlusher); ok {
+		return f.ForceFlush(ctx)
+	}
+
+	return nil
+}
+
+// stream 返回 stream 对应的 LoggerProvider，不存在时创建
+func (s *otlpSink) stream(name string) (*otlpStream, error) {
+
+	if name == "" {
+		name = s.defaultStream
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stream, ok := s.streams[name]; ok {
+		return stream, nil
+	}
+
+	exp, err := s.newExporter(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &otlpStream{exporter: exp}
+
+	var batchExporter log.Exporter = &breakerLogExporter{
+		Exporter:   exp,
+		breaker:    s.breaker,
+		reportOpen: s.spool != nil,
+		dropped:    s.dropped,
+		onExport:   s.onExport,
+	}
+
+	if s.spool != nil {
+		batchExporter = &spoolExporter{Exporter: batchExporter, stream: name, spool: s.spool, onError: s.onError}
+
+		stream.collector = &collectProcessor{}
+		replay := log.NewLoggerProvider(log.WithProcessor(stream.collector), log.WithResource(s.resource))
+		stream.replay = otelslog.NewHandler(s.scope, otelslog.WithLoggerProvider(replay))
+	}
+
+	stream.provider = log.NewLoggerProvider(
+		log.WithProcessor(log.NewBatchProcessor(batchExporter, s.options...)),
+		log.WithResource(s.resource),
+	)
+	stream.handler = otelslog.NewHandler(s.scope, otelslog.WithLoggerProvider(stream.provider))
+	s.streams[name] = stream
+
+	return stream, nil
+}
+
+func (e *RecordRequestLog) newOTLPGRPCExporter(ctx context.Context, streamName string) (log.Exporter, error) {
+
+	return e.newFailoverLogExporter(func(endpoint string) (log.Exporter, error) {
+		opts := []otlploggrpc.Option{
+			otlploggrpc.WithEndpointURL(endpoint),
+			otlploggrpc.WithInsecure(),
+			otlploggrpc.WithHeaders(e.exportHeaders(streamName)),
+			otlploggrpc.WithRetry(otlploggrpc.RetryConfig{Enabled: false}),
+			otlploggrpc.WithDialOption(e.retry.dialOptions()...),
+			otlploggrpc.WithDialOption(e.proxy.dialOptions()...),
+		}
+
+		if e.compression == CompressionGzip {
+			opts = append(opts, otlploggrpc.WithCompressor(CompressionGzip))
+		}
+
+		return otlploggrpc.New(ctx, opts...)
+	})
+}
+
+func (e *RecordRequestLog) newOTLPHTTPExporter(ctx context.Context, streamName string) (log.Exporter, error) {
+
+	compression := otlploghttp.NoCompression
+	if e.compression == CompressionGzip {
+		compression = otlploghttp.GzipCompression
+	}
+
+	return e.newFailoverLogExporter(func(endpoint string) (log.Exporter, error) {
+		opts := []otlploghttp.Option{
+			otlploghttp.WithEndpointURL(endpoint),
+			otlploghttp.WithHeaders(e.exportHeaders(streamName)),
+			otlploghttp.WithRetry(e.retry.httpConfig()),
+			otlploghttp.WithCompression(compression),
+		}
+
+		if e.proxy != nil {
+			opts = append(opts, otlploghttp.WithProxy(e.proxy.httpProxy))
+		}
+
+		return otlploghttp.New(ctx, opts...)
+	})
+}
+
+// replayBatch 同步导出一个缓冲的批次，导出失败时保留在缓冲中等待下次重放
+func (s *otlpSink) replayBatch(ctx context.Context, batch spoolBatch) error {
+
+	stream, err := s.stream(batch.Stream)
+	if err != nil {
+		return err
+	}
+
+	stream.collector.records = stream.collector.records[:0]
+	for _, r := range batch.Records {
+		rctx, record := r.record(ctx, batch.Stream)
+		if err := stream.replay.Handle(rctx, record.slogRecord()); err != nil {
+			return err
+		}
+	}
+
+	return stream.exporter.Export(ctx, stream.collector.records)
+}
+
+// spoolExporter 导出失败时将批次写入本地缓冲
+type spoolExporter struct {
+	log.Exporter
+	stream  string
+	spool   *spool
+	onError func(msg string, err error)
+}
+
+func (x *spoolExporter) Export(ctx context.Context, records []log.Record) error {
+
+	err := x.Exporter.Export(ctx, records)
+	if err == nil || len(records) == 0 {
+		return err
+	}
+
+	batch := spoolBatch{Stream: x.stream}
+	for i := range records {
+		batch.Records = append(batch.Records, sdkSpoolRecord(&records[i]))
+	}
+
+	if serr := x.spool.write(batch); serr != nil {
+		return errors.Join(err, serr)
+	}
+
+	// 熔断期间的跳过是预期行为，不重复输出错误
+	if !errors.Is(err, errCircuitOpen) {
+		x.onError("export failed, batch spooled for replay", err)
+	}
+	return nil
+}
+
+// sdkSpoolRecord 将 SDK 的日志记录转换为持久化形式
+func sdkSpoolRecord(r *log.Record) spoolRecord {
+
+	record := spoolRecord{
+		Time: r.Timestamp(),
+		// otelslog 将 slog 级别映射为 severity = level + 9
+		Level:   slog.Level(r.Severity() - 9),
+		Message: r.Body().AsString(),
+		Attrs:   make(map[string]any, r.AttributesLen()),
+	}
+
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		record.Attrs[kv.Key] = logValue(kv.Value)
+		return true
+	})
+
+	if r.TraceID().IsValid() {
+		record.TraceID = r.TraceID().String()
+		record.SpanID = r.SpanID().String()
+	}
+
+	return record
+}
+
+// logValue 将 OTel 日志属性值转换为可以 JSON 序列化的值
+func logValue(v otellog.Value) any {
+
+	switch v.Kind() {
+	case otellog.KindBool:
+		return v.AsBool()
+	case otellog.KindInt64:
+		return v.AsInt64()
+	case otellog.KindFloat64:
+		return v.AsFloat64()
+	case otellog.KindString:
+		return v.AsString()
+	case otellog.KindBytes:
+		return v.AsBytes()
+	case otellog.KindSlice:
+		values := make([]any, 0, len(v.AsSlice()))
+		for _, item := range v.AsSlice() {
+			values = append(values, logValue(item))
+		}
+		return values
+	case otellog.KindMap:
+		values := make(map[string]any, len(v.AsMap()))
+		for _, kv := range v.AsMap() {
+			values[kv.Key] = logValue(kv.Value)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// collectProcessor 收集 LoggerProvider 生成的记录，不做导出
+type collectProcessor struct {
+	records []log.Record
+}
+
+func (p *collectProcessor) OnEmit(ctx context.Context, record log.Record) error {
+	p.records = append(p.records, record.Clone())
+	return nil
+}
+
+func (p *collectProcessor) Enabled(context.Context, log.Record) bool { return true }
+
+func (p *collectProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *collectProcessor) ForceFlush(context.Context) error { return nil }
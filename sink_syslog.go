@@ -0,0 +1,264 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// 默认的 syslog facility 和 structured data ID，32473 为 RFC 5612 保留用于示例的企业编号
+const (
+	defaultSyslogFacility = "local0"
+	defaultSyslogSDID     = "request@32473"
+)
+
+// syslogFacilities RFC 5424 的 facility 编号
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSink 按 RFC 5424 格式发送记录，属性写入 structured data，日志消息写入 MSG。
+// TCP 和 TLS 连接按 RFC 6587 的长度前缀分帧，写入失败时重新连接一次
+type syslogSink struct {
+	network  string
+	address  string
+	tls      *tls.Config
+	timeout  time.Duration
+	facility int
+	hostname string
+	appName  string
+	sdID     string
+	onExport func(ctx context.Context, err error)
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// parseSyslogAddress 解析 udp://host:port、tcp://host:port 或 tls://host:port 形式的地址
+func parseSyslogAddress(address string) (string, string, error) {
+
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return "", "", fmt.Errorf("invalid syslog_address %q: must be udp://, tcp:// or tls:// followed by host:port", address)
+	}
+
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+	default:
+		return "", "", fmt.Errorf("invalid syslog_address %q: scheme must be udp, tcp or tls", address)
+	}
+
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return "", "", fmt.Errorf("invalid syslog_address %q: %w", address, err)
+	}
+
+	return u.Scheme, u.Host, nil
+}
+
+func (e *RecordRequestLog) newSyslogSink(config *Config) (*syslogSink, error) {
+
+	network, address, err := parseSyslogAddress(config.SyslogAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	facility, ok := syslogFacilities[strings.ToLower(config.SyslogFacility)]
+	if !ok && config.SyslogFacility != "" {
+		return nil, fmt.Errorf("invalid syslog_facility %q", config.SyslogFacility)
+	}
+	if config.SyslogFacility == "" {
+		facility = syslogFacilities[defaultSyslogFacility]
+	}
+
+	s := &syslogSink{
+		network:  network,
+		address:  address,
+		timeout:  e.logExportTimeout,
+		facility: facility,
+		appName:  config.SyslogAppName,
+		sdID:     config.SyslogSDID,
+		onExport: e.exportResult("logs"),
+	}
+
+	if network == "tls" {
+		if s.tls, err = newTLSConfig("syslog_tls_ca_file", config.SyslogTLSCAFile, config.SyslogTLSInsecureSkipVerify); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.appName == "" {
+		service, _ := e.resource.Set().Value(semconv.ServiceNameKey)
+		s.appName = service.AsString()
+	}
+	if s.sdID == "" {
+		s.sdID = defaultSyslogSDID
+	}
+
+	if s.hostname, err = os.Hostname(); err != nil || s.hostname == "" {
+		s.hostname = "-"
+	}
+
+	return s, nil
+}
+
+func (s *syslogSink) dial() (net.Conn, error) {
+
+	dialer := &net.Dialer{Timeout: s.timeout}
+	if s.network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.address, s.tls)
+	}
+
+	return dialer.Dial(s.network, s.address)
+}
+
+func (s *syslogSink) Emit(ctx context.Context, record Record) error {
+
+	message := s.format(record)
+	if s.network != "udp" {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				break
+			}
+		}
+
+		if s.timeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		}
+		if _, err = s.conn.Write(message); err == nil {
+			break
+		}
+
+		// 连接可能已被服务端关闭，重新连接后再写入一次
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	s.onExport(ctx, err)
+	return err
+}
+
+// format 返回 RFC 5424 格式的消息：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID ...] MSG
+func (s *syslogSink) format(record Record) []byte {
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ",
+		s.facility*8+syslogSeverity(record.Level),
+		record.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeader(s.hostname, 255),
+		syslogHeader(s.appName, 48),
+		os.Getpid(),
+		syslogHeader(record.StreamName, 32),
+	)
+
+	b.WriteString("[" + s.sdID)
+	writeSyslogParams(&b, "", record.Attrs)
+	b.WriteString("] ")
+	b.WriteString(record.Message)
+
+	return b.Bytes()
+}
+
+// syslogSeverity 将日志级别转换为 syslog severity
+func syslogSeverity(level slog.Level) int {
+
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogHeader 返回头部字段，只保留可打印的 ASCII 字符，为空时返回 "-"
+func syslogHeader(value string, limit int) string {
+
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+
+	if value == "" {
+		return "-"
+	}
+
+	return value[:min(len(value), limit)]
+}
+
+// writeSyslogParams 写入 structured data 参数，分组展开为以点号连接的参数名
+func writeSyslogParams(b *bytes.Buffer, prefix string, attrs []slog.Attr) {
+
+	for _, attr := range attrs {
+		v := attr.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			writeSyslogParams(b, prefix+attr.Key+".", v.Group())
+			continue
+		}
+
+		value := v.String()
+		if v.Kind() == slog.KindTime {
+			value = v.Time().Format(time.RFC3339Nano)
+		}
+
+		b.WriteString(" " + syslogParamName(prefix+attr.Key) + `="`)
+		b.WriteString(syslogParamEscaper.Replace(value))
+		b.WriteString(`"`)
+	}
+}
+
+// syslogParamName 参数名最长 32 个字符，不能包含 =、空格、] 和双引号
+func syslogParamName(name string) string {
+
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	return name[:min(len(name), 32)]
+}
+
+// syslogParamEscaper 转义参数值中的双引号、反斜杠和 ]
+var syslogParamEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+func (s *syslogSink) Shutdown(ctx context.Context) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,86 @@
+package recordrequestlog_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogBackend(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// TCP 连接按长度前缀分帧
+		r := bufio.NewReader(conn)
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(size))
+		message := make([]byte, n)
+		if _, err := io.ReadFull(r, message); err != nil {
+			t.Error(err)
+		}
+		received <- string(message)
+	}()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendSyslog
+	cfg.SyslogAddress = "tcp://" + ln.Addr().String()
+	cfg.SyslogAppName = "gateway"
+	cfg.StreamName = "access"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	req.Header.Set("User-Agent", `agent "x" ]`)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case message := <-received:
+		// local0 的 error 级别：16*8+3
+		header := regexp.MustCompile(`^<131>1 \S+ \S+ gateway \d+ access \[request@32473 `)
+		if !header.MatchString(message) {
+			t.Errorf("unexpected header in %q", message)
+		}
+		for _, param := range []string{`method="POST"`, `status="500"`, `user-agent="agent \"x\" \]"`} {
+			if !strings.Contains(message, param) {
+				t.Errorf("expected %s in %q", param, message)
+			}
+		}
+		if !strings.HasSuffix(message, `] {"id":1}`) {
+			t.Errorf("expected the body as MSG in %q", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog message")
+	}
+}
@@ -0,0 +1,260 @@
+package recordrequestlog_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+// readRecords 读取 file 后端写入的 JSON 行
+func readRecords(t *testing.T, path string) []map[string]any {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
+
+// captureRecord 使用 file 后端处理一个请求，并返回写入的记录
+func captureRecord(t *testing.T, cfg *recordrequestlog.Config, req *http.Request) map[string]any {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	return records[0]
+}
+
+func TestFileBackend(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.ServerName = "announcement"
+	cfg.LogFormat = recordrequestlog.LogFormatSemConv
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders?page=1", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	record := records[0]
+	for key, want := range map[string]any{
+		"msg":                       "POST /api/orders",
+		"http.request.method":       "POST",
+		"url.full":                  "http://localhost/api/orders?page=1",
+		"http.request.body.content": `{"id":1}`,
+		"service.name":              "announcement",
+	} {
+		if record[key] != want {
+			t.Errorf("%s: got %v, want %v", key, record[key], want)
+		}
+	}
+}
+
+func TestInvalidBackend(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin"); err == nil {
+		t.Fatal("expected error for file backend without file_path")
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "requests.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	// 每条记录写入前都会轮转
+	cfg.FileMaxSize = 1
+	cfg.FileCompress = true
+	cfg.FileMaxBackups = 2
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/api", nil))
+	}
+
+	// Shutdown 等待后台压缩和清理完成
+	if err := handler.(*recordrequestlog.RecordRequestLog).Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := readRecords(t, path); len(records) != 1 {
+		t.Fatalf("expected 1 record in the current file, got %d", len(records))
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "requests-*.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 compressed backups, got %v", backups)
+	}
+
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record map[string]any
+	if err := json.NewDecoder(zr).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record["url"] != "http://localhost/api" {
+		t.Errorf("unexpected backup record %v", record)
+	}
+}
+
+func TestFileFallback(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "fallback.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = "http://127.0.0.1:1"
+	cfg.Organization = "default"
+	cfg.StreamName = "requests"
+	// 队列已满时记录写入文件
+	cfg.LogQueueSize = 1
+	cfg.LogBatchInterval = "1h"
+	cfg.FilePath = path
+	cfg.FileFallback = true
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/queued", "/fallback"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+target, nil))
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 1 || records[0]["url"] != "http://localhost/fallback" {
+		t.Fatalf("expected the rejected record in the fallback file, got %v", records)
+	}
+}
+
+func TestMultipleSinks(t *testing.T) {
+
+	dir := t.TempDir()
+	all := filepath.Join(dir, "requests.log")
+	errorsOnly := filepath.Join(dir, "errors.log")
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = all
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+	cfg.Sinks = []recordrequestlog.SinkConfig{
+		{Name: "errors", Backend: recordrequestlog.BackendFile, FilePath: errorsOnly, MinLevel: "error"},
+		// 无法连接的后端不影响其他后端
+		{Name: "unreachable", Backend: recordrequestlog.BackendSyslog},
+	}
+	cfg.SyslogAddress = "tcp://127.0.0.1:1"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/fail" {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/ok", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+target, nil))
+	}
+
+	// 各后端异步导出，Shutdown 等待队列清空
+	handler.(*recordrequestlog.RecordRequestLog).Shutdown(context.Background())
+
+	if records := readRecords(t, all); len(records) != 2 {
+		t.Errorf("expected 2 records in the top-level backend, got %d", len(records))
+	}
+
+	records := readRecords(t, errorsOnly)
+	if len(records) != 1 || records[0]["url"] != "http://localhost/fail" {
+		t.Errorf("expected only the failed request in the errors sink, got %v", records)
+	}
+}
@@ -0,0 +1,173 @@
+package recordrequestlog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// 控制台输出的时间格式，也可以使用 Go 的时间格式，例如 "2006-01-02 15:04:05.000"
+const (
+	TimeFormatRFC3339Nano = "rfc3339nano"
+	TimeFormatRFC3339     = "rfc3339"
+	TimeFormatUnix        = "unix"
+	TimeFormatUnixMilli   = "unix_ms"
+)
+
+// writerSink 将记录以 JSON 行的形式写入 io.Writer
+type writerSink struct {
+	handler slog.Handler
+	closer  io.Closer
+	layout  *consoleLayout
+}
+
+// consoleLayout 控制台输出的字段选择、字段名和时间格式
+type consoleLayout struct {
+	// 为空时输出全部字段
+	fields     map[string]bool
+	names      map[string]string
+	timeFormat string
+}
+
+// newConsoleSink 创建 stdout 或 stderr 后端，按 console_* 配置调整输出的字段
+func newConsoleSink(w io.Writer, config *Config) *writerSink {
+
+	s := &writerSink{}
+
+	options := &slog.HandlerOptions{ReplaceAttr: replaceLevel}
+	if len(config.ConsoleFields) > 0 || len(config.ConsoleFieldNames) > 0 || config.ConsoleTimeFormat != "" {
+		s.layout = &consoleLayout{names: config.ConsoleFieldNames, timeFormat: config.ConsoleTimeFormat}
+		if len(config.ConsoleFields) > 0 {
+			s.layout.fields = make(map[string]bool, len(config.ConsoleFields))
+			for _, field := range config.ConsoleFields {
+				s.layout.fields[field] = true
+			}
+		}
+		options.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			return s.layout.replaceBuiltin(groups, replaceLevel(groups, a))
+		}
+	}
+
+	s.handler = slog.NewJSONHandler(w, options)
+	return s
+}
+
+// newFileSink 将记录以 JSON 行写入 file_path，按 file_* 配置轮转、压缩和清理备份文件
+func (e *RecordRequestLog) newFileSink(config *Config) (*writerSink, error) {
+
+	f, err := newRotatingFile(config, e.logError)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writerSink{
+		handler: slog.NewJSONHandler(f, &slog.HandlerOptions{ReplaceAttr: replaceLevel}),
+		closer:  f,
+	}, nil
+}
+
+func (s *writerSink) Emit(ctx context.Context, record Record) error {
+
+	if s.layout != nil {
+		record = s.layout.apply(record)
+	}
+
+	return s.handler.Handle(ctx, record.slogRecord())
+}
+
+func (s *writerSink) Shutdown(ctx context.Context) error {
+
+	if s.closer == nil {
+		return nil
+	}
+
+	return s.closer.Close()
+}
+
+// apply 选择并重命名记录的顶层属性，分组属性不经过 ReplaceAttr，在这里处理
+func (l *consoleLayout) apply(record Record) Record {
+
+	attrs := make([]slog.Attr, 0, len(record.Attrs))
+	for _, attr := range record.Attrs {
+		if l.fields != nil && !l.fields[attr.Key] {
+			continue
+		}
+		if name, ok := l.names[attr.Key]; ok {
+			attr.Key = name
+		}
+		attrs = append(attrs, attr)
+	}
+
+	record.Attrs = attrs
+	return record
+}
+
+// replaceLevel 将 level 字段输出为小写的级别名称，与 JSON 类后端一致
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(levelName(level))
+		}
+	}
+
+	return a
+}
+
+// replaceBuiltin 处理 time、level 和 msg 字段
+func (l *consoleLayout) replaceBuiltin(groups []string, a slog.Attr) slog.Attr {
+
+	if len(groups) > 0 {
+		return a
+	}
+
+	switch a.Key {
+	case slog.TimeKey, slog.LevelKey, slog.MessageKey:
+	default:
+		return a
+	}
+
+	if l.fields != nil && !l.fields[a.Key] {
+		return slog.Attr{}
+	}
+
+	if a.Key == slog.TimeKey && l.timeFormat != "" && a.Value.Kind() == slog.KindTime {
+		a.Value = formatTime(l.timeFormat, a.Value.Time())
+	}
+
+	if name, ok := l.names[a.Key]; ok {
+		a.Key = name
+	}
+
+	return a
+}
+
+// formatTime 按时间格式返回 time 字段的值，unix 格式为数字
+func formatTime(format string, t time.Time) slog.Value {
+
+	switch format {
+	case TimeFormatRFC3339Nano:
+		return slog.StringValue(t.Format(time.RFC3339Nano))
+	case TimeFormatRFC3339:
+		return slog.StringValue(t.Format(time.RFC3339))
+	case TimeFormatUnix:
+		return slog.Int64Value(t.Unix())
+	case TimeFormatUnixMilli:
+		return slog.Int64Value(t.UnixMilli())
+	default:
+		return slog.StringValue(t.Format(format))
+	}
+}
+
+// validTimeFormat 判断 console_time_format 是否为预定义格式或包含时间字段的 Go 时间格式
+func validTimeFormat(format string) bool {
+
+	switch format {
+	case "", TimeFormatRFC3339Nano, TimeFormatRFC3339, TimeFormatUnix, TimeFormatUnixMilli:
+		return true
+	}
+
+	// 不包含任何时间字段的格式化结果与格式本身相同
+	return time.Unix(0, 0).UTC().Format(format) != format
+}
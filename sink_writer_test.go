@@ -0,0 +1,69 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConsoleLayout(t *testing.T) {
+
+	record := Record{
+		Time:    time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		Level:   slog.LevelInfo,
+		Message: "GET /api",
+		Attrs: []slog.Attr{
+			slog.String("method", "GET"),
+			slog.Int("status", 200),
+			slog.Group("query", slog.String("page", "1")),
+		},
+	}
+
+	cfg := CreateConfig()
+	cfg.ConsoleFields = []string{"time", "msg", "status", "query"}
+	cfg.ConsoleFieldNames = map[string]string{"msg": "message", "time": "@timestamp", "query": "params"}
+	cfg.ConsoleTimeFormat = TimeFormatUnixMilli
+
+	var buf bytes.Buffer
+	if err := newConsoleSink(&buf, cfg).Emit(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"@timestamp": float64(record.Time.UnixMilli()),
+		"message":    "GET /api",
+		"status":     float64(200),
+		"params":     map[string]any{"page": "1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected fields %v, got %v", want, got)
+	}
+}
+
+func TestConsoleDefaultLayout(t *testing.T) {
+
+	var buf bytes.Buffer
+	sink := newConsoleSink(&buf, CreateConfig())
+	if err := sink.Emit(context.Background(), Record{Time: time.Now(), Message: "GET /api", Attrs: []slog.Attr{slog.String("method", "GET")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"time", "level", "msg", "method"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("expected field %s in %v", key, got)
+		}
+	}
+}
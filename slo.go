@@ -0,0 +1,116 @@
+package recordrequestlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Apdex 分区：耗时不超过阈值 T 为 satisfied，不超过 4T 为 tolerating，超过 4T 或 5xx 为 frustrated
+const (
+	apdexSatisfied  = "satisfied"
+	apdexTolerating = "tolerating"
+	apdexFrustrated = "frustrated"
+)
+
+// 默认的 SLO 达标比例
+const defaultSLOObjective = 0.999
+
+// slo 路由的延迟阈值和达标比例，1 - objective 为错误预算
+type slo struct {
+	// 指标中的名称，顶层为 default，路由为 routes[i]
+	name      string
+	threshold time.Duration
+	objective float64
+}
+
+// newSLO 检查 slo_latency_threshold 和 slo_objective，没有设置阈值时返回 nil；
+// prefix 为错误信息中的字段前缀，例如 "routes[0]."
+func newSLO(name, prefix, threshold string, objective float64) (*slo, error) {
+
+	if objective < 0 || objective >= 1 {
+		return nil, fmt.Errorf("invalid %sslo_objective %v: must be at least 0 and less than 1", prefix, objective)
+	}
+
+	if threshold == "" {
+		return nil, nil
+	}
+
+	t, err := parseDuration(prefix+"slo_latency_threshold", threshold, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if objective == 0 {
+		objective = defaultSLOObjective
+	}
+
+	return &slo{name: name, threshold: t, objective: objective}, nil
+}
+
+// zone 返回请求的 Apdex 分区
+func (s *slo) zone(status int, duration time.Duration) string {
+
+	switch {
+	case status >= http.StatusInternalServerError || duration > 4*s.threshold:
+		return apdexFrustrated
+	case duration > s.threshold:
+		return apdexTolerating
+	default:
+		return apdexSatisfied
+	}
+}
+
+// recordSLO 按路由的 SLO 统计请求，返回请求是否违反 SLO（5xx 或耗时超过阈值）和 Apdex 分区；没有配置 SLO 时返回空分区
+func (e *RecordRequestLog) recordSLO(ctx context.Context, settings *routeSettings, status int, duration time.Duration) (bool, string) {
+
+	s := settings.slo
+	if s == nil {
+		return false, ""
+	}
+
+	zone := s.zone(status, duration)
+	violation := zone != apdexSatisfied
+
+	e.sloRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("slo.name", s.name),
+		attribute.String("apdex.zone", zone),
+		attribute.Bool("slo.violation", violation),
+	))
+
+	return violation, zone
+}
+
+// sloAttrs 返回记录中的 SLO 属性
+func (e *RecordRequestLog) sloAttrs(violation bool, zone string) []slog.Attr {
+	return []slog.Attr{
+		slog.Bool(e.attrKey("slo-violation", "slo_violation"), violation),
+		slog.String(e.attrKey("apdex", "apdex_zone"), zone),
+	}
+}
+
+// slos 返回所有配置了 SLO 的路由使用的 SLO，沿用顶层设置的路由只返回一次
+func (rules *rules) slos() []*slo {
+
+	var slos []*slo
+	seen := make(map[*slo]bool)
+
+	add := func(s *slo) {
+		if s != nil && !seen[s] {
+			seen[s] = true
+			slos = append(slos, s)
+		}
+	}
+
+	add(rules.defaults.slo)
+	for _, r := range rules.routes {
+		add(r.settings.slo)
+	}
+
+	return slos
+}
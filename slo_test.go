@@ -0,0 +1,157 @@
+package recordrequestlog_test
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestSLO(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	objective := 0.99
+	cfg := recordrequestlog.CreateConfig()
+	cfg.SLOLatencyThreshold = "10ms"
+	cfg.Routes = []recordrequestlog.RouteConfig{{PathPrefix: "/batch", SLOObjective: &objective}}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		delay, _ := time.ParseDuration(req.URL.Query().Get("delay"))
+		time.Sleep(delay)
+		if status, _ := strconv.Atoi(req.URL.Query().Get("status")); status > 0 {
+			rw.WriteHeader(status)
+		}
+	}))
+
+	tests := []struct {
+		target    string
+		zone      string
+		violation bool
+	}{
+		{"/api", "satisfied", false},
+		{"/api?delay=20ms", "tolerating", true},
+		{"/api?delay=50ms", "frustrated", true},
+		{"/batch?status=503", "frustrated", true},
+	}
+
+	for _, tt := range tests {
+		rec.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+tt.target, nil))
+
+		record := rec.RequireRecords(t, 1)[0]
+		if v, _ := recordrequestlogtest.Attr(record, "apdex"); v.String() != tt.zone {
+			t.Errorf("%s: expected apdex %s, got %v", tt.target, tt.zone, v)
+		}
+		if v, _ := recordrequestlogtest.Attr(record, "slo-violation"); v.Bool() != tt.violation {
+			t.Errorf("%s: expected slo-violation %v, got %v", tt.target, tt.violation, v)
+		}
+	}
+
+	// 每个 SLO、分区和是否违反 SLO 的组合各一个数据点
+	counts := map[string]int64{}
+	for _, point := range rec.RequireMetric(t, "recordrequestlog.slo.requests").Data.(metricdata.Sum[int64]).DataPoints {
+		name, _ := point.Attributes.Value("slo.name")
+		zone, _ := point.Attributes.Value("apdex.zone")
+		counts[name.AsString()+"/"+zone.AsString()] += point.Value
+	}
+	want := map[string]int64{"default/satisfied": 1, "default/tolerating": 1, "default/frustrated": 1, "routes[0]/frustrated": 1}
+	if !maps.Equal(counts, want) {
+		t.Fatalf("expected slo requests %v, got %v", want, counts)
+	}
+
+	objectives := map[string]float64{}
+	for _, point := range rec.RequireMetric(t, "recordrequestlog.slo.objective").Data.(metricdata.Gauge[float64]).DataPoints {
+		name, _ := point.Attributes.Value("slo.name")
+		objectives[name.AsString()] = point.Value
+	}
+	if objectives["default"] != 0.999 || objectives["routes[0]"] != 0.99 {
+		t.Fatalf("unexpected objectives %v", objectives)
+	}
+}
+
+func TestSLOSkipsStreams(t *testing.T) {
+
+	handlers := map[string]http.HandlerFunc{
+		"sse": func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.Write([]byte("data: tick\n\n"))
+			time.Sleep(10 * time.Millisecond)
+		},
+		"websocket": func(rw http.ResponseWriter, req *http.Request) {
+			conn, buf, err := rw.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+			buf.Flush()
+			time.Sleep(10 * time.Millisecond)
+		},
+	}
+
+	for name, next := range handlers {
+		t.Run(name, func(t *testing.T) {
+
+			rec := recordrequestlogtest.New()
+
+			cfg := recordrequestlog.CreateConfig()
+			cfg.SLOLatencyThreshold = "1ms"
+
+			middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := httptest.NewServer(middleware(next))
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+			if name == "websocket" {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			// 劫持的连接在处理器返回后才记录关闭
+			closed := recordrequestlogtest.HasAttr("event", "connection-closed")
+			deadline := time.Now().Add(5 * time.Second)
+			for !slices.ContainsFunc(rec.Records(), closed) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			record := rec.RequireRecord(t, closed)
+			if _, ok := recordrequestlogtest.Attr(record, "apdex"); ok {
+				t.Errorf("expected no apdex zone for a long-lived connection, got %v", record)
+			}
+
+			for _, m := range rec.Metrics(t) {
+				if m.Name != "recordrequestlog.slo.requests" {
+					continue
+				}
+				if points := m.Data.(metricdata.Sum[int64]).DataPoints; len(points) > 0 {
+					t.Fatalf("expected long-lived connections not to count against the SLO, got %+v", points)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package recordrequestlog
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 默认作为 span 事件记录的请求体和响应体大小上限
+const defaultSpanBodyMaxSize = 1024
+
+// bodySnippet 保留写入内容的前 limit 个字节
+type bodySnippet struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (s *bodySnippet) Write(p []byte) (int, error) {
+
+	room := s.limit - len(s.buf)
+	if len(p) > room {
+		s.truncated = true
+		s.buf = append(s.buf, p[:max(room, 0)]...)
+		return len(p), nil
+	}
+
+	s.buf = append(s.buf, p...)
+	return len(p), nil
+}
+
+// snippet 将内容截断到 limit 个字节，截断处不完整的 UTF-8 字符一并去掉
+func snippet(content string, limit int) (string, bool) {
+
+	if len(content) <= limit {
+		return content, false
+	}
+
+	return strings.ToValidUTF8(content[:limit], ""), true
+}
+
+// addRequestBodyEvent 将脱敏后的请求体作为 server span 的事件记录，
+// 摘要、multipart、二进制和只记录元数据的请求体不记录
+func (x *Exchange) addRequestBodyEvent() {
+
+	e, body := x.e, x.body
+	if !e.spanBodyEvents || body == nil || !x.span.IsRecording() {
+		return
+	}
+
+	if body.digest != nil || body.multipart != nil || body.metadataOnly || body.encoding != "" {
+		return
+	}
+
+	content := formContent(e.rules.Load().query, body)
+	if content == "" {
+		return
+	}
+
+	content, truncated := snippet(content, e.spanBodyMaxSize)
+	x.span.AddEvent("http.request.body", trace.WithAttributes(
+		attribute.String("http.request.body.content", content),
+		attribute.Int64("http.request.body.size", body.size),
+		attribute.Bool("http.request.body.truncated", truncated || body.truncated),
+	))
+}
+
+// addResponseBodyEvent 将响应体的前 span_body_max_size 个字节作为 server span 的事件记录，
+// 只记录 capture_content_types 中未压缩的响应，表单和 XML 按请求体的规则脱敏
+func (x *Exchange) addResponseBodyEvent(ctx context.Context) {
+
+	e, s := x.e, x.rw.snippet
+	if s == nil || len(s.buf) == 0 {
+		return
+	}
+
+	header := x.rw.Header()
+	contentType := header.Get("Content-Type")
+	if header.Get("Content-Encoding") != "" || !x.settings.isPlaintext(contentType) {
+		return
+	}
+
+	content := strings.ToValidUTF8(string(s.buf), "")
+	switch {
+	case isForm(contentType):
+		content = e.rules.Load().query.redactRaw(content)
+	case isXML(contentType):
+		content, _, _ = e.redactXML(ctx, content, "")
+	}
+
+	x.span.AddEvent("http.response.body", trace.WithAttributes(
+		attribute.String("http.response.body.content", content),
+		attribute.Int64("http.response.body.size", x.rw.size),
+		attribute.Bool("http.response.body.truncated", s.truncated),
+	))
+}
+
+// captureResponseSnippet 开启 span_body_events 且 span 被采样时保留响应体的前一部分
+func (x *Exchange) captureResponseSnippet() {
+
+	if x.e.spanBodyEvents && x.span.IsRecording() && x.req.Method != http.MethodHead {
+		x.rw.snippet = &bodySnippet{limit: x.e.spanBodyMaxSize}
+	}
+}
@@ -0,0 +1,161 @@
+package recordrequestlog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 从 span_link_header 读取的链接个数上限，超出的忽略
+const maxSpanLinks = 8
+
+// 记住的请求 ID 个数上限，超过时先清理过期的条目，仍然超过时清空
+const maxRetryLinks = 10000
+
+// 链接的来源，记录为链接的 link.reason 属性
+const (
+	linkReasonHeader = "linked"
+	linkReasonRetry  = "retry"
+)
+
+// headerLinks 解析请求头中以逗号分隔的 W3C traceparent，无效的值忽略
+func headerLinks(req *http.Request, header string) []trace.Link {
+
+	if header == "" {
+		return nil
+	}
+
+	var links []trace.Link
+	for _, value := range req.Header.Values(header) {
+		for _, parent := range strings.Split(value, ",") {
+			if len(links) >= maxSpanLinks {
+				return links
+			}
+
+			carrier := propagation.MapCarrier{"traceparent": strings.TrimSpace(parent)}
+			sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+			if !sc.IsValid() {
+				continue
+			}
+
+			links = append(links, trace.Link{
+				SpanContext: sc,
+				Attributes:  []attribute.KeyValue{attribute.String("link.reason", linkReasonHeader)},
+			})
+		}
+	}
+
+	return links
+}
+
+// retryLinks 记住调用方提供的请求 ID 第一次出现时的 span，窗口内相同请求 ID 的请求视为重试
+type retryLinks struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*retryEntry
+}
+
+type retryEntry struct {
+	first   trace.SpanContext
+	retries int
+	expires time.Time
+}
+
+func newRetryLinks(window time.Duration) *retryLinks {
+
+	if window <= 0 {
+		return nil
+	}
+
+	return &retryLinks{window: window, entries: make(map[string]*retryEntry)}
+}
+
+// lookup 返回请求 ID 第一次请求的 span 和包括本次在内的重试次数，没有记住该请求 ID 时返回 false
+func (r *retryLinks) lookup(id string, now time.Time) (trace.SpanContext, int, bool) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[id]
+	if !ok || now.After(entry.expires) {
+		return trace.SpanContext{}, 0, false
+	}
+
+	entry.retries++
+	return entry.first, entry.retries, true
+}
+
+// remember 记住请求 ID 第一次请求的 span，窗口从第一次请求开始计算
+func (r *retryLinks) remember(id string, sc trace.SpanContext, now time.Time) {
+
+	if !sc.IsValid() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.entries[id]; ok && !now.After(entry.expires) {
+		return
+	}
+
+	if len(r.entries) >= maxRetryLinks {
+		for key, entry := range r.entries {
+			if now.After(entry.expires) {
+				delete(r.entries, key)
+			}
+		}
+		if len(r.entries) >= maxRetryLinks {
+			clear(r.entries)
+		}
+	}
+
+	r.entries[id] = &retryEntry{first: sc, expires: now.Add(r.window)}
+}
+
+// spanLinks 返回 server span 的链接，包括请求头中的链接和重试请求到第一次请求的链接
+func (x *Exchange) spanLinks(req *http.Request, suppliedID bool) []trace.Link {
+
+	e := x.e
+	links := headerLinks(req, e.spanLinkHeader)
+
+	if e.retryLinks == nil || !suppliedID {
+		return links
+	}
+
+	first, retries, ok := e.retryLinks.lookup(x.requestID, x.start)
+	if !ok {
+		return links
+	}
+
+	x.retries = retries
+	return append(links, trace.Link{
+		SpanContext: first,
+		Attributes: []attribute.KeyValue{
+			attribute.String("link.reason", linkReasonRetry),
+			attribute.Int("http.request.resend_count", retries),
+		},
+	})
+}
+
+// rememberSpan 记住调用方提供的请求 ID 第一次请求的 span
+func (x *Exchange) rememberSpan(suppliedID bool) {
+
+	if x.e.retryLinks != nil && suppliedID && x.retries == 0 {
+		x.e.retryLinks.remember(x.requestID, x.span.SpanContext(), x.start)
+	}
+}
+
+// retryAttr 返回重试次数属性
+func (e *RecordRequestLog) retryAttr(retries int) slog.Attr {
+
+	return slog.Int(e.attrKey("retry-count", "http.request.resend_count"), retries)
+}
@@ -0,0 +1,79 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestSpanLinks(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.SpanLinkHeader = "X-Linked-Trace"
+	cfg.RetryLinkWindow = "1m"
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/jobs", nil)
+	req.Header.Set("X-Linked-Trace", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01, invalid")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := rec.RequireSpan(t, http.MethodPost)
+	if len(span.Links) != 1 || span.Links[0].SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected a link from X-Linked-Trace, got %v", span.Links)
+	}
+	if !slices.Contains(span.Links[0].Attributes, attribute.String("link.reason", "linked")) {
+		t.Errorf("expected link.reason=linked, got %v", span.Links[0].Attributes)
+	}
+
+	// 相同请求 ID 的重试链接到第一次请求的 span
+	rec.Reset()
+	for range 3 {
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/jobs/1", nil)
+		req.Header.Set("X-Request-Id", "retry-1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans := rec.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	if len(spans[0].Links) != 0 {
+		t.Errorf("expected no links on the first attempt, got %v", spans[0].Links)
+	}
+	for i, span := range spans[1:] {
+		if len(span.Links) != 1 || span.Links[0].SpanContext.SpanID() != spans[0].SpanContext.SpanID() {
+			t.Fatalf("expected retry %d to link to the first attempt, got %v", i+1, span.Links)
+		}
+		if !slices.Contains(span.Attributes, attribute.Int("http.request.resend_count", i+1)) {
+			t.Errorf("expected http.request.resend_count=%d, got %v", i+1, span.Attributes)
+		}
+	}
+
+	records := rec.RequireRecords(t, 3)
+	if _, ok := recordrequestlogtest.Attr(records[0], "retry-count"); ok {
+		t.Errorf("expected no retry-count on the first attempt, got %v", records[0].Attrs)
+	}
+	if v, _ := recordrequestlogtest.Attr(records[2], "retry-count"); v.Int64() != 2 {
+		t.Errorf("expected retry-count=2, got %v", records[2].Attrs)
+	}
+
+	// 自动生成的请求 ID 不视为重试
+	rec.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "http://localhost/jobs/1", nil))
+	if span := rec.RequireSpan(t, http.MethodPut); len(span.Links) != 0 {
+		t.Errorf("expected no links for a generated request id, got %v", span.Links)
+	}
+}
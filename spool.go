@@ -0,0 +1,332 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 默认的本地缓冲大小上限和重放间隔
+const (
+	defaultSpoolMaxSize          = 64 << 20
+	defaultSpoolRetryInterval    = time.Second
+	defaultSpoolMaxRetryInterval = time.Minute
+)
+
+// spool 将导出失败的批次写入本地目录，每个批次一个文件，文件名按写入顺序递增。
+// 总大小超过上限时删除最早的批次
+type spool struct {
+	dir     string
+	maxSize int64
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// spoolBatch 缓冲文件的内容
+type spoolBatch struct {
+	Stream  string        `json:"stream"`
+	Records []spoolRecord `json:"records"`
+}
+
+// spoolRecord Record 的持久化形式，属性以字段集合保存
+type spoolRecord struct {
+	Time    time.Time      `json:"time"`
+	Level   slog.Level     `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	TraceID string         `json:"trace_id,omitempty"`
+	SpanID  string         `json:"span_id,omitempty"`
+}
+
+func newSpool(dir string, maxSize int64) (*spool, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if maxSize <= 0 {
+		maxSize = defaultSpoolMaxSize
+	}
+
+	return &spool{dir: dir, maxSize: maxSize}, nil
+}
+
+// sub 返回子目录中的缓冲，多路导出的各后端分别缓冲和重放，避免批次被重放到其他后端
+func (s *spool) sub(name string) (*spool, error) {
+
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(name, ".") == "" {
+		name = "_" + name
+	}
+
+	return newSpool(filepath.Join(s.dir, name), s.maxSize)
+}
+
+// write 写入一个批次，并按先进先出的顺序淘汰超出大小上限的批次
+func (s *spool) write(batch spoolBatch) error {
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.seq%1000000)
+
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return err
+	}
+
+	return s.evict()
+}
+
+// evict 删除最早的批次直到总大小不超过上限，调用方需要持有锁
+func (s *spool) evict() error {
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	for _, f := range files {
+		if total <= s.maxSize {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= f.size
+	}
+
+	return nil
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// files 按写入顺序返回全部批次文件
+func (s *spool) files() ([]spoolFile, error) {
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]spoolFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, spoolFile{name: entry.Name(), size: info.Size()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// oldest 返回最早写入的批次，没有缓冲的批次时 ok 为 false
+func (s *spool) oldest() (name string, batch spoolBatch, ok bool, err error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.files()
+	if err != nil || len(files) == 0 {
+		return "", batch, false, err
+	}
+
+	name = files[0].name
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return "", batch, false, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&batch); err != nil {
+		// 无法解析的批次直接丢弃，避免阻塞后续的重放
+		os.Remove(filepath.Join(s.dir, name))
+		return "", batch, false, fmt.Errorf("discard corrupt spool file %s: %w", name, err)
+	}
+
+	return name, batch, true, nil
+}
+
+func (s *spool) remove(name string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// replay 定时重放缓冲的批次，失败时按指数退避延长重试间隔，直到 stop 被关闭
+func (s *spool) replay(stop <-chan struct{}, initial, max time.Duration, fn func(ctx context.Context, batch spoolBatch) error, onError func(string, error)) {
+
+	delay := initial
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+
+		for {
+			name, batch, ok, err := s.oldest()
+			if err != nil {
+				onError("read spool", err)
+			}
+
+			if !ok {
+				delay = initial
+				break
+			}
+
+			if err := fn(context.Background(), batch); err != nil {
+				delay = min(delay*2, max)
+				break
+			}
+
+			if err := s.remove(name); err != nil {
+				onError("remove spool file", err)
+				break
+			}
+
+			delay = initial
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// newSpoolRecord 将 Record 转换为持久化形式
+func newSpoolRecord(ctx context.Context, record Record) spoolRecord {
+
+	r := spoolRecord{
+		Time:    record.Time,
+		Level:   record.Level,
+		Message: record.Message,
+		Attrs:   make(map[string]any, len(record.Attrs)),
+	}
+
+	for _, attr := range record.Attrs {
+		r.Attrs[attr.Key] = attrValue(attr.Value)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.TraceID = sc.TraceID().String()
+		r.SpanID = sc.SpanID().String()
+	}
+
+	return r
+}
+
+// record 将持久化形式还原为 Record 和携带原始 trace 信息的 context
+func (r spoolRecord) record(ctx context.Context, stream string) (context.Context, Record) {
+
+	record := Record{
+		Time:       r.Time,
+		Level:      r.Level,
+		Message:    r.Message,
+		StreamName: stream,
+	}
+
+	keys := make([]string, 0, len(r.Attrs))
+	for key := range r.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		record.Attrs = append(record.Attrs, slog.Attr{Key: key, Value: jsonValue(r.Attrs[key])})
+	}
+
+	traceID, err := trace.TraceIDFromHex(r.TraceID)
+	if err != nil {
+		return ctx, record
+	}
+
+	spanID, err := trace.SpanIDFromHex(r.SpanID)
+	if err != nil {
+		return ctx, record
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	return trace.ContextWithSpanContext(ctx, sc), record
+}
+
+// jsonValue 将 JSON 解码得到的值还原为 slog.Value
+func jsonValue(v any) slog.Value {
+
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return slog.Int64Value(i)
+		}
+		f, _ := v.Float64()
+		return slog.Float64Value(f)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		attrs := make([]slog.Attr, 0, len(v))
+		for _, key := range keys {
+			attrs = append(attrs, slog.Attr{Key: key, Value: jsonValue(v[key])})
+		}
+		return slog.GroupValue(attrs...)
+	default:
+		return slog.AnyValue(v)
+	}
+}
@@ -0,0 +1,157 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSpoolReplay(t *testing.T) {
+
+	var attempts atomic.Int32
+	received := make(chan []map[string]any, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// 前两次写入失败，模拟 OpenObserve 不可用
+		if attempts.Add(1) <= 2 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var records []map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&records); err != nil {
+			t.Error(err)
+		}
+		received <- records
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = server.URL
+	cfg.Organization = "default"
+	cfg.StreamName = "requests"
+	cfg.LogMaxBatchSize = 1
+	cfg.SpoolDir = dir
+	cfg.SpoolRetryInterval = "10ms"
+	cfg.SpoolMaxRetryInterval = "20ms"
+	// 关闭导出重试，写入失败时直接进入缓冲
+	cfg.ExportRetry = false
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case records := <-received:
+		if len(records) != 1 || records[0]["message"] != `{"id":1}` {
+			t.Fatalf("unexpected replayed records %v", records)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for spooled batch to be replayed")
+	}
+
+	// 重放成功后缓冲文件会被删除
+	deadline := time.Now().Add(time.Second)
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected spool to be empty, found %d files", len(entries))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSpoolPerSink(t *testing.T) {
+
+	failing := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	var archived atomic.Int32
+	archive := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var records []map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&records); err != nil {
+			t.Error(err)
+		}
+		archived.Add(int32(len(records)))
+	}))
+	defer archive.Close()
+
+	dir := t.TempDir()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = failing.URL
+	cfg.Organization = "default"
+	cfg.StreamName = "requests"
+	cfg.LogMaxBatchSize = 1
+	cfg.SpoolDir = dir
+	cfg.SpoolRetryInterval = "10ms"
+	cfg.SpoolMaxRetryInterval = "20ms"
+	cfg.ExportRetry = false
+	cfg.Sinks = []recordrequestlog.SinkConfig{{Name: "archive", Backend: recordrequestlog.BackendOpenObserve, Endpoint: archive.URL}}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 顶层后端缓冲的批次只由顶层后端重放，不会被 archive 后端重复导出
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spooled := 0
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				spooled++
+			}
+		}
+		if archived.Load() == 1 && spooled == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 1 archived record and 1 spooled batch, got %d and %d", archived.Load(), spooled)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := archived.Load(); n != 1 {
+		t.Errorf("expected the spooled batch not to be replayed to archive, got %d records", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive")); err != nil {
+		t.Errorf("expected a spool directory for archive: %v", err)
+	}
+}
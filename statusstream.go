@@ -0,0 +1,118 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 按状态码选择的请求体记录级别
+const (
+	// CaptureFull 记录按 capture_* 配置读取的请求体内容
+	CaptureFull = "full"
+	// CaptureMetadata 只记录请求体的内容类型和大小
+	CaptureMetadata = "metadata"
+	// CaptureNone 不记录任何请求体信息
+	CaptureNone = "none"
+)
+
+// StatusStreamConfig 按响应状态码选择写入的 stream 和请求体的记录级别
+type StatusStreamConfig struct {
+	// 状态码类别或具体状态码，例如 "5xx"、"4xx"、"429"
+	Status string `yaml:"status"`
+	// 为空时使用路由或顶层配置的 stream
+	StreamName string `yaml:"stream_name,omitempty"`
+	// full（默认）、metadata 或 none
+	Capture string `yaml:"capture,omitempty"`
+}
+
+// statusStream 解析后的状态码规则
+type statusStream struct {
+	// 状态码类别（1 到 5）或具体状态码
+	class, code int
+	streamName  string
+	capture     string
+}
+
+// newStatusStreams 解析状态码规则，name 为错误信息中使用的字段名
+func newStatusStreams(name string, configs []StatusStreamConfig) ([]statusStream, error) {
+
+	streams := make([]statusStream, 0, len(configs))
+
+	for i, config := range configs {
+		s := statusStream{streamName: config.StreamName, capture: config.Capture}
+
+		var ok bool
+		if s.class, s.code, ok = parseStatusMatch(config.Status); !ok {
+			return nil, fmt.Errorf("invalid %s[%d].status %q: must be a status class such as 5xx or a status code", name, i, config.Status)
+		}
+
+		switch s.capture {
+		case "":
+			s.capture = CaptureFull
+		case CaptureFull, CaptureMetadata, CaptureNone:
+		default:
+			return nil, fmt.Errorf("invalid %s[%d].capture %q", name, i, config.Capture)
+		}
+
+		streams = append(streams, s)
+	}
+
+	return streams, nil
+}
+
+// parseStatusMatch 解析状态码类别（例如 "5xx"，返回 1 到 5）或具体状态码
+func parseStatusMatch(value string) (class, code int, ok bool) {
+
+	status := strings.ToLower(value)
+	if len(status) == 3 && strings.HasSuffix(status, "xx") && status[0] >= '1' && status[0] <= '5' {
+		return int(status[0] - '0'), 0, true
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 100 || code > 599 {
+		return 0, 0, false
+	}
+
+	return 0, code, true
+}
+
+// statusStream 返回第一个匹配状态码的规则，没有匹配时返回 nil
+func (s *routeSettings) statusStream(status int) *statusStream {
+
+	for i, rule := range s.statusStreams {
+		if rule.code == status || (rule.code == 0 && rule.class == status/100) {
+			return &s.statusStreams[i]
+		}
+	}
+
+	return nil
+}
+
+// body 按记录级别返回记录使用的请求体信息
+func (rule *statusStream) body(body *capturedBody) *capturedBody {
+
+	switch {
+	case body == nil || rule.capture == CaptureFull:
+		return body
+	case rule.capture == CaptureNone:
+		return nil
+	}
+
+	// 只保留内容类型、大小和编码，multipart 的解析结果由原始请求体负责结束
+	metadata := &capturedBody{
+		contentType:     body.contentType,
+		size:            body.size,
+		contentEncoding: body.contentEncoding,
+		digest:          body.digest,
+		soap:            body.soap,
+		metadataOnly:    true,
+	}
+	if body.graphql != nil {
+		op := *body.graphql
+		op.variables = ""
+		metadata.graphql = &op
+	}
+
+	return metadata
+}
@@ -0,0 +1,72 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+func TestStatusStreams(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.StreamName = "default"
+	cfg.StatusStreams = []recordrequestlog.StatusStreamConfig{
+		{Status: "5xx", StreamName: "errors", Capture: recordrequestlog.CaptureFull},
+		{Status: "404", Capture: recordrequestlog.CaptureNone},
+		{Status: "2xx", StreamName: "access", Capture: recordrequestlog.CaptureMetadata},
+	}
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/fail":
+			rw.WriteHeader(http.StatusBadGateway)
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+		case "/conflict":
+			rw.WriteHeader(http.StatusConflict)
+		}
+	}))
+
+	tests := []struct {
+		path    string
+		stream  string
+		message string
+		size    bool
+	}{
+		{path: "/ok", stream: "access", size: true},
+		{path: "/fail", stream: "errors", message: `{"id":1}`, size: true},
+		{path: "/missing", stream: "default"},
+		{path: "/conflict", stream: "default", message: `{"id":1}`, size: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimPrefix(tt.path, "/"), func(t *testing.T) {
+			rec.Reset()
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost"+tt.path, strings.NewReader(`{"id":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			record := rec.RequireRecords(t, 1)[0]
+			if record.StreamName != tt.stream {
+				t.Errorf("expected stream %q, got %q", tt.stream, record.StreamName)
+			}
+			if record.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, record.Message)
+			}
+			if _, ok := recordrequestlogtest.Attr(record, "body-size"); ok != tt.size {
+				t.Errorf("expected body-size present %v", tt.size)
+			}
+		})
+	}
+}
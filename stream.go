@@ -0,0 +1,125 @@
+package recordrequestlog
+
+import (
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 长连接类型
+const (
+	streamWebSocket = "websocket"
+	streamSSE       = "sse"
+)
+
+// 长连接记录的事件
+const (
+	eventConnectionEstablished = "connection-established"
+	eventConnectionClosed      = "connection-closed"
+)
+
+// countingConn 统计接管的连接收发的字节数
+type countingConn struct {
+	net.Conn
+	read    atomic.Int64
+	written atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// isWebSocketUpgrade 判断请求是否为 WebSocket 升级
+func isWebSocketUpgrade(req *http.Request) bool {
+
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// statusWritten 在首次写入状态码时识别 WebSocket 和 SSE 长连接，并记录连接建立
+func (x *Exchange) statusWritten() {
+
+	switch {
+	case x.rw.status == http.StatusSwitchingProtocols && isWebSocketUpgrade(x.req):
+		x.stream = streamWebSocket
+	case x.rw.status == http.StatusOK && isEventStream(x.rw.Header().Get("Content-Type")):
+		x.stream = streamSSE
+	default:
+		return
+	}
+
+	// 连接建立记录代替尚未写入的请求记录
+	x.resolvePendingRecord()
+	x.stopWatchHung()
+	x.emitStream(eventConnectionEstablished, x.rw.status)
+}
+
+// emitStream 写入长连接的建立或关闭记录。长连接的耗时不视为慢请求
+func (x *Exchange) emitStream(event string, status int) {
+
+	e := x.e
+
+	if !x.sampled || !x.settings.shouldLog(status, 0) {
+		return
+	}
+
+	record := e.completedRecord(x.req, x.body, x.settings, x.start, status, time.Since(x.start), false)
+	record.Attrs = append(record.Attrs,
+		slog.String(requestIDKey, x.requestID),
+		slog.String(e.attrKey("event", "event.name"), event),
+		slog.String(e.attrKey("connection-type", "http.connection.type"), x.stream),
+	)
+
+	if x.route != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("route", "http.route"), x.route))
+	}
+
+	if event == eventConnectionClosed {
+		sent, received := x.rw.size, int64(0)
+		if conn := x.rw.conn; conn != nil {
+			sent, received = conn.written.Load(), conn.read.Load()
+		}
+
+		record.Attrs = append(record.Attrs,
+			slog.Int64(e.attrKey("bytes-sent", "network.io.sent"), sent),
+			slog.Int64(e.attrKey("bytes-received", "network.io.received"), received),
+		)
+	}
+
+	if e.logFormat != LogFormatSemConv {
+		record.Message = event
+	}
+
+	e.emit(x.req.Context(), record)
+}
+
+func isEventStream(contentType string) bool {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
@@ -0,0 +1,62 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"testing"
+	"time"
+)
+
+func TestServerSentEvents(t *testing.T) {
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			rw.Write([]byte("data: tick\n\n"))
+			rw.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	for mode, want := range map[string]int{"all": 2, "slow": 0} {
+		t.Run(mode, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "requests.log")
+			cfg := recordrequestlog.CreateConfig()
+			cfg.Backend = recordrequestlog.BackendFile
+			cfg.FilePath = path
+			cfg.LogMode = mode
+			cfg.SlowThreshold = "1ms"
+
+			handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/events", nil))
+
+			records := readRecords(t, path)
+			if len(records) != want {
+				t.Fatalf("expected %d records, got %d", want, len(records))
+			}
+
+			if want == 0 {
+				return
+			}
+
+			if records[0]["event"] != "connection-established" || records[0]["connection-type"] != "sse" {
+				t.Errorf("unexpected established record %v", records[0])
+			}
+
+			closed := records[1]
+			if closed["event"] != "connection-closed" || closed["bytes-sent"] != float64(3*len("data: tick\n\n")) {
+				t.Errorf("unexpected closed record %v", closed)
+			}
+			if d, _ := closed["duration-ms"].(float64); d < 15 {
+				t.Errorf("expected total duration of the stream, got %v", closed["duration-ms"])
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+func (e *RecordRequestLog) setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, err error) {
+
+	var shutdownFuncs []func(context.Context) error
+
+	shutdown = func(ctx context.Context) error {
+		var err error
+
+		for _, fn := range shutdownFuncs {
+			err = errors.Join(err, fn(ctx))
+		}
+
+		shutdownFuncs = nil
+		return err
+	}
+
+	handleErr := func(inErr error) {
+		err = errors.Join(inErr, shutdown(ctx))
+	}
+
+	// provider 只属于当前实例，不修改进程内的全局状态，除非开启了 set_global_providers
+	if e.setGlobalProviders {
+		otel.SetTextMapPropagator(e.propagator)
+	}
+
+	var flushFuncs []func(context.Context) error
+
+	// 设置 trace provider，关闭 trace 时使用 noop 实现，仍然传播上游的 trace 上下文
+	e.tracerProvider = tracenoop.NewTracerProvider()
+	if e.enableTraces {
+		var traceProvider *trace.TracerProvider
+		if traceProvider, err = e.newTraceProvider(e.streamName); err != nil {
+			handleErr(err)
+			return
+		}
+
+		shutdownFuncs = append(shutdownFuncs, traceProvider.Shutdown)
+		flushFuncs = append(flushFuncs, traceProvider.ForceFlush)
+		e.tracerProvider = traceProvider
+	}
+
+	e.meterProvider = noop.NewMeterProvider()
+	if e.enableMetrics {
+		if e.exemplars {
+			enableExemplars()
+		}
+
+		var meterProvider *sdkmetric.MeterProvider
+		if meterProvider, err = e.newMeterProvider(e.streamName); err != nil {
+			handleErr(err)
+			return
+		}
+
+		shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+		flushFuncs = append(flushFuncs, meterProvider.ForceFlush)
+		e.meterProvider = meterProvider
+
+		if e.metricsHandler != nil && e.prometheusAddress != "" {
+			var stop func(context.Context) error
+			if stop, err = e.servePrometheus(); err != nil {
+				handleErr(err)
+				return
+			}
+			shutdownFuncs = append(shutdownFuncs, stop)
+		}
+
+		// Go 运行时和主机指标通过同一个 MeterProvider 导出
+		if e.runtimeMetrics {
+			err = runtime.Start(
+				runtime.WithMeterProvider(meterProvider),
+				runtime.WithMinimumReadMemStatsInterval(e.metricInterval),
+			)
+			if err != nil {
+				handleErr(err)
+				return
+			}
+		}
+
+		if e.hostMetrics {
+			if err = host.Start(host.WithMeterProvider(meterProvider)); err != nil {
+				handleErr(err)
+				return
+			}
+		}
+	}
+
+	if e.setGlobalProviders {
+		otel.SetTracerProvider(e.tracerProvider)
+		otel.SetMeterProvider(e.meterProvider)
+	}
+
+	e.flush = func(ctx context.Context) error {
+		var err error
+		for _, fn := range flushFuncs {
+			err = errors.Join(err, fn(ctx))
+		}
+		return err
+	}
+
+	return
+}
+
+// TracerProvider 返回中间件使用的 TracerProvider，应用可以用它创建与请求 span 同属一个 provider 的 span
+func (e *RecordRequestLog) TracerProvider() oteltrace.TracerProvider {
+	return e.tracerProvider
+}
+
+// MeterProvider 返回中间件使用的 MeterProvider
+func (e *RecordRequestLog) MeterProvider() metric.MeterProvider {
+	return e.meterProvider
+}
+
+func (e *RecordRequestLog) newTraceProvider(streamName string) (*trace.TracerProvider, error) {
+
+	if e.exporters != nil && e.exporters.SpanExporter != nil {
+		return trace.NewTracerProvider(
+			trace.WithSyncer(e.exporters.SpanExporter),
+			trace.WithResource(e.resource),
+			trace.WithSampler(reloadableSampler{rules: e.rules}),
+		), nil
+	}
+
+	exp, err := e.newFailoverSpanExporter(func(endpoint string) (trace.SpanExporter, error) {
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpointURL(endpoint),
+			otlptracegrpc.WithInsecure(),
+			otlptracegrpc.WithHeaders(e.exportHeaders(streamName)),
+			// 由 retryPolicy 的拦截器按配置的状态码重试
+			otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+			otlptracegrpc.WithDialOption(e.retry.dialOptions()...),
+			otlptracegrpc.WithDialOption(e.proxy.dialOptions()...),
+		}
+
+		if e.compression == CompressionGzip {
+			opts = append(opts, otlptracegrpc.WithCompressor(CompressionGzip))
+		}
+
+		return otlptracegrpc.New(context.Background(), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	spanExporter := &breakerSpanExporter{SpanExporter: exp, breaker: e.breaker, onExport: e.exportResult("traces")}
+
+	batchOptions := []trace.BatchSpanProcessorOption{
+		trace.WithBatchTimeout(e.traceBatchTimeout),
+	}
+
+	if e.traceMaxBatchSize > 0 {
+		batchOptions = append(batchOptions, trace.WithMaxExportBatchSize(e.traceMaxBatchSize))
+	}
+
+	traceProvider := trace.NewTracerProvider(
+		trace.WithBatcher(spanExporter, batchOptions...),
+		trace.WithResource(e.resource),
+		trace.WithSampler(reloadableSampler{rules: e.rules}),
+	)
+	return traceProvider, nil
+}
+
+func (e *RecordRequestLog) newMeterProvider(streamName string) (*sdkmetric.MeterProvider, error) {
+
+	if e.exporters != nil && e.exporters.MetricReader != nil {
+		return sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(e.resource),
+			sdkmetric.WithReader(e.exporters.MetricReader),
+			sdkmetric.WithView(metricView(e.metricViews)),
+		), nil
+	}
+
+	var reader sdkmetric.Reader
+	switch e.metricsBackend {
+	case MetricsBackendPrometheus:
+		var err error
+		if reader, err = e.newPrometheusReader(); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if reader, err = e.newOTLPMetricReader(streamName); err != nil {
+			return nil, err
+		}
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(e.resource),
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(metricView(e.metricViews)),
+	)
+
+	return meterProvider, nil
+}
+
+// newOTLPMetricReader 创建按 metric_interval 推送 OTLP 指标的 reader
+func (e *RecordRequestLog) newOTLPMetricReader(streamName string) (sdkmetric.Reader, error) {
+
+	exp, err := e.newFailoverMetricExporter(func(endpoint string) (sdkmetric.Exporter, error) {
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpointURL(endpoint),
+			otlpmetricgrpc.WithInsecure(),
+			otlpmetricgrpc.WithHeaders(e.exportHeaders(streamName)),
+			otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{Enabled: false}),
+			otlpmetricgrpc.WithDialOption(e.retry.dialOptions()...),
+			otlpmetricgrpc.WithDialOption(e.proxy.dialOptions()...),
+		}
+
+		if e.compression == CompressionGzip {
+			opts = append(opts, otlpmetricgrpc.WithCompressor(CompressionGzip))
+		}
+
+		return otlpmetricgrpc.New(context.Background(), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metricExporter := &breakerMetricExporter{Exporter: exp, breaker: e.breaker, onExport: e.exportResult("metrics")}
+
+	return sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(e.metricInterval)), nil
+}
+
+// exemplarEnv 当前版本的 SDK 中 exemplar 仍是实验功能，只能通过环境变量开启
+const exemplarEnv = "OTEL_GO_X_EXEMPLAR"
+
+// enableExemplars 开启 exemplar，SDK 在创建指标时读取环境变量；已经显式设置时不覆盖
+func enableExemplars() {
+	if _, ok := os.LookupEnv(exemplarEnv); !ok {
+		os.Setenv(exemplarEnv, "true")
+	}
+}
+
+// emitDurationBuckets 导出记录耗时的分桶，正常情况下在微秒到毫秒级
+var emitDurationBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// defaultSizeBuckets 请求体和响应体大小的默认分桶（字节）
+var defaultSizeBuckets = []float64{0, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864}
+
+// parseSizeBuckets 检查 size_buckets 是否递增，为空时使用默认分桶
+func parseSizeBuckets(buckets []float64) ([]float64, error) {
+
+	if len(buckets) == 0 {
+		return defaultSizeBuckets, nil
+	}
+
+	if err := checkBuckets("size_buckets", buckets); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// checkBuckets 检查直方图的分桶上界是否递增
+func checkBuckets(name string, buckets []float64) error {
+
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("invalid %s %v: boundaries must be increasing", name, buckets)
+		}
+	}
+
+	return nil
+}
+
+// instrumentationName 中间件自身的 tracer 和 meter 名称
+const instrumentationName = "recordrequestlog"
+
+// newInstruments 创建中间件的 tracer 和指标，创建失败的指标使用 noop 实现
+func (e *RecordRequestLog) newInstruments() error {
+
+	// 遥测初始化失败时 provider 为空，使用 noop 实现
+	if e.tracerProvider == nil {
+		e.tracerProvider = tracenoop.NewTracerProvider()
+	}
+	if e.meterProvider == nil {
+		e.meterProvider = noop.NewMeterProvider()
+	}
+
+	e.tracer = e.tracerProvider.Tracer(instrumentationName)
+	meter := e.meterProvider.Meter(instrumentationName)
+
+	var err error
+
+	e.requestDuration = newFloat64Histogram(meter, &err, "http.server.request.duration",
+		"Duration of HTTP server requests.", "s")
+	e.requestBodySize = newInt64Histogram(meter, &err, "http.server.request.body.size",
+		"Size of HTTP server request bodies.", "By",
+		metric.WithExplicitBucketBoundaries(e.sizeBuckets...))
+	e.responseBodySize = newInt64Histogram(meter, &err, "http.server.response.body.size",
+		"Size of HTTP server response bodies.", "By",
+		metric.WithExplicitBucketBoundaries(e.sizeBuckets...))
+	e.clientRequestDuration = newFloat64Histogram(meter, &err, "http.client.request.duration",
+		"Duration of HTTP client requests.", "s")
+	e.rpcServerDuration = newFloat64Histogram(meter, &err, "rpc.server.duration",
+		"Duration of inbound RPCs.", "ms")
+	e.rpcClientDuration = newFloat64Histogram(meter, &err, "rpc.client.duration",
+		"Duration of outbound RPCs.", "ms")
+	e.droppedRecords = newInt64Counter(meter, &err, "recordrequestlog.records.dropped",
+		"Number of request records dropped before export.", "{record}")
+	e.emitDuration = newFloat64Histogram(meter, &err, "recordrequestlog.emit.duration",
+		"Time the request path spent emitting request records.", "s",
+		metric.WithExplicitBucketBoundaries(emitDurationBuckets...))
+	e.emitTimeouts = newInt64Counter(meter, &err, "recordrequestlog.emit.timeouts",
+		"Number of request records whose emit exceeded export_timeout.", "{record}")
+	e.breakerTrips = newInt64Counter(meter, &err, "recordrequestlog.exporter.circuit_breaker.trips",
+		"Number of times the exporter circuit breaker opened.", "{trip}")
+	e.failovers = newInt64Counter(meter, &err, "recordrequestlog.exporter.failovers",
+		"Number of times OTLP export switched to another endpoint.", "{failover}")
+	e.recordsEmitted = newInt64Counter(meter, &err, "recordrequestlog.records.emitted",
+		"Number of request records handed to the log backend.", "{record}")
+	e.exportFailures = newInt64Counter(meter, &err, "recordrequestlog.exporter.failures",
+		"Number of failed export requests.", "{request}")
+	e.redactions = newInt64Counter(meter, &err, "recordrequestlog.redactions",
+		"Number of values redacted from request records.", "{value}")
+	e.shadowRequests = newInt64Counter(meter, &err, "recordrequestlog.shadow.requests",
+		"Number of mirrored requests by result (sent, failed, dropped or skipped).", "{request}")
+	e.overhead = newFloat64Histogram(meter, &err, "recordrequestlog.overhead.duration",
+		"Latency added to each request by the middleware, excluding the next handler.", "s",
+		metric.WithExplicitBucketBoundaries(emitDurationBuckets...))
+	e.appRequests = newInt64Counter(meter, &err, "recordrequestlog.app.requests",
+		"Number of requests per calling application.", "{request}")
+	e.appRequestBytes = newInt64Counter(meter, &err, "recordrequestlog.app.request.size",
+		"Request body bytes received per calling application.", "By")
+	e.appResponseBytes = newInt64Counter(meter, &err, "recordrequestlog.app.response.size",
+		"Response body bytes sent per calling application.", "By")
+	e.hungRequests = newInt64Counter(meter, &err, "recordrequestlog.requests.hung",
+		"Number of requests that exceeded hung_threshold without completing.", "{request}")
+	e.sloRequests = newInt64Counter(meter, &err, "recordrequestlog.slo.requests",
+		"Number of requests per SLO by Apdex zone and whether they violated the SLO.", "{request}")
+	e.truncations = newInt64Counter(meter, &err, "recordrequestlog.truncations",
+		"Number of values truncated or dropped to keep records within the size budget.", "{value}")
+
+	// 每次采集指标时读取各路由生效的采样率
+	_, samplingErr := meter.Float64ObservableGauge("recordrequestlog.sampling.rate",
+		metric.WithDescription("Effective log sampling probability per route under adaptive sampling."),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			for _, settings := range e.rules.Load().adaptiveSamplers() {
+				o.Observe(settings.effectiveRate(), metric.WithAttributes(attribute.String("route", settings.adaptive.name)))
+			}
+			return nil
+		}))
+	err = errors.Join(err, samplingErr)
+
+	// 达标比例与 recordrequestlog.slo.requests 一起计算错误预算的消耗速度
+	_, objectiveErr := meter.Float64ObservableGauge("recordrequestlog.slo.objective",
+		metric.WithDescription("Target ratio of requests meeting the SLO; one minus the objective is the error budget."),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			for _, s := range e.rules.Load().slos() {
+				o.Observe(s.objective, metric.WithAttributes(attribute.String("slo.name", s.name)))
+			}
+			return nil
+		}))
+	err = errors.Join(err, objectiveErr)
+
+	return err
+}
+
+// exportResult 返回记录一类信号导出结果的函数，导出失败时计入导出失败指标
+func (e *RecordRequestLog) exportResult(signal string) func(ctx context.Context, err error) {
+	return func(ctx context.Context, err error) {
+		if err != nil {
+			e.exportFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+		}
+	}
+}
+
+func newInt64Counter(meter metric.Meter, errs *error, name, description, unit string) metric.Int64Counter {
+
+	counter, err := meter.Int64Counter(name, metric.WithDescription(description), metric.WithUnit(unit))
+	if err != nil {
+		*errs = errors.Join(*errs, err)
+		return noop.Int64Counter{}
+	}
+
+	return counter
+}
+
+func newFloat64Histogram(meter metric.Meter, errs *error, name, description, unit string, opts ...metric.Float64HistogramOption) metric.Float64Histogram {
+
+	opts = append(opts, metric.WithDescription(description), metric.WithUnit(unit))
+	histogram, err := meter.Float64Histogram(name, opts...)
+	if err != nil {
+		*errs = errors.Join(*errs, err)
+		return noop.Float64Histogram{}
+	}
+
+	return histogram
+}
+
+func newInt64Histogram(meter metric.Meter, errs *error, name, description, unit string, opts ...metric.Int64HistogramOption) metric.Int64Histogram {
+
+	opts = append(opts, metric.WithDescription(description), metric.WithUnit(unit))
+	histogram, err := meter.Int64Histogram(name, opts...)
+	if err != nil {
+		*errs = errors.Join(*errs, err)
+		return noop.Int64Histogram{}
+	}
+
+	return histogram
+}
+
+// exportHeaders 导出请求携带的自定义请求头以及认证和 stream 信息，后者不为空时优先
+func (e *RecordRequestLog) exportHeaders(streamName string) map[string]string {
+
+	headers := make(map[string]string, len(e.exporterHeaders)+3)
+	for key, value := range e.exporterHeaders {
+		headers[key] = value
+	}
+
+	for key, value := range map[string]string{
+		"Authorization": e.authorization,
+		"organization":  e.organization,
+		"stream-name":   streamName,
+	} {
+		if value != "" {
+			headers[key] = value
+		}
+	}
+
+	return headers
+}
@@ -0,0 +1,94 @@
+package recordrequestlog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSelfMetrics(t *testing.T) {
+
+	cfg := CreateConfig()
+	cfg.Backend = BackendStdout
+	cfg.EnableTraces = false
+	cfg.EnableMetrics = false
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 使用手动采集的 MeterProvider 重新创建指标
+	reader := sdkmetric.NewManualReader()
+	e := handler.(*RecordRequestLog)
+	e.meterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if err := e.newInstruments(); err != nil {
+		t.Fatal(err)
+	}
+	e.sink = countingSink{LogSink: noopSink{}, exported: &e.state.exported, emitted: e.recordsEmitted}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/api?token=secret", nil))
+	e.exportResult("logs")(context.Background(), errors.New("unavailable"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	for _, name := range []string{"recordrequestlog.records.emitted", "recordrequestlog.redactions", "recordrequestlog.exporter.failures"} {
+		sum, ok := metrics[name].(metricdata.Sum[int64])
+		if !ok || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+			t.Errorf("unexpected %s: %+v", name, metrics[name])
+		}
+	}
+
+	overhead, ok := metrics["recordrequestlog.overhead.duration"].(metricdata.Histogram[float64])
+	if !ok || len(overhead.DataPoints) != 1 || overhead.DataPoints[0].Count != 1 {
+		t.Errorf("unexpected recordrequestlog.overhead.duration: %+v", metrics["recordrequestlog.overhead.duration"])
+	}
+}
+
+func TestGlobalProviders(t *testing.T) {
+
+	global := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(global)
+
+	for _, set := range []bool{false, true} {
+		cfg := CreateConfig()
+		cfg.Backend = BackendStdout
+		cfg.EnableMetrics = false
+		cfg.SetGlobalProviders = set
+
+		e, err := newRecordRequestLog(http.NotFoundHandler(), cfg, "demo-plugin", &TestExporters{SpanExporter: tracetest.NewInMemoryExporter()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer e.Shutdown(context.Background())
+
+		if _, ok := e.TracerProvider().(*sdktrace.TracerProvider); !ok {
+			t.Fatalf("expected an instance tracer provider, got %T", e.TracerProvider())
+		}
+
+		// 默认不修改全局 provider
+		if installed := otel.GetTracerProvider() == e.TracerProvider(); installed != set {
+			t.Fatalf("set_global_providers=%v: expected global provider installed=%v", set, set)
+		}
+	}
+}
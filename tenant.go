@@ -0,0 +1,157 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// stream_name 中的租户占位符
+const tenantPlaceholder = "{tenant}"
+
+const (
+	defaultTenant = "default"
+	// 租户名的最大长度，超出部分截断
+	maxTenantLength = 64
+	// 默认最多记录的租户数
+	defaultMaxTenants = 100
+)
+
+// tenantResolver 从请求头或 token 声明中解析租户，用于按租户计算 stream。
+// 请求头优先，都没有时使用 fallback。每个租户对应一个 stream，OTLP 后端为每个 stream 创建导出端，
+// 不在 allowed 中的租户和超过 max 个之后出现的新租户使用 fallback，避免客户端通过任意租户耗尽内存和连接
+type tenantResolver struct {
+	header   string
+	claim    string
+	fallback string
+	jwt      *jwtExtractor
+	allowed  []string
+	max      int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// resolve 返回请求的租户，只保留字母、数字、下划线和连字符
+func (r *tenantResolver) resolve(req *http.Request) string {
+
+	var tenant string
+
+	if r.header != "" {
+		tenant = req.Header.Get(r.header)
+	}
+
+	if tenant == "" && r.claim != "" {
+		if claims, ok, err := r.jwt.requestClaims(req); ok && err == nil {
+			if v, ok := claims[r.claim]; ok {
+				tenant = claimValue(v).String()
+			}
+		}
+	}
+
+	tenant = sanitizeTenant(tenant)
+	if tenant == "" || !r.admit(tenant) {
+		return r.fallback
+	}
+
+	return tenant
+}
+
+// admit 判断是否可以使用租户，没有配置 tenants 时前 max 个出现的租户可以使用
+func (r *tenantResolver) admit(tenant string) bool {
+
+	if len(r.allowed) > 0 {
+		return slices.Contains(r.allowed, tenant)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.seen[tenant]; ok {
+		return true
+	}
+	if len(r.seen) >= r.max {
+		return false
+	}
+	r.seen[tenant] = struct{}{}
+
+	return true
+}
+
+// setStream 设置记录写入的 stream，配置了租户时同时设置记录的租户并替换 stream 中的租户占位符
+func (e *RecordRequestLog) setStream(record *Record, streamName string, req *http.Request) {
+
+	record.StreamName = streamName
+	if e.tenant == nil {
+		return
+	}
+
+	record.Tenant = e.tenant.resolve(req)
+	record.StreamName = strings.ReplaceAll(streamName, tenantPlaceholder, record.Tenant)
+}
+
+func sanitizeTenant(tenant string) string {
+
+	tenant = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(tenant))
+
+	if len(tenant) > maxTenantLength {
+		tenant = tenant[:maxTenantLength]
+	}
+
+	return tenant
+}
+
+// newTenantResolver 根据配置生成租户解析器，没有配置租户来源时返回 nil
+func newTenantResolver(config *Config, keys *jwks) (*tenantResolver, error) {
+
+	if config.TenantHeader == "" && config.TenantClaim == "" {
+		return nil, nil
+	}
+
+	fallback := config.DefaultTenant
+	if fallback == "" {
+		fallback = defaultTenant
+	}
+
+	if sanitizeTenant(fallback) != fallback {
+		return nil, fmt.Errorf("invalid default_tenant %q", config.DefaultTenant)
+	}
+
+	// 未校验签名的 token 可以任意伪造
+	if config.TenantClaim != "" && config.JWTJWKSURL == "" {
+		return nil, fmt.Errorf("tenant_claim requires jwt_jwks_url to verify the token signature")
+	}
+
+	for _, tenant := range config.Tenants {
+		if tenant == "" || sanitizeTenant(tenant) != tenant {
+			return nil, fmt.Errorf("invalid tenants entry %q", tenant)
+		}
+	}
+
+	if config.MaxTenants < 0 {
+		return nil, fmt.Errorf("invalid max_tenants %d: must not be negative", config.MaxTenants)
+	}
+	max := config.MaxTenants
+	if max == 0 {
+		max = defaultMaxTenants
+	}
+
+	return &tenantResolver{
+		header:   config.TenantHeader,
+		claim:    config.TenantClaim,
+		fallback: fallback,
+		jwt:      newJWTExtractor(nil, keys),
+		allowed:  config.Tenants,
+		max:      max,
+		seen:     make(map[string]struct{}),
+	}, nil
+}
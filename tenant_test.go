@@ -0,0 +1,118 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"testing"
+	"time"
+)
+
+func TestTenantStream(t *testing.T) {
+
+	paths := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		paths <- req.URL.Path
+	}))
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = server.URL
+	cfg.Organization = "default"
+	cfg.StreamName = "requests-{tenant}"
+	cfg.TenantHeader = "X-Tenant"
+	cfg.TenantClaim = "tenant_id"
+	cfg.JWTJWKSURL = newJWKSServer(t, key).URL
+	cfg.MaxTenants = 3
+	cfg.LogMaxBatchSize = 1
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header, token, want string
+	}{
+		{header: "acme", want: "/api/default/requests-acme/_json"},
+		{token: signToken(t, key, map[string]any{"tenant_id": "globex"}), want: "/api/default/requests-globex/_json"},
+		{token: signToken(t, nil, map[string]any{"tenant_id": "forged"}), want: "/api/default/requests-default/_json"},
+		{header: "../etc", want: "/api/default/requests-___etc/_json"},
+		{want: "/api/default/requests-default/_json"},
+		// 超过 max_tenants 后新租户使用 default_tenant
+		{header: "initech", want: "/api/default/requests-default/_json"},
+		{header: "acme", want: "/api/default/requests-acme/_json"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Tenant", tt.header)
+		}
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		select {
+		case got := <-paths:
+			if got != tt.want {
+				t.Errorf("expected ingest path %q, got %q", tt.want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for ingest request")
+		}
+	}
+}
+
+func TestTenantAllowlist(t *testing.T) {
+
+	paths := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		paths <- req.URL.Path
+	}))
+	defer server.Close()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendOpenObserve
+	cfg.Endpoint = server.URL
+	cfg.Organization = "default"
+	cfg.StreamName = "requests-{tenant}"
+	cfg.TenantHeader = "X-Tenant"
+	cfg.Tenants = []string{"acme"}
+	cfg.LogMaxBatchSize = 1
+
+	handler, err := recordrequestlog.New(context.Background(), http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tenant, want := range map[string]string{
+		"acme":   "/api/default/requests-acme/_json",
+		"globex": "/api/default/requests-default/_json",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		select {
+		case got := <-paths:
+			if got != want {
+				t.Errorf("expected ingest path %q for tenant %s, got %q", want, tenant, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for ingest request")
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package recordrequestlog
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// 请求开始和结束时间的精度
+const (
+	TimestampPrecisionSecond      = "s"
+	TimestampPrecisionMillisecond = "ms"
+	TimestampPrecisionMicrosecond = "us"
+	TimestampPrecisionNanosecond  = "ns"
+)
+
+// timestampLayouts 各精度对应的 RFC3339 格式，小数部分固定位数，便于按字符串排序
+var timestampLayouts = map[string]string{
+	TimestampPrecisionSecond:      "2006-01-02T15:04:05Z07:00",
+	TimestampPrecisionMillisecond: "2006-01-02T15:04:05.000Z07:00",
+	TimestampPrecisionMicrosecond: "2006-01-02T15:04:05.000000Z07:00",
+	TimestampPrecisionNanosecond:  "2006-01-02T15:04:05.000000000Z07:00",
+}
+
+// parseTimestampPrecision 返回精度对应的时间格式，为空时使用纳秒
+func parseTimestampPrecision(precision string) (string, error) {
+
+	if precision == "" {
+		precision = TimestampPrecisionNanosecond
+	}
+
+	layout, ok := timestampLayouts[precision]
+	if !ok {
+		return "", fmt.Errorf("invalid timestamp_precision %q: must be s, ms, us or ns", precision)
+	}
+
+	return layout, nil
+}
+
+// timestampAttrs 返回请求的开始和结束时间（UTC）。结束时间由开始时间加上单调时钟测得的耗时得出，
+// 处理期间系统时间被调整时两者之差仍与耗时一致
+func (e *RecordRequestLog) timestampAttrs(start time.Time, duration time.Duration) []slog.Attr {
+
+	return []slog.Attr{
+		slog.String(e.attrKey("start-time", "http.request.start_time"), start.UTC().Format(e.timestampLayout)),
+		slog.String(e.attrKey("end-time", "http.request.end_time"), start.Add(duration).UTC().Format(e.timestampLayout)),
+	}
+}
+
+// timeToFirstByteAttr 返回从开始处理到首次写出响应的耗时，处理器没有写出响应时返回 false
+func (x *Exchange) timeToFirstByteAttr() (slog.Attr, bool) {
+
+	if x.rw.firstWrite.IsZero() {
+		return slog.Attr{}, false
+	}
+
+	ttfb := x.rw.firstWrite.Sub(x.start)
+	if x.e.logFormat == LogFormatSemConv {
+		return slog.Float64("http.server.time_to_first_byte", ttfb.Seconds()), true
+	}
+
+	return slog.Float64("time-to-first-byte-ms", float64(ttfb)/float64(time.Millisecond)), true
+}
@@ -0,0 +1,58 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTimestamps(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.TimestampPrecision = recordrequestlog.TimestampPrecisionMillisecond
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		rw.Write([]byte("ok"))
+		time.Sleep(20 * time.Millisecond)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	record := rec.RequireRecords(t, 1)[0]
+
+	layout := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+	var times []time.Time
+	for _, key := range []string{"start-time", "end-time"} {
+		value, ok := recordrequestlogtest.Attr(record, key)
+		if !ok || !layout.MatchString(value.String()) {
+			t.Fatalf("unexpected %s %q", key, value)
+		}
+		parsed, _ := time.Parse(time.RFC3339Nano, value.String())
+		times = append(times, parsed)
+	}
+
+	if elapsed := times[1].Sub(times[0]); elapsed < 39*time.Millisecond {
+		t.Fatalf("expected end-time at least 40ms after start-time, got %v", elapsed)
+	}
+
+	ttfb, ok := recordrequestlogtest.Attr(record, "time-to-first-byte-ms")
+	if !ok {
+		t.Fatal("missing time-to-first-byte-ms")
+	}
+	duration, _ := recordrequestlogtest.Attr(record, "duration-ms")
+	if ttfb.Float64() < 20 || ttfb.Float64() >= duration.Float64() {
+		t.Fatalf("expected time to first byte between 20ms and %vms, got %v", duration.Float64(), ttfb.Float64())
+	}
+}
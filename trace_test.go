@@ -0,0 +1,180 @@
+package recordrequestlog_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTraceContextPropagation(t *testing.T) {
+
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendStdout
+	cfg.SampleRate = 0
+	cfg.TraceIDResponseHeader = "X-Trace-Id"
+
+	var traceparent string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.Header.Set("traceparent", "00-"+parentTraceID+"-00f067aa0ba902b7-01")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	// 下游收到的是中间件的 server span，trace ID 保持不变，span ID 变化
+	if !strings.HasPrefix(traceparent, "00-"+parentTraceID+"-") || strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Fatalf("unexpected downstream traceparent %q", traceparent)
+	}
+
+	if got := recorder.Header().Get("X-Trace-Id"); got != parentTraceID {
+		t.Fatalf("unexpected X-Trace-Id %q", got)
+	}
+}
+
+func TestB3Propagation(t *testing.T) {
+
+	const parentTraceID = "80f198ee56343ba864fe8b2a57d3eff7"
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendStdout
+	cfg.SampleRate = 0
+	cfg.Propagators = []string{recordrequestlog.PropagatorTraceContext, recordrequestlog.PropagatorB3Multi}
+
+	var downstream http.Header
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		downstream = req.Header.Clone()
+	})
+
+	handler, err := recordrequestlog.New(context.Background(), next, cfg, "demo-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.Header.Set("X-B3-TraceId", parentTraceID)
+	req.Header.Set("X-B3-SpanId", "e457b5a2e4d86bd1")
+	req.Header.Set("X-B3-Sampled", "1")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 从 B3 请求头提取的 trace 同时以 traceparent 和 B3 格式传给下游
+	if got := downstream.Get("X-B3-TraceId"); got != parentTraceID {
+		t.Errorf("unexpected downstream X-B3-TraceId %q", got)
+	}
+
+	if got := downstream.Get("traceparent"); !strings.HasPrefix(got, "00-"+parentTraceID+"-") {
+		t.Errorf("unexpected downstream traceparent %q", got)
+	}
+}
+
+func TestTraceSampler(t *testing.T) {
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.TraceSampler = recordrequestlog.SamplerAlwaysOff
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// 未采样的 span 不影响日志记录
+	captureRecord(t, cfg, req)
+
+	if got := req.Header.Get("traceparent"); !strings.HasSuffix(got, "-00") {
+		t.Errorf("expected downstream span to be unsampled, got traceparent %q", got)
+	}
+}
+
+func TestMetricExemplars(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	middleware, err := recordrequestlog.NewMiddleware(rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/orders", nil))
+
+	span := rec.RequireSpan(t, http.MethodGet)
+
+	m := rec.RequireMetric(t, "http.server.request.duration")
+	points := m.Data.(metricdata.Histogram[float64]).DataPoints
+	if len(points) == 0 || len(points[0].Exemplars) == 0 {
+		t.Fatal("expected exemplar on request duration histogram")
+	}
+
+	exemplar := points[0].Exemplars[0]
+	if traceID := hex.EncodeToString(exemplar.TraceID); traceID != span.SpanContext.TraceID().String() {
+		t.Fatalf("expected exemplar trace id %s, got %s", span.SpanContext.TraceID(), traceID)
+	}
+}
+
+func TestSpanBodyEvents(t *testing.T) {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	cfg.SpanBodyEvents = true
+	cfg.SpanBodyMaxSize = 16
+	// 日志不记录时仍然按 trace 采样记录 span 事件
+	cfg.SampleRate = 0
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		rw.Write([]byte("user=alice&token=abc&padding=0123456789"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/login", strings.NewReader("user=alice&password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := rec.RequireSpan(t, http.MethodPost)
+
+	events := map[string]map[string]string{}
+	for _, event := range span.Events {
+		attrs := map[string]string{}
+		for _, attr := range event.Attributes {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		events[event.Name] = attrs
+	}
+
+	request := events["http.request.body"]
+	if request == nil || request["http.request.body.truncated"] != "true" || strings.Contains(request["http.request.body.content"], "hunter2") {
+		t.Fatalf("unexpected request body event %v", request)
+	}
+	if len(request["http.request.body.content"]) > 16 {
+		t.Fatalf("request body snippet not capped: %q", request["http.request.body.content"])
+	}
+
+	response := events["http.response.body"]
+	if response == nil || response["http.response.body.content"] != "user=alice&token=REDACTED" || response["http.response.body.size"] != "39" {
+		t.Fatalf("unexpected response body event %v", response)
+	}
+
+	if len(rec.Records()) != 0 {
+		t.Fatalf("expected no records with sample_rate 0, got %d", len(rec.Records()))
+	}
+}
@@ -0,0 +1,144 @@
+package recordrequestlog
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// transport 记录出站请求的 http.RoundTripper，与中间件共用导出器和请求级设置
+type transport struct {
+	base http.RoundTripper
+	e    *RecordRequestLog
+}
+
+// NewTransport 创建记录出站请求的 http.RoundTripper：为每个请求创建 client span、注入 trace 上下文，
+// 并在收到响应后按配置写入日志。base 为 nil 时使用 http.DefaultTransport
+func NewTransport(base http.RoundTripper, config *Config) (http.RoundTripper, error) {
+
+	e, err := NewRecorder(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Transport(base), nil
+}
+
+// Transport 返回与当前中间件共用导出器的 http.RoundTripper
+func (e *RecordRequestLog) Transport(base http.RoundTripper) http.RoundTripper {
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{base: base, e: e}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	e := t.e
+	start := time.Now()
+
+	rules := e.rules.Load()
+
+	ctx, span := e.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(rules.query.url(req.URL).String()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	// RoundTripper 不能修改调用方的请求，注入 trace 上下文前先复制
+	req = req.Clone(ctx)
+	e.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	settings := rules.settings(req)
+	sampled := e.sampled(settings)
+
+	var body *capturedBody
+	if sampled && settings.shouldCaptureBody(req) {
+		var err error
+		if body, err = e.captureBody(req, settings); err != nil {
+			e.logError("read outbound request body", err)
+		}
+		defer body.release()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+
+	var status int
+	if err == nil {
+		status = resp.StatusCode
+	}
+
+	// 请求失败时没有状态码，由错误类型决定 error.type
+	errorType := classifyError(req.Context(), status, err, false)
+	if errorType != "" {
+		span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		// 客户端 span 的 4xx 也视为错误
+		if status >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+
+	metricAttrs := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(req.Method)}
+	if err == nil {
+		metricAttrs = append(metricAttrs, semconv.HTTPResponseStatusCode(status))
+	}
+	if errorType != "" {
+		metricAttrs = append(metricAttrs, semconv.ErrorTypeKey.String(errorType))
+	}
+	e.clientRequestDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(metricAttrs...))
+
+	// 请求失败时总是记录
+	if !sampled || (err == nil && !settings.shouldLog(status, duration)) {
+		return resp, err
+	}
+
+	record := e.completedRecord(req, body, settings, start, status, duration, true)
+	record.Attrs = append(record.Attrs, slog.String("direction", "outbound"))
+	if errorType != "" {
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("error-type", "error.type"), errorType))
+	}
+
+	if err != nil {
+		record.setLevel(slog.LevelError)
+		record.Attrs = append(record.Attrs, slog.String(e.attrKey("error", "exception.message"), err.Error()))
+	} else {
+		if resp.ContentLength >= 0 {
+			record.Attrs = append(record.Attrs, slog.Int64(e.attrKey("response-size", "http.response.body.size"), resp.ContentLength))
+		}
+		if attr, ok := e.responseHeadersAttr(ctx, resp.Header); ok {
+			record.Attrs = append(record.Attrs, attr)
+		}
+	}
+
+	info := ResponseInfo{StatusCode: status, Size: -1, Duration: duration, Err: err}
+	if resp != nil {
+		info.Header = resp.Header
+		info.Size = resp.ContentLength
+	}
+	e.enrich(&record, req, info)
+
+	e.emit(ctx, record)
+
+	return resp, err
+}
@@ -0,0 +1,94 @@
+package recordrequestlog_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recordrequestlog"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+
+	var traceparent, body string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+
+	rt, err := recordrequestlog.NewTransport(nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/orders?token=secret", strings.NewReader(`{"id":1}`))
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("expected caller's request to be left unmodified")
+	}
+	if traceparent == "" {
+		t.Error("expected traceparent to be injected")
+	}
+	if body != `{"id":1}` {
+		t.Errorf("expected body to be forwarded, got %q", body)
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	record := records[0]
+	if record["direction"] != "outbound" || record["status"] != float64(http.StatusCreated) || record["msg"] != `{"id":1}` {
+		t.Errorf("unexpected record %v", record)
+	}
+	if want := server.URL + "/orders?token=REDACTED"; record["url"] != want {
+		t.Errorf("expected url %q, got %v", want, record["url"])
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestTransportError(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "requests.log")
+	cfg := recordrequestlog.CreateConfig()
+	cfg.Backend = recordrequestlog.BackendFile
+	cfg.FilePath = path
+	cfg.LogMode = recordrequestlog.LogModeErrors
+
+	rt, err := recordrequestlog.NewTransport(failingTransport{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://upstream.invalid/", nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatal("expected error")
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 1 || records[0]["level"] != "error" || records[0]["error"] != "connection refused" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
@@ -0,0 +1,170 @@
+package recordrequestlog
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// userAgent 从 User-Agent 解析出的浏览器、操作系统和设备类型，无法识别的字段为空
+type userAgent struct {
+	browser        string
+	browserVersion string
+	os             string
+	device         string
+	bot            bool
+}
+
+// 设备类型
+const (
+	deviceDesktop = "desktop"
+	deviceMobile  = "mobile"
+	deviceTablet  = "tablet"
+	deviceBot     = "bot"
+)
+
+// uaRule 按顺序匹配的规则，version 为 pattern 中版本号的分组序号，0 表示不记录版本
+type uaRule struct {
+	name    string
+	pattern *regexp.Regexp
+	version int
+}
+
+// uaBotRules 常见的爬虫、监控和命令行客户端，匹配时不再识别浏览器
+var uaBotRules = []uaRule{
+	{"Googlebot", regexp.MustCompile(`Googlebot(?:-\w+)?/(\d+(?:\.\d+)?)`), 1},
+	{"Bingbot", regexp.MustCompile(`(?i)bingbot/(\d+(?:\.\d+)?)`), 1},
+	{"Baiduspider", regexp.MustCompile(`Baiduspider(?:-\w+)?/(\d+(?:\.\d+)?)`), 1},
+	{"YandexBot", regexp.MustCompile(`YandexBot/(\d+(?:\.\d+)?)`), 1},
+	{"DuckDuckBot", regexp.MustCompile(`DuckDuckBot/(\d+(?:\.\d+)?)`), 1},
+	{"Applebot", regexp.MustCompile(`Applebot/(\d+(?:\.\d+)?)`), 1},
+	{"facebookexternalhit", regexp.MustCompile(`facebookexternalhit/(\d+(?:\.\d+)?)`), 1},
+	{"curl", regexp.MustCompile(`^curl/(\d+(?:\.\d+)*)`), 1},
+	{"Wget", regexp.MustCompile(`^Wget/(\d+(?:\.\d+)*)`), 1},
+	{"python-requests", regexp.MustCompile(`python-requests/(\d+(?:\.\d+)*)`), 1},
+	{"Go-http-client", regexp.MustCompile(`^Go-http-client/(\d+(?:\.\d+)?)`), 1},
+	{"kube-probe", regexp.MustCompile(`^kube-probe/(\d+(?:\.\d+)?)`), 1},
+	{"Prometheus", regexp.MustCompile(`^Prometheus/(\d+(?:\.\d+)*)`), 1},
+	{"bot", regexp.MustCompile(`(?i)bot\b|crawler|spider|slurp|headless`), 0},
+}
+
+// uaBrowserRules 浏览器规则，基于 Chromium 的浏览器需要排在 Chrome 之前，Chrome 需要排在 Safari 之前
+var uaBrowserRules = []uaRule{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+(?:\.\d+)?)`), 1},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+(?:\.\d+)?)`), 1},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+(?:\.\d+)?)`), 1},
+	{"WeChat", regexp.MustCompile(`MicroMessenger/(\d+(?:\.\d+)?)`), 1},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+(?:\.\d+)?)`), 1},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+(?:\.\d+)?)`), 1},
+	{"Safari", regexp.MustCompile(`Version/(\d+(?:\.\d+)?).*Safari/`), 1},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)(\d+(?:\.\d+)?)`), 1},
+}
+
+// uaOSRules 操作系统规则，iOS 和 Android 需要排在 macOS 和 Linux 之前
+var uaOSRules = []uaRule{
+	{"Windows", regexp.MustCompile(`Windows NT`), 0},
+	{"iOS", regexp.MustCompile(`iPhone|iPad|iPod`), 0},
+	{"Android", regexp.MustCompile(`Android`), 0},
+	{"ChromeOS", regexp.MustCompile(`CrOS`), 0},
+	{"macOS", regexp.MustCompile(`Mac OS X|Macintosh`), 0},
+	{"Linux", regexp.MustCompile(`Linux`), 0},
+}
+
+// matchUARules 返回第一个匹配的规则名称和版本
+func matchUARules(rules []uaRule, ua string) (string, string, bool) {
+
+	for _, rule := range rules {
+		m := rule.pattern.FindStringSubmatch(ua)
+		if m == nil {
+			continue
+		}
+
+		if rule.version > 0 && rule.version < len(m) {
+			return rule.name, m[rule.version], true
+		}
+		return rule.name, "", true
+	}
+
+	return "", "", false
+}
+
+// parseUserAgent 按内置规则解析 User-Agent
+func parseUserAgent(ua string) userAgent {
+
+	var parsed userAgent
+	if ua == "" {
+		return parsed
+	}
+
+	if name, version, ok := matchUARules(uaBotRules, ua); ok {
+		return userAgent{browser: name, browserVersion: version, device: deviceBot, bot: true}
+	}
+
+	parsed.browser, parsed.browserVersion, _ = matchUARules(uaBrowserRules, ua)
+	parsed.os, _, _ = matchUARules(uaOSRules, ua)
+
+	switch {
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet") || (parsed.os == "Android" && !strings.Contains(ua, "Mobile")):
+		parsed.device = deviceTablet
+	case strings.Contains(ua, "Mobi") || strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod"):
+		parsed.device = deviceMobile
+	case parsed.os != "":
+		parsed.device = deviceDesktop
+	}
+
+	return parsed
+}
+
+// 解析结果的缓存，同一服务收到的 User-Agent 通常只有少数几种；超过上限时清空
+const maxUserAgentCache = 1024
+
+var userAgentCache = struct {
+	sync.Mutex
+	entries map[string]userAgent
+}{entries: map[string]userAgent{}}
+
+// cachedUserAgent 返回缓存的解析结果
+func cachedUserAgent(ua string) userAgent {
+
+	userAgentCache.Lock()
+	parsed, ok := userAgentCache.entries[ua]
+	userAgentCache.Unlock()
+	if ok {
+		return parsed
+	}
+
+	parsed = parseUserAgent(ua)
+
+	userAgentCache.Lock()
+	if len(userAgentCache.entries) >= maxUserAgentCache {
+		clear(userAgentCache.entries)
+	}
+	userAgentCache.entries[ua] = parsed
+	userAgentCache.Unlock()
+
+	return parsed
+}
+
+// attrs 返回解析出的字段，未识别的字段不记录
+func (u userAgent) attrs(e *RecordRequestLog) []slog.Attr {
+
+	var attrs []slog.Attr
+
+	for _, field := range []struct{ legacy, semconv, value string }{
+		{"ua-browser", "user_agent.name", u.browser},
+		{"ua-browser-version", "user_agent.version", u.browserVersion},
+		{"ua-os", "user_agent.os.name", u.os},
+		{"ua-device", "user_agent.device.type", u.device},
+	} {
+		if field.value != "" {
+			attrs = append(attrs, slog.String(e.attrKey(field.legacy, field.semconv), field.value))
+		}
+	}
+
+	if u.bot {
+		attrs = append(attrs, slog.Bool(e.attrKey("ua-bot", "user_agent.bot"), true))
+	}
+
+	return attrs
+}
@@ -0,0 +1,43 @@
+package recordrequestlog
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+
+	tests := map[string]userAgent{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36": {
+			browser: "Chrome", browserVersion: "126.0", os: "Windows", device: deviceDesktop,
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.2592.87": {
+			browser: "Edge", browserVersion: "126.0", os: "Windows", device: deviceDesktop,
+		},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1": {
+			browser: "Safari", browserVersion: "17.5", os: "iOS", device: deviceMobile,
+		},
+		"Mozilla/5.0 (iPad; CPU OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/126.0.6478.54 Mobile/15E148 Safari/604.1": {
+			browser: "Chrome", browserVersion: "126.0", os: "iOS", device: deviceTablet,
+		},
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36": {
+			browser: "Chrome", browserVersion: "126.0", os: "Android", device: deviceMobile,
+		},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.5; rv:127.0) Gecko/20100101 Firefox/127.0": {
+			browser: "Firefox", browserVersion: "127.0", os: "macOS", device: deviceDesktop,
+		},
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": {
+			browser: "Googlebot", browserVersion: "2.1", device: deviceBot, bot: true,
+		},
+		"curl/8.6.0": {
+			browser: "curl", browserVersion: "8.6.0", device: deviceBot, bot: true,
+		},
+		"Mozilla/5.0 (compatible; SomeCrawler/1.0)": {
+			browser: "bot", device: deviceBot, bot: true,
+		},
+		"custom-client": {},
+	}
+
+	for ua, want := range tests {
+		if got := parseUserAgent(ua); got != want {
+			t.Errorf("parseUserAgent(%q) = %+v, want %+v", ua, got, want)
+		}
+	}
+}
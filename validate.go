@@ -0,0 +1,388 @@
+package recordrequestlog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validateConfig 检查配置并返回全部问题，New 在创建导出器之前调用，使 Traefik 拒绝加载错误的配置
+func validateConfig(config *Config) error {
+
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if config.Endpoint != "" {
+		for _, endpoint := range splitEndpoints(config.Endpoint) {
+			check(validateEndpoint(endpoint))
+		}
+	}
+
+	validateBackend(config, check)
+
+	names := make(map[string]bool, len(config.Sinks))
+	for i, sink := range config.Sinks {
+		// 名称用于指标属性和缓冲子目录，不能重复
+		if sink.Name != "" {
+			if names[sink.Name] {
+				check(fmt.Errorf("duplicate sinks[%d].name %q", i, sink.Name))
+			}
+			names[sink.Name] = true
+		}
+		for _, endpoint := range splitEndpoints(sink.Endpoint) {
+			if err := validateEndpoint(endpoint); err != nil {
+				check(fmt.Errorf("sinks[%d]: %w", i, err))
+			}
+		}
+		validateBackend(sink.sinkConfig(config), func(err error) {
+			if err != nil {
+				check(fmt.Errorf("sinks[%d]: %w", i, err))
+			}
+		})
+		if sink.MinLevel != "" {
+			if _, err := parseLevel(fmt.Sprintf("sinks[%d].min_level", i), sink.MinLevel); err != nil {
+				check(err)
+			}
+		}
+		if sink.SampleRate != nil && (*sink.SampleRate < 0 || *sink.SampleRate > 1) {
+			check(fmt.Errorf("invalid sinks[%d].sample_rate %v: must be between 0 and 1", i, *sink.SampleRate))
+		}
+	}
+
+	apps := make([]string, 0, len(config.AppIDSampleRates))
+	for app := range config.AppIDSampleRates {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	for _, app := range apps {
+		if rate := config.AppIDSampleRates[app]; rate < 0 || rate > 1 {
+			check(fmt.Errorf("invalid app_id_sample_rates[%s] %v: must be between 0 and 1", app, rate))
+		}
+	}
+
+	switch config.LogFormat {
+	case "", LogFormatLegacy, LogFormatSemConv:
+	default:
+		check(fmt.Errorf("invalid log_format %q", config.LogFormat))
+	}
+
+	if _, err := parseSizeBuckets(config.SizeBuckets); err != nil {
+		check(err)
+	}
+
+	check(validateMetricViews(config.MetricViews))
+
+	if _, err := parsePreflightMode(config.PreflightMode); err != nil {
+		check(err)
+	}
+
+	if _, err := parseTimestampPrecision(config.TimestampPrecision); err != nil {
+		check(err)
+	}
+
+	switch config.Compression {
+	case "", CompressionNone, CompressionGzip:
+	default:
+		check(fmt.Errorf("invalid compression %q", config.Compression))
+	}
+
+	switch config.MetricsBackend {
+	case "", MetricsBackendOTLP, MetricsBackendPrometheus:
+	default:
+		check(fmt.Errorf("invalid metrics_backend %q", config.MetricsBackend))
+	}
+
+	if config.PrometheusAddress != "" {
+		if config.MetricsBackend != MetricsBackendPrometheus {
+			check(errors.New("prometheus_address requires metrics_backend prometheus"))
+		} else if _, _, err := net.SplitHostPort(config.PrometheusAddress); err != nil {
+			check(fmt.Errorf("invalid prometheus_address %q: %w", config.PrometheusAddress, err))
+		}
+	}
+
+	switch config.BodyMode {
+	case "", BodyModeContent, BodyModeHash:
+	default:
+		check(fmt.Errorf("invalid body_mode %q", config.BodyMode))
+	}
+
+	if !validTimeFormat(config.ConsoleTimeFormat) {
+		check(fmt.Errorf("invalid console_time_format %q", config.ConsoleTimeFormat))
+	}
+
+	if config.FileFallback && config.FilePath == "" {
+		check(errors.New("file_path is required when file_fallback is enabled"))
+	}
+
+	if config.ErrorStatus != 0 && (config.ErrorStatus < 100 || config.ErrorStatus > 599) {
+		check(fmt.Errorf("invalid error_status %d", config.ErrorStatus))
+	}
+
+	if config.DebugKey != "" && config.DebugHeader == "" {
+		check(errors.New("debug_header is required when debug_key is set"))
+	}
+
+	if config.AuditMode && config.AuditKey == "" {
+		check(errors.New("audit_key is required when audit_mode is enabled"))
+	}
+
+	switch config.AsyncDropPolicy {
+	case "", DropNewest, DropOldest:
+	default:
+		check(fmt.Errorf("invalid async_drop_policy %q", config.AsyncDropPolicy))
+	}
+
+	durations := []struct{ name, value string }{
+		{"trace_batch_timeout", config.TraceBatchTimeout},
+		{"metric_interval", config.MetricInterval},
+		{"log_export_timeout", config.LogExportTimeout},
+		{"log_batch_interval", config.LogBatchInterval},
+		{"export_timeout", config.ExportTimeout},
+		{"shutdown_timeout", config.ShutdownTimeout},
+		{"jwt_jwks_refresh_interval", config.JWTJWKSRefreshInterval},
+		{"spool_retry_interval", config.SpoolRetryInterval},
+		{"spool_max_retry_interval", config.SpoolMaxRetryInterval},
+		{"circuit_breaker_cooloff", config.CircuitBreakerCooloff},
+		{"failback_interval", config.FailbackInterval},
+		{"file_rotate_interval", config.FileRotateInterval},
+		{"file_max_age", config.FileMaxAge},
+		{"geoip_timeout", config.GeoIPTimeout},
+		{"debug_max_age", config.DebugMaxAge},
+		{"request_record_delay", config.RequestRecordDelay},
+		{"hung_threshold", config.HungThreshold},
+		{"dedup_window", config.DedupWindow},
+		{"kubernetes_cache_ttl", config.KubernetesCacheTTL},
+		{"retry_link_window", config.RetryLinkWindow},
+		{"shadow_timeout", config.ShadowTimeout},
+	}
+	for _, d := range durations {
+		_, err := parseDuration(d.name, d.value, 0)
+		check(err)
+	}
+
+	counts := []struct {
+		name  string
+		value int64
+	}{
+		{"trace_max_batch_size", int64(config.TraceMaxBatchSize)},
+		{"log_queue_size", int64(config.LogQueueSize)},
+		{"log_max_batch_size", int64(config.LogMaxBatchSize)},
+		{"max_body_size", int64(config.MaxBodySize)},
+		{"max_binary_body_size", int64(config.MaxBinaryBodySize)},
+		{"span_body_max_size", int64(config.SpanBodyMaxSize)},
+		{"body_max_depth", int64(config.BodyMaxDepth)},
+		{"async_queue_size", int64(config.AsyncQueueSize)},
+		{"async_workers", int64(config.AsyncWorkers)},
+		{"export_max_pending", int64(config.ExportMaxPending)},
+		{"spool_max_size", config.SpoolMaxSize},
+		{"circuit_breaker_threshold", int64(config.CircuitBreakerThreshold)},
+		{"failover_threshold", int64(config.FailoverThreshold)},
+		{"file_max_size", config.FileMaxSize},
+		{"file_max_backups", int64(config.FileMaxBackups)},
+		{"geoip_cache_size", int64(config.GeoIPCacheSize)},
+		{"hung_stack_size", int64(config.HungStackSize)},
+		{"max_attribute_size", int64(config.MaxAttributeSize)},
+		{"max_message_size", int64(config.MaxMessageSize)},
+		{"max_record_size", int64(config.MaxRecordSize)},
+		{"app_id_metrics_limit", int64(config.AppIDMetricsLimit)},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			check(fmt.Errorf("invalid %s %d: must not be negative", c.name, c.value))
+		}
+	}
+
+	// 以下检查复用各功能的构造函数，只收集错误
+	defaults, err := newRouteSettings(config)
+	check(err)
+
+	// 顶层设置有误时路由仍然单独检查
+	if defaults == nil {
+		defaults = &routeSettings{}
+	}
+
+	for i, r := range config.Routes {
+		_, err := newRoute(i, r, defaults)
+		check(err)
+	}
+
+	for i, t := range config.PathTemplates {
+		if _, err := regexp.Compile(t.Pattern); err != nil {
+			check(fmt.Errorf("invalid path_templates[%d].pattern %q: %w", i, t.Pattern, err))
+		}
+	}
+
+	if _, err := newXMLRedactor(config.RedactXMLPaths); err != nil {
+		check(err)
+	}
+
+	if _, err := newFieldEncryptor(config); err != nil {
+		check(err)
+	}
+
+	if _, err := newRetentionClasses(config.RetentionClasses); err != nil {
+		check(err)
+	}
+
+	if _, err := newShadowMirror(config); err != nil {
+		check(err)
+	}
+
+	if _, err := newStatusLevels(config.StatusLevels); err != nil {
+		check(err)
+	}
+
+	if config.MinLevel != "" {
+		if _, err := parseLevel("min_level", config.MinLevel); err != nil {
+			check(err)
+		}
+	}
+
+	for i, pattern := range config.GraphQLPaths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			check(fmt.Errorf("invalid graphql_paths[%d] %q: %w", i, pattern, err))
+		}
+	}
+
+	if config.JWTJWKSURL != "" {
+		if u, err := url.Parse(config.JWTJWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			check(fmt.Errorf("invalid jwt_jwks_url %q: must be an http or https URL", config.JWTJWKSURL))
+		}
+	}
+
+	_, err = newSampler(config.TraceSampler, config.TraceSampleRatio)
+	check(err)
+
+	_, err = newPropagator(config.Propagators)
+	check(err)
+
+	_, err = newRetryPolicy(config)
+	check(err)
+
+	_, err = newExportProxy(config)
+	check(err)
+
+	_, err = newClientIPResolver(config.TrustedProxies, config.AnonymizeClientIP)
+	check(err)
+
+	_, err = newTenantResolver(config, nil)
+	check(err)
+
+	if config.AdminPathPrefix != "" {
+		if !strings.HasPrefix(config.AdminPathPrefix, "/") {
+			check(fmt.Errorf("invalid admin_path_prefix %q: must start with /", config.AdminPathPrefix))
+		}
+		if config.AdminToken == "" {
+			check(errors.New("admin_token is required when admin_path_prefix is set"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateBackend 检查后端类型和后端需要的参数
+func validateBackend(config *Config, check func(err error)) {
+
+	switch config.Backend {
+	case "", BackendOTLPGRPC, BackendStdout, BackendStderr:
+	case BackendOTLPHTTP:
+		// OTLP HTTP 导出器只重试固定的状态码，配置的 HTTP 状态码不会生效
+		for _, value := range config.RetryStatusCodes {
+			if _, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				check(fmt.Errorf("retry_status_codes %q is not supported by the otlp-http backend", value))
+			}
+		}
+	case BackendOpenObserve:
+		if config.Endpoint == "" {
+			check(errors.New("endpoint is required for the openobserve backend"))
+		}
+		if config.Organization == "" {
+			check(errors.New("organization is required for the openobserve backend"))
+		}
+		if config.StreamName == "" {
+			check(errors.New("stream_name is required for the openobserve backend"))
+		}
+	case BackendFile:
+		if config.FilePath == "" {
+			check(errors.New("file_path is required for the file backend"))
+		}
+	case BackendKafka:
+		if len(config.KafkaBrokers) == 0 {
+			check(errors.New("kafka_brokers is required for the kafka backend"))
+		}
+		if config.KafkaTopic == "" {
+			check(errors.New("kafka_topic is required for the kafka backend"))
+		}
+		switch config.KafkaKey {
+		case "", KafkaKeyRequestID, KafkaKeyTenant, KafkaKeyNone:
+		default:
+			check(fmt.Errorf("invalid kafka_key %q", config.KafkaKey))
+		}
+		if config.KafkaSASLMechanism != "" {
+			if _, err := newKafkaSASL(config.KafkaSASLMechanism, config.KafkaUsername, config.KafkaPassword); err != nil {
+				check(err)
+			} else if config.KafkaUsername == "" {
+				check(errors.New("kafka_username is required when kafka_sasl_mechanism is set"))
+			}
+		}
+	case BackendLoki:
+		if config.Endpoint == "" {
+			check(errors.New("endpoint is required for the loki backend"))
+		}
+		check(validateLokiLabels(config.LokiLabels))
+	case BackendElasticsearch:
+		if config.Endpoint == "" {
+			check(errors.New("endpoint is required for the elasticsearch backend"))
+		}
+		if config.ElasticsearchAPIKey != "" && config.ElasticsearchUsername != "" {
+			check(errors.New("elasticsearch_api_key and elasticsearch_username are mutually exclusive"))
+		}
+		// 日期格式之外的部分需要是小写
+		if index := elasticsearchDate.ReplaceAllString(config.ElasticsearchIndex, ""); strings.ToLower(index) != index {
+			check(fmt.Errorf("invalid elasticsearch_index %q: must be lowercase", config.ElasticsearchIndex))
+		}
+	case BackendSyslog:
+		if config.SyslogAddress == "" {
+			check(errors.New("syslog_address is required for the syslog backend"))
+		} else if _, _, err := parseSyslogAddress(config.SyslogAddress); err != nil {
+			check(err)
+		}
+		if _, ok := syslogFacilities[strings.ToLower(config.SyslogFacility)]; !ok && config.SyslogFacility != "" {
+			check(fmt.Errorf("invalid syslog_facility %q", config.SyslogFacility))
+		}
+		if strings.ContainsAny(config.SyslogSDID, ` =]"`) || len(config.SyslogSDID) > 32 {
+			check(fmt.Errorf("invalid syslog_sd_id %q", config.SyslogSDID))
+		}
+	default:
+		check(fmt.Errorf("invalid backend %q", config.Backend))
+	}
+}
+
+// validateEndpoint 导出端地址需要是带有主机名的 http 或 https URL
+func validateEndpoint(endpoint string) error {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid endpoint %q: scheme must be http or https", endpoint)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: missing host", endpoint)
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package recordrequestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 展开 XML 时最多记录的叶子元素个数
+const maxXMLFields = 100
+
+// xmlRedactor 按路径隐去 XML 元素的内容。路径由以 / 分隔的元素本地名称（不含命名空间前缀）组成，
+// * 匹配任意一个元素，// 匹配任意层级，例如 "/Envelope/Body/Login/Password"、"//Password"
+type xmlRedactor struct {
+	paths [][]string
+}
+
+func newXMLRedactor(paths []string) (*xmlRedactor, error) {
+
+	steps, err := parsePaths("redact_xml_paths", paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xmlRedactor{paths: steps}, nil
+}
+
+// parsePaths 将路径拆分为元素名称，name 为配置项名称
+func parsePaths(name string, paths []string) ([][]string, error) {
+
+	var steps [][]string
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") || strings.Contains(p, "///") {
+			return nil, fmt.Errorf("invalid %s %q", name, p)
+		}
+		// "//" 拆分后为空的一段，表示任意层级
+		steps = append(steps, strings.Split(p[1:], "/"))
+	}
+
+	return steps, nil
+}
+
+// redacted 判断当前元素是否需要脱敏，stack 为从根元素到当前元素的本地名称
+func (r *xmlRedactor) redacted(stack []string) bool {
+
+	for _, steps := range r.paths {
+		if matchXMLPath(steps, stack) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchXMLPath(steps, stack []string) bool {
+
+	if len(steps) == 0 {
+		return len(stack) == 0
+	}
+
+	if steps[0] == "" {
+		for i := 0; i <= len(stack); i++ {
+			if matchXMLPath(steps[1:], stack[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(stack) == 0 || (steps[0] != "*" && steps[0] != stack[0]) {
+		return false
+	}
+
+	return matchXMLPath(steps[1:], stack[1:])
+}
+
+// soapInfo SOAP 请求的操作信息
+type soapInfo struct {
+	// SOAP 1.1 的 SOAPAction 请求头或 SOAP 1.2 Content-Type 的 action 参数
+	action string
+	// Body 的第一个子元素的本地名称
+	operation string
+}
+
+// isXML 判断内容类型是否为 XML
+func isXML(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// soapAction 返回请求的 SOAP action
+func soapAction(req *http.Request) string {
+
+	if action := req.Header.Get("SOAPAction"); action != "" {
+		return strings.Trim(action, `"`)
+	}
+
+	_, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return params["action"]
+}
+
+// captureXML 重新编码记录的 XML 请求体：去掉注释和元素之间的空白，隐去匹配 redact_xml_paths 或
+// redact_query_params 的元素的内容；开启 flatten_xml 时记录叶子元素的值代替请求体内容。
+// 被截断或格式有误的请求体只记录能够解析的部分，不会记录未经脱敏的原始内容
+func (e *RecordRequestLog) captureXML(ctx context.Context, req *http.Request, body *capturedBody) {
+
+	content, fields, soap := e.redactXML(ctx, body.content, soapAction(req))
+
+	if soap.action != "" || soap.operation != "" {
+		body.soap = soap
+	}
+
+	if e.flattenXML {
+		body.content = ""
+		body.xmlFields = fields
+		return
+	}
+
+	body.content = content
+}
+
+// redactXML 返回重新编码并脱敏的 XML、叶子元素的值和 SOAP 操作信息
+func (e *RecordRequestLog) redactXML(ctx context.Context, content, action string) (string, []slog.Attr, *soapInfo) {
+
+	rules := e.rules.Load()
+
+	var (
+		out     bytes.Buffer
+		stack   []string
+		text    bytes.Buffer
+		fields  []slog.Attr
+		skip    int
+		redacts int
+		soap    = &soapInfo{action: action}
+	)
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+
+			// 第一个子元素之前的文本（空白）不属于叶子元素
+			text.Reset()
+			stack = append(stack, t.Name.Local)
+			if len(stack) == 3 && soap.operation == "" && stack[0] == "Envelope" && stack[1] == "Body" {
+				soap.operation = t.Name.Local
+			}
+
+			writeXMLStart(&out, t)
+			if rules.xml.redacted(stack) || rules.query.redacted(t.Name.Local) {
+				out.WriteString(redactedValue)
+				redacts++
+				skip = 1
+				fields = appendXMLField(fields, stack, redactedValue)
+			}
+		case xml.EndElement:
+			if skip > 1 {
+				skip--
+				continue
+			}
+
+			if skip == 0 && text.Len() > 0 && len(stack) > 0 {
+				fields = appendXMLField(fields, stack, strings.TrimSpace(text.String()))
+			}
+			text.Reset()
+			skip = 0
+
+			out.WriteString("</" + xmlName(t.Name) + ">")
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if skip > 0 || len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+			text.Write(t)
+			xml.EscapeText(&out, t)
+		case xml.ProcInst:
+			out.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			out.WriteString("<!" + string(t) + ">")
+		}
+	}
+
+	if redacts > 0 {
+		e.redactions.Add(ctx, int64(redacts), metric.WithAttributes(attribute.String("source", "xml")))
+	}
+
+	return out.String(), fields, soap
+}
+
+// appendXMLField 追加叶子元素的值，属性名为以点号连接的元素路径
+func appendXMLField(fields []slog.Attr, stack []string, value string) []slog.Attr {
+
+	if len(fields) >= maxXMLFields {
+		return fields
+	}
+
+	return append(fields, slog.String(strings.Join(stack, "."), value))
+}
+
+func writeXMLStart(out *bytes.Buffer, t xml.StartElement) {
+
+	out.WriteString("<" + xmlName(t.Name))
+	for _, attr := range t.Attr {
+		out.WriteString(" " + xmlName(attr.Name) + `="`)
+		xml.EscapeText(out, []byte(attr.Value))
+		out.WriteString(`"`)
+	}
+	out.WriteString(">")
+}
+
+// xmlName 返回带命名空间前缀的名称，RawToken 不解析命名空间，Space 即为前缀
+func xmlName(name xml.Name) string {
+
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+// attrs 返回 SOAP 操作的日志属性
+func (s *soapInfo) attrs(e *RecordRequestLog) []slog.Attr {
+
+	var attrs []slog.Attr
+	if s.action != "" {
+		attrs = append(attrs, slog.String(e.attrKey("soap-action", "soap.action"), s.action))
+	}
+	if s.operation != "" {
+		attrs = append(attrs, slog.String(e.attrKey("soap-operation", "soap.operation"), s.operation))
+	}
+
+	return attrs
+}
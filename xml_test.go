@@ -0,0 +1,90 @@
+package recordrequestlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"recordrequestlog"
+	"recordrequestlog/recordrequestlogtest"
+	"strings"
+	"testing"
+)
+
+const soapEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <!-- 登录 -->
+  <soap:Body>
+    <Login xmlns="urn:auth">
+      <User>alice</User>
+      <Pin>1234</Pin>
+      <Credentials><Secret>s3cr3t</Secret></Credentials>
+    </Login>
+  </soap:Body>
+</soap:Envelope>`
+
+func serveSOAP(t *testing.T, mutate func(cfg *recordrequestlog.Config)) recordrequestlog.Record {
+
+	rec := recordrequestlogtest.New()
+
+	cfg := recordrequestlog.CreateConfig()
+	mutate(cfg)
+
+	middleware, err := recordrequestlog.NewMiddleware(recordrequestlog.WithConfig(cfg), rec.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/AuthService", strings.NewReader(soapEnvelope))
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", `"urn:auth/Login"`)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	return rec.RequireRecords(t, 1)[0]
+}
+
+func TestXMLBody(t *testing.T) {
+
+	record := serveSOAP(t, func(cfg *recordrequestlog.Config) {
+		cfg.RedactXMLPaths = []string{"/Envelope/Body/*/Pin"}
+	})
+
+	want := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><Login xmlns="urn:auth">` +
+		`<User>alice</User><Pin>REDACTED</Pin><Credentials><Secret>REDACTED</Secret></Credentials>` +
+		`</Login></soap:Body></soap:Envelope>`
+	if record.Message != want {
+		t.Fatalf("unexpected body\n got: %s\nwant: %s", record.Message, want)
+	}
+
+	for key, want := range map[string]string{
+		"soap-action":    "urn:auth/Login",
+		"soap-operation": "Login",
+	} {
+		if v, _ := recordrequestlogtest.Attr(record, key); v.String() != want {
+			t.Errorf("%s: expected %q, got %q", key, want, v)
+		}
+	}
+}
+
+func TestXMLBodyFlatten(t *testing.T) {
+
+	record := serveSOAP(t, func(cfg *recordrequestlog.Config) {
+		cfg.RedactXMLPaths = []string{"//Pin"}
+		cfg.FlattenXML = true
+	})
+
+	if record.Message != "" {
+		t.Fatalf("expected no body content, got %q", record.Message)
+	}
+
+	for key, want := range map[string]string{
+		"xml.Envelope.Body.Login.User":               "alice",
+		"xml.Envelope.Body.Login.Pin":                "REDACTED",
+		"xml.Envelope.Body.Login.Credentials.Secret": "REDACTED",
+	} {
+		if v, _ := recordrequestlogtest.Attr(record, key); v.String() != want {
+			t.Errorf("%s: expected %q, got %q", key, want, v)
+		}
+	}
+}